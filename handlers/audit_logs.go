@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// auditedDB returns the database for writes made by a request: the audit callbacks record the
+// logged in user and client IP of the request with every created, updated or deleted row.
+// Transactions started from it carry the actor too.
+func auditedDB(c *fiber.Ctx) *gorm.DB {
+	actor := helpers.AuditActor{UserID: currentUserID(c), IPAddress: c.IP()}
+	return config.DB.WithContext(helpers.WithAuditActor(c.UserContext(), actor))
+}
+
+// currentUserID returns the logged in user, nil outside an authenticated request
+func currentUserID(c *fiber.Ctx) *uuid.UUID {
+	userID, _ := c.Locals("userID").(string)
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// GetAuditLogs godoc
+// @Summary Get audit logs
+// @Description Retrieve the audit trail of created, updated and deleted records, newest first. Updates only list the changed columns in before and after. Requires audit_logs:read.
+// @Tags Audit Logs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param entity_type query string false "Filter by table name, e.g. books or sales_transactions"
+// @Param entity_id query string false "Filter by record ID"
+// @Param user_id query string false "Filter by the user who made the change"
+// @Param action query string false "Filter by action: create, update or delete"
+// @Param start_date query string false "Filter by change date from (YYYY-MM-DD)"
+// @Param end_date query string false "Filter by change date to (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{} "List of audit logs with pagination"
+// @Failure 400 {object} map[string]interface{} "Invalid filter"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Forbidden"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/audit-logs [get]
+func GetAuditLogs(c *fiber.Ctx) error {
+	var logs []models.AuditLog
+
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("created_at DESC")
+	queryCount := config.DB.Model(&models.AuditLog{})
+
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+		queryCount = queryCount.Where("entity_type = ?", entityType)
+	}
+
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+		queryCount = queryCount.Where("entity_id = ?", entityID)
+	}
+
+	if userID := c.Query("user_id"); userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user_id",
+			})
+		}
+		query = query.Where("user_id = ?", userID)
+		queryCount = queryCount.Where("user_id = ?", userID)
+	}
+
+	if action := c.Query("action"); action != "" {
+		switch action {
+		case models.AuditActionCreate, models.AuditActionUpdate, models.AuditActionDelete:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "action must be create, update or delete",
+			})
+		}
+		query = query.Where("action = ?", action)
+		queryCount = queryCount.Where("action = ?", action)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+		queryCount = queryCount.Where("created_at >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at < (?::date + 1)", endDate)
+		queryCount = queryCount.Where("created_at < (?::date + 1)", endDate)
+	}
+
+	if err := query.
+		Preload("User").
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&logs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit logs",
+		})
+	}
+
+	response, err := helpers.CreatePaginationResponse(queryCount, logs, "audit_logs", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
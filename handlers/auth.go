@@ -2,14 +2,15 @@ package handlers
 
 import (
     "pustaka-backend/config"
+    "pustaka-backend/helpers"
     "pustaka-backend/models"
-    "os"
-    "strconv"
     "time"
 
     "github.com/gofiber/fiber/v2"
     "github.com/golang-jwt/jwt/v5"
+    "github.com/google/uuid"
     "golang.org/x/crypto/bcrypt"
+    "gorm.io/gorm"
 )
 
 type LoginRequest struct {
@@ -18,22 +19,24 @@ type LoginRequest struct {
 }
 
 type Claims struct {
-    UserID string `json:"user_id"`
-    Email  string `json:"email"`
-    Role   string `json:"role"`
+    UserID       string `json:"user_id"`
+    Email        string `json:"email"`
+    Role         string `json:"role"`
+    TokenVersion int    `json:"ver"` // Must match users.token_version, see revokeUserSessions
     jwt.RegisteredClaims
 }
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and start a session. Repeated failures lock the email address (LOGIN_MAX_FAILURES, default 5) or IP address (LOGIN_MAX_IP_FAILURES, default 20) out for LOGIN_LOCKOUT_SECONDS (default 60), doubling with every further failure. Returns a short-lived access token (JWT_ACCESS_EXPIRE_MINUTES, default 15) and a refresh token (JWT_REFRESH_EXPIRE_HOURS, default 720) for POST /api/auth/refresh.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login credentials"
-// @Success 200 {object} map[string]interface{} "Login successful with token and user data"
+// @Success 200 {object} map[string]interface{} "Login successful with tokens and user data, or two_factor_required with a challenge_token for POST /api/auth/2fa/verify"
 // @Failure 400 {object} map[string]interface{} "Invalid request body"
 // @Failure 401 {object} map[string]interface{} "Invalid email or password"
+// @Failure 429 {object} map[string]interface{} "Too many failed attempts, retry_after holds the seconds to wait"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/auth/login [post]
 func Login(c *fiber.Ctx) error {
@@ -44,59 +47,60 @@ func Login(c *fiber.Ctx) error {
         })
     }
 
-    // Find user
-    var user models.User
-    if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-            "error": "Invalid email or password",
+    // Refuse logins while the email or IP address is locked out
+    email := normalizeLoginEmail(req.Email)
+    retryAfter, err := loginRetryAfter(email, c.IP(), time.Now())
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "Failed to check login attempts",
         })
     }
+    if retryAfter > 0 {
+        return respondLoginLocked(c, email, retryAfter)
+    }
 
-    // Check password
-    if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+    // Find user and check password
+    var user models.User
+    var userID *uuid.UUID
+    err = config.DB.Where("email = ?", req.Email).First(&user).Error
+    if err == nil {
+        userID = &user.ID
+        err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
+    }
+    if err != nil {
+        recordLoginAttempt(c, email, userID, models.LoginReasonInvalidCredentials)
+        if err := registerLoginFailure(email, c.IP(), time.Now()); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+                "error": "Failed to record login attempt",
+            })
+        }
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
             "error": "Invalid email or password",
         })
     }
 
-    // Generate JWT token
-    expireHours, _ := strconv.Atoi(os.Getenv("JWT_EXPIRE_HOURS"))
-    if expireHours == 0 {
-        expireHours = 72
-    }
-
-    claims := Claims{
-        UserID: user.ID.String(),
-        Email:  user.Email,
-        Role:   user.Role,
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-        },
-    }
-
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-    if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "error": "Failed to generate token",
+    // Users with 2FA get a challenge for the second step instead of the tokens
+    if user.TwoFactorEnabled {
+        challengeToken, challenge, err := issueLoginChallenge(c, user)
+        if err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+                "error": "Failed to create login challenge",
+            })
+        }
+        recordLoginAttempt(c, email, &user.ID, models.LoginReasonTwoFactorRequired)
+        return c.JSON(fiber.Map{
+            "two_factor_required":  true,
+            "challenge_token":      challengeToken,
+            "challenge_expires_at": challenge.ExpiresAt,
         })
     }
 
-    return c.JSON(fiber.Map{
-        "token": tokenString,
-        "user": fiber.Map{
-            "id":        user.ID,
-            "email":     user.Email,
-            "full_name": user.FullName,
-            "role":      user.Role,
-        },
-    })
+    return startSession(c, user)
 }
 
 // GetMe godoc
 // @Summary Get current user profile
-// @Description Get authenticated user's profile information
+// @Description Get authenticated user's profile information and the permissions granted by their role
 // @Tags User
 // @Accept json
 // @Produce json
@@ -115,14 +119,24 @@ func GetMe(c *fiber.Ctx) error {
         })
     }
 
+    // Permissions of the role, so the client can hide what the user cannot do
+    permissions := models.PermissionList{}
+    var role models.Role
+    if err := config.DB.Where("name = ?", user.Role).First(&role).Error; err == nil {
+        permissions = role.Permissions
+    }
+
     return c.JSON(fiber.Map{
-        "id":         user.ID,
-        "email":      user.Email,
-        "full_name":  user.FullName,
-        "role":       user.Role,
-        "photo_url":  user.PhotoUrl,
-        "created_at": user.CreatedAt,
-        "updated_at": user.UpdatedAt,
+        "id":                 user.ID,
+        "email":              user.Email,
+        "full_name":          user.FullName,
+        "role":               user.Role,
+        "permissions":        permissions,
+        "two_factor_enabled": user.TwoFactorEnabled,
+        "sales_associate_id": user.SalesAssociateID,
+        "photo_url":          user.PhotoUrl,
+        "created_at":         user.CreatedAt,
+        "updated_at":         user.UpdatedAt,
     })
 }
 
@@ -133,14 +147,14 @@ type UpdateMeRequest struct {
 
 // UpdateMe godoc
 // @Summary Update current user profile
-// @Description Update authenticated user's profile information (full_name and password)
+// @Description Update authenticated user's profile information (full_name and password). Changing the password logs the user out of every session, including the current one.
 // @Tags User
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body UpdateMeRequest true "Update data (full_name, password)"
 // @Success 200 {object} map[string]interface{} "Updated user profile"
-// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 400 {object} map[string]interface{} "Invalid request body or weak password"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 404 {object} map[string]interface{} "User not found"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -162,26 +176,41 @@ func UpdateMe(c *fiber.Ctx) error {
         })
     }
 
-    // Update full name if provided
+    // Only the changed columns are written, so a concurrent token_version bump is kept
+    updates := map[string]interface{}{}
     if req.FullName != "" {
-        user.FullName = req.FullName
+        updates["full_name"] = req.FullName
     }
 
-    // Update password if provided
     if req.Password != "" {
+        if !helpers.IsStrongPassword(req.Password) {
+            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                "error": "Password must be at least 8 characters long, must contain at least one number, one uppercase letter, one lowercase letter, and one special character",
+            })
+        }
         hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
         if err != nil {
             return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
                 "error": "Failed to hash password",
             })
         }
-        user.PasswordHash = string(hashedPassword)
+        updates["password_hash"] = string(hashedPassword)
     }
 
-    if err := config.DB.Save(&user).Error; err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "error": "Failed to update user",
-        })
+    if len(updates) > 0 {
+        if err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+            if err := tx.Model(&user).Updates(updates).Error; err != nil {
+                return err
+            }
+            if req.Password != "" {
+                return revokeUserSessions(tx, user.ID)
+            }
+            return nil
+        }); err != nil {
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+                "error": "Failed to update user",
+            })
+        }
     }
 
     return c.JSON(fiber.Map{
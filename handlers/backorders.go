@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BackorderAllocation represents stock handed to a waiting backorder line
+type BackorderAllocation struct {
+	SalesTransactionID uuid.UUID `json:"sales_transaction_id"`
+	NoInvoice          string    `json:"no_invoice"`
+	ItemID             uuid.UUID `json:"item_id"`
+	BookID             uuid.UUID `json:"book_id"`
+	AllocatedQuantity  int       `json:"allocated_quantity"`
+	RemainingQuantity  int       `json:"remaining_quantity"`
+}
+
+// PendingBackorder represents a sales line that is still owed to the customer
+type PendingBackorder struct {
+	ItemID              uuid.UUID `json:"item_id"`
+	BookID              uuid.UUID `json:"book_id"`
+	BookName            string    `json:"book_name"`
+	Quantity            int       `json:"quantity"`
+	ReservedQuantity    int       `json:"reserved_quantity"`
+	BackorderedQuantity int       `json:"backordered_quantity"`
+}
+
+// reserveSalesStock takes up to quantity of a book from the warehouse for a sales line.
+// When the warehouse holds less and backorders are allowed, the shortfall is returned as
+// backordered; otherwise errInsufficientStock is returned together with the available stock.
+func reserveSalesStock(tx *gorm.DB, bookID, warehouseID uuid.UUID, quantity int, allowBackorder bool) (backordered int, available int, err error) {
+	available, err = getWarehouseStock(tx, bookID, warehouseID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reserved := quantity
+	if available < quantity {
+		if !allowBackorder {
+			return 0, available, errInsufficientStock
+		}
+		reserved = available
+	}
+
+	if err := adjustWarehouseStock(tx, bookID, warehouseID, -reserved); err != nil {
+		return 0, available, err
+	}
+
+	return quantity - reserved, available, nil
+}
+
+// reservedQuantity returns the part of a sales line that has been taken from stock
+func reservedQuantity(item models.SalesTransactionItem) int {
+	return item.Quantity - item.BackorderedQuantity
+}
+
+// pendingBackorders lists the lines of a transaction that are still owed to the customer
+func pendingBackorders(items []models.SalesTransactionItem) []PendingBackorder {
+	pending := []PendingBackorder{}
+	for _, item := range items {
+		if item.BackorderedQuantity <= 0 {
+			continue
+		}
+
+		line := PendingBackorder{
+			ItemID:              item.ID,
+			BookID:              item.BookID,
+			Quantity:            item.Quantity,
+			ReservedQuantity:    reservedQuantity(item),
+			BackorderedQuantity: item.BackorderedQuantity,
+		}
+		if item.Book != nil {
+			line.BookName = item.Book.Name
+		}
+		pending = append(pending, line)
+	}
+	return pending
+}
+
+// allocateBackorders hands the stock of a book in a warehouse to waiting backorder lines,
+// oldest transaction first, until either the stock or the backorders run out
+func allocateBackorders(tx *gorm.DB, bookID, warehouseID uuid.UUID) ([]BackorderAllocation, error) {
+	available, err := getWarehouseStock(tx, bookID, warehouseID)
+	if err != nil || available <= 0 {
+		return nil, err
+	}
+
+	type waitingLine struct {
+		models.SalesTransactionItem
+		NoInvoice string
+	}
+
+	var waiting []waitingLine
+	if err := tx.Model(&models.SalesTransactionItem{}).
+		Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "sales_transaction_items"}}).
+		Select("sales_transaction_items.*, sales_transactions.no_invoice").
+		Joins("JOIN sales_transactions ON sales_transactions.id = sales_transaction_items.transaction_id").
+		Where("sales_transaction_items.book_id = ? AND sales_transaction_items.backordered_quantity > 0", bookID).
+		Where("sales_transactions.warehouse_id = ? AND sales_transactions.status <> ?", warehouseID, models.SalesStatusCancelled).
+		Order("sales_transactions.created_at ASC, sales_transaction_items.created_at ASC").
+		Scan(&waiting).Error; err != nil {
+		return nil, err
+	}
+
+	var allocations []BackorderAllocation
+	for _, line := range waiting {
+		if available <= 0 {
+			break
+		}
+
+		allocated := line.BackorderedQuantity
+		if allocated > available {
+			allocated = available
+		}
+
+		if err := adjustWarehouseStock(tx, bookID, warehouseID, -allocated); err != nil {
+			return nil, err
+		}
+
+		remaining := line.BackorderedQuantity - allocated
+		if err := tx.Model(&models.SalesTransactionItem{}).
+			Where("id = ?", line.ID).
+			Update("backordered_quantity", remaining).Error; err != nil {
+			return nil, err
+		}
+
+		available -= allocated
+		allocations = append(allocations, BackorderAllocation{
+			SalesTransactionID: line.TransactionID,
+			NoInvoice:          line.NoInvoice,
+			ItemID:             line.ID,
+			BookID:             bookID,
+			AllocatedQuantity:  allocated,
+			RemainingQuantity:  remaining,
+		})
+	}
+
+	return allocations, nil
+}
+
+// GetBackorders godoc
+// @Summary Get sales transactions with pending backorders
+// @Description Retrieve sales transactions that still owe books to the customer, oldest first, with the pending quantity per line
+// @Tags Sales Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param book_id query string false "Filter by book ID"
+// @Param warehouse_id query string false "Filter by warehouse ID"
+// @Param sales_associate_id query string false "Filter by sales associate ID"
+// @Success 200 {object} map[string]interface{} "Transactions with pending backorders and pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-transactions/backorders [get]
+func GetBackorders(c *fiber.Ctx) error {
+	var transactions []models.SalesTransaction
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	bookID := c.Query("book_id")
+
+	pendingCond := "EXISTS (SELECT 1 FROM sales_transaction_items sti WHERE sti.transaction_id = sales_transactions.id AND sti.backordered_quantity > 0)"
+	pendingArgs := []interface{}{}
+	if bookID != "" {
+		pendingCond = "EXISTS (SELECT 1 FROM sales_transaction_items sti WHERE sti.transaction_id = sales_transactions.id AND sti.backordered_quantity > 0 AND sti.book_id = ?)"
+		pendingArgs = append(pendingArgs, bookID)
+	}
+
+	query := config.DB.Order("created_at ASC").
+		Where("status <> ?", models.SalesStatusCancelled).
+		Where(pendingCond, pendingArgs...)
+	queryCount := config.DB.Model(&models.SalesTransaction{}).
+		Where("status <> ?", models.SalesStatusCancelled).
+		Where(pendingCond, pendingArgs...)
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+		queryCount = queryCount.Where("warehouse_id = ?", warehouseID)
+	}
+
+	if salesAssociateID := c.Query("sales_associate_id"); salesAssociateID != "" {
+		query = query.Where("sales_associate_id = ?", salesAssociateID)
+		queryCount = queryCount.Where("sales_associate_id = ?", salesAssociateID)
+	}
+
+	if err := query.
+		Preload("SalesAssociate", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch backorders",
+		})
+	}
+
+	data := make([]fiber.Map, 0, len(transactions))
+	for _, transaction := range transactions {
+		pending := pendingBackorders(transaction.Items)
+
+		totalBackordered := 0
+		for _, line := range pending {
+			totalBackordered += line.BackorderedQuantity
+		}
+
+		data = append(data, fiber.Map{
+			"id":                transaction.ID,
+			"no_invoice":        transaction.NoInvoice,
+			"transaction_date":  transaction.TransactionDate,
+			"status":            transaction.Status,
+			"sales_associate":   transaction.SalesAssociate,
+			"warehouse":         transaction.Warehouse,
+			"total_backordered": totalBackordered,
+			"items":             pending,
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, data, "data", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
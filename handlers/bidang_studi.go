@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted bidang studi (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all bidang studi with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllBidangStudi(c *fiber.Ctx) error {
 	query := config.DB.Order("name ASC")
 	queryCount := config.DB.Model(&models.BidangStudi{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,6 +55,11 @@ func GetAllBidangStudi(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.BidangStudi{}, "bidang_studi")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&bidangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -113,7 +126,7 @@ func CreateBidangStudi(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&bidangStudi).Error; err != nil {
+	if err := auditedDB(c).Create(&bidangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create bidang studi",
 		})
@@ -153,7 +166,7 @@ func UpdateBidangStudi(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&bidangStudi).Updates(bidangStudi).Error; err != nil {
+	if err := auditedDB(c).Model(&bidangStudi).Updates(bidangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update bidang studi",
 		})
@@ -164,34 +177,49 @@ func UpdateBidangStudi(c *fiber.Ctx) error {
 
 // DeleteBidangStudi godoc
 // @Summary Delete a bidang studi
-// @Description Delete a field of study by ID
+// @Description Soft delete a bidang studi by ID. Deleted bidang studi are hidden from lists and lookups and can be restored. Bidang studi used by books cannot be deleted.
 // @Tags BidangStudi
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "BidangStudi ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the bidang studi taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "BidangStudi deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "BidangStudi not found"
+// @Failure 409 {object} map[string]interface{} "Bidang studi is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/bidang-studi/{id} [delete]
 func DeleteBidangStudi(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.BidangStudi{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete bidang studi",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var bidangStudi models.BidangStudi
+	if err := config.DB.Where("id = ?", id).First(&bidangStudi).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "BidangStudi not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "BidangStudi deleted successfully",
-	})
+	return deleteMasterRecord(c, &bidangStudi, bidangStudi.ID, "bidang_studi", "Bidang studi", "BidangStudi deleted successfully")
+}
+
+// RestoreBidangStudi godoc
+// @Summary Restore a deleted bidang studi
+// @Description Undo the soft delete of a bidang studi. Fails when another bidang studi has taken its code or name since.
+// @Tags BidangStudi
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "BidangStudi ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored bidang studi"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted bidang studi not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/bidang-studi/{id}/restore [post]
+func RestoreBidangStudi(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.BidangStudi{}, "Bidang studi", "bidang_studi")
 }
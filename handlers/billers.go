@@ -18,6 +18,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted billers (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all billers with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -31,6 +33,12 @@ func GetAllBillers(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at DESC")
 	queryCount := config.DB.Model(&models.Biller{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -48,8 +56,13 @@ func GetAllBillers(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("City", withDeleted), models.Biller{}, "billers")
+	}
+
 	// Apply pagination and fetch data
-	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City").Find(&billers).Error; err != nil {
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City", withDeleted).Find(&billers).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch all billers",
 		})
@@ -82,7 +95,7 @@ func GetBiller(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var biller models.Biller
-	if err := config.DB.Preload("City").Where("id = ?", id).First(&biller).Error; err != nil {
+	if err := config.DB.Preload("City", withDeleted).Where("id = ?", id).First(&biller).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Biller not found",
 		})
@@ -114,7 +127,7 @@ func CreateBiller(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&biller).Error; err != nil {
+	if err := auditedDB(c).Create(&biller).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create biller",
 		})
@@ -154,7 +167,7 @@ func UpdateBiller(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&biller).Updates(biller).Error; err != nil {
+	if err := auditedDB(c).Model(&biller).Updates(biller).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update biller",
 		})
@@ -165,34 +178,49 @@ func UpdateBiller(c *fiber.Ctx) error {
 
 // DeleteBiller godoc
 // @Summary Delete a biller
-// @Description Delete a biller by ID
+// @Description Soft delete a biller by ID. Deleted billers are hidden from lists and lookups and can be restored. Billers of open sales transactions cannot be deleted.
 // @Tags Billers
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Biller ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the biller taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "Biller deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "Biller not found"
+// @Failure 409 {object} map[string]interface{} "Biller is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/billers/{id} [delete]
 func DeleteBiller(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Biller{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete biller",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var biller models.Biller
+	if err := config.DB.Where("id = ?", id).First(&biller).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Biller not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Biller deleted successfully",
-	})
+	return deleteMasterRecord(c, &biller, biller.ID, "billers", "Biller", "Biller deleted successfully")
+}
+
+// RestoreBiller godoc
+// @Summary Restore a deleted biller
+// @Description Undo the soft delete of a biller. Fails when another biller has taken its code or name since.
+// @Tags Billers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Biller ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored biller"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted biller not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/billers/{id}/restore [post]
+func RestoreBiller(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Biller{}, "Biller", "biller")
 }
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBookCodeLength matches the size of the books.code column
+const maxBookCodeLength = 50
+
+// sameOptionalString reports whether two optional strings hold the same value
+func sameOptionalString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// copyOptionalString returns a pointer to a copy of the value of s, so later writes through s
+// do not change it
+func copyOptionalString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	value := *s
+	return &value
+}
+
+// validateBookCode checks the format of a trimmed book code and returns an error message, or an
+// empty string when the code is valid. Codes are printed as Code 128 barcodes, so they are
+// limited to printable ASCII.
+func validateBookCode(code string) string {
+	if len(code) > maxBookCodeLength {
+		return fmt.Sprintf("Book code cannot be longer than %d characters", maxBookCodeLength)
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 32 || code[i] > 126 {
+			return "Book code may only contain printable ASCII characters"
+		}
+	}
+	return ""
+}
+
+// validateBookIdentifiers normalizes and validates the ISBN and code of a book. Values equal to
+// the previous ones are left alone so books saved before validation existed can still be edited.
+// It returns the HTTP status and message to report, or 0 when the identifiers are valid.
+func validateBookIdentifiers(book *models.Book, previousISBN, previousCode *string) (int, string) {
+	if book.ISBN != nil && !sameOptionalString(book.ISBN, previousISBN) {
+		isbn := helpers.NormalizeISBN(*book.ISBN)
+		switch {
+		case isbn == "":
+			book.ISBN = nil
+		case !helpers.IsValidISBN(isbn):
+			return fiber.StatusBadRequest, "Invalid ISBN, expected an ISBN-10 or ISBN-13 with a valid check digit"
+		default:
+			book.ISBN = &isbn
+		}
+	}
+
+	if book.Code != nil && !sameOptionalString(book.Code, previousCode) {
+		code := strings.TrimSpace(*book.Code)
+		if code == "" {
+			book.Code = nil
+			return 0, ""
+		}
+
+		if message := validateBookCode(code); message != "" {
+			return fiber.StatusBadRequest, message
+		}
+
+		var count int64
+		if err := config.DB.Model(&models.Book{}).Where("code = ? AND id <> ?", code, book.ID).Count(&count).Error; err != nil {
+			return fiber.StatusInternalServerError, "Failed to check book code"
+		}
+		if count > 0 {
+			return fiber.StatusConflict, "Book code already exists"
+		}
+		book.Code = &code
+	}
+
+	return 0, ""
+}
+
+// formatRupiah formats an amount as Indonesian rupiah, e.g. Rp 50.000
+func formatRupiah(amount float64) string {
+	digits := strconv.FormatInt(int64(math.Round(amount)), 10)
+
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+
+	if negative {
+		return "Rp -" + b.String()
+	}
+	return "Rp " + b.String()
+}
+
+// LookupBook godoc
+// @Summary Look up a book by barcode
+// @Description Find a book by a scanned barcode. ISBN-13 and ISBN-10 are matched in both forms, with or without hyphens; anything else is matched against the book code (SKU). When several editions share an ISBN the newest is returned first.
+// @Tags Books
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param barcode query string true "Scanned ISBN-13, ISBN-10 or book code"
+// @Success 200 {object} map[string]interface{} "Matching book"
+// @Failure 400 {object} map[string]interface{} "Barcode is required"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "No book found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/lookup [get]
+func LookupBook(c *fiber.Ctx) error {
+	barcode := strings.TrimSpace(c.Query("barcode"))
+	if barcode == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "barcode is required",
+		})
+	}
+
+	// An ISBN may be stored in either form
+	var isbns []string
+	normalized := helpers.NormalizeISBN(barcode)
+	switch {
+	case helpers.IsValidISBN13(normalized):
+		isbns = append(isbns, normalized)
+		if isbn10 := helpers.ISBN13To10(normalized); isbn10 != "" {
+			isbns = append(isbns, isbn10)
+		}
+	case helpers.IsValidISBN10(normalized):
+		isbns = append(isbns, normalized, helpers.ISBN10To13(normalized))
+	}
+
+	query := config.DB.
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Publisher", withDeleted).
+		Order("year DESC, periode DESC")
+	if len(isbns) > 0 {
+		query = query.Where("isbn IN ? OR UPPER(code) = UPPER(?)", isbns, barcode)
+	} else {
+		query = query.Where("UPPER(code) = UPPER(?)", barcode)
+	}
+
+	var books []models.Book
+	if err := query.Find(&books).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to look up book",
+		})
+	}
+
+	if len(books) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No book found for this barcode",
+		})
+	}
+
+	matchedBy := "isbn"
+	if books[0].Code != nil && strings.EqualFold(*books[0].Code, barcode) {
+		matchedBy = "code"
+	}
+
+	return c.JSON(fiber.Map{
+		"book":           books[0],
+		"matched_by":     matchedBy,
+		"other_editions": books[1:],
+	})
+}
+
+// GetBookBarcode godoc
+// @Summary Generate a barcode label for a book
+// @Description Render a printable shelf or box label with the book name, barcode and price. ISBNs are printed as EAN-13, book codes as Code 128.
+// @Tags Books
+// @Produce image/svg+xml
+// @Produce image/png
+// @Security BearerAuth
+// @Param id path string true "Book ID (UUID)"
+// @Param format query string false "Image format: svg or png (default: svg)"
+// @Param value query string false "Value to encode: isbn or code (default: isbn when set, otherwise code)"
+// @Param module_width query int false "Width of the narrowest bar in pixels, 1-10 (default: 2)"
+// @Param height query int false "Bar height in pixels, 20-400 (default: 60)"
+// @Param title query bool false "Print the book name above the barcode (default: true)"
+// @Param price query bool false "Print the price below the barcode (default: true)"
+// @Success 200 {file} file "Barcode label image"
+// @Failure 400 {object} map[string]interface{} "Invalid parameters or nothing to encode"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Book not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/{id}/barcode [get]
+func GetBookBarcode(c *fiber.Ctx) error {
+	format := c.Query("format", "svg")
+	if format != "svg" && format != "png" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be svg or png",
+		})
+	}
+
+	id := c.Params("id")
+	var book models.Book
+	if err := config.DB.Where("id = ?", id).First(&book).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Book not found",
+		})
+	}
+
+	var value *string
+	switch c.Query("value") {
+	case "isbn":
+		value = book.ISBN
+	case "code":
+		value = book.Code
+	case "":
+		value = book.ISBN
+		if value == nil || *value == "" {
+			value = book.Code
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "value must be isbn or code",
+		})
+	}
+
+	if value == nil || *value == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Book has no ISBN or code to encode",
+		})
+	}
+
+	barcode, err := helpers.EncodeBarcode(*value)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to encode barcode: %s", err.Error()),
+		})
+	}
+
+	moduleWidth := c.QueryInt("module_width", 2)
+	if moduleWidth < 1 || moduleWidth > 10 {
+		moduleWidth = 2
+	}
+	barHeight := c.QueryInt("height", 60)
+	if barHeight < 20 || barHeight > 400 {
+		barHeight = 60
+	}
+
+	label := helpers.BarcodeLabel{Barcode: barcode}
+	if c.Query("title") != "false" {
+		label.Title = book.Name
+	}
+	if c.Query("price") != "false" {
+		label.Caption = formatRupiah(book.Price)
+	}
+
+	filename := strings.ReplaceAll(barcode.Text, " ", "_")
+	if format == "png" {
+		image, err := helpers.RenderBarcodePNG(label, moduleWidth, barHeight)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to render barcode",
+			})
+		}
+		c.Set(fiber.HeaderContentType, "image/png")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", filename+".png"))
+		return c.Send(image)
+	}
+
+	c.Set(fiber.HeaderContentType, "image/svg+xml")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", filename+".svg"))
+	return c.Send(helpers.RenderBarcodeSVG(label, moduleWidth, barHeight))
+}
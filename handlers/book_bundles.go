@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BookBundleRequest represents the request body for creating or updating a book bundle
+type BookBundleRequest struct {
+	Code        *string                 `json:"code"`
+	Name        *string                 `json:"name"`
+	Description *string                 `json:"description"`
+	Price       *float64                `json:"price"`
+	IsActive    *bool                   `json:"is_active"`
+	Items       []BookBundleItemRequest `json:"items"` // Replaces all books of the bundle when given
+}
+
+// BookBundleItemRequest represents a book in a bundle
+type BookBundleItemRequest struct {
+	BookID   string `json:"book_id"`
+	Quantity int    `json:"quantity"` // Copies per bundle, defaults to 1
+}
+
+// validateBundleItems checks the books of a bundle and returns the items to save, or an error message
+func validateBundleItems(db *gorm.DB, items []BookBundleItemRequest) ([]models.BookBundleItem, string) {
+	if len(items) == 0 {
+		return nil, "A bundle needs at least one book"
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	bundleItems := make([]models.BookBundleItem, 0, len(items))
+	var bookIDs []uuid.UUID
+	for _, item := range items {
+		bookID, err := uuid.Parse(item.BookID)
+		if err != nil {
+			return nil, fmt.Sprintf("Invalid book_id %s", item.BookID)
+		}
+		if seen[bookID] {
+			return nil, fmt.Sprintf("Duplicate book_id in bundle: %s", item.BookID)
+		}
+		seen[bookID] = true
+
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 {
+			return nil, "Quantity must be greater than 0"
+		}
+
+		bookIDs = append(bookIDs, bookID)
+		bundleItems = append(bundleItems, models.BookBundleItem{BookID: bookID, Quantity: item.Quantity})
+	}
+
+	var count int64
+	if err := db.Model(&models.Book{}).Where("id IN ?", bookIDs).Count(&count).Error; err != nil {
+		return nil, "Failed to check bundle books"
+	}
+	if count != int64(len(bookIDs)) {
+		return nil, "One or more books of the bundle were not found"
+	}
+
+	return bundleItems, ""
+}
+
+// spreadBundlePrice divides the subtotal of a bundle line over its components in proportion to
+// their list value (book price times quantity), or to their quantity when no book has a price.
+// Shares are rounded to cents and the last component takes the rounding difference, so the
+// shares always add up to the bundle subtotal.
+func spreadBundlePrice(subtotal float64, components []models.BookBundleItem) []float64 {
+	weights := make([]float64, len(components))
+	var totalWeight float64
+	for i, component := range components {
+		if component.Book != nil {
+			weights[i] = component.Book.Price * float64(component.Quantity)
+		}
+		totalWeight += weights[i]
+	}
+	if totalWeight == 0 {
+		for i, component := range components {
+			weights[i] = float64(component.Quantity)
+			totalWeight += weights[i]
+		}
+	}
+
+	subtotal = roundCents(subtotal)
+	shares := make([]float64, len(components))
+	var allocated float64
+	for i := range components {
+		if i == len(components)-1 {
+			shares[i] = roundCents(subtotal - allocated)
+			break
+		}
+		shares[i] = roundCents(subtotal * weights[i] / totalWeight)
+		allocated += shares[i]
+	}
+	return shares
+}
+
+// roundCents rounds an amount to two decimals, the precision of the amount columns
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// findSellableBundle loads a bundle with its books for a sales line. Deleted books are not
+// loaded, so the sale can turn the bundle down.
+func findSellableBundle(db *gorm.DB, id string) (models.BookBundle, error) {
+	return loadBookBundle(db, id)
+}
+
+// findBookBundle loads a bundle with its books for display, deleted books included
+func findBookBundle(db *gorm.DB, id string) (models.BookBundle, error) {
+	return loadBookBundle(db, id, withDeleted)
+}
+
+func loadBookBundle(db *gorm.DB, id string, bookConditions ...interface{}) (models.BookBundle, error) {
+	var bundle models.BookBundle
+	err := db.
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		Preload("Items.Book", bookConditions...).
+		Where("id = ?", id).First(&bundle).Error
+	return bundle, err
+}
+
+// GetAllBookBundles godoc
+// @Summary Get all book bundles
+// @Description Retrieve all book bundles (paket buku) with their books
+// @Tags BookBundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param search query string false "Search by code, name, or description"
+// @Param is_active query bool false "Filter by active status"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "List of all book bundles with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/book-bundles [get]
+func GetAllBookBundles(c *fiber.Ctx) error {
+	var bundles []models.BookBundle
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("created_at DESC")
+	queryCount := config.DB.Model(&models.BookBundle{})
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	// Filter search
+	if searchQuery := c.Query("search"); searchQuery != "" {
+		searchTerm := "%" + searchQuery + "%"
+		cond := "book_bundles.code ILIKE ? OR book_bundles.name ILIKE ? OR book_bundles.description ILIKE ?"
+		args := []interface{}{searchTerm, searchTerm, searchTerm}
+
+		query = query.Where(cond, args...)
+		queryCount = queryCount.Where(cond, args...)
+	}
+
+	// Filter active status
+	if isActive := c.Query("is_active"); isActive != "" {
+		query = query.Where("book_bundles.is_active = ?", isActive == "true")
+		queryCount = queryCount.Where("book_bundles.is_active = ?", isActive == "true")
+	}
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.BookBundle{}, "book_bundles")
+	}
+
+	// Apply pagination and fetch data
+	if err := query.
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&bundles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch all book bundles",
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, bundles, "book_bundles", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetBookBundle godoc
+// @Summary Get a book bundle by ID
+// @Description Retrieve a single book bundle with its books and the list value of the bundle
+// @Tags BookBundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Book bundle ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Book bundle details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Book bundle not found"
+// @Router /api/book-bundles/{id} [get]
+func GetBookBundle(c *fiber.Ctx) error {
+	bundle, err := findBookBundle(config.DB, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Book bundle not found",
+		})
+	}
+
+	// The list value shows how much the bundle price saves compared to buying the books separately
+	var listValue float64
+	for _, item := range bundle.Items {
+		if item.Book != nil {
+			listValue += item.Book.Price * float64(item.Quantity)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"book_bundle": bundle,
+		"list_value":  listValue,
+	})
+}
+
+// CreateBookBundle godoc
+// @Summary Create a new book bundle
+// @Description Create a book bundle (paket buku) from a list of books with a quantity per bundle and a bundle price
+// @Tags BookBundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BookBundleRequest true "Book bundle details"
+// @Success 201 {object} models.BookBundle "Created book bundle"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 409 {object} map[string]interface{} "Bundle code already exists"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/book-bundles [post]
+func CreateBookBundle(c *fiber.Ctx) error {
+	var req BookBundleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Code == nil || strings.TrimSpace(*req.Code) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "code is required",
+		})
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+	if req.Price == nil || *req.Price < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "price is required and cannot be negative",
+		})
+	}
+
+	bundle := models.BookBundle{
+		Code:        strings.TrimSpace(*req.Code),
+		Name:        strings.TrimSpace(*req.Name),
+		Description: req.Description,
+		Price:       *req.Price,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		bundle.IsActive = *req.IsActive
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.BookBundle{}).Where("code = ?", bundle.Code).Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check bundle code",
+		})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Bundle code already exists",
+		})
+	}
+
+	items, message := validateBundleItems(config.DB, req.Items)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(&bundle).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BundleID = bundle.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create book bundle",
+		})
+	}
+
+	created, _ := findBookBundle(config.DB, bundle.ID.String())
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// UpdateBookBundle godoc
+// @Summary Update a book bundle
+// @Description Update a book bundle by ID. When items are given they replace all books of the bundle. Sales already made keep the books and prices they were sold with.
+// @Tags BookBundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Book bundle ID (UUID)"
+// @Param request body BookBundleRequest true "Updated book bundle details"
+// @Success 200 {object} models.BookBundle "Updated book bundle"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Book bundle not found"
+// @Failure 409 {object} map[string]interface{} "Bundle code already exists"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/book-bundles/{id} [put]
+func UpdateBookBundle(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var bundle models.BookBundle
+	if err := config.DB.Where("id = ?", id).First(&bundle).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Book bundle not found",
+		})
+	}
+
+	var req BookBundleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Build updates map to handle zero values properly
+	updates := make(map[string]interface{})
+
+	if req.Code != nil {
+		code := strings.TrimSpace(*req.Code)
+		if code == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "code cannot be empty",
+			})
+		}
+		var count int64
+		if err := config.DB.Model(&models.BookBundle{}).Where("code = ? AND id <> ?", code, bundle.ID).Count(&count).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check bundle code",
+			})
+		}
+		if count > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Bundle code already exists",
+			})
+		}
+		updates["code"] = code
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name cannot be empty",
+			})
+		}
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Price != nil {
+		if *req.Price < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "price cannot be negative",
+			})
+		}
+		updates["price"] = *req.Price
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	var items []models.BookBundleItem
+	if req.Items != nil {
+		var message string
+		if items, message = validateBundleItems(config.DB, req.Items); message != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": message,
+			})
+		}
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&bundle).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Items == nil {
+			return nil
+		}
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BookBundleItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BundleID = bundle.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update book bundle",
+		})
+	}
+
+	updated, _ := findBookBundle(config.DB, bundle.ID.String())
+	return c.JSON(updated)
+}
+
+// DeleteBookBundle godoc
+// @Summary Delete a book bundle
+// @Description Delete a book bundle by ID. Bundles that have been sold cannot be deleted, deactivate them instead.
+// @Tags BookBundles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Book bundle ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Book bundle deleted successfully"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Book bundle not found"
+// @Failure 409 {object} map[string]interface{} "Bundle has been sold"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/book-bundles/{id} [delete]
+func DeleteBookBundle(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var sold int64
+	if err := config.DB.Model(&models.SalesTransactionItem{}).Where("bundle_id = ?", id).Count(&sold).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check bundle sales",
+		})
+	}
+	if sold > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Bundle has been sold and cannot be deleted, deactivate it instead",
+		})
+	}
+
+	result := auditedDB(c).Delete(&models.BookBundle{}, "id = ?", id)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete book bundle",
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Book bundle not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Book bundle deleted successfully",
+	})
+}
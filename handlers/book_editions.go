@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RollBookEditionRequest represents the request body for rolling books into a new edition
+type RollBookEditionRequest struct {
+	Year                   string                 `json:"year"`
+	Periode                *int                   `json:"periode"`
+	CurriculumID           *string                `json:"curriculum_id"`
+	MerkBukuID             *string                `json:"merk_buku_id"`
+	JenjangStudiID         *string                `json:"jenjang_studi_id"`
+	PublisherID            *string                `json:"publisher_id"`
+	BookIDs                []string               `json:"book_ids"`
+	TargetYear             string                 `json:"target_year"`
+	TargetPeriode          int                    `json:"target_periode"`
+	PriceAdjustmentPercent float64                `json:"price_adjustment_percent"` // e.g. 5 raises every price by 5%
+	RoundTo                float64                `json:"round_to"`                 // e.g. 500 rounds new prices to the nearest 500
+	Prices                 []RollBookEditionPrice `json:"prices"`                   // Explicit new prices, these win over the adjustment
+	DryRun                 bool                   `json:"dry_run"`
+}
+
+// RollBookEditionPrice sets the price of the new edition of a single book
+type RollBookEditionPrice struct {
+	BookID string  `json:"book_id"`
+	Price  float64 `json:"price"`
+}
+
+// RolledBookEdition represents a new edition created from a previous one
+type RolledBookEdition struct {
+	PreviousEditionID uuid.UUID   `json:"previous_edition_id"`
+	PreviousYear      string      `json:"previous_year"`
+	PreviousPeriode   int         `json:"previous_periode"`
+	PreviousPrice     float64     `json:"previous_price"`
+	Book              models.Book `json:"book"`
+}
+
+// SkippedBookEdition represents a book that was not rolled into the new edition
+type SkippedBookEdition struct {
+	BookID   uuid.UUID `json:"book_id"`
+	BookName string    `json:"book_name"`
+	Reason   string    `json:"reason"`
+}
+
+// EditionSalesLine represents the sales of one edition, or of one year and periode
+type EditionSalesLine struct {
+	BookID               *string  `json:"book_id,omitempty"`
+	BookName             *string  `json:"book_name,omitempty"`
+	Price                *float64 `json:"price,omitempty"`
+	Year                 string   `json:"year"`
+	Periode              int      `json:"periode"`
+	TotalBooks           int      `json:"total_books,omitempty"`
+	TransactionCount     int      `json:"transaction_count"`
+	TotalItems           int      `json:"total_items"`
+	Revenue              float64  `json:"revenue"`
+	COGS                 float64  `gorm:"column:cogs" json:"cogs"`
+	GrossMargin          float64  `json:"gross_margin"`
+	ItemsChangePercent   *float64 `json:"items_change_percent"`
+	RevenueChangePercent *float64 `json:"revenue_change_percent"`
+}
+
+// roundEditionPrice rounds a new edition price to the nearest multiple of roundTo, or to cents
+func roundEditionPrice(price, roundTo float64) float64 {
+	if roundTo > 0 {
+		return math.Round(price/roundTo) * roundTo
+	}
+	return math.Round(price*100) / 100
+}
+
+// changePercent returns the change from previous to current as a percentage, nil without a base
+func changePercent(previous, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := math.Round((current-previous)/previous*10000) / 100
+	return &change
+}
+
+// RollBookEdition godoc
+// @Summary Roll books into a new edition
+// @Description Copy a catalog subset (selected by year, periode, curriculum, merk buku, jenjang studi, publisher or explicit book IDs) into a new year and periode. New rows link to their previous edition, start with zero stock and no average cost, and get new prices from explicit prices or a percentage adjustment. ISBN and code are not copied since a new edition needs its own. Books already rolled into the target year and periode are skipped. Use dry_run to preview.
+// @Tags Books
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RollBookEditionRequest true "Selection, target edition and pricing"
+// @Success 200 {object} map[string]interface{} "Preview of the new editions (dry run)"
+// @Success 201 {object} map[string]interface{} "Created editions and skipped books"
+// @Failure 400 {object} map[string]interface{} "Invalid request"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/roll-edition [post]
+func RollBookEdition(c *fiber.Ctx) error {
+	var req RollBookEditionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.TargetYear == "" || req.TargetPeriode <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "target_year and target_periode are required",
+		})
+	}
+
+	if req.Year == "" && req.CurriculumID == nil && req.MerkBukuID == nil && req.JenjangStudiID == nil &&
+		req.PublisherID == nil && len(req.BookIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one filter is required: year, curriculum_id, merk_buku_id, jenjang_studi_id, publisher_id or book_ids",
+		})
+	}
+
+	if req.RoundTo < 0 || req.PriceAdjustmentPercent <= -100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "price_adjustment_percent must be above -100 and round_to cannot be negative",
+		})
+	}
+
+	prices := make(map[string]float64)
+	for _, price := range req.Prices {
+		if price.Price <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Price for book ID %s must be greater than 0", price.BookID),
+			})
+		}
+		prices[price.BookID] = price.Price
+	}
+
+	// Select the books to roll, never the target edition itself
+	query := config.DB.Order("name ASC").
+		Where("NOT (year = ? AND periode = ?)", req.TargetYear, req.TargetPeriode)
+	if req.Year != "" {
+		query = query.Where("year = ?", req.Year)
+	}
+	if req.Periode != nil {
+		query = query.Where("periode = ?", *req.Periode)
+	}
+	if req.CurriculumID != nil {
+		query = query.Where("curriculum_id = ?", *req.CurriculumID)
+	}
+	if req.MerkBukuID != nil {
+		query = query.Where("merk_buku_id = ?", *req.MerkBukuID)
+	}
+	if req.JenjangStudiID != nil {
+		query = query.Where("jenjang_studi_id = ?", *req.JenjangStudiID)
+	}
+	if req.PublisherID != nil {
+		query = query.Where("publisher_id = ?", *req.PublisherID)
+	}
+	if len(req.BookIDs) > 0 {
+		query = query.Where("id IN ?", req.BookIDs)
+	}
+
+	var books []models.Book
+	if err := query.Find(&books).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch books",
+		})
+	}
+
+	if len(books) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No books match the selected filters",
+		})
+	}
+
+	// Books that already have an edition in the target year and periode
+	sourceIDs := make([]uuid.UUID, 0, len(books))
+	for _, book := range books {
+		sourceIDs = append(sourceIDs, book.ID)
+	}
+	var alreadyRolled []uuid.UUID
+	if err := config.DB.Model(&models.Book{}).
+		Where("previous_edition_id IN ? AND year = ? AND periode = ?", sourceIDs, req.TargetYear, req.TargetPeriode).
+		Pluck("previous_edition_id", &alreadyRolled).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check existing editions",
+		})
+	}
+	rolled := make(map[uuid.UUID]bool)
+	for _, id := range alreadyRolled {
+		rolled[id] = true
+	}
+
+	created := []RolledBookEdition{}
+	skipped := []SkippedBookEdition{}
+	for _, book := range books {
+		if rolled[book.ID] {
+			skipped = append(skipped, SkippedBookEdition{
+				BookID:   book.ID,
+				BookName: book.Name,
+				Reason:   fmt.Sprintf("Already has an edition for %s periode %d", req.TargetYear, req.TargetPeriode),
+			})
+			continue
+		}
+
+		price, ok := prices[book.ID.String()]
+		if !ok {
+			price = roundEditionPrice(book.Price*(1+req.PriceAdjustmentPercent/100), req.RoundTo)
+		}
+
+		previousID := book.ID
+		edition := models.Book{
+			Name:              book.Name,
+			Description:       book.Description,
+			Year:              req.TargetYear,
+			Author:            book.Author,
+			Periode:           req.TargetPeriode,
+			MinStock:          book.MinStock,
+			ReorderQuantity:   book.ReorderQuantity,
+			NoPages:           book.NoPages,
+			Kelas:             book.Kelas,
+			MerkBukuID:        book.MerkBukuID,
+			JenisBukuID:       book.JenisBukuID,
+			JenjangStudiID:    book.JenjangStudiID,
+			BidangStudiID:     book.BidangStudiID,
+			CurriculumID:      book.CurriculumID,
+			PublisherID:       book.PublisherID,
+			Price:             price,
+			ImageUrl:          book.ImageUrl,
+			FileUrl:           book.FileUrl,
+			PreviousEditionID: &previousID,
+		}
+
+		created = append(created, RolledBookEdition{
+			PreviousEditionID: book.ID,
+			PreviousYear:      book.Year,
+			PreviousPeriode:   book.Periode,
+			PreviousPrice:     book.Price,
+			Book:              edition,
+		})
+	}
+
+	if req.DryRun || len(created) == 0 {
+		return c.JSON(fiber.Map{
+			"message":       fmt.Sprintf("%d books would be rolled into %s periode %d", len(created), req.TargetYear, req.TargetPeriode),
+			"dry_run":       req.DryRun,
+			"created":       created,
+			"skipped":       skipped,
+			"total_created": len(created),
+			"total_skipped": len(skipped),
+		})
+	}
+
+	if err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		for i := range created {
+			if err := tx.Create(&created[i].Book).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create new editions",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":       fmt.Sprintf("%d books rolled into %s periode %d", len(created), req.TargetYear, req.TargetPeriode),
+		"dry_run":       false,
+		"created":       created,
+		"skipped":       skipped,
+		"total_created": len(created),
+		"total_skipped": len(skipped),
+	})
+}
+
+// GetEditionSalesReport godoc
+// @Summary Compare sales across editions
+// @Description Compare non-cancelled sales across editions. With book_id, every edition linked to that book (older and newer) is listed. Without it, sales are totalled per year and periode for the filtered catalog. Each row carries the change against the row before it.
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param book_id query string false "List every edition of this book"
+// @Param curriculum_id query string false "Filter by curriculum ID"
+// @Param merk_buku_id query string false "Filter by merk buku ID"
+// @Param jenjang_studi_id query string false "Filter by jenjang studi ID"
+// @Param publisher_id query string false "Filter by publisher ID"
+// @Param format query string false "Export as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "Sales per edition"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/reports/edition-sales [get]
+func GetEditionSalesReport(c *fiber.Ctx) error {
+	salesJoin := "LEFT JOIN (sales_transaction_items sti JOIN sales_transactions st ON st.id = sti.transaction_id AND st.status <> ?) ON sti.book_id = books.id"
+	totalsSelect := "COUNT(DISTINCT st.id) AS transaction_count, " +
+		"COALESCE(SUM(sti.quantity), 0) AS total_items, " +
+		"COALESCE(SUM(sti.subtotal), 0) AS revenue, " +
+		"COALESCE(SUM(sti.cogs), 0) AS cogs"
+
+	var lines []EditionSalesLine
+	bookID := c.Query("book_id")
+	if bookID != "" {
+		// Walk the edition links in both directions from the requested book
+		editions := `
+			WITH RECURSIVE older AS (
+				SELECT id, previous_edition_id FROM books WHERE id = ?
+				UNION
+				SELECT b.id, b.previous_edition_id FROM books b JOIN older o ON b.id = o.previous_edition_id
+			), newer AS (
+				SELECT id FROM books WHERE id = ?
+				UNION
+				SELECT b.id FROM books b JOIN newer n ON b.previous_edition_id = n.id
+			)
+			SELECT id FROM older UNION SELECT id FROM newer`
+
+		if err := config.DB.Table("books").
+			Select("books.id::text AS book_id, books.name AS book_name, books.price, books.year, books.periode, "+totalsSelect).
+			Joins(salesJoin, models.SalesStatusCancelled).
+			Where("books.id IN ("+editions+")", bookID, bookID).
+			Group("books.id, books.name, books.price, books.year, books.periode").
+			Order("books.year ASC, books.periode ASC").
+			Scan(&lines).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch edition sales",
+			})
+		}
+	} else {
+		query := config.DB.Table("books").
+			Select("books.year, books.periode, COUNT(DISTINCT books.id) AS total_books, "+totalsSelect).
+			Joins(salesJoin, models.SalesStatusCancelled)
+
+		if curriculumID := c.Query("curriculum_id"); curriculumID != "" {
+			query = query.Where("books.curriculum_id = ?", curriculumID)
+		}
+		if merkBukuID := c.Query("merk_buku_id"); merkBukuID != "" {
+			query = query.Where("books.merk_buku_id = ?", merkBukuID)
+		}
+		if jenjangStudiID := c.Query("jenjang_studi_id"); jenjangStudiID != "" {
+			query = query.Where("books.jenjang_studi_id = ?", jenjangStudiID)
+		}
+		if publisherID := c.Query("publisher_id"); publisherID != "" {
+			query = query.Where("books.publisher_id = ?", publisherID)
+		}
+
+		if err := query.
+			Group("books.year, books.periode").
+			Order("books.year ASC, books.periode ASC").
+			Scan(&lines).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch edition sales",
+			})
+		}
+	}
+
+	for i := range lines {
+		lines[i].GrossMargin = lines[i].Revenue - lines[i].COGS
+		if i > 0 {
+			lines[i].ItemsChangePercent = changePercent(float64(lines[i-1].TotalItems), float64(lines[i].TotalItems))
+			lines[i].RevenueChangePercent = changePercent(lines[i-1].Revenue, lines[i].Revenue)
+		}
+	}
+
+	// Export the comparison as a file instead of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, lines, "edition_sales_report")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": lines,
+	})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pustaka-backend/config"
+
+	"gorm.io/gorm"
+)
+
+// bookFilter is one condition of the book catalog query. Facet names the facet the filter
+// belongs to, or is empty for filters that are not facets such as search.
+type bookFilter struct {
+	Facet string
+	Cond  string
+	Args  []interface{}
+}
+
+// joinBookFilters combines the filters into one WHERE clause, leaving out the filters of the
+// given facet
+func joinBookFilters(filters []bookFilter, excludeFacet string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	for _, filter := range filters {
+		if excludeFacet != "" && filter.Facet == excludeFacet {
+			continue
+		}
+		conds = append(conds, filter.Cond)
+		args = append(args, filter.Args...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// bookFacet is a column of the catalog that can be counted per value
+type bookFacet struct {
+	Name   string
+	Column string
+	Join   string // Join that provides the label, if any
+	Label  string // Label expression, e.g. the name of the related master data
+}
+
+var bookFacets = []bookFacet{
+	{Name: "bidang_studi_id", Column: "books.bidang_studi_id", Join: "LEFT JOIN bidang_studi ON bidang_studi.id = books.bidang_studi_id", Label: "bidang_studi.name"},
+	{Name: "jenis_buku_id", Column: "books.jenis_buku_id", Join: "LEFT JOIN jenis_buku ON jenis_buku.id = books.jenis_buku_id", Label: "jenis_buku.name"},
+	{Name: "jenjang_studi_id", Column: "books.jenjang_studi_id", Join: "LEFT JOIN jenjang_studi ON jenjang_studi.id = books.jenjang_studi_id", Label: "jenjang_studi.name"},
+	{Name: "curriculum_id", Column: "books.curriculum_id", Join: "LEFT JOIN curriculum ON curriculum.id = books.curriculum_id", Label: "curriculum.name"},
+	{Name: "publisher_id", Column: "books.publisher_id", Join: "LEFT JOIN publishers ON publishers.id = books.publisher_id", Label: "publishers.name"},
+	{Name: "merk_buku_id", Column: "books.merk_buku_id", Join: "LEFT JOIN merk_buku ON merk_buku.id = books.merk_buku_id", Label: "merk_buku.name"},
+	{Name: "periode", Column: "books.periode"},
+	{Name: "year", Column: "books.year"},
+	// Kelas codes are only unique among kelas that are not deleted, a deleted one would count its books twice
+	{Name: "kelas", Column: "books.kelas", Join: "LEFT JOIN kelas ON kelas.code = books.kelas AND kelas.deleted_at IS NULL", Label: "kelas.name"},
+}
+
+// bookPriceFacetBounds are the upper bounds of the price ranges, in rupiah. The last range has no
+// upper bound.
+var bookPriceFacetBounds = []float64{25000, 50000, 100000, 200000}
+
+// BookFacetValue is the number of books with one value of a facet. Value is nil for books
+// without a value, e.g. without a publisher.
+type BookFacetValue struct {
+	Value *string `json:"value"`
+	Label *string `json:"label,omitempty"`
+	Count int64   `json:"count"`
+}
+
+// BookPriceRange is the number of books priced from From up to, but not including, To
+type BookPriceRange struct {
+	From  float64  `json:"from"`
+	To    *float64 `json:"to"`
+	Count int64    `json:"count"`
+}
+
+// BookPriceFacet summarizes the prices of the matching books
+type BookPriceFacet struct {
+	Min    *float64         `json:"min"`
+	Max    *float64         `json:"max"`
+	Ranges []BookPriceRange `json:"ranges"`
+}
+
+// bookCatalog starts a query on the books table, without deleted books unless withDeleted is set
+func bookCatalog(withDeleted bool) *gorm.DB {
+	if withDeleted {
+		return config.DB.Table("books")
+	}
+	return config.DB.Table("books").Where("books.deleted_at IS NULL")
+}
+
+// bookFacetCounts counts the books per value of every facet. Each facet is counted with all
+// filters applied except its own, so the counts show what selecting another value would return.
+func bookFacetCounts(filters []bookFilter, withDeleted bool) (map[string]interface{}, error) {
+	facets := make(map[string]interface{}, len(bookFacets)+1)
+
+	for _, facet := range bookFacets {
+		selectClause := facet.Column + " AS value, NULL AS label, COUNT(*) AS count"
+		groupClause := facet.Column
+		if facet.Label != "" {
+			selectClause = facet.Column + " AS value, " + facet.Label + " AS label, COUNT(*) AS count"
+			groupClause += ", " + facet.Label
+		}
+
+		query := bookCatalog(withDeleted).Select(selectClause)
+		if facet.Join != "" {
+			query = query.Joins(facet.Join)
+		}
+		if whereClause, args := joinBookFilters(filters, facet.Name); whereClause != "" {
+			query = query.Where(whereClause, args...)
+		}
+
+		values := []BookFacetValue{}
+		if err := query.Group(groupClause).Order("count DESC, value").Scan(&values).Error; err != nil {
+			return nil, fmt.Errorf("facet %s: %w", facet.Name, err)
+		}
+		facets[facet.Name] = values
+	}
+
+	price, err := bookPriceFacet(filters, withDeleted)
+	if err != nil {
+		return nil, err
+	}
+	facets["price"] = price
+
+	return facets, nil
+}
+
+// bookPriceFacet counts the matching books per price range in one query
+func bookPriceFacet(filters []bookFilter, withDeleted bool) (BookPriceFacet, error) {
+	columns := []string{"MIN(books.price)", "MAX(books.price)"}
+	var from float64
+	for _, to := range bookPriceFacetBounds {
+		columns = append(columns, fmt.Sprintf("COUNT(*) FILTER (WHERE books.price >= %g AND books.price < %g)", from, to))
+		from = to
+	}
+	columns = append(columns, fmt.Sprintf("COUNT(*) FILTER (WHERE books.price >= %g)", from))
+
+	query := bookCatalog(withDeleted).Select(strings.Join(columns, ", "))
+	if whereClause, args := joinBookFilters(filters, "price"); whereClause != "" {
+		query = query.Where(whereClause, args...)
+	}
+
+	var min, max sql.NullFloat64
+	counts := make([]int64, len(bookPriceFacetBounds)+1)
+	dest := []interface{}{&min, &max}
+	for i := range counts {
+		dest = append(dest, &counts[i])
+	}
+	if err := query.Row().Scan(dest...); err != nil {
+		return BookPriceFacet{}, fmt.Errorf("facet price: %w", err)
+	}
+
+	facet := BookPriceFacet{Ranges: make([]BookPriceRange, len(counts))}
+	if min.Valid {
+		facet.Min = &min.Float64
+		facet.Max = &max.Float64
+	}
+	from = 0
+	for i, count := range counts {
+		facet.Ranges[i] = BookPriceRange{From: from, Count: count}
+		if i < len(bookPriceFacetBounds) {
+			to := bookPriceFacetBounds[i]
+			facet.Ranges[i].To = &to
+			from = to
+		}
+	}
+	return facet, nil
+}
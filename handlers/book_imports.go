@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// bookImportColumns lists the columns of the book import file in template order
+var bookImportColumns = []string{
+	"code", "isbn", "name", "description", "author", "year", "periode", "kelas", "no_pages", "price",
+	"min_stock", "reorder_quantity", "merk_buku_code", "jenis_buku_code", "jenjang_studi_code",
+	"bidang_studi_code", "curriculum_code", "publisher_code",
+}
+
+// bookImportRelation maps a *_code column of the import file to its master table and book column
+type bookImportRelation struct {
+	Column   string
+	Table    string
+	BookAttr string
+}
+
+var bookImportRelations = []bookImportRelation{
+	{Column: "merk_buku_code", Table: "merk_buku", BookAttr: "merk_buku_id"},
+	{Column: "jenis_buku_code", Table: "jenis_buku", BookAttr: "jenis_buku_id"},
+	{Column: "jenjang_studi_code", Table: "jenjang_studi", BookAttr: "jenjang_studi_id"},
+	{Column: "bidang_studi_code", Table: "bidang_studi", BookAttr: "bidang_studi_id"},
+	{Column: "curriculum_code", Table: "curriculum", BookAttr: "curriculum_id"},
+	{Column: "publisher_code", Table: "publishers", BookAttr: "publisher_id"},
+}
+
+// bookImportMaster is a master data row resolved by its code
+type bookImportMaster struct {
+	ID   uuid.UUID
+	Code string
+	Name string
+}
+
+// BookImportRowResult reports what the import does with one row of the file
+type BookImportRowResult struct {
+	Row    int        `json:"row"`    // Row number in the file, the header is row 1
+	Action string     `json:"action"` // create, update or error
+	BookID *uuid.UUID `json:"book_id,omitempty"`
+	Code   *string    `json:"code,omitempty"`
+	ISBN   *string    `json:"isbn,omitempty"`
+	Name   string     `json:"name"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+// bookImportOperation is a validated row waiting to be written
+type bookImportOperation struct {
+	result  *BookImportRowResult
+	book    models.Book            // Book to create
+	updates map[string]interface{} // Columns to update on an existing book
+}
+
+// ImportBooks godoc
+// @Summary Import books from a spreadsheet
+// @Description Create or update books from an XLSX or CSV file laid out like the import template. Rows are matched to existing books by code, then by ISBN; unmatched rows create new books. Relations are resolved by the code of merk buku, jenis buku, jenjang studi, bidang studi, curriculum and publisher. Empty cells leave existing values unchanged. Stock is not imported, use a goods receipt or stock opname instead. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags Books
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "XLSX or CSV file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/import [post]
+func ImportBooks(c *fiber.Ctx) error {
+	rows, message := readImportUpload(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	dryRun := c.FormValue("dry_run", "true") != "false"
+
+	columns, message := mapImportColumns(rows[0], bookImportColumns)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":            message,
+			"expected_columns": bookImportColumns,
+		})
+	}
+
+	_, hasCode := columns["code"]
+	_, hasISBN := columns["isbn"]
+	if !hasCode && !hasISBN {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The file needs a code or isbn column to match existing books",
+		})
+	}
+
+	cell := func(row []string, column string) string {
+		return importCell(columns, row, column)
+	}
+
+	dataRows := importDataRows(rows)
+	if len(dataRows) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The file has no data rows",
+		})
+	}
+
+	// Resolve the master data referenced by code, one query per relation
+	masters := make(map[string]map[string]bookImportMaster)
+	for _, relation := range bookImportRelations {
+		if _, ok := columns[relation.Column]; !ok {
+			continue
+		}
+
+		codeSet := make(map[string]bool)
+		var codes []string
+		for _, row := range dataRows {
+			code := strings.ToUpper(cell(row.Values, relation.Column))
+			if code != "" && !codeSet[code] {
+				codeSet[code] = true
+				codes = append(codes, code)
+			}
+		}
+		if len(codes) == 0 {
+			continue
+		}
+
+		var found []bookImportMaster
+		if err := config.DB.Table(relation.Table).Select("id, code, name").Where("UPPER(code) IN ? AND deleted_at IS NULL", codes).Scan(&found).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve " + relation.Column,
+			})
+		}
+
+		byCode := make(map[string]bookImportMaster, len(found))
+		for _, master := range found {
+			byCode[strings.ToUpper(master.Code)] = master
+		}
+		masters[relation.Column] = byCode
+	}
+
+	// Load the existing books the rows may refer to
+	var bookCodes, bookISBNs []string
+	for _, row := range dataRows {
+		if code := cell(row.Values, "code"); code != "" {
+			bookCodes = append(bookCodes, code)
+		}
+		if isbn := helpers.NormalizeISBN(cell(row.Values, "isbn")); isbn != "" {
+			bookISBNs = append(bookISBNs, isbn)
+		}
+	}
+
+	var existingBooks []models.Book
+	if len(bookCodes) > 0 || len(bookISBNs) > 0 {
+		query := config.DB.Select("id, code, isbn, name")
+		switch {
+		case len(bookCodes) > 0 && len(bookISBNs) > 0:
+			query = query.Where("code IN ? OR isbn IN ?", bookCodes, bookISBNs)
+		case len(bookCodes) > 0:
+			query = query.Where("code IN ?", bookCodes)
+		default:
+			query = query.Where("isbn IN ?", bookISBNs)
+		}
+		if err := query.Find(&existingBooks).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load existing books",
+			})
+		}
+	}
+
+	booksByCode := make(map[string]models.Book)
+	booksByISBN := make(map[string][]models.Book)
+	for _, book := range existingBooks {
+		if book.Code != nil {
+			booksByCode[*book.Code] = book
+		}
+		if book.ISBN != nil {
+			booksByISBN[*book.ISBN] = append(booksByISBN[*book.ISBN], book)
+		}
+	}
+
+	// Validate every row
+	codeRows := make(map[string]int)
+	isbnRows := make(map[string]int)
+	results := make([]*BookImportRowResult, 0, len(dataRows))
+	var operations []bookImportOperation
+	failed := 0
+
+	for _, row := range dataRows {
+		result := &BookImportRowResult{Row: row.Number, Name: cell(row.Values, "name")}
+		results = append(results, result)
+
+		var errs []string
+		updates := make(map[string]interface{})
+
+		code := cell(row.Values, "code")
+		if code != "" {
+			if message := validateBookCode(code); message != "" {
+				errs = append(errs, message)
+			} else if previous, ok := codeRows[code]; ok {
+				errs = append(errs, fmt.Sprintf("Code %s is also used on row %d", code, previous))
+			} else {
+				codeRows[code] = row.Number
+			}
+			result.Code = &code
+			updates["code"] = code
+		}
+
+		if rawISBN := cell(row.Values, "isbn"); rawISBN != "" {
+			isbn := helpers.NormalizeISBN(rawISBN)
+			if !helpers.IsValidISBN(isbn) {
+				errs = append(errs, fmt.Sprintf("Invalid ISBN %s", rawISBN))
+			} else if previous, ok := isbnRows[isbn]; ok {
+				errs = append(errs, fmt.Sprintf("ISBN %s is also used on row %d", rawISBN, previous))
+			} else {
+				isbnRows[isbn] = row.Number
+			}
+			result.ISBN = &isbn
+			updates["isbn"] = isbn
+		}
+
+		if code == "" && result.ISBN == nil {
+			errs = append(errs, "Code or ISBN is required")
+		}
+
+		// Find the book this row updates
+		var existing *models.Book
+		if book, ok := booksByCode[code]; ok && code != "" {
+			existing = &book
+		} else if result.ISBN != nil {
+			switch matches := booksByISBN[*result.ISBN]; {
+			case len(matches) == 1:
+				existing = &matches[0]
+			case len(matches) > 1:
+				errs = append(errs, "ISBN matches several books, add a code to choose one")
+			}
+		}
+		if existing != nil && result.ISBN != nil {
+			for _, other := range booksByISBN[*result.ISBN] {
+				if other.ID != existing.ID {
+					errs = append(errs, fmt.Sprintf("ISBN already belongs to another book (%s)", other.Name))
+					break
+				}
+			}
+		}
+
+		for _, column := range []string{"description", "author"} {
+			if value := cell(row.Values, column); value != "" {
+				updates[column] = value
+			}
+		}
+		if result.Name != "" {
+			updates["name"] = result.Name
+		}
+		if year := cell(row.Values, "year"); year != "" {
+			updates["year"] = year
+		}
+		if kelas := cell(row.Values, "kelas"); kelas != "" {
+			if len(kelas) > 5 {
+				errs = append(errs, "kelas cannot be longer than 5 characters")
+			}
+			updates["kelas"] = kelas
+		}
+
+		for _, column := range []string{"periode", "no_pages", "min_stock", "reorder_quantity"} {
+			value := cell(row.Values, column)
+			if value == "" {
+				continue
+			}
+			minimum := 0
+			if column == "periode" || column == "no_pages" {
+				minimum = 1
+			}
+			number, err := strconv.Atoi(value)
+			if err != nil || number < minimum {
+				errs = append(errs, fmt.Sprintf("%s must be a whole number of at least %d", column, minimum))
+				continue
+			}
+			updates[column] = number
+		}
+
+		if value := cell(row.Values, "price"); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				errs = append(errs, "price must be a number of at least 0")
+			} else {
+				updates["price"] = price
+			}
+		}
+
+		var bidangStudiName string
+		for _, relation := range bookImportRelations {
+			value := cell(row.Values, relation.Column)
+			if value == "" {
+				continue
+			}
+			master, ok := masters[relation.Column][strings.ToUpper(value)]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("Unknown %s %s", relation.Column, value))
+				continue
+			}
+			updates[relation.BookAttr] = master.ID
+			if relation.Column == "bidang_studi_code" {
+				bidangStudiName = master.Name
+			}
+		}
+
+		if existing == nil {
+			// Same rules as CreateBook: the name falls back to the bidang studi name
+			if result.Name == "" && bidangStudiName != "" {
+				result.Name = bidangStudiName
+				updates["name"] = bidangStudiName
+			}
+			if result.Name == "" {
+				errs = append(errs, "Name is required (provide name or bidang_studi_code)")
+			}
+			if _, ok := updates["year"]; !ok {
+				errs = append(errs, "Year is required for new books")
+			}
+			if _, ok := updates["price"]; !ok && cell(row.Values, "price") == "" {
+				errs = append(errs, "Price is required for new books")
+			}
+		}
+
+		if len(errs) > 0 {
+			result.Action = "error"
+			result.Errors = errs
+			failed++
+			continue
+		}
+
+		operation := bookImportOperation{result: result}
+		if existing != nil {
+			id := existing.ID
+			result.Action = "update"
+			result.BookID = &id
+			if result.Name == "" {
+				result.Name = existing.Name
+			}
+			operation.updates = updates
+		} else {
+			result.Action = "create"
+			operation.book = newImportedBook(updates)
+		}
+		operations = append(operations, operation)
+	}
+
+	created, updated := 0, 0
+	for _, operation := range operations {
+		if operation.result.Action == "create" {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	report := fiber.Map{
+		"dry_run":    dryRun,
+		"total_rows": len(results),
+		"created":    created,
+		"updated":    updated,
+		"failed":     failed,
+		"rows":       results,
+	}
+
+	if dryRun {
+		return c.JSON(report)
+	}
+	if failed > 0 {
+		report["error"] = "Some rows have errors, nothing was imported"
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		for _, operation := range operations {
+			if operation.result.Action == "create" {
+				book := operation.book
+				if err := tx.Create(&book).Error; err != nil {
+					return err
+				}
+				operation.result.BookID = &book.ID
+				continue
+			}
+
+			if err := tx.Model(&models.Book{}).Where("id = ?", *operation.result.BookID).Updates(operation.updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to import books",
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// newImportedBook builds a new book from the validated columns of an import row
+func newImportedBook(values map[string]interface{}) models.Book {
+	book := models.Book{Periode: 1, NoPages: 1}
+
+	optionalString := func(column string) *string {
+		if value, ok := values[column].(string); ok {
+			return &value
+		}
+		return nil
+	}
+	optionalID := func(column string) *uuid.UUID {
+		if value, ok := values[column].(uuid.UUID); ok {
+			return &value
+		}
+		return nil
+	}
+
+	book.Name, _ = values["name"].(string)
+	book.Year, _ = values["year"].(string)
+	book.Price, _ = values["price"].(float64)
+	book.Code = optionalString("code")
+	book.ISBN = optionalString("isbn")
+	book.Description = optionalString("description")
+	book.Author = optionalString("author")
+	book.Kelas = optionalString("kelas")
+	if periode, ok := values["periode"].(int); ok {
+		book.Periode = periode
+	}
+	if noPages, ok := values["no_pages"].(int); ok {
+		book.NoPages = noPages
+	}
+	book.MinStock, _ = values["min_stock"].(int)
+	book.ReorderQuantity, _ = values["reorder_quantity"].(int)
+	book.MerkBukuID = optionalID("merk_buku_id")
+	book.JenisBukuID = optionalID("jenis_buku_id")
+	book.JenjangStudiID = optionalID("jenjang_studi_id")
+	book.BidangStudiID = optionalID("bidang_studi_id")
+	book.CurriculumID = optionalID("curriculum_id")
+	book.PublisherID = optionalID("publisher_id")
+
+	return book
+}
+
+// GetBookImportTemplate godoc
+// @Summary Download the book import template
+// @Description Download an empty import file with the expected columns and one example row
+// @Tags Books
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce text/csv
+// @Security BearerAuth
+// @Param format query string false "File format: xlsx or csv (default: xlsx)"
+// @Success 200 {file} file "Import template"
+// @Failure 400 {object} map[string]interface{} "Invalid format"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/import/template [get]
+func GetBookImportTemplate(c *fiber.Ctx) error {
+	example := []interface{}{
+		"MTK-SD1-2025", "9786020324784", "Matematika Kelas 1", "Buku siswa", "Tim Penulis", "2025", 1, "1", 120, 50000,
+		10, 50, "MERK-CODE", "JENIS-CODE", "JENJANG-CODE", "BIDANG-CODE", "CURRICULUM-CODE", "PUBLISHER-CODE",
+	}
+
+	var buf bytes.Buffer
+	switch c.Query("format", "xlsx") {
+	case "xlsx":
+		writer, err := helpers.NewXLSXWriter(&buf, "Books", bookImportColumns)
+		if err == nil {
+			err = writer.WriteRow(example)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate template",
+			})
+		}
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="book_import_template.xlsx"`)
+	case "csv":
+		writer := csv.NewWriter(&buf)
+		record := make([]string, len(example))
+		for i, value := range example {
+			record[i] = fmt.Sprint(value)
+		}
+		writer.Write(bookImportColumns)
+		writer.Write(record)
+		writer.Flush()
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="book_import_template.csv"`)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be xlsx or csv",
+		})
+	}
+
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTypeaheadLimit = 10
+	maxTypeaheadLimit     = 25
+)
+
+// BookSuggestion is a typeahead match, with only the columns needed to show and pick a book
+type BookSuggestion struct {
+	ID      uuid.UUID `json:"id"`
+	Code    *string   `json:"code"`
+	ISBN    *string   `json:"isbn"`
+	Name    string    `json:"name"`
+	Author  *string   `json:"author"`
+	Year    string    `json:"year"`
+	Periode int       `json:"periode"`
+	Price   float64   `json:"price"`
+	Stock   int       `json:"stock"`
+}
+
+// GetBookSuggestions godoc
+// @Summary Typeahead search for books
+// @Description Return the best matching books for search-as-you-type. Every word matches as a prefix, so "mat kel" finds "Matematika Kelas 1". Common Indonesian stopwords are ignored. Only a few columns are returned to keep the response small.
+// @Tags Books
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Text typed so far"
+// @Param limit query int false "Number of suggestions, 1-25 (default: 10)"
+// @Success 200 {object} map[string]interface{} "Suggestions ordered by relevance"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/typeahead [get]
+func GetBookSuggestions(c *fiber.Ctx) error {
+	suggestions := []BookSuggestion{}
+
+	tsQuery := helpers.BuildTSQuery(c.Query("q"), true)
+	if tsQuery == "" {
+		return c.JSON(fiber.Map{
+			"suggestions": suggestions,
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultTypeaheadLimit)
+	if limit < 1 || limit > maxTypeaheadLimit {
+		limit = defaultTypeaheadLimit
+	}
+
+	err := config.DB.Table("books").
+		Select("id, code, isbn, name, author, year, periode, price, stock, ts_rank_cd(search_vector, to_tsquery('"+helpers.SearchConfig+"', ?)) AS search_rank", tsQuery).
+		Where("search_vector @@ to_tsquery('"+helpers.SearchConfig+"', ?) AND deleted_at IS NULL", tsQuery).
+		Order("search_rank DESC, name").
+		Limit(limit).
+		Scan(&suggestions).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search books",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"suggestions": suggestions,
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"pustaka-backend/helpers"
 	"pustaka-backend/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetAllBooks godoc
@@ -15,10 +17,12 @@ import (
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param search query string false "Search by name or description"
+// @Param search query string false "Full-text search on name, author, ISBN, code, description, bidang studi and jenjang studi, ranked by relevance unless sort_by is given"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
-// @Param code query string false "Filter by book code"
+// @Param include_deleted query bool false "Also list deleted books (default: false)"
+// @Param code query string false "Partial match on book code (SKU)"
+// @Param isbn query string false "Partial match on ISBN, hyphens are ignored"
 // @Param bidang_studi_id query string false "Filter by bidang studi ID"
 // @Param jenis_buku_id query string false "Filter by jenis buku ID"
 // @Param jenjang_studi_id query string false "Filter by jenjang studi ID"
@@ -32,6 +36,8 @@ import (
 // @Param price_max query number false "Maximum price filter"
 // @Param sort_by query string false "Field to sort by (name, price, stock, year, periode, no_pages, created_at, updated_at)"
 // @Param sort_order query string false "Sort order: asc or desc (default: desc)"
+// @Param facets query bool false "Also return book counts per bidang studi, jenis buku, jenjang studi, curriculum, publisher, merk buku, periode, year, kelas and price range. Each facet is counted with all other filters applied but not its own."
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all books with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -42,8 +48,11 @@ func GetAllBooks(c *fiber.Ctx) error {
 	// Get pagination parameters
 	pagination := helpers.GetPaginationParams(c)
 
-	conds := []string{}
-	args := []interface{}{}
+	// Each filter remembers its facet so facet counts can leave out their own filter
+	filters := []bookFilter{}
+	addFilter := func(facet, cond string, arg interface{}) {
+		filters = append(filters, bookFilter{Facet: facet, Cond: cond, Args: []interface{}{arg}})
+	}
 
 	// Whitelist of valid sortable fields to prevent SQL injection
 	validSortFields := map[string]string{
@@ -73,111 +82,123 @@ func GetAllBooks(c *fiber.Ctx) error {
 	}
 
 	orderClause := sortField + " " + strings.ToUpper(sortOrder)
-	query := config.DB.Order(orderClause)
+	query := config.DB
 	queryCount := config.DB.Model(&models.Book{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
+	// Full-text search, ranked by relevance unless another sort order is requested
+	if tsQuery := helpers.BuildTSQuery(c.Query("search"), false); tsQuery != "" {
+		addFilter("", "books.search_vector @@ to_tsquery('"+helpers.SearchConfig+"', ?)", tsQuery)
+		if c.Query("sort_by") == "" {
+			query = query.
+				Select("books.*, ts_rank_cd(books.search_vector, to_tsquery('"+helpers.SearchConfig+"', ?)) AS search_rank", tsQuery).
+				Order("search_rank DESC")
+		}
+	} else if searchQuery := c.Query("search"); searchQuery != "" {
+		// Only stopwords were given, fall back to a plain match on the name
+		addFilter("", "books.name ILIKE ?", "%"+searchQuery+"%")
+	}
+	query = query.Order(orderClause)
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
 		pagination.Offset = 0 // No offset
 	}
 
-	// Filter search (name or description)
-	if searchQuery := c.Query("search"); searchQuery != "" {
-		searchTerm := "%" + searchQuery + "%"
-		conds = append(conds, "(books.name ILIKE ? OR books.description ILIKE ?)")
-		args = append(args, searchTerm, searchTerm)
-	}
-
-	// Filter by code (if books have a code field - using name for now as fallback)
+	// Filter by book code (SKU)
 	if code := c.Query("code"); code != "" {
 		searchTerm := "%" + code + "%"
-		conds = append(conds, "books.name ILIKE ?")
-		args = append(args, searchTerm)
+		addFilter("", "books.code ILIKE ?", searchTerm)
+	}
+
+	// Filter by ISBN, with or without hyphens
+	if isbn := c.Query("isbn"); isbn != "" {
+		addFilter("", "books.isbn LIKE ?", "%"+helpers.NormalizeISBN(isbn)+"%")
 	}
 
 	// Filter bidang_studi_id
 	if bidangStudiId := c.Query("bidang_studi_id"); bidangStudiId != "" {
-		conds = append(conds, "books.bidang_studi_id = ?")
-		args = append(args, bidangStudiId)
+		addFilter("bidang_studi_id", "books.bidang_studi_id = ?", bidangStudiId)
 	}
 
 	// Filter jenis_buku_id
 	if jenisBukuId := c.Query("jenis_buku_id"); jenisBukuId != "" {
-		conds = append(conds, "books.jenis_buku_id = ?")
-		args = append(args, jenisBukuId)
+		addFilter("jenis_buku_id", "books.jenis_buku_id = ?", jenisBukuId)
 	}
 
 	// Filter jenjang_studi_id
 	if jenjangStudiId := c.Query("jenjang_studi_id"); jenjangStudiId != "" {
-		conds = append(conds, "books.jenjang_studi_id = ?")
-		args = append(args, jenjangStudiId)
+		addFilter("jenjang_studi_id", "books.jenjang_studi_id = ?", jenjangStudiId)
 	}
 
 	// Filter curriculum_id
 	if curriculumId := c.Query("curriculum_id"); curriculumId != "" {
-		conds = append(conds, "books.curriculum_id = ?")
-		args = append(args, curriculumId)
+		addFilter("curriculum_id", "books.curriculum_id = ?", curriculumId)
 	}
 
 	// Filter publisher_id
 	if publisherId := c.Query("publisher_id"); publisherId != "" {
-		conds = append(conds, "books.publisher_id = ?")
-		args = append(args, publisherId)
+		addFilter("publisher_id", "books.publisher_id = ?", publisherId)
 	}
 
 	// Filter merk_buku_id
 	if merkBukuId := c.Query("merk_buku_id"); merkBukuId != "" {
-		conds = append(conds, "books.merk_buku_id = ?")
-		args = append(args, merkBukuId)
+		addFilter("merk_buku_id", "books.merk_buku_id = ?", merkBukuId)
 	}
 
 	// Filter periode
 	if periode := c.Query("periode"); periode != "" {
-		conds = append(conds, "books.periode = ?")
-		args = append(args, periode)
+		addFilter("periode", "books.periode = ?", periode)
 	}
 
 	// Filter year
 	if year := c.Query("year"); year != "" {
-		conds = append(conds, "books.year = ?")
-		args = append(args, year)
+		addFilter("year", "books.year = ?", year)
 	}
 
 	// Filter kelas (CHAR(5) code)
 	if kelas := c.Query("kelas"); kelas != "" {
-		conds = append(conds, "books.kelas = ?")
-		args = append(args, kelas)
+		addFilter("kelas", "books.kelas = ?", kelas)
 	}
 
 	// Filter price range
 	if priceMin := c.Query("price_min"); priceMin != "" {
-		conds = append(conds, "books.price >= ?")
-		args = append(args, priceMin)
+		addFilter("price", "books.price >= ?", priceMin)
 	}
 
 	if priceMax := c.Query("price_max"); priceMax != "" {
-		conds = append(conds, "books.price <= ?")
-		args = append(args, priceMax)
+		addFilter("price", "books.price <= ?", priceMax)
 	}
 
 	// Apply all conditions
-	if len(conds) > 0 {
-		whereClause := strings.Join(conds, " AND ")
+	if whereClause, args := joinBookFilters(filters, ""); whereClause != "" {
 		query = query.Where(whereClause, args...)
 		queryCount = queryCount.Where(whereClause, args...)
 	}
 
+	query = query.
+		Preload("MerkBuku", withDeleted).
+		Preload("JenisBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("BidangStudi", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("Publisher", withDeleted).
+		Preload("Publisher.City", withDeleted)
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.Book{}, "books")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.
 		Offset(pagination.Offset).Limit(pagination.Limit).
-		Preload("MerkBuku").
-		Preload("JenisBuku").
-		Preload("JenjangStudi").
-		Preload("BidangStudi").
-		Preload("Curriculum").
-		Preload("Publisher").
-		Preload("Publisher.City").
 		Find(&books).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch all books",
@@ -192,6 +213,17 @@ func GetAllBooks(c *fiber.Ctx) error {
 		})
 	}
 
+	// Count the books per facet value for the current filters
+	if c.Query("facets") == "true" {
+		facets, err := bookFacetCounts(filters, includeDeleted(c))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count book facets",
+			})
+		}
+		response["facets"] = facets
+	}
+
 	return c.JSON(response)
 }
 
@@ -212,19 +244,23 @@ func GetBook(c *fiber.Ctx) error {
 
 	var book models.Book
 	if err := config.DB.
-		Preload("MerkBuku").
-		Preload("JenisBuku").
-		Preload("JenjangStudi").
-		Preload("BidangStudi").
-		Preload("Curriculum").
-		Preload("Publisher").
-		Preload("Publisher.City").
+		Preload("MerkBuku", withDeleted).
+		Preload("JenisBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("BidangStudi", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("Publisher", withDeleted).
+		Preload("Publisher.City", withDeleted).
+		Preload("PreviousEdition", withDeleted).
 		Where("id = ?", id).First(&book).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Book not found",
 		})
 	}
 
+	// Attach stock per warehouse
+	config.DB.Preload("Warehouse", withDeleted).Where("book_id = ?", book.ID).Find(&book.Stocks)
+
 	return c.JSON(fiber.Map{
 		"book": book,
 	})
@@ -232,7 +268,7 @@ func GetBook(c *fiber.Ctx) error {
 
 // CreateBook godoc
 // @Summary Create a new book
-// @Description Create a new book entry. If name is empty and bidang_studi_id is provided, name will be auto-populated from bidang_studi.name
+// @Description Create a new book entry. If name is empty and bidang_studi_id is provided, name will be auto-populated from bidang_studi.name. Initial stock is placed in the default warehouse. average_cost is ignored, it is set by goods receipts. The ISBN must have a valid check digit and the code must be unique.
 // @Tags Books
 // @Accept json
 // @Produce json
@@ -266,7 +302,34 @@ func CreateBook(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&book).Error; err != nil {
+	if status, message := validateBookIdentifiers(&book, nil, nil); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Initial stock is received into the default warehouse so per-warehouse stock stays in sync
+	initialStock := book.Stock
+	book.Stock = 0
+
+	// Average cost only moves with goods receipts, so clients cannot seed the valuation
+	book.AverageCost = 0
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&book).Error; err != nil {
+			return err
+		}
+		if initialStock <= 0 {
+			return nil
+		}
+
+		warehouse, err := getDefaultWarehouse(tx)
+		if err != nil {
+			return err
+		}
+		return adjustWarehouseStock(tx, book.ID, warehouse.ID, initialStock)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create book",
 		})
@@ -274,12 +337,12 @@ func CreateBook(c *fiber.Ctx) error {
 
 	// Fetch created book with relations
 	config.DB.
-		Preload("MerkBuku").
-		Preload("JenisBuku").
-		Preload("JenjangStudi").
-		Preload("BidangStudi").
-		Preload("Curriculum").
-		Preload("Publisher").
+		Preload("MerkBuku", withDeleted).
+		Preload("JenisBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("BidangStudi", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("Publisher", withDeleted).
 		Where("id = ?", book.ID).First(&book)
 
 	return c.Status(fiber.StatusCreated).JSON(book)
@@ -287,7 +350,7 @@ func CreateBook(c *fiber.Ctx) error {
 
 // UpdateBook godoc
 // @Summary Update a book
-// @Description Update an existing book by ID
+// @Description Update an existing book by ID. Fields left out of the body keep their value, fields sent as null (e.g. code or isbn) are cleared. Stock cannot be changed here, use a stock opname instead.
 // @Tags Books
 // @Accept json
 // @Produce json
@@ -310,13 +373,33 @@ func UpdateBook(c *fiber.Ctx) error {
 		})
 	}
 
+	currentStock := book.Stock
+	// BodyParser writes through the pointers of the loaded book, so compare against copies
+	currentISBN, currentCode := copyOptionalString(book.ISBN), copyOptionalString(book.Code)
 	if err := c.BodyParser(&book); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body",
 		})
 	}
 
-	if err := config.DB.Model(&book).Updates(book).Error; err != nil {
+	// Stock only changes through transactions, transfers and stock opname
+	if book.Stock != currentStock {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Stock cannot be edited directly, use a stock opname to adjust stock",
+		})
+	}
+
+	if status, message := validateBookIdentifiers(&book, currentISBN, currentCode); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	// Every column is written so a field sent as null is cleared; fields left out of the body
+	// keep the loaded value. The average cost is maintained by goods receipts.
+	if err := auditedDB(c).Model(&book).Select("*").
+		Omit("id", "stock", "average_cost", "created_at", "deleted_at", clause.Associations).
+		Updates(book).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update book",
 		})
@@ -327,7 +410,7 @@ func UpdateBook(c *fiber.Ctx) error {
 
 // DeleteBook godoc
 // @Summary Delete a book
-// @Description Delete a book by ID
+// @Description Soft delete a book by ID. Deleted books are hidden from lists and lookups and can be restored. Books on open sales or purchase transactions, pending stock transfers, open stock opnames, pending draft orders or active bundles cannot be deleted.
 // @Tags Books
 // @Accept json
 // @Produce json
@@ -336,25 +419,36 @@ func UpdateBook(c *fiber.Ctx) error {
 // @Success 200 {object} map[string]interface{} "Book deleted successfully"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 404 {object} map[string]interface{} "Book not found"
+// @Failure 409 {object} map[string]interface{} "Book is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/books/{id} [delete]
 func DeleteBook(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Book{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete book",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var book models.Book
+	if err := config.DB.Where("id = ?", id).First(&book).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Book not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Book deleted successfully",
-	})
+	return deleteMasterRecord(c, &book, book.ID, "books", "Book", "Book deleted successfully")
+}
+
+// RestoreBook godoc
+// @Summary Restore a deleted book
+// @Description Undo the soft delete of a book. Fails when another book has taken its code since. Names and ISBNs do not have to be unique and never block a restore.
+// @Tags Books
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Book ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored book"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted book not found"
+// @Failure 409 {object} map[string]interface{} "Code already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/books/{id}/restore [post]
+func RestoreBook(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Book{}, "Book", "book")
 }
@@ -18,6 +18,8 @@ import (
 // @Param search query string false "Search by code or name"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted cities (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all cities with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -31,6 +33,12 @@ func GetAllCities(c *fiber.Ctx) error {
 	query := config.DB.Order("name ASC")
 	queryCount := config.DB.Model(&models.City{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -48,6 +56,11 @@ func GetAllCities(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.City{}, "cities")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&cities).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -114,7 +127,7 @@ func CreateCity(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&city).Error; err != nil {
+	if err := auditedDB(c).Create(&city).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create city",
 		})
@@ -154,7 +167,7 @@ func UpdateCity(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&city).Updates(city).Error; err != nil {
+	if err := auditedDB(c).Model(&city).Updates(city).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update city",
 		})
@@ -165,34 +178,49 @@ func UpdateCity(c *fiber.Ctx) error {
 
 // DeleteCity godoc
 // @Summary Delete a city
-// @Description Delete a city by ID
+// @Description Soft delete a city by ID. Deleted cities are hidden from lists and lookups and can be restored. Cities of publishers, expeditions, sales associates, billers or warehouses cannot be deleted.
 // @Tags Cities
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "City ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the city taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "City deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "City not found"
+// @Failure 409 {object} map[string]interface{} "City is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/cities/{id} [delete]
 func DeleteCity(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.City{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete city",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var city models.City
+	if err := config.DB.Where("id = ?", id).First(&city).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "City not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "City deleted successfully",
-	})
+	return deleteMasterRecord(c, &city, city.ID, "cities", "City", "City deleted successfully")
+}
+
+// RestoreCity godoc
+// @Summary Restore a deleted city
+// @Description Undo the soft delete of a city. Fails when another city has taken its code or name since.
+// @Tags Cities
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "City ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored city"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted city not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/cities/{id}/restore [post]
+func RestoreCity(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.City{}, "City", "city")
 }
@@ -18,7 +18,9 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted curricula (default: false)"
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all curriculum with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -32,6 +34,12 @@ func GetAllCurriculums(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at ASC")
 	queryCount := config.DB.Model(&models.Curriculum{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -49,6 +57,11 @@ func GetAllCurriculums(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.Curriculum{}, "curriculum")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&curriculum).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -115,7 +128,7 @@ func CreateCurriculum(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&curriculum).Error; err != nil {
+	if err := auditedDB(c).Create(&curriculum).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create curriculum",
 		})
@@ -155,7 +168,7 @@ func UpdateCurriculum(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&curriculum).Updates(curriculum).Error; err != nil {
+	if err := auditedDB(c).Model(&curriculum).Updates(curriculum).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update curriculum",
 		})
@@ -166,34 +179,49 @@ func UpdateCurriculum(c *fiber.Ctx) error {
 
 // DeleteCurriculum godoc
 // @Summary Delete a curriculum
-// @Description Delete a curriculum by ID
+// @Description Soft delete a curriculum by ID. Deleted curricula are hidden from lists and lookups and can be restored. Curricula used by books, open sales transactions or pending draft orders cannot be deleted.
 // @Tags Curriculum
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Curriculum ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the curriculum taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "Curriculum deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "Curriculum not found"
+// @Failure 409 {object} map[string]interface{} "Curriculum is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/curriculum/{id} [delete]
 func DeleteCurriculum(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Curriculum{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete curriculum",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var curriculum models.Curriculum
+	if err := config.DB.Where("id = ?", id).First(&curriculum).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Curriculum not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Curriculum deleted successfully",
-	})
+	return deleteMasterRecord(c, &curriculum, curriculum.ID, "curriculum", "Curriculum", "Curriculum deleted successfully")
+}
+
+// RestoreCurriculum godoc
+// @Summary Restore a deleted curriculum
+// @Description Undo the soft delete of a curriculum. Fails when another curriculum has taken its code or name since.
+// @Tags Curriculum
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Curriculum ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored curriculum"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted curriculum not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/curriculum/{id}/restore [post]
+func RestoreCurriculum(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Curriculum{}, "Curriculum", "curriculum")
 }
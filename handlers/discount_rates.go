@@ -41,6 +41,7 @@ func parseYearValue(year any) (string, error) {
 // @Produce json
 // @Security BearerAuth
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of discount rates with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -67,6 +68,11 @@ func GetAllDiscountRates(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.DiscountRate{}, "discount_rates")
+	}
+
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&discountRates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch discount rates",
@@ -200,7 +206,7 @@ func CreateDiscountRate(c *fiber.Ctx) error {
 		Description: req.Description,
 	}
 
-	if err := config.DB.Create(&discountRate).Error; err != nil {
+	if err := auditedDB(c).Create(&discountRate).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create discount rate",
 		})
@@ -296,7 +302,7 @@ func UpdateDiscountRate(c *fiber.Ctx) error {
 	discountRate.EndDate = endDate
 	discountRate.Description = req.Description
 
-	if err := config.DB.Model(&discountRate).Select("name", "discount", "periode", "year", "start_date", "end_date", "description", "updated_at").Updates(discountRate).Error; err != nil {
+	if err := auditedDB(c).Model(&discountRate).Select("name", "discount", "periode", "year", "start_date", "end_date", "description", "updated_at").Updates(discountRate).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update discount rate",
 		})
@@ -321,7 +327,7 @@ func UpdateDiscountRate(c *fiber.Ctx) error {
 func DeleteDiscountRate(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.DiscountRate{}, "id = ?", id)
+	result := auditedDB(c).Delete(&models.DiscountRate{}, "id = ?", id)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete discount rate",
@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted expeditions (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all expeditions with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllExpeditions(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at DESC")
 	queryCount := config.DB.Model(&models.Expedition{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,8 +55,13 @@ func GetAllExpeditions(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("City", withDeleted), models.Expedition{}, "expeditions")
+	}
+
 	// Apply pagination and fetch data
-	if err := query.Preload("City").Offset(pagination.Offset).Limit(pagination.Limit).Find(&expeditions).Error; err != nil {
+	if err := query.Preload("City", withDeleted).Offset(pagination.Offset).Limit(pagination.Limit).Find(&expeditions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch all expeditions",
 		})
@@ -81,7 +94,7 @@ func GetExpedition(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var expedition models.Expedition
-	if err := config.DB.Preload("City").Where("id = ?", id).First(&expedition).Error; err != nil {
+	if err := config.DB.Preload("City", withDeleted).Where("id = ?", id).First(&expedition).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Expedition not found",
 		})
@@ -113,7 +126,7 @@ func CreateExpedition(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&expedition).Error; err != nil {
+	if err := auditedDB(c).Create(&expedition).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create expedition",
 		})
@@ -153,7 +166,7 @@ func UpdateExpedition(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&expedition).Updates(expedition).Error; err != nil {
+	if err := auditedDB(c).Model(&expedition).Updates(expedition).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update expedition",
 		})
@@ -164,34 +177,49 @@ func UpdateExpedition(c *fiber.Ctx) error {
 
 // DeleteExpedition godoc
 // @Summary Delete an expedition
-// @Description Delete an expedition by ID
+// @Description Soft delete an expedition by ID. Deleted expeditions are hidden from lists and lookups and can be restored. Expeditions shipping open sales transactions cannot be deleted.
 // @Tags Expeditions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Expedition ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the expedition taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "Expedition deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "Expedition not found"
+// @Failure 409 {object} map[string]interface{} "Expedition is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/expeditions/{id} [delete]
 func DeleteExpedition(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Expedition{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete expedition",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var expedition models.Expedition
+	if err := config.DB.Where("id = ?", id).First(&expedition).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Expedition not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Expedition deleted successfully",
-	})
+	return deleteMasterRecord(c, &expedition, expedition.ID, "expeditions", "Expedition", "Expedition deleted successfully")
+}
+
+// RestoreExpedition godoc
+// @Summary Restore a deleted expedition
+// @Description Undo the soft delete of an expedition. Fails when another expedition has taken its code or name since.
+// @Tags Expeditions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Expedition ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored expedition"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted expedition not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/expeditions/{id}/restore [post]
+func RestoreExpedition(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Expedition{}, "Expedition", "expedition")
 }
@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errOverReceived is returned when a purchase line would be received beyond its ordered quantity
+var errOverReceived = errors.New("received quantity exceeds the ordered quantity")
+
+// CreateGoodsReceiptRequest represents the request body for receiving goods against a purchase
+type CreateGoodsReceiptRequest struct {
+	ReceiptDate models.Date                 `json:"receipt_date"`
+	Note        *string                     `json:"note"`
+	Items       []CreateGoodsReceiptItemReq `json:"items"`
+}
+
+// CreateGoodsReceiptItemReq represents a received quantity. The purchase line is picked by
+// purchase_transaction_item_id, or by book_id when the line ID is not given.
+type CreateGoodsReceiptItemReq struct {
+	PurchaseTransactionItemID string `json:"purchase_transaction_item_id"`
+	BookID                    string `json:"book_id"`
+	Quantity                  int    `json:"quantity"`
+}
+
+// PurchaseShortfallLine represents a purchase line that was not received in full
+type PurchaseShortfallLine struct {
+	ItemID            uuid.UUID `json:"item_id"`
+	BookID            uuid.UUID `json:"book_id"`
+	BookName          string    `json:"book_name"`
+	OrderedQuantity   int       `json:"ordered_quantity"`
+	ReceivedQuantity  int       `json:"received_quantity"`
+	ShortfallQuantity int       `json:"shortfall_quantity"`
+	ShortfallAmount   float64   `json:"shortfall_amount"`
+}
+
+// generateGoodsReceiptNumber generates sequential goods receipt number: GRN + YYYYMMDD + 8-digit sequence
+// Example: GRN2023120500000001
+func generateGoodsReceiptNumber(db *gorm.DB) (string, error) {
+	prefix := "GRN"
+	dateStr := time.Now().Format("20060102") // YYYYMMDD
+	pattern := prefix + dateStr + "%"
+
+	var maxNumber string
+	err := db.Model(&models.GoodsReceipt{}).
+		Where("no_receipt LIKE ?", pattern).
+		Select("COALESCE(MAX(no_receipt), '')").
+		Scan(&maxNumber).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if maxNumber != "" {
+		// Extract sequence part (last 8 digits)
+		seqStr := maxNumber[len(prefix)+8:] // Skip prefix (3) + date (8)
+		if seq, err := strconv.Atoi(seqStr); err == nil {
+			nextSeq = seq + 1
+		}
+	}
+
+	return fmt.Sprintf("%s%s%08d", prefix, dateStr, nextSeq), nil
+}
+
+// canReceivePurchase reports whether goods can still be received against a purchase
+func canReceivePurchase(status int) bool {
+	return status == models.PurchaseStatusPending || status == models.PurchaseStatusPartiallyReceived
+}
+
+// outstandingQuantity returns the part of a purchase line that has not been received yet
+func outstandingQuantity(item models.PurchaseTransactionItem) int {
+	return item.Quantity - item.ReceivedQuantity
+}
+
+// lockPurchaseForReceipt loads a purchase and its lines inside tx with row locks, so concurrent
+// receipts for the same purchase wait for each other and check against what was received
+func lockPurchaseForReceipt(tx *gorm.DB, id string) (models.PurchaseTransaction, error) {
+	var transaction models.PurchaseTransaction
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Clauses(clause.Locking{Strength: "UPDATE"})
+		}).
+		Where("id = ?", id).
+		First(&transaction).Error
+	return transaction, err
+}
+
+// purchaseShortfall lists the purchase lines that have not been received in full
+func purchaseShortfall(items []models.PurchaseTransactionItem) []PurchaseShortfallLine {
+	lines := []PurchaseShortfallLine{}
+	for _, item := range items {
+		shortfall := outstandingQuantity(item)
+		if shortfall <= 0 {
+			continue
+		}
+
+		line := PurchaseShortfallLine{
+			ItemID:            item.ID,
+			BookID:            item.BookID,
+			OrderedQuantity:   item.Quantity,
+			ReceivedQuantity:  item.ReceivedQuantity,
+			ShortfallQuantity: shortfall,
+			ShortfallAmount:   item.Price * float64(shortfall),
+		}
+		if item.Book != nil {
+			line.BookName = item.Book.Name
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// receiveGoods records a goods receipt for the given quantities per purchase line, updates the
+// average cost of the received books, increases stock in the receiving warehouse, hands the new
+// stock to waiting backorders and moves the purchase to completed once every line has been
+// received in full
+func receiveGoods(tx *gorm.DB, transaction *models.PurchaseTransaction, quantities map[uuid.UUID]int, receiptDate models.Date, note *string) (*models.GoodsReceipt, []BackorderAllocation, error) {
+	// Receive into the chosen warehouse, or the default warehouse if none was chosen
+	warehouseID, err := warehouseIDOrDefault(tx, transaction.WarehouseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	noReceipt, err := generateGoodsReceiptNumber(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if receiptDate.IsZero() {
+		receiptDate = models.Date{Time: time.Now()}
+	}
+
+	receipt := models.GoodsReceipt{
+		NoReceipt:             noReceipt,
+		PurchaseTransactionID: transaction.ID,
+		WarehouseID:           warehouseID,
+		ReceiptDate:           receiptDate,
+		Note:                  note,
+	}
+
+	var receivedBookIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	fullyReceived := true
+	for i := range transaction.Items {
+		item := &transaction.Items[i]
+
+		quantity := quantities[item.ID]
+		if quantity > 0 {
+			if err := receiveAtCost(tx, item.BookID, quantity, item.Price); err != nil {
+				return nil, nil, err
+			}
+			if err := adjustWarehouseStock(tx, item.BookID, warehouseID, quantity); err != nil {
+				return nil, nil, err
+			}
+
+			result := tx.Model(&models.PurchaseTransactionItem{}).
+				Where("id = ? AND received_quantity + ? <= quantity", item.ID, quantity).
+				Update("received_quantity", gorm.Expr("received_quantity + ?", quantity))
+			if result.Error != nil {
+				return nil, nil, result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil, nil, errOverReceived
+			}
+			item.ReceivedQuantity += quantity
+
+			receipt.Items = append(receipt.Items, models.GoodsReceiptItem{
+				PurchaseTransactionItemID: item.ID,
+				BookID:                    item.BookID,
+				Quantity:                  quantity,
+			})
+
+			if !seen[item.BookID] {
+				seen[item.BookID] = true
+				receivedBookIDs = append(receivedBookIDs, item.BookID)
+			}
+		}
+
+		if outstandingQuantity(*item) > 0 {
+			fullyReceived = false
+		}
+	}
+
+	if err := tx.Create(&receipt).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// Hand the received stock to waiting backorders, oldest first
+	allocations := []BackorderAllocation{}
+	for _, bookID := range receivedBookIDs {
+		allocated, err := allocateBackorders(tx, bookID, warehouseID)
+		if err != nil {
+			return nil, nil, err
+		}
+		allocations = append(allocations, allocated...)
+	}
+
+	status := models.PurchaseStatusPartiallyReceived
+	if fullyReceived {
+		status = models.PurchaseStatusCompleted
+	}
+
+	if err := tx.Model(&models.PurchaseTransaction{ID: transaction.ID}).Updates(map[string]interface{}{
+		"status":       status,
+		"warehouse_id": warehouseID,
+	}).Error; err != nil {
+		return nil, nil, err
+	}
+	transaction.Status = status
+	transaction.WarehouseID = &warehouseID
+
+	return &receipt, allocations, nil
+}
+
+// GetAllGoodsReceipts godoc
+// @Summary Get all goods receipts
+// @Description Retrieve all goods receipts recorded against purchase transactions
+// @Tags Goods Receipts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param no_receipt query string false "Partial match on goods receipt number"
+// @Param purchase_transaction_id query string false "Filter by purchase transaction ID"
+// @Param warehouse_id query string false "Filter by receiving warehouse ID"
+// @Param start_date query string false "Filter by receipt date from (YYYY-MM-DD)"
+// @Param end_date query string false "Filter by receipt date to (YYYY-MM-DD)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "List of goods receipts with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/goods-receipts [get]
+func GetAllGoodsReceipts(c *fiber.Ctx) error {
+	var receipts []models.GoodsReceipt
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("created_at DESC")
+	queryCount := config.DB.Model(&models.GoodsReceipt{})
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	if noReceipt := c.Query("no_receipt"); noReceipt != "" {
+		query = query.Where("no_receipt ILIKE ?", "%"+noReceipt+"%")
+		queryCount = queryCount.Where("no_receipt ILIKE ?", "%"+noReceipt+"%")
+	}
+
+	if purchaseID := c.Query("purchase_transaction_id"); purchaseID != "" {
+		query = query.Where("purchase_transaction_id = ?", purchaseID)
+		queryCount = queryCount.Where("purchase_transaction_id = ?", purchaseID)
+	}
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+		queryCount = queryCount.Where("warehouse_id = ?", warehouseID)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("receipt_date >= ?", startDate)
+		queryCount = queryCount.Where("receipt_date >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("receipt_date <= ?", endDate)
+		queryCount = queryCount.Where("receipt_date <= ?", endDate)
+	}
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("PurchaseTransaction").Preload("Warehouse", withDeleted), models.GoodsReceipt{}, "goods_receipts")
+	}
+
+	if err := query.
+		Preload("PurchaseTransaction").
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&receipts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch goods receipts",
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, receipts, "goods_receipts", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetGoodsReceipt godoc
+// @Summary Get a goods receipt by ID
+// @Description Retrieve a single goods receipt with its purchase transaction, warehouse and items
+// @Tags Goods Receipts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Goods receipt ID (UUID)"
+// @Success 200 {object} models.GoodsReceipt "Goods receipt details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Goods receipt not found"
+// @Router /api/goods-receipts/{id} [get]
+func GetGoodsReceipt(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var receipt models.GoodsReceipt
+
+	if err := config.DB.
+		Preload("PurchaseTransaction").
+		Preload("PurchaseTransaction.Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&receipt).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Goods receipt not found",
+		})
+	}
+
+	return c.JSON(receipt)
+}
+
+// GetPurchaseGoodsReceipts godoc
+// @Summary Get the goods receipts of a purchase transaction
+// @Description Retrieve every goods receipt recorded against a purchase transaction, oldest first, together with the received versus ordered quantity per line
+// @Tags Purchase Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Goods receipts and outstanding lines"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/purchase-transactions/{id}/goods-receipts [get]
+func GetPurchaseGoodsReceipts(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transaction models.PurchaseTransaction
+	if err := config.DB.Preload("Items").Preload("Items.Book", withDeleted).Where("id = ?", id).First(&transaction).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Purchase transaction not found",
+		})
+	}
+
+	var receipts []models.GoodsReceipt
+	if err := config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Where("purchase_transaction_id = ?", transaction.ID).
+		Order("created_at ASC").
+		Find(&receipts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch goods receipts",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"purchase_transaction_id": transaction.ID,
+		"status":                  transaction.Status,
+		"goods_receipts":          receipts,
+		"outstanding":             purchaseShortfall(transaction.Items),
+	})
+}
+
+// CreateGoodsReceipt godoc
+// @Summary Receive goods against a purchase transaction
+// @Description Record a goods receipt for part or all of the outstanding quantities of a pending or partially received purchase. Stock is increased in the receiving warehouse, waiting backorders are allocated, and the purchase is completed automatically once every line has been received in full.
+// @Tags Purchase Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID (UUID)"
+// @Param receipt body CreateGoodsReceiptRequest true "Received quantities"
+// @Success 201 {object} map[string]interface{} "Created goods receipt and updated purchase"
+// @Failure 400 {object} map[string]interface{} "Invalid request or quantities"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 409 {object} map[string]interface{} "Goods received concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/purchase-transactions/{id}/goods-receipts [post]
+func CreateGoodsReceipt(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req CreateGoodsReceiptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	for _, reqItem := range req.Items {
+		if reqItem.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Quantity must be greater than 0",
+			})
+		}
+	}
+
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	transaction, err := lockPurchaseForReceipt(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Purchase transaction not found",
+		})
+	}
+
+	if !canReceivePurchase(transaction.Status) {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Goods can only be received for pending or partially received purchases",
+		})
+	}
+
+	// Resolve every requested quantity to a purchase line and check it against what is outstanding
+	quantities := make(map[uuid.UUID]int)
+	for _, reqItem := range req.Items {
+
+		var line *models.PurchaseTransactionItem
+		for i := range transaction.Items {
+			item := &transaction.Items[i]
+			if reqItem.PurchaseTransactionItemID != "" {
+				if item.ID.String() == reqItem.PurchaseTransactionItemID {
+					line = item
+					break
+				}
+				continue
+			}
+			if item.BookID.String() == reqItem.BookID && outstandingQuantity(*item)-quantities[item.ID] > 0 {
+				line = item
+				break
+			}
+		}
+
+		if line == nil {
+			ref := reqItem.PurchaseTransactionItemID
+			if ref == "" {
+				ref = reqItem.BookID
+			}
+			tx.Rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Item %s has no outstanding quantity on this purchase", ref),
+			})
+		}
+
+		outstanding := outstandingQuantity(*line) - quantities[line.ID]
+		if reqItem.Quantity > outstanding {
+			tx.Rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":       fmt.Sprintf("Received quantity for book ID %s exceeds the outstanding quantity", line.BookID.String()),
+				"outstanding": outstanding,
+				"requested":   reqItem.Quantity,
+			})
+		}
+
+		quantities[line.ID] += reqItem.Quantity
+	}
+
+	receipt, allocations, err := receiveGoods(tx, &transaction, quantities, req.ReceiptDate, req.Note)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, errOverReceived) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Goods were received concurrently, reload the purchase",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to receive goods",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"goods_receipt":         receipt,
+		"status":                transaction.Status,
+		"outstanding":           purchaseShortfall(transaction.Items),
+		"backorder_allocations": allocations,
+	})
+}
+
+// ClosePurchaseTransaction godoc
+// @Summary Close a partially received purchase with a shortfall
+// @Description Close a partially received purchase when the remaining quantities will not arrive. The purchase moves to closed short and the shortfall per line is returned.
+// @Tags Purchase Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Closed purchase with shortfall report"
+// @Failure 400 {object} map[string]interface{} "Transaction cannot be closed"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 409 {object} map[string]interface{} "Transaction was changed concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/purchase-transactions/{id}/close [post]
+func ClosePurchaseTransaction(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transaction models.PurchaseTransaction
+	if err := config.DB.Where("id = ?", id).First(&transaction).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Purchase transaction not found",
+		})
+	}
+
+	if transaction.Status != models.PurchaseStatusPartiallyReceived {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only partially received purchases can be closed short",
+		})
+	}
+
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Close only while still partially received; a receipt for the rest may have completed it
+	result := tx.Model(&models.PurchaseTransaction{ID: transaction.ID}).
+		Where("status = ?", models.PurchaseStatusPartiallyReceived).
+		Update("status", models.PurchaseStatusClosedShort)
+	if result.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to close purchase transaction",
+		})
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Purchase transaction is no longer partially received",
+		})
+	}
+
+	// Report the shortfall as received up to the close
+	if err := tx.Preload("Book", withDeleted).
+		Where("purchase_transaction_id = ?", transaction.ID).
+		Find(&transaction.Items).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load purchase transaction items",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	shortfall := purchaseShortfall(transaction.Items)
+	totalShortfall := 0
+	totalShortfallAmount := 0.0
+	for _, line := range shortfall {
+		totalShortfall += line.ShortfallQuantity
+		totalShortfallAmount += line.ShortfallAmount
+	}
+
+	return c.JSON(fiber.Map{
+		"message":                "Purchase transaction closed with a shortfall",
+		"status":                 models.PurchaseStatusClosedShort,
+		"shortfall":              shortfall,
+		"total_shortfall":        totalShortfall,
+		"total_shortfall_amount": totalShortfallAmount,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"pustaka-backend/helpers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxImportSize limits the size of an uploaded import file
+const maxImportSize = 10 * 1024 * 1024 // 10MB
+
+// normalizeImportHeader turns a header cell such as "Merk Buku Code" into merk_buku_code
+func normalizeImportHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.Join(strings.Fields(strings.ReplaceAll(header, "-", " ")), "_")
+}
+
+// readImportUpload reads the uploaded XLSX or CSV file of an import request. It returns the rows,
+// header first, or a message describing why the upload cannot be used.
+func readImportUpload(c *fiber.Ctx) ([][]string, string) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, "No file uploaded"
+	}
+
+	fileExt := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if fileExt != ".xlsx" && fileExt != ".csv" {
+		return nil, "Invalid file type. Only XLSX and CSV files are allowed"
+	}
+	if fileHeader.Size > maxImportSize {
+		return nil, fmt.Sprintf("File size exceeds maximum allowed size of %dMB", maxImportSize/(1024*1024))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "Failed to read uploaded file"
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "Failed to read uploaded file"
+	}
+
+	rows, err := helpers.ReadSpreadsheet(fileHeader.Filename, data)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if len(rows) < 2 {
+		return nil, "The file has no data rows"
+	}
+	return rows, ""
+}
+
+// mapImportColumns maps the normalized header names of an import file to their positions.
+// Unknown and repeated columns are rejected with a message.
+func mapImportColumns(header []string, expected []string) (map[string]int, string) {
+	known := make(map[string]bool, len(expected))
+	for _, column := range expected {
+		known[column] = true
+	}
+
+	columns := make(map[string]int)
+	var unknown []string
+	for i, cell := range header {
+		name := normalizeImportHeader(cell)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			unknown = append(unknown, cell)
+			continue
+		}
+		if _, exists := columns[name]; exists {
+			return nil, fmt.Sprintf("Column %s appears more than once", name)
+		}
+		columns[name] = i
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Sprintf("Unknown columns: %s", strings.Join(unknown, ", "))
+	}
+	return columns, ""
+}
+
+// importRow is a non-blank data row of an import file
+type importRow struct {
+	Number int // Row number in the file, the header is row 1
+	Values []string
+}
+
+// importDataRows returns the data rows of an import file, skipping blank rows but keeping the
+// original row numbers for the report
+func importDataRows(rows [][]string) []importRow {
+	var dataRows []importRow
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		dataRows = append(dataRows, importRow{Number: i + 2, Values: row})
+	}
+	return dataRows
+}
+
+// importCell returns the trimmed value of a named column, or an empty string when the file has
+// no such column
+func importCell(columns map[string]int, row []string, column string) string {
+	i, ok := columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+
+	"pustaka-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errInsufficientStock is returned when a warehouse does not hold enough stock for a movement
+var errInsufficientStock = errors.New("insufficient stock")
+
+// getDefaultWarehouse returns the warehouse flagged as default, or the oldest warehouse when none is flagged
+func getDefaultWarehouse(db *gorm.DB) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	err := db.Order("is_default DESC, created_at ASC").First(&warehouse).Error
+	return warehouse, err
+}
+
+// resolveWarehouse returns the requested warehouse, or the default warehouse when none is given
+func resolveWarehouse(db *gorm.DB, warehouseID *string) (models.Warehouse, error) {
+	if warehouseID == nil || *warehouseID == "" {
+		return getDefaultWarehouse(db)
+	}
+
+	var warehouse models.Warehouse
+	err := db.Where("id = ?", *warehouseID).First(&warehouse).Error
+	return warehouse, err
+}
+
+// warehouseIDOrDefault returns the stored warehouse ID of a document, falling back to the
+// default warehouse for documents created before stock was tracked per warehouse
+func warehouseIDOrDefault(db *gorm.DB, warehouseID *uuid.UUID) (uuid.UUID, error) {
+	if warehouseID != nil && *warehouseID != uuid.Nil {
+		return *warehouseID, nil
+	}
+
+	warehouse, err := getDefaultWarehouse(db)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return warehouse.ID, nil
+}
+
+// getWarehouseStock returns the quantity of a book held in a warehouse
+func getWarehouseStock(db *gorm.DB, bookID, warehouseID uuid.UUID) (int, error) {
+	var stock models.BookStock
+	err := db.Where("book_id = ? AND warehouse_id = ?", bookID, warehouseID).First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return stock.Quantity, nil
+}
+
+// adjustWarehouseStock changes the stock of a book in a warehouse by delta and keeps books.stock
+// (the total across warehouses) in sync. Taking more than the warehouse holds returns errInsufficientStock.
+func adjustWarehouseStock(tx *gorm.DB, bookID, warehouseID uuid.UUID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+
+	var stock models.BookStock
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("book_id = ? AND warehouse_id = ?", bookID, warehouseID).
+		First(&stock).Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if delta < 0 {
+			return errInsufficientStock
+		}
+		stock = models.BookStock{
+			BookID:      bookID,
+			WarehouseID: warehouseID,
+			Quantity:    delta,
+		}
+		if err := tx.Create(&stock).Error; err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if stock.Quantity+delta < 0 {
+			return errInsufficientStock
+		}
+		if err := tx.Model(&stock).Update("quantity", stock.Quantity+delta).Error; err != nil {
+			return err
+		}
+	}
+
+	// Deleted books still follow stock movements, e.g. when a paid-off sale is deleted
+	return tx.Unscoped().Model(&models.Book{}).
+		Where("id = ?", bookID).
+		Update("stock", gorm.Expr("stock + ?", delta)).Error
+}
+
+// receiveAtCost folds quantity units bought at unitCost into the moving weighted average cost
+// of a book. It must run before the received quantity is added to the stock. Stock below zero
+// is treated as empty so a negative balance cannot drag the average down.
+func receiveAtCost(tx *gorm.DB, bookID uuid.UUID, quantity int, unitCost float64) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	return tx.Model(&models.Book{}).
+		Where("id = ?", bookID).
+		Update("average_cost", gorm.Expr(
+			"ROUND((GREATEST(stock, 0) * average_cost + ? * ?) / (GREATEST(stock, 0) + ?), 2)",
+			quantity, unitCost, quantity,
+		)).Error
+}
+
+// releaseAtCost takes quantity units bought at unitCost back out of the moving weighted average
+// cost of a book, undoing receiveAtCost. It must run before the quantity is removed from the
+// stock. When nothing would be left the average is kept as it is.
+func releaseAtCost(tx *gorm.DB, bookID uuid.UUID, quantity int, unitCost float64) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	return tx.Unscoped().Model(&models.Book{}).
+		Where("id = ?", bookID).
+		Update("average_cost", gorm.Expr(
+			"CASE WHEN stock > ? THEN GREATEST(ROUND((stock * average_cost - ? * ?) / (stock - ?), 2), 0) ELSE average_cost END",
+			quantity, quantity, unitCost, quantity,
+		)).Error
+}
@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted jenis buku (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all jenis buku with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllJenisBuku(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at ASC")
 	queryCount := config.DB.Model(&models.JenisBuku{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,6 +55,11 @@ func GetAllJenisBuku(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.JenisBuku{}, "jenis_buku")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&jenisBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -113,7 +126,7 @@ func CreateJenisBuku(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&jenisBuku).Error; err != nil {
+	if err := auditedDB(c).Create(&jenisBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create jenis buku",
 		})
@@ -153,7 +166,7 @@ func UpdateJenisBuku(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&jenisBuku).Updates(jenisBuku).Error; err != nil {
+	if err := auditedDB(c).Model(&jenisBuku).Updates(jenisBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update jenis buku",
 		})
@@ -164,34 +177,49 @@ func UpdateJenisBuku(c *fiber.Ctx) error {
 
 // DeleteJenisBuku godoc
 // @Summary Delete a jenis buku
-// @Description Delete a book type by ID
+// @Description Soft delete a jenis buku by ID. Deleted jenis buku are hidden from lists and lookups and can be restored. Jenis buku used by books cannot be deleted.
 // @Tags JenisBuku
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "JenisBuku ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the jenis buku taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "JenisBuku deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "JenisBuku not found"
+// @Failure 409 {object} map[string]interface{} "Jenis buku is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/jenis-buku/{id} [delete]
 func DeleteJenisBuku(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.JenisBuku{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete jenis buku",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var jenisBuku models.JenisBuku
+	if err := config.DB.Where("id = ?", id).First(&jenisBuku).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "JenisBuku not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "JenisBuku deleted successfully",
-	})
+	return deleteMasterRecord(c, &jenisBuku, jenisBuku.ID, "jenis_buku", "Jenis buku", "JenisBuku deleted successfully")
+}
+
+// RestoreJenisBuku godoc
+// @Summary Restore a deleted jenis buku
+// @Description Undo the soft delete of a jenis buku. Fails when another jenis buku has taken its code or name since.
+// @Tags JenisBuku
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "JenisBuku ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored jenis buku"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted jenis buku not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/jenis-buku/{id}/restore [post]
+func RestoreJenisBuku(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.JenisBuku{}, "Jenis buku", "jenis_buku")
 }
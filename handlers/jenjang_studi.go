@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted jenjang studi (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all jenjang studi with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllJenjangStudi(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at ASC")
 	queryCount := config.DB.Model(&models.JenjangStudi{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,6 +55,11 @@ func GetAllJenjangStudi(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.JenjangStudi{}, "jenjang_studi")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&jenjangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -120,7 +133,7 @@ func CreateJenjangStudi(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&jenjangStudi).Error; err != nil {
+	if err := auditedDB(c).Create(&jenjangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create jenjang studi",
 		})
@@ -167,7 +180,7 @@ func UpdateJenjangStudi(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&jenjangStudi).Updates(jenjangStudi).Error; err != nil {
+	if err := auditedDB(c).Model(&jenjangStudi).Updates(jenjangStudi).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update jenjang studi",
 		})
@@ -178,34 +191,49 @@ func UpdateJenjangStudi(c *fiber.Ctx) error {
 
 // DeleteJenjangStudi godoc
 // @Summary Delete a jenjang studi
-// @Description Delete an education level by ID
+// @Description Soft delete a jenjang studi by ID. Deleted jenjang studi are hidden from lists and lookups and can be restored. Jenjang studi used by books, open sales transactions, pending draft orders or open stock opnames cannot be deleted.
 // @Tags JenjangStudi
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "JenjangStudi ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the jenjang studi taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "JenjangStudi deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "JenjangStudi not found"
+// @Failure 409 {object} map[string]interface{} "Jenjang studi is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/jenjang-studi/{id} [delete]
 func DeleteJenjangStudi(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.JenjangStudi{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete jenjang studi",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var jenjangStudi models.JenjangStudi
+	if err := config.DB.Where("id = ?", id).First(&jenjangStudi).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "JenjangStudi not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "JenjangStudi deleted successfully",
-	})
+	return deleteMasterRecord(c, &jenjangStudi, jenjangStudi.ID, "jenjang_studi", "Jenjang studi", "JenjangStudi deleted successfully")
+}
+
+// RestoreJenjangStudi godoc
+// @Summary Restore a deleted jenjang studi
+// @Description Undo the soft delete of a jenjang studi. Fails when another jenjang studi has taken its code or name since.
+// @Tags JenjangStudi
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "JenjangStudi ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored jenjang studi"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted jenjang studi not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/jenjang-studi/{id}/restore [post]
+func RestoreJenjangStudi(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.JenjangStudi{}, "Jenjang studi", "jenjang_studi")
 }
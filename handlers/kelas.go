@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted kelas (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all kelas with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllKelas(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at ASC")
 	queryCount := config.DB.Model(&models.Kelas{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,6 +55,11 @@ func GetAllKelas(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.Kelas{}, "kelas")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&kelas).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -113,7 +126,7 @@ func CreateKelas(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&kelas).Error; err != nil {
+	if err := auditedDB(c).Create(&kelas).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create kelas",
 		})
@@ -153,7 +166,7 @@ func UpdateKelas(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&kelas).Updates(kelas).Error; err != nil {
+	if err := auditedDB(c).Model(&kelas).Updates(kelas).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update kelas",
 		})
@@ -164,7 +177,7 @@ func UpdateKelas(c *fiber.Ctx) error {
 
 // DeleteKelas godoc
 // @Summary Delete a kelas
-// @Description Delete a class by ID
+// @Description Soft delete a kelas by ID. Deleted kelas are hidden from lists and lookups and can be restored.
 // @Tags Kelas
 // @Accept json
 // @Produce json
@@ -178,20 +191,30 @@ func UpdateKelas(c *fiber.Ctx) error {
 func DeleteKelas(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Kelas{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete kelas",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var kelas models.Kelas
+	if err := config.DB.Where("id = ?", id).First(&kelas).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Kelas not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Kelas deleted successfully",
-	})
+	return deleteMasterRecord(c, &kelas, kelas.ID, "kelas", "Kelas", "Kelas deleted successfully")
+}
+
+// RestoreKelas godoc
+// @Summary Restore a deleted kelas
+// @Description Undo the soft delete of a kelas. Fails when another kelas has taken its code or name since.
+// @Tags Kelas
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Kelas ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored kelas"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted kelas not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/kelas/{id}/restore [post]
+func RestoreKelas(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Kelas{}, "Kelas", "kelas")
 }
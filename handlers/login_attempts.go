@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Defaults of the login and password reset lockout policies, each can be overridden by an environment variable
+const (
+	defaultLoginMaxFailures           = 5    // LOGIN_MAX_FAILURES, failures per email before a lockout
+	defaultLoginMaxIPFailures         = 20   // LOGIN_MAX_IP_FAILURES, failures per IP address before a lockout
+	defaultLoginLockoutSeconds        = 60   // LOGIN_LOCKOUT_SECONDS, first lockout, doubled on every further failure
+	defaultLoginMaxLockoutHours       = 1    // LOGIN_MAX_LOCKOUT_HOURS, longest lockout
+	defaultLoginFailureWindowMinutes  = 15   // LOGIN_FAILURE_WINDOW_MINUTES, failures older than this are forgotten
+	defaultPasswordResetMaxRequests   = 3    // PASSWORD_RESET_MAX_REQUESTS, reset emails per email address before a lockout
+	defaultPasswordResetMaxIPRequests = 10   // PASSWORD_RESET_MAX_IP_REQUESTS, reset requests per IP address before a lockout
+	maxMemoryLoginAttempts            = 5000 // Audit rows kept by the in-memory store
+)
+
+// LoginAttemptStore keeps the failed login counters and the login audit trail
+type LoginAttemptStore interface {
+	// GetLockout returns the counter of the key, or nil when the key has no failures
+	GetLockout(key string) (*models.LoginLockout, error)
+	// AddFailure counts a failure of the key in one atomic step and returns the updated
+	// counter. The count starts over when the last failure is older than window and no
+	// lockout is running.
+	AddFailure(key string, now time.Time, window time.Duration) (models.LoginLockout, error)
+	// LockUntil locks the key until the given time, unless it is already locked for longer
+	LockUntil(key string, until time.Time) error
+	DeleteLockout(key string) error
+	RecordAttempt(attempt models.LoginAttempt) error
+}
+
+var loginAttempts LoginAttemptStore = PostgresLoginAttemptStore{}
+
+// SetLoginAttemptStore replaces the store used by Login, e.g. with NewMemoryLoginAttemptStore
+// for single-node setups or tests
+func SetLoginAttemptStore(store LoginAttemptStore) {
+	loginAttempts = store
+}
+
+// PostgresLoginAttemptStore keeps the counters in login_lockouts and the audit trail in
+// login_attempts, so all nodes share them
+type PostgresLoginAttemptStore struct{}
+
+func (PostgresLoginAttemptStore) GetLockout(key string) (*models.LoginLockout, error) {
+	var lockout models.LoginLockout
+	if err := config.DB.Where("key = ?", key).First(&lockout).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lockout, nil
+}
+
+// AddFailure increments the counter with a single upsert, so concurrent failures of the same
+// key are all counted
+func (PostgresLoginAttemptStore) AddFailure(key string, now time.Time, window time.Duration) (models.LoginLockout, error) {
+	var lockout models.LoginLockout
+	err := config.DB.Raw(`INSERT INTO login_lockouts (key, failures, last_failure_at, updated_at) VALUES (@key, 1, @now, @now)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_lockouts.last_failure_at < @expired AND (login_lockouts.locked_until IS NULL OR login_lockouts.locked_until < @now)
+				THEN 1 ELSE login_lockouts.failures + 1 END,
+			locked_until = CASE WHEN login_lockouts.last_failure_at < @expired AND (login_lockouts.locked_until IS NULL OR login_lockouts.locked_until < @now)
+				THEN NULL ELSE login_lockouts.locked_until END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING key, failures, last_failure_at, locked_until, updated_at`,
+		sql.Named("key", key), sql.Named("now", now), sql.Named("expired", now.Add(-window)),
+	).Scan(&lockout).Error
+	return lockout, err
+}
+
+func (PostgresLoginAttemptStore) LockUntil(key string, until time.Time) error {
+	return config.DB.Model(&models.LoginLockout{}).
+		Where("key = ? AND (locked_until IS NULL OR locked_until < ?)", key, until).
+		Update("locked_until", until).Error
+}
+
+func (PostgresLoginAttemptStore) DeleteLockout(key string) error {
+	return config.DB.Where("key = ?", key).Delete(&models.LoginLockout{}).Error
+}
+
+func (PostgresLoginAttemptStore) RecordAttempt(attempt models.LoginAttempt) error {
+	return config.DB.Create(&attempt).Error
+}
+
+// MemoryLoginAttemptStore keeps everything in process memory. Counters are lost on restart and
+// not shared between nodes; the audit trail keeps only the latest attempts.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	lockouts map[string]models.LoginLockout
+	attempts []models.LoginAttempt
+}
+
+// NewMemoryLoginAttemptStore creates an empty in-memory store
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{lockouts: make(map[string]models.LoginLockout)}
+}
+
+func (s *MemoryLoginAttemptStore) GetLockout(key string) (*models.LoginLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lockout, ok := s.lockouts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &lockout, nil
+}
+
+func (s *MemoryLoginAttemptStore) AddFailure(key string, now time.Time, window time.Duration) (models.LoginLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lockout, ok := s.lockouts[key]
+	if !ok || (now.Sub(lockout.LastFailureAt) > window && (lockout.LockedUntil == nil || lockout.LockedUntil.Before(now))) {
+		lockout = models.LoginLockout{Key: key}
+	}
+	lockout.Failures++
+	lockout.LastFailureAt = now
+	lockout.UpdatedAt = now
+	s.lockouts[key] = lockout
+	return lockout, nil
+}
+
+func (s *MemoryLoginAttemptStore) LockUntil(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lockout, ok := s.lockouts[key]
+	if !ok || (lockout.LockedUntil != nil && !lockout.LockedUntil.Before(until)) {
+		return nil
+	}
+	lockout.LockedUntil = &until
+	lockout.UpdatedAt = time.Now()
+	s.lockouts[key] = lockout
+	return nil
+}
+
+// SaveLockout replaces the counter of a key, e.g. to prepare a lockout in tests
+func (s *MemoryLoginAttemptStore) SaveLockout(lockout models.LoginLockout) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lockout.UpdatedAt = time.Now()
+	s.lockouts[lockout.Key] = lockout
+}
+
+func (s *MemoryLoginAttemptStore) DeleteLockout(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lockouts, key)
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) RecordAttempt(attempt models.LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = time.Now()
+	s.attempts = append(s.attempts, attempt)
+	if len(s.attempts) > maxMemoryLoginAttempts {
+		s.attempts = s.attempts[len(s.attempts)-maxMemoryLoginAttempts:]
+	}
+	return nil
+}
+
+// Attempts returns a copy of the recorded attempts, oldest first
+func (s *MemoryLoginAttemptStore) Attempts() []models.LoginAttempt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.LoginAttempt(nil), s.attempts...)
+}
+
+// envInt reads a positive integer setting, falling back to the default
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// loginLockoutKeys returns the counter keys of a login with their failure limits
+func loginLockoutKeys(email, ip string) map[string]int {
+	return map[string]int{
+		"email:" + email: envInt("LOGIN_MAX_FAILURES", defaultLoginMaxFailures),
+		"ip:" + ip:       envInt("LOGIN_MAX_IP_FAILURES", defaultLoginMaxIPFailures),
+	}
+}
+
+// normalizeLoginEmail makes "User@Example.com " and "user@example.com" share a counter
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// passwordResetLockoutKeys returns the counter keys of a password reset request with their
+// limits. They are separate from the login keys, so asking for resets never blocks a login.
+func passwordResetLockoutKeys(email, ip string) map[string]int {
+	return map[string]int{
+		"reset-email:" + email: envInt("PASSWORD_RESET_MAX_REQUESTS", defaultPasswordResetMaxRequests),
+		"reset-ip:" + ip:       envInt("PASSWORD_RESET_MAX_IP_REQUESTS", defaultPasswordResetMaxIPRequests),
+	}
+}
+
+// loginRetryAfter returns how long the email or IP address is still locked out, or zero
+func loginRetryAfter(email, ip string, now time.Time) (time.Duration, error) {
+	return lockoutRetryAfter(loginLockoutKeys(email, ip), now)
+}
+
+// lockoutRetryAfter returns how long the longest running lockout of the keys lasts, or zero
+func lockoutRetryAfter(keys map[string]int, now time.Time) (time.Duration, error) {
+	var retryAfter time.Duration
+	for key := range keys {
+		lockout, err := loginAttempts.GetLockout(key)
+		if err != nil {
+			return 0, err
+		}
+		if lockout != nil && lockout.LockedUntil != nil && lockout.LockedUntil.After(now) {
+			if wait := lockout.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+	return retryAfter, nil
+}
+
+// registerLoginFailure counts a failed login for the email and the IP address. Once a key
+// reaches its limit it is locked, for twice as long with every further failure.
+func registerLoginFailure(email, ip string, now time.Time) error {
+	return registerFailure(loginLockoutKeys(email, ip), now)
+}
+
+// registerFailure counts a failure for each key and locks the keys that reached their limit
+func registerFailure(keys map[string]int, now time.Time) error {
+	window := time.Duration(envInt("LOGIN_FAILURE_WINDOW_MINUTES", defaultLoginFailureWindowMinutes)) * time.Minute
+	baseLockout := time.Duration(envInt("LOGIN_LOCKOUT_SECONDS", defaultLoginLockoutSeconds)) * time.Second
+	maxLockout := time.Duration(envInt("LOGIN_MAX_LOCKOUT_HOURS", defaultLoginMaxLockoutHours)) * time.Hour
+
+	for key, limit := range keys {
+		lockout, err := loginAttempts.AddFailure(key, now, window)
+		if err != nil {
+			return err
+		}
+		if lockout.Failures < limit {
+			continue
+		}
+
+		duration := baseLockout
+		for i := limit; i < lockout.Failures && duration < maxLockout; i++ {
+			duration *= 2
+		}
+		if duration > maxLockout {
+			duration = maxLockout
+		}
+		if err := loginAttempts.LockUntil(key, now.Add(duration)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordLoginAttempt writes the audit row of a login. A failure to write it is not shown to
+// the user, the login itself has already been decided.
+func recordLoginAttempt(c *fiber.Ctx, email string, userID *uuid.UUID, reason string) {
+	attempt := models.LoginAttempt{
+		Email:     email,
+		IPAddress: c.IP(),
+		UserID:    userID,
+		Success:   reason == models.LoginReasonSuccess,
+		Reason:    reason,
+	}
+	if userAgent := c.Get(fiber.HeaderUserAgent); userAgent != "" {
+		attempt.UserAgent = &userAgent
+	}
+	loginAttempts.RecordAttempt(attempt)
+}
+
+// respondLoginLocked records the refused attempt and tells the client how long to wait
+func respondLoginLocked(c *fiber.Ctx, email string, retryAfter time.Duration) error {
+	recordLoginAttempt(c, email, nil, models.LoginReasonLocked)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "Too many failed login attempts, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user's login
+// @Description Clear the failed login counter and lockout of the user's email address. Lockouts of IP addresses expire on their own. Requires users:write.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} map[string]interface{} "User unlocked"
+// @Failure 400 {object} map[string]interface{} "Invalid user ID"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/users/{id}/unlock [post]
+func UnlockUser(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	// Validate UUID
+	if _, err := uuid.Parse(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := loginAttempts.DeleteLockout("email:" + normalizeLoginEmail(user.Email)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlock user",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User unlocked",
+	})
+}
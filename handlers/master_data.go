@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pustaka-backend/config"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Master data (books, publishers, sales associates, cities and the other lookup tables) is soft
+// deleted: the row keeps its ID for the documents that point at it and can be restored.
+
+// masterDependent is a column of another table that points at master records, with the
+// condition that selects the rows that keep a record from being deleted: live master data and
+// documents still in progress. Label names those rows in the dependency report. Model is the
+// model of Table, so a forced delete moves the rows through the audit callbacks. Unlink rows
+// are detached from the record on a forced delete instead of being moved to the replacement.
+type masterDependent struct {
+	Model     interface{}
+	Table     string
+	Column    string
+	Label     string
+	Condition string
+	Args      []interface{}
+	Unlink    bool
+}
+
+// dependencyBlocker is one line of the dependency report of a master record
+type dependencyBlocker struct {
+	Table  string `json:"table"`
+	Label  string `json:"label"`
+	Count  int64  `json:"count"`
+	unlink bool
+}
+
+var (
+	errDependentsExist     = errors.New("master record has dependent rows")
+	errInvalidReplacement  = errors.New("replacement_id is not another record")
+	errReplacementNotFound = errors.New("replacement record not found")
+)
+
+// Sales are open until paid off or cancelled, purchases until fully received, closed short or
+// cancelled
+var (
+	openSalesStatuses    = []int{models.SalesStatusBooking, models.SalesStatusInstallment}
+	openPurchaseStatuses = []int{models.PurchaseStatusPending, models.PurchaseStatusPartiallyReceived}
+)
+
+// liveRowsBy selects the rows of other master data pointing at a record; the soft delete
+// scope of model leaves out the deleted ones
+func liveRowsBy(model interface{}, table, column, label string) masterDependent {
+	return masterDependent{Model: model, Table: table, Column: column, Label: label}
+}
+
+func openSalesTransactionsBy(column string) masterDependent {
+	return masterDependent{Model: &models.SalesTransaction{}, Table: "sales_transactions", Column: column, Label: "open sales transactions", Condition: "status IN ?", Args: []interface{}{openSalesStatuses}}
+}
+
+func openPurchaseTransactionsBy(column string) masterDependent {
+	return masterDependent{Model: &models.PurchaseTransaction{}, Table: "purchase_transactions", Column: column, Label: "open purchase transactions", Condition: "status IN ?", Args: []interface{}{openPurchaseStatuses}}
+}
+
+func pendingStockTransfersBy(column string) masterDependent {
+	return masterDependent{Model: &models.StockTransfer{}, Table: "stock_transfers", Column: column, Label: "pending stock transfers", Condition: "status = ?", Args: []interface{}{models.TransferStatusPending}}
+}
+
+func openStockOpnamesBy(column string) masterDependent {
+	return masterDependent{Model: &models.StockOpname{}, Table: "stock_opnames", Column: column, Label: "open stock opnames", Condition: "status = ?", Args: []interface{}{models.OpnameStatusOpen}}
+}
+
+func pendingSalesOrderDraftsBy(column string) masterDependent {
+	return masterDependent{Model: &models.SalesOrderDraft{}, Table: "sales_order_drafts", Column: column, Label: "pending draft orders", Condition: "status = ?", Args: []interface{}{models.DraftStatusPending}}
+}
+
+// masterDependents lists, per master table, the rows that block deleting a record. Closed
+// documents keep pointing at the deleted record and do not block it.
+var masterDependents = map[string][]masterDependent{
+	"cities": {
+		liveRowsBy(&models.Publisher{}, "publishers", "city_id", "publishers"),
+		liveRowsBy(&models.Expedition{}, "expeditions", "city_id", "expeditions"),
+		liveRowsBy(&models.SalesAssociate{}, "sales_associates", "city_id", "sales associates"),
+		liveRowsBy(&models.Biller{}, "billers", "city_id", "billers"),
+		liveRowsBy(&models.Warehouse{}, "warehouses", "city_id", "warehouses"),
+	},
+	"books": {
+		{Model: &models.SalesTransactionItem{}, Table: "sales_transaction_items", Column: "book_id", Label: "open sales transaction lines", Condition: "transaction_id IN (SELECT id FROM sales_transactions WHERE status IN ?)", Args: []interface{}{openSalesStatuses}},
+		{Model: &models.PurchaseTransactionItem{}, Table: "purchase_transaction_items", Column: "book_id", Label: "open purchase transaction lines", Condition: "purchase_transaction_id IN (SELECT id FROM purchase_transactions WHERE status IN ?)", Args: []interface{}{openPurchaseStatuses}},
+		{Model: &models.StockTransferItem{}, Table: "stock_transfer_items", Column: "book_id", Label: "pending stock transfer lines", Condition: "stock_transfer_id IN (SELECT id FROM stock_transfers WHERE status = ?)", Args: []interface{}{models.TransferStatusPending}},
+		{Model: &models.StockOpnameItem{}, Table: "stock_opname_items", Column: "book_id", Label: "open stock opname lines", Condition: "stock_opname_id IN (SELECT id FROM stock_opnames WHERE status = ?)", Args: []interface{}{models.OpnameStatusOpen}},
+		{Model: &models.SalesOrderDraftItem{}, Table: "sales_order_draft_items", Column: "book_id", Label: "pending draft order lines", Condition: "draft_id IN (SELECT id FROM sales_order_drafts WHERE status = ?)", Args: []interface{}{models.DraftStatusPending}},
+		{Model: &models.BookBundleItem{}, Table: "book_bundle_items", Column: "book_id", Label: "active bundles", Condition: "bundle_id IN (SELECT id FROM book_bundles WHERE is_active)"},
+	},
+	"publishers": {
+		liveRowsBy(&models.Book{}, "books", "publisher_id", "books"),
+		openPurchaseTransactionsBy("supplier_id"),
+	},
+	"sales_associates": {
+		openSalesTransactionsBy("sales_associate_id"),
+		pendingSalesOrderDraftsBy("sales_associate_id"),
+		// A user account is never handed to another sales associate, a forced delete only
+		// detaches it
+		{Model: &models.User{}, Table: "users", Column: "sales_associate_id", Label: "user accounts", Unlink: true},
+	},
+	"billers": {
+		openSalesTransactionsBy("biller_id"),
+	},
+	"expeditions": {
+		{Model: &models.Shipping{}, Table: "shippings", Column: "expedition_id", Label: "shippings of open sales transactions", Condition: "sales_transaction_id IN (SELECT id FROM sales_transactions WHERE status IN ?)", Args: []interface{}{openSalesStatuses}},
+	},
+	"curriculum": {
+		liveRowsBy(&models.Book{}, "books", "curriculum_id", "books"),
+		openSalesTransactionsBy("curriculum_id"),
+		pendingSalesOrderDraftsBy("curriculum_id"),
+	},
+	"merk_buku": {
+		liveRowsBy(&models.Book{}, "books", "merk_buku_id", "books"),
+		openSalesTransactionsBy("merk_buku_id"),
+		pendingSalesOrderDraftsBy("merk_buku_id"),
+		openStockOpnamesBy("merk_buku_id"),
+	},
+	"jenis_buku": {
+		liveRowsBy(&models.Book{}, "books", "jenis_buku_id", "books"),
+	},
+	"jenjang_studi": {
+		liveRowsBy(&models.Book{}, "books", "jenjang_studi_id", "books"),
+		openSalesTransactionsBy("jenjang_studi_id"),
+		pendingSalesOrderDraftsBy("jenjang_studi_id"),
+		openStockOpnamesBy("jenjang_studi_id"),
+	},
+	"bidang_studi": {
+		liveRowsBy(&models.Book{}, "books", "bidang_studi_id", "books"),
+	},
+	"warehouses": {
+		openSalesTransactionsBy("warehouse_id"),
+		openPurchaseTransactionsBy("warehouse_id"),
+		pendingStockTransfersBy("from_warehouse_id"),
+		pendingStockTransfersBy("to_warehouse_id"),
+		openStockOpnamesBy("warehouse_id"),
+	},
+}
+
+// Moving the documents of a book to another book would leave stock levels wrong, so books
+// cannot be force deleted. Warehouses have their own delete without a force mode.
+var unforceableMasters = map[string]bool{"books": true}
+
+// dependentsOf selects the rows of dep that point at the master record id
+func dependentsOf(db *gorm.DB, dep masterDependent, id uuid.UUID) *gorm.DB {
+	query := db.Model(dep.Model).Where(dep.Column+" = ?", id)
+	if dep.Condition != "" {
+		query = query.Where(dep.Condition, dep.Args...)
+	}
+	return query
+}
+
+// dependencyReport counts the rows that block deleting a master record, one line per kind of
+// row. It is empty when the record can be deleted.
+func dependencyReport(db *gorm.DB, table string, id uuid.UUID) ([]dependencyBlocker, error) {
+	blockers := []dependencyBlocker{}
+	for _, dep := range masterDependents[table] {
+		var count int64
+		if err := dependentsOf(db, dep, id).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		// Transfers count once whether the warehouse sends or receives
+		merged := false
+		for i := range blockers {
+			if blockers[i].Table == dep.Table && blockers[i].Label == dep.Label {
+				blockers[i].Count += count
+				merged = true
+			}
+		}
+		if !merged {
+			blockers = append(blockers, dependencyBlocker{Table: dep.Table, Label: dep.Label, Count: count, unlink: dep.Unlink})
+		}
+	}
+	return blockers, nil
+}
+
+// dependencyConflict answers a delete that is blocked, e.g. "Merk buku is used by 37 books,
+// 5 open sales transactions"
+func dependencyConflict(c *fiber.Ctx, label string, blockers []dependencyBlocker) error {
+	parts := make([]string, len(blockers))
+	for i, blocker := range blockers {
+		parts[i] = fmt.Sprintf("%d %s", blocker.Count, blocker.Label)
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":    label + " is used by " + strings.Join(parts, ", "),
+		"blockers": blockers,
+	})
+}
+
+// deleteMasterRecord soft deletes a loaded master record unless other rows depend on it. With
+// force=true a holder of master_data:force_delete moves the dependents to the record in replacement_id first, in the same
+// transaction. message is the success message.
+func deleteMasterRecord(c *fiber.Ctx, record interface{}, id uuid.UUID, table, label, message string) error {
+	force := c.Query("force") == "true"
+	if force {
+		allowed, err := callerHasPermission(c, "master_data:force_delete")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check permissions",
+			})
+		}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Permission required: master_data:force_delete",
+			})
+		}
+		if unforceableMasters[table] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": label + " cannot be force deleted",
+			})
+		}
+	}
+
+	var blockers, reassigned, unlinked []dependencyBlocker
+	var replacementID uuid.UUID
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		// The foreign keys of rows that start pointing at the record wait for this lock, so
+		// nothing is added behind the report before the delete commits
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(record).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if blockers, err = dependencyReport(tx, table, id); err != nil {
+			return err
+		}
+		if len(blockers) == 0 {
+			return tx.Delete(record).Error
+		}
+		if !force {
+			return errDependentsExist
+		}
+
+		for _, blocker := range blockers {
+			if blocker.unlink {
+				unlinked = append(unlinked, blocker)
+			} else {
+				reassigned = append(reassigned, blocker)
+			}
+		}
+		if len(reassigned) > 0 {
+			replacementID, err = uuid.Parse(c.Query("replacement_id"))
+			if err != nil || replacementID == id {
+				return errInvalidReplacement
+			}
+			var found int64
+			if err := tx.Table(table).Where("id = ? AND deleted_at IS NULL", replacementID).Count(&found).Error; err != nil {
+				return err
+			}
+			if found == 0 {
+				return errReplacementNotFound
+			}
+		}
+
+		for _, dep := range masterDependents[table] {
+			var value interface{} = replacementID
+			if dep.Unlink {
+				value = nil
+			} else if len(reassigned) == 0 {
+				continue
+			}
+			if err := dependentsOf(tx, dep, id).Update(dep.Column, value).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(record).Error
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": label + " not found",
+		})
+	case errors.Is(err, errDependentsExist):
+		return dependencyConflict(c, label, blockers)
+	case errors.Is(err, errInvalidReplacement):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "replacement_id must be the ID of another " + strings.ToLower(label),
+		})
+	case errors.Is(err, errReplacementNotFound):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Replacement " + strings.ToLower(label) + " not found",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete " + strings.ToLower(label),
+		})
+	}
+
+	response := fiber.Map{"message": message}
+	if len(reassigned) > 0 {
+		response["replacement_id"] = replacementID
+		response["reassigned"] = reassigned
+	}
+	if len(unlinked) > 0 {
+		response["unlinked"] = unlinked
+	}
+	return c.JSON(response)
+}
+
+// includeDeleted reports whether a list request asks for soft deleted records too
+func includeDeleted(c *fiber.Ctx) bool {
+	return c.Query("include_deleted") == "true"
+}
+
+// withDeleted is a preload condition for relations that point at master data, so a
+// document keeps showing the book, customer or warehouse it was made with after that
+// record is soft deleted
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// restoreMasterRecord undoes the soft delete of the master record in the id parameter. record
+// points at an empty model; label names the record in messages and key in the response.
+func restoreMasterRecord(c *fiber.Ctx, record interface{}, label, key string) error {
+	id := c.Params("id")
+
+	if err := config.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(record).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Deleted " + strings.ToLower(label) + " not found",
+		})
+	}
+
+	if err := auditedDB(c).Unscoped().Model(record).Update("deleted_at", nil).Error; err != nil {
+		// A record created after the delete took the code or name
+		if isUniqueViolation(err) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Another " + strings.ToLower(label) + " already uses the same code or name",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore " + strings.ToLower(label),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": label + " restored successfully",
+		key:       record,
+	})
+}
@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of master import columns, deciding how a cell is validated and stored
+const (
+	masterFieldText        = "text"
+	masterFieldEmail       = "email"
+	masterFieldPhone       = "phone"
+	masterFieldDate        = "date"
+	masterFieldPercent     = "percent"
+	masterFieldPaymentType = "payment_type"
+	masterFieldCity        = "city" // city_code column, stored as city_id
+)
+
+// masterImportField describes one column of a master data import file
+type masterImportField struct {
+	Column   string
+	Kind     string
+	Required bool        // Required when the row creates a new record
+	Unique   bool        // Must not be used by another record
+	Default  interface{} // Stored on new records when the cell is empty
+}
+
+// masterImportSpec describes the import file of one master entity
+type masterImportSpec struct {
+	Entity string // Name used in messages, e.g. "city"
+	Table  string
+	Model  interface{} // Rows are written through the model so the audit log records them
+	Fields []masterImportField
+}
+
+// contactImportFields are the address and contact columns shared by expeditions and publishers
+var contactImportFields = []masterImportField{
+	{Column: "code", Kind: masterFieldText, Required: true},
+	{Column: "name", Kind: masterFieldText, Required: true, Unique: true},
+	{Column: "description", Kind: masterFieldText},
+	{Column: "address", Kind: masterFieldText, Required: true},
+	{Column: "city_code", Kind: masterFieldCity},
+	{Column: "area", Kind: masterFieldText},
+	{Column: "phone1", Kind: masterFieldPhone, Required: true},
+	{Column: "phone2", Kind: masterFieldPhone},
+	{Column: "email", Kind: masterFieldEmail},
+	{Column: "website", Kind: masterFieldText},
+}
+
+var (
+	cityImportSpec = masterImportSpec{
+		Entity: "city",
+		Table:  "cities",
+		Model:  &models.City{},
+		Fields: []masterImportField{
+			{Column: "code", Kind: masterFieldText, Required: true},
+			{Column: "name", Kind: masterFieldText, Required: true, Unique: true},
+		},
+	}
+
+	expeditionImportSpec = masterImportSpec{
+		Entity: "expedition",
+		Table:  "expeditions",
+		Model:  &models.Expedition{},
+		Fields: contactImportFields,
+	}
+
+	publisherImportSpec = masterImportSpec{
+		Entity: "publisher",
+		Table:  "publishers",
+		Model:  &models.Publisher{},
+		Fields: contactImportFields,
+	}
+
+	salesAssociateImportSpec = masterImportSpec{
+		Entity: "sales associate",
+		Table:  "sales_associates",
+		Model:  &models.SalesAssociate{},
+		Fields: []masterImportField{
+			{Column: "code", Kind: masterFieldText, Required: true},
+			{Column: "name", Kind: masterFieldText, Required: true},
+			{Column: "no_ktp", Kind: masterFieldText},
+			{Column: "description", Kind: masterFieldText},
+			{Column: "address", Kind: masterFieldText, Required: true},
+			{Column: "city_code", Kind: masterFieldCity},
+			{Column: "area", Kind: masterFieldText},
+			{Column: "phone1", Kind: masterFieldPhone, Required: true},
+			{Column: "phone2", Kind: masterFieldPhone},
+			{Column: "email", Kind: masterFieldEmail},
+			{Column: "website", Kind: masterFieldText},
+			{Column: "jenis_pembayaran", Kind: masterFieldPaymentType, Default: "T"},
+			{Column: "join_date", Kind: masterFieldDate, Required: true},
+			{Column: "end_join_date", Kind: masterFieldDate},
+			{Column: "discount", Kind: masterFieldPercent, Default: float64(0)},
+		},
+	}
+
+	billerImportSpec = masterImportSpec{
+		Entity: "biller",
+		Table:  "billers",
+		Model:  &models.Biller{},
+		Fields: []masterImportField{
+			{Column: "code", Kind: masterFieldText, Required: true},
+			{Column: "name", Kind: masterFieldText, Unique: true},
+			{Column: "description", Kind: masterFieldText},
+			{Column: "npwp", Kind: masterFieldText, Required: true, Unique: true},
+			{Column: "address", Kind: masterFieldText, Required: true},
+			{Column: "city_code", Kind: masterFieldCity},
+			{Column: "phone1", Kind: masterFieldPhone, Required: true},
+			{Column: "phone2", Kind: masterFieldPhone},
+			{Column: "fax", Kind: masterFieldPhone},
+			{Column: "email", Kind: masterFieldEmail},
+			{Column: "website", Kind: masterFieldText},
+		},
+	}
+)
+
+// columns returns the column names of the import file in template order
+func (spec masterImportSpec) columns() []string {
+	columns := make([]string, len(spec.Fields))
+	for i, field := range spec.Fields {
+		columns[i] = field.Column
+	}
+	return columns
+}
+
+// MasterImportRowResult reports what the import does with one row of a master data file
+type MasterImportRowResult struct {
+	Row    int        `json:"row"`    // Row number in the file, the header is row 1
+	Action string     `json:"action"` // create, update or error
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Code   string     `json:"code"`
+	Name   string     `json:"name,omitempty"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+// masterImportOperation is a validated row waiting to be written
+type masterImportOperation struct {
+	result *MasterImportRowResult
+	values map[string]interface{}
+}
+
+// masterImportRecord is an existing record matched by code or a unique column
+type masterImportRecord struct {
+	ID    uuid.UUID
+	Code  string
+	Value string
+}
+
+// normalizeImportPhone removes the separators people commonly type in phone numbers,
+// e.g. "(021) 555-1234" becomes 0215551234
+func normalizeImportPhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+}
+
+// importMasterData validates and upserts the rows of a master data import file. Rows are matched
+// to existing records by code; empty cells leave existing values unchanged. Like the book import,
+// the file is only validated unless dry_run=false, and then written in one transaction only when
+// no row has errors.
+func importMasterData(c *fiber.Ctx, spec masterImportSpec) error {
+	rows, message := readImportUpload(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	dryRun := c.FormValue("dry_run", "true") != "false"
+
+	expected := spec.columns()
+	columns, message := mapImportColumns(rows[0], expected)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":            message,
+			"expected_columns": expected,
+		})
+	}
+	if _, ok := columns["code"]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The file needs a code column to match existing records",
+		})
+	}
+
+	cell := func(row []string, column string) string {
+		return importCell(columns, row, column)
+	}
+
+	dataRows := importDataRows(rows)
+	if len(dataRows) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The file has no data rows",
+		})
+	}
+
+	// Collect the values that need a lookup: codes, city codes and unique columns
+	var codes, cityCodes []string
+	uniqueValues := make(map[string][]string)
+	for _, row := range dataRows {
+		if code := cell(row.Values, "code"); code != "" {
+			codes = append(codes, code)
+		}
+		if cityCode := cell(row.Values, "city_code"); cityCode != "" {
+			cityCodes = append(cityCodes, strings.ToUpper(cityCode))
+		}
+		for _, field := range spec.Fields {
+			if value := cell(row.Values, field.Column); field.Unique && value != "" {
+				uniqueValues[field.Column] = append(uniqueValues[field.Column], value)
+			}
+		}
+	}
+
+	existingByCode := make(map[string]masterImportRecord)
+	if len(codes) > 0 {
+		var existing []masterImportRecord
+		if err := config.DB.Table(spec.Table).Select("id, code").Where("code IN ? AND deleted_at IS NULL", codes).Scan(&existing).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to load existing %s records", spec.Entity),
+			})
+		}
+		for _, record := range existing {
+			existingByCode[record.Code] = record
+		}
+	}
+
+	citiesByCode := make(map[string]uuid.UUID)
+	if len(cityCodes) > 0 {
+		var cities []masterImportRecord
+		if err := config.DB.Table("cities").Select("id, code").Where("UPPER(code) IN ? AND deleted_at IS NULL", cityCodes).Scan(&cities).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve city_code",
+			})
+		}
+		for _, city := range cities {
+			citiesByCode[strings.ToUpper(city.Code)] = city.ID
+		}
+	}
+
+	// Unique values already taken, mapped to the code of the record using them
+	takenValues := make(map[string]map[string]string)
+	for _, field := range spec.Fields {
+		values := uniqueValues[field.Column]
+		if len(values) == 0 {
+			continue
+		}
+		var taken []masterImportRecord
+		err := config.DB.Table(spec.Table).
+			Select("id, code, "+field.Column+" AS value").
+			Where(field.Column+" IN ? AND deleted_at IS NULL", values).
+			Scan(&taken).Error
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check " + field.Column,
+			})
+		}
+		takenValues[field.Column] = make(map[string]string, len(taken))
+		for _, record := range taken {
+			takenValues[field.Column][record.Value] = record.Code
+		}
+	}
+
+	// Validate every row
+	seen := make(map[string]map[string]int)
+	results := make([]*MasterImportRowResult, 0, len(dataRows))
+	var operations []masterImportOperation
+	failed := 0
+
+	for _, row := range dataRows {
+		code := cell(row.Values, "code")
+		result := &MasterImportRowResult{Row: row.Number, Code: code, Name: cell(row.Values, "name")}
+		results = append(results, result)
+
+		var errs []string
+		values := make(map[string]interface{})
+
+		existing, exists := existingByCode[code]
+
+		for _, field := range spec.Fields {
+			value := cell(row.Values, field.Column)
+			if value == "" {
+				if !exists && field.Required {
+					errs = append(errs, fmt.Sprintf("%s is required", field.Column))
+				} else if !exists && field.Default != nil {
+					values[field.Column] = field.Default
+				}
+				continue
+			}
+
+			if field.Unique || field.Column == "code" {
+				if seen[field.Column] == nil {
+					seen[field.Column] = make(map[string]int)
+				}
+				if previous, ok := seen[field.Column][value]; ok {
+					errs = append(errs, fmt.Sprintf("%s %s is also used on row %d", field.Column, value, previous))
+				} else {
+					seen[field.Column][value] = row.Number
+				}
+			}
+			if owner, ok := takenValues[field.Column][value]; ok && owner != code {
+				errs = append(errs, fmt.Sprintf("%s %s already belongs to %s %s", field.Column, value, spec.Entity, owner))
+			}
+
+			switch field.Kind {
+			case masterFieldEmail:
+				if !helpers.IsValidEmail(value) {
+					errs = append(errs, fmt.Sprintf("Invalid email %s", value))
+					continue
+				}
+			case masterFieldPhone:
+				phone := normalizeImportPhone(value)
+				if !helpers.IsValidPhoneNumber(phone) {
+					errs = append(errs, fmt.Sprintf("Invalid %s %s, expected 10 to 15 digits", field.Column, value))
+					continue
+				}
+				values[field.Column] = phone
+				continue
+			case masterFieldDate:
+				date, err := helpers.ParseDateString(&value)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("Invalid %s %s, expected YYYY-MM-DD", field.Column, value))
+					continue
+				}
+				values[field.Column] = *date
+				continue
+			case masterFieldPercent:
+				number, err := strconv.ParseFloat(value, 64)
+				if err != nil || number < 0 || number > 100 {
+					errs = append(errs, fmt.Sprintf("%s must be a number between 0 and 100", field.Column))
+					continue
+				}
+				values[field.Column] = number
+				continue
+			case masterFieldPaymentType:
+				value = strings.ToUpper(value)
+				if value != "T" && value != "K" {
+					errs = append(errs, fmt.Sprintf("%s must be T (tunai) or K (kredit)", field.Column))
+					continue
+				}
+			case masterFieldCity:
+				cityID, ok := citiesByCode[strings.ToUpper(value)]
+				if !ok {
+					errs = append(errs, fmt.Sprintf("Unknown city_code %s", value))
+					continue
+				}
+				values["city_id"] = cityID
+				continue
+			}
+			values[field.Column] = value
+		}
+
+		if len(errs) > 0 {
+			result.Action = "error"
+			result.Errors = errs
+			failed++
+			continue
+		}
+
+		now := time.Now()
+		values["updated_at"] = now
+		if exists {
+			id := existing.ID
+			result.Action = "update"
+			result.ID = &id
+		} else {
+			id := uuid.New()
+			result.Action = "create"
+			result.ID = &id
+			values["id"] = id
+			values["created_at"] = now
+		}
+		operations = append(operations, masterImportOperation{result: result, values: values})
+	}
+
+	created, updated := 0, 0
+	for _, operation := range operations {
+		if operation.result.Action == "create" {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	report := fiber.Map{
+		"dry_run":    dryRun,
+		"total_rows": len(results),
+		"created":    created,
+		"updated":    updated,
+		"failed":     failed,
+		"rows":       results,
+	}
+
+	if dryRun {
+		// IDs of new records are only assigned when the import is written
+		for _, operation := range operations {
+			if operation.result.Action == "create" {
+				operation.result.ID = nil
+			}
+		}
+		return c.JSON(report)
+	}
+	if failed > 0 {
+		report["error"] = "Some rows have errors, nothing was imported"
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(report)
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		for _, operation := range operations {
+			var err error
+			if operation.result.Action == "create" {
+				err = tx.Model(spec.Model).Create(operation.values).Error
+			} else {
+				err = tx.Model(spec.Model).Where("id = ?", *operation.result.ID).Updates(operation.values).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to import %s records", spec.Entity),
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// ImportCities godoc
+// @Summary Import cities from a spreadsheet
+// @Description Create or update cities from a CSV or XLSX file with the columns code and name. Rows are matched to existing cities by code. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags Cities
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/cities/import [post]
+func ImportCities(c *fiber.Ctx) error {
+	return importMasterData(c, cityImportSpec)
+}
+
+// ImportExpeditions godoc
+// @Summary Import expeditions from a spreadsheet
+// @Description Create or update expeditions from a CSV or XLSX file with the columns code, name, description, address, city_code, area, phone1, phone2, email and website. Rows are matched to existing expeditions by code; empty cells leave existing values unchanged. Emails and phone numbers are validated like the create endpoint. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags Expeditions
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/expeditions/import [post]
+func ImportExpeditions(c *fiber.Ctx) error {
+	return importMasterData(c, expeditionImportSpec)
+}
+
+// ImportSalesAssociates godoc
+// @Summary Import sales associates from a spreadsheet
+// @Description Create or update sales associates from a CSV or XLSX file with the columns code, name, no_ktp, description, address, city_code, area, phone1, phone2, email, website, jenis_pembayaran (T or K), join_date and end_join_date (YYYY-MM-DD) and discount (0-100). Rows are matched to existing sales associates by code; empty cells leave existing values unchanged. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags SalesAssociates
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-associates/import [post]
+func ImportSalesAssociates(c *fiber.Ctx) error {
+	return importMasterData(c, salesAssociateImportSpec)
+}
+
+// ImportPublishers godoc
+// @Summary Import publishers from a spreadsheet
+// @Description Create or update publishers from a CSV or XLSX file with the columns code, name, description, address, city_code, area, phone1, phone2, email and website. Rows are matched to existing publishers by code; empty cells leave existing values unchanged. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags Publishers
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/publishers/import [post]
+func ImportPublishers(c *fiber.Ctx) error {
+	return importMasterData(c, publisherImportSpec)
+}
+
+// ImportBillers godoc
+// @Summary Import billers from a spreadsheet
+// @Description Create or update billers from a CSV or XLSX file with the columns code, name, description, npwp, address, city_code, phone1, phone2, fax, email and website. Rows are matched to existing billers by code; empty cells leave existing values unchanged. By default the file is only validated (dry_run=true); send dry_run=false to write all rows in one transaction, which only happens when no row has errors.
+// @Tags Billers
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file, max 10MB"
+// @Param dry_run formData bool false "Only validate the file (default: true)"
+// @Success 200 {object} map[string]interface{} "Import report with a result per row"
+// @Failure 400 {object} map[string]interface{} "Invalid file or columns"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 422 {object} map[string]interface{} "Some rows have errors, nothing was imported"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/billers/import [post]
+func ImportBillers(c *fiber.Ctx) error {
+	return importMasterData(c, billerImportSpec)
+}
@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted merk buku (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all merk buku with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllMerkBuku(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at ASC")
 	queryCount := config.DB.Model(&models.MerkBuku{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,6 +55,11 @@ func GetAllMerkBuku(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query, models.MerkBuku{}, "merk_buku")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&merkBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -113,7 +126,7 @@ func CreateMerkBuku(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&merkBuku).Error; err != nil {
+	if err := auditedDB(c).Create(&merkBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create merk buku",
 		})
@@ -153,7 +166,7 @@ func UpdateMerkBuku(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&merkBuku).Updates(merkBuku).Error; err != nil {
+	if err := auditedDB(c).Model(&merkBuku).Updates(merkBuku).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update merk buku",
 		})
@@ -164,35 +177,50 @@ func UpdateMerkBuku(c *fiber.Ctx) error {
 
 // DeleteMerkBuku godoc
 // @Summary Delete a merk buku
-// @Description Delete a book type by ID
+// @Description Soft delete a merk buku by ID. Deleted merk buku are hidden from lists and lookups and can be restored. Merk buku used by books, open sales transactions, pending draft orders or open stock opnames cannot be deleted.
 // @Tags MerkBuku
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "MerkBuku ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the merk buku taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "MerkBuku deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "MerkBuku not found"
+// @Failure 409 {object} map[string]interface{} "Merk buku is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/merk-buku/{id} [delete]
 func DeleteMerkBuku(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.MerkBuku{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete merk buku",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var merkBuku models.MerkBuku
+	if err := config.DB.Where("id = ?", id).First(&merkBuku).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MerkBuku not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "MerkBuku deleted successfully",
-	})
+	return deleteMasterRecord(c, &merkBuku, merkBuku.ID, "merk_buku", "Merk buku", "MerkBuku deleted successfully")
+}
+
+// RestoreMerkBuku godoc
+// @Summary Restore a deleted merk buku
+// @Description Undo the soft delete of a merk buku. Fails when another merk buku has taken its code or name since.
+// @Tags MerkBuku
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "MerkBuku ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored merk buku"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted merk buku not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/merk-buku/{id}/restore [post]
+func RestoreMerkBuku(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.MerkBuku{}, "Merk buku", "merk_buku")
 }
 
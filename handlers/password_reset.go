@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const defaultPasswordResetMinutes = 30 // PASSWORD_RESET_EXPIRE_MINUTES, lifetime of a reset token
+
+var mailer helpers.Mailer = helpers.SMTPMailer{}
+
+// SetMailer replaces the mailer used for password reset emails, e.g. with a helpers.CaptureMailer
+func SetMailer(m helpers.Mailer) {
+	mailer = m
+}
+
+// ForgotPasswordRequest is the body of the forgot-password endpoint
+type ForgotPasswordRequest struct {
+	Email string `json:"email" example:"user@example.com"`
+}
+
+// ResetPasswordRequest is the body of the reset-password endpoint
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"Xn2q6v0bP3kR8sT1uW4yZ7aC9dE2fG5hJ8kL1mN4pQ7"`
+	Password string `json:"password" example:"N3w-Passw0rd"`
+}
+
+// passwordResetMessage builds the reset email. With PASSWORD_RESET_URL set (the reset page of
+// the frontend) the email holds a link, otherwise only the token.
+func passwordResetMessage(user models.User, token string, ttl time.Duration) string {
+	instruction := "Use this token to reset your password: " + token
+	if resetURL := os.Getenv("PASSWORD_RESET_URL"); resetURL != "" {
+		instruction = "Open this link to reset your password: " + resetURL + "?token=" + token
+	}
+	return fmt.Sprintf("Hello %s,\n\nWe received a request to reset the password of your account.\n\n%s\n\n"+
+		"The token expires in %d minutes and can be used once. If you did not ask for a password reset, you can ignore this email.\n",
+		user.FullName, instruction, int(ttl.Minutes()))
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a one-time password reset token to the user. The token expires after PASSWORD_RESET_EXPIRE_MINUTES (default 30) and replaces earlier tokens. The response is the same whether or not the email belongs to a user, failures to create or send the token are only logged. Every request counts towards a limit per email address (PASSWORD_RESET_MAX_REQUESTS, default 3) and per IP address (PASSWORD_RESET_MAX_IP_REQUESTS, default 10), locked out like logins.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Email of the account"
+// @Success 200 {object} map[string]interface{} "Reset email sent if the account exists"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 429 {object} map[string]interface{} "Too many reset requests"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/forgot-password [post]
+func ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if !helpers.IsValidEmail(req.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid email format",
+		})
+	}
+
+	// Throttle before looking the email up, so unknown emails are limited the same way
+	keys := passwordResetLockoutKeys(normalizeLoginEmail(req.Email), c.IP())
+	retryAfter, err := lockoutRetryAfter(keys, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check reset requests",
+		})
+	}
+	if retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"error":       "Too many password reset requests, try again later",
+			"retry_after": seconds,
+		})
+	}
+	if err := registerFailure(keys, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record reset request",
+		})
+	}
+
+	response := fiber.Map{
+		"message": "If the email belongs to an account, a password reset link has been sent",
+	}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		return c.JSON(response)
+	}
+
+	// From here on an error would reveal that the account exists
+	token, hash, err := helpers.GenerateToken()
+	if err != nil {
+		log.Printf("Password reset for user %s: failed to generate token: %v", user.ID, err)
+		return c.JSON(response)
+	}
+	ttl := time.Duration(envInt("PASSWORD_RESET_EXPIRE_MINUTES", defaultPasswordResetMinutes)) * time.Minute
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if ip := c.IP(); ip != "" {
+		resetToken.IPAddress = &ip
+	}
+
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		// Only the latest token works
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if err != nil {
+		log.Printf("Password reset for user %s: failed to store token: %v", user.ID, err)
+		return c.JSON(response)
+	}
+
+	if err := mailer.Send(user.Email, "Reset your password", passwordResetMessage(user, token, ttl)); err != nil {
+		log.Printf("Password reset for user %s: failed to send email: %v", user.ID, err)
+	}
+
+	return c.JSON(response)
+}
+
+// ResetPassword godoc
+// @Summary Reset password with a token
+// @Description Set a new password with the token from the reset email. The token can be used once. All sessions of the user are logged out and the login lockout of the email is cleared.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]interface{} "Password reset"
+// @Failure 400 {object} map[string]interface{} "Invalid request body, weak password or invalid or expired token"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/reset-password [post]
+func ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "token is required",
+		})
+	}
+	if !helpers.IsStrongPassword(req.Password) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Password must be at least 8 characters long, must contain at least one number, one uppercase letter, one lowercase letter, and one special character",
+		})
+	}
+
+	var stored models.PasswordResetToken
+	if err := config.DB.Where("token_hash = ?", helpers.HashToken(req.Token)).First(&stored).Error; err != nil ||
+		stored.UsedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired reset token",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", stored.UserID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired reset token",
+		})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to hash password",
+		})
+	}
+
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		// Only one reset may win when the same token is sent twice at the same time
+		now := time.Now()
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired reset token")
+		}
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Update("password_hash", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	})
+	if err != nil {
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset password",
+		})
+	}
+
+	loginAttempts.DeleteLockout("email:" + normalizeLoginEmail(user.Email))
+
+	return c.JSON(fiber.Map{
+		"message": "Password has been reset, please log in with the new password",
+	})
+}
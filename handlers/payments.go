@@ -74,6 +74,8 @@ func GetTransactionPayments(c *fiber.Ctx) error {
 
 	var payments []models.Payment
 	if err := config.DB.
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Where("sales_transaction_id = ?", transactionID).
 		Order("payment_date ASC").
 		Find(&payments).Error; err != nil {
@@ -110,6 +112,12 @@ func CreatePayment(c *fiber.Ctx) error {
 		})
 	}
 
+	if transaction.Status == models.SalesStatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot add payment to a cancelled transaction",
+		})
+	}
+
 	var req CreatePaymentRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -194,7 +202,7 @@ func CreatePayment(c *fiber.Ctx) error {
 		Note:               req.Note,
 	}
 
-	if err := config.DB.Create(&payment).Error; err != nil {
+	if err := auditedDB(c).Create(&payment).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create payment",
 		})
@@ -211,14 +219,14 @@ func CreatePayment(c *fiber.Ctx) error {
 	}
 
 	if totalCoverage >= transaction.TotalAmount {
-		newStatus = 1
+		newStatus = models.SalesStatusPaidOff
 	} else if newTotalEffective > 0 {
-		newStatus = 2
+		newStatus = models.SalesStatusInstallment
 	} else {
-		newStatus = 0
+		newStatus = models.SalesStatusBooking
 	}
 
-	config.DB.Model(&transaction).Update("status", newStatus)
+	auditedDB(c).Model(&transaction).Update("status", newStatus)
 
 	remainingAmount := transaction.TotalAmount - totalCoverage
 	if remainingAmount < 0 {
@@ -254,7 +262,7 @@ func DeletePayment(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Delete(&payment).Error; err != nil {
+	if err := auditedDB(c).Delete(&payment).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete payment",
 		})
@@ -280,14 +288,14 @@ func DeletePayment(c *fiber.Ctx) error {
 	}
 
 	if totalCoverage >= transaction.TotalAmount {
-		newStatus = 1
+		newStatus = models.SalesStatusPaidOff
 	} else if totalEffective > 0 {
-		newStatus = 2
+		newStatus = models.SalesStatusInstallment
 	} else {
-		newStatus = 0
+		newStatus = models.SalesStatusBooking
 	}
 
-	config.DB.Model(&transaction).Update("status", newStatus)
+	auditedDB(c).Model(&transaction).Update("status", newStatus)
 
 	remainingAmount := transaction.TotalAmount - totalCoverage
 	if remainingAmount < 0 {
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The portal is the self-service API of field sales associates. Its routes run after
+// middleware.SalesAssociateRequired and every query is scoped to the sales associate linked to
+// the logged in user, never to an ID sent by the client. Responses leave out costs and
+// margins.
+
+// portalSalesAssociateID returns the sales associate linked to the logged in user
+func portalSalesAssociateID(c *fiber.Ctx) uuid.UUID {
+	id, _ := c.Locals("salesAssociateID").(string)
+	return helpers.ParseUUID(id)
+}
+
+// PortalTransactionItem is a line of a sales transaction as shown on the portal
+type PortalTransactionItem struct {
+	BookID              uuid.UUID  `json:"book_id"`
+	BookName            string     `json:"book_name"`
+	BundleID            *uuid.UUID `json:"bundle_id"`
+	Quantity            int        `json:"quantity"`
+	BackorderedQuantity int        `json:"backordered_quantity"`
+	Price               float64    `json:"price"`
+	Promotion           float64    `json:"promotion"`
+	Discount            float64    `json:"discount"`
+	Subtotal            float64    `json:"subtotal"`
+}
+
+// PortalPayment is a payment as shown on the portal
+type PortalPayment struct {
+	ID                 uuid.UUID `json:"id"`
+	SalesTransactionID uuid.UUID `json:"sales_transaction_id"`
+	NoInvoice          string    `json:"no_invoice"`
+	NoPayment          string    `json:"no_payment"`
+	PaymentDate        time.Time `json:"payment_date"`
+	Amount             float64   `json:"amount"`
+	DiscountAmount     float64   `json:"discount_amount"`
+	Note               *string   `json:"note"`
+}
+
+// PortalTransaction is a sales transaction as shown on the portal, with what is left to pay
+type PortalTransaction struct {
+	ID              uuid.UUID               `json:"id"`
+	NoInvoice       string                  `json:"no_invoice"`
+	PaymentType     string                  `json:"payment_type"`
+	TransactionDate time.Time               `json:"transaction_date"`
+	TotalAmount     float64                 `json:"total_amount"`
+	TotalPaid       float64                 `json:"total_paid"`
+	TotalDiscount   float64                 `json:"total_discount"`
+	RemainingAmount float64                 `json:"remaining_amount"`
+	Status          int                     `json:"status"`
+	Periode         int                     `json:"periode"`
+	Year            string                  `json:"year"`
+	Items           []PortalTransactionItem `json:"items,omitempty"`
+	Payments        []PortalPayment         `json:"payments,omitempty"`
+}
+
+// newPortalTransaction converts a sales transaction loaded with its payments, and items when
+// needed, for the portal
+func newPortalTransaction(transaction models.SalesTransaction) PortalTransaction {
+	result := PortalTransaction{
+		ID:              transaction.ID,
+		NoInvoice:       transaction.NoInvoice,
+		PaymentType:     transaction.PaymentType,
+		TransactionDate: transaction.TransactionDate,
+		TotalAmount:     transaction.TotalAmount,
+		Status:          transaction.Status,
+		Periode:         transaction.Periode,
+		Year:            transaction.Year,
+	}
+	for _, item := range transaction.Items {
+		line := PortalTransactionItem{
+			BookID:              item.BookID,
+			BundleID:            item.BundleID,
+			Quantity:            item.Quantity,
+			BackorderedQuantity: item.BackorderedQuantity,
+			Price:               item.Price,
+			Promotion:           item.Promotion,
+			Discount:            item.Discount,
+			Subtotal:            item.Subtotal,
+		}
+		if item.Book != nil {
+			line.BookName = item.Book.Name
+		}
+		result.Items = append(result.Items, line)
+	}
+	for _, payment := range transaction.Payments {
+		result.TotalPaid += payment.Amount
+		result.TotalDiscount += payment.DiscountAmount
+		result.Payments = append(result.Payments, PortalPayment{
+			ID:                 payment.ID,
+			SalesTransactionID: payment.SalesTransactionID,
+			NoInvoice:          transaction.NoInvoice,
+			NoPayment:          payment.NoPayment,
+			PaymentDate:        payment.PaymentDate,
+			Amount:             payment.Amount,
+			DiscountAmount:     payment.DiscountAmount,
+			Note:               payment.Note,
+		})
+	}
+	result.RemainingAmount = roundCents(result.TotalAmount - result.TotalPaid - result.TotalDiscount)
+	return result
+}
+
+// GetPortalSalesTransactions godoc
+// @Summary Get my sales transactions
+// @Description Sales transactions of the sales associate linked to the logged in user, newest first, with what is paid and left to pay. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param status query int false "Exact match: 0 (Pesanan), 1 (Lunas), 2 (Angsuran), 3 (Dibatalkan)"
+// @Param payment_type query string false "Exact match: T (Tunai/Cash) or K (Kredit/Credit)"
+// @Param start_date query string false "Transaction date from (YYYY-MM-DD)"
+// @Param end_date query string false "Transaction date to (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{} "List of sales transactions with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/sales-transactions [get]
+func GetPortalSalesTransactions(c *fiber.Ctx) error {
+	var transactions []models.SalesTransaction
+
+	pagination := helpers.GetPaginationParams(c)
+	salesAssociateID := portalSalesAssociateID(c)
+
+	query := config.DB.Order("transaction_date DESC").Where("sales_associate_id = ?", salesAssociateID)
+	queryCount := config.DB.Model(&models.SalesTransaction{}).Where("sales_associate_id = ?", salesAssociateID)
+
+	if status := c.Query("status"); status != "" {
+		if _, err := strconv.Atoi(status); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid status",
+			})
+		}
+		query = query.Where("status = ?", status)
+		queryCount = queryCount.Where("status = ?", status)
+	}
+
+	if paymentType := c.Query("payment_type"); paymentType != "" {
+		query = query.Where("payment_type = ?", paymentType)
+		queryCount = queryCount.Where("payment_type = ?", paymentType)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("transaction_date >= ?", startDate)
+		queryCount = queryCount.Where("transaction_date >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("transaction_date <= ?", endDate+" 23:59:59")
+		queryCount = queryCount.Where("transaction_date <= ?", endDate+" 23:59:59")
+	}
+
+	if err := query.
+		Preload("Payments").
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sales transactions",
+		})
+	}
+
+	result := make([]PortalTransaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		result = append(result, newPortalTransaction(transaction))
+	}
+
+	response, err := helpers.CreatePaginationResponse(queryCount, result, "sales_transactions", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetPortalSalesTransaction godoc
+// @Summary Get one of my sales transactions
+// @Description A sales transaction of the sales associate linked to the logged in user, with its items and payments. Transactions of other associates are not found. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID (UUID)"
+// @Success 200 {object} PortalTransaction "Transaction details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Router /api/portal/sales-transactions/{id} [get]
+func GetPortalSalesTransaction(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Transaction not found",
+		})
+	}
+
+	var transaction models.SalesTransaction
+	if err := config.DB.
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Preload("Payments").
+		Where("id = ? AND sales_associate_id = ?", id, portalSalesAssociateID(c)).
+		First(&transaction).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Transaction not found",
+		})
+	}
+
+	return c.JSON(newPortalTransaction(transaction))
+}
+
+// GetPortalPayments godoc
+// @Summary Get my payments
+// @Description Payments on the sales transactions of the sales associate linked to the logged in user, newest first. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param start_date query string false "Payment date from (YYYY-MM-DD)"
+// @Param end_date query string false "Payment date to (YYYY-MM-DD)"
+// @Success 200 {object} map[string]interface{} "List of payments with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/payments [get]
+func GetPortalPayments(c *fiber.Ctx) error {
+	var payments []PortalPayment
+
+	pagination := helpers.GetPaginationParams(c)
+	salesAssociateID := portalSalesAssociateID(c)
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.Payment{}).
+			Joins("JOIN sales_transactions ON sales_transactions.id = payments.sales_transaction_id").
+			Where("sales_transactions.sales_associate_id = ?", salesAssociateID)
+	}
+	query := config.DB.Scopes(scope).
+		Select("payments.id, payments.sales_transaction_id, sales_transactions.no_invoice, payments.no_payment, payments.payment_date, payments.amount, payments.discount_amount, payments.note").
+		Order("payments.payment_date DESC")
+	queryCount := config.DB.Scopes(scope)
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("payments.payment_date >= ?", startDate)
+		queryCount = queryCount.Where("payments.payment_date >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("payments.payment_date <= ?", endDate+" 23:59:59")
+		queryCount = queryCount.Where("payments.payment_date <= ?", endDate+" 23:59:59")
+	}
+
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Scan(&payments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch payments",
+		})
+	}
+	if payments == nil {
+		payments = []PortalPayment{}
+	}
+
+	response, err := helpers.CreatePaginationResponse(queryCount, payments, "payments", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetPortalCredits godoc
+// @Summary Get my outstanding credit
+// @Description Credit (payment type K) transactions of the sales associate linked to the logged in user that are not paid off or cancelled, oldest first, with what is left to pay. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Outstanding transactions and summary"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/credits [get]
+func GetPortalCredits(c *fiber.Ctx) error {
+	var transactions []models.SalesTransaction
+
+	outstandingStatuses := []int{models.SalesStatusBooking, models.SalesStatusInstallment}
+	if err := config.DB.Order("transaction_date ASC").
+		Where("sales_associate_id = ?", portalSalesAssociateID(c)).
+		Where("payment_type = ?", "K").
+		Where("status IN ?", outstandingStatuses).
+		Preload("Payments").
+		Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch credits",
+		})
+	}
+
+	credits := make([]PortalTransaction, 0, len(transactions))
+	var totalOutstanding float64
+	for _, transaction := range transactions {
+		credit := newPortalTransaction(transaction)
+		if credit.RemainingAmount <= 0 {
+			continue // Paid off, waiting for its status to be updated
+		}
+		credits = append(credits, credit)
+		totalOutstanding += credit.RemainingAmount
+	}
+
+	return c.JSON(fiber.Map{
+		"data": credits,
+		"summary": fiber.Map{
+			"total_transactions": len(credits),
+			"total_outstanding":  roundCents(totalOutstanding),
+		},
+	})
+}
+
+// GetPortalStatement godoc
+// @Summary Get my statement
+// @Description Statement of the sales associate linked to the logged in user: opening balance, invoices and payments with a running balance, and closing balance. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param start_date query string false "Start of the period (YYYY-MM-DD); earlier entries make up the opening balance"
+// @Param end_date query string false "End of the period (YYYY-MM-DD)"
+// @Success 200 {object} SalesAssociateStatement "Statement"
+// @Failure 400 {object} map[string]interface{} "Invalid date"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/statement [get]
+func GetPortalStatement(c *fiber.Ctx) error {
+	statement, err := buildSalesAssociateStatement(portalSalesAssociateID(c), c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(statement)
+}
+
+// PortalBook is a book as shown in the portal catalog
+type PortalBook struct {
+	ID             uuid.UUID  `json:"id"`
+	Code           *string    `json:"code"`
+	ISBN           *string    `json:"isbn"`
+	Name           string     `json:"name"`
+	Year           string     `json:"year"`
+	Periode        int        `json:"periode"`
+	Kelas          *string    `json:"kelas"`
+	Price          float64    `json:"price"`
+	Stock          int        `json:"stock"`
+	MerkBukuID     *uuid.UUID `json:"merk_buku_id"`
+	JenjangStudiID *uuid.UUID `json:"jenjang_studi_id"`
+	CurriculumID   *uuid.UUID `json:"curriculum_id"`
+}
+
+// GetPortalBooks godoc
+// @Summary Get the book catalog
+// @Description Books with their list price and stock, to pick for draft orders. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param search query string false "Search by name, code or ISBN"
+// @Param merk_buku_id query string false "Filter by merk buku"
+// @Param jenjang_studi_id query string false "Filter by jenjang studi"
+// @Success 200 {object} map[string]interface{} "List of books with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/books [get]
+func GetPortalBooks(c *fiber.Ctx) error {
+	var books []PortalBook
+
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Model(&models.Book{}).
+		Select("id, code, isbn, name, year, periode, kelas, price, stock, merk_buku_id, jenjang_studi_id, curriculum_id").
+		Order("name ASC")
+	queryCount := config.DB.Model(&models.Book{})
+
+	if search := c.Query("search"); search != "" {
+		searchTerm := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR code ILIKE ? OR isbn ILIKE ?", searchTerm, searchTerm, searchTerm)
+		queryCount = queryCount.Where("name ILIKE ? OR code ILIKE ? OR isbn ILIKE ?", searchTerm, searchTerm, searchTerm)
+	}
+
+	if merkBukuID := c.Query("merk_buku_id"); merkBukuID != "" {
+		query = query.Where("merk_buku_id = ?", merkBukuID)
+		queryCount = queryCount.Where("merk_buku_id = ?", merkBukuID)
+	}
+
+	if jenjangStudiID := c.Query("jenjang_studi_id"); jenjangStudiID != "" {
+		query = query.Where("jenjang_studi_id = ?", jenjangStudiID)
+		queryCount = queryCount.Where("jenjang_studi_id = ?", jenjangStudiID)
+	}
+
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Scan(&books).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch books",
+		})
+	}
+	if books == nil {
+		books = []PortalBook{}
+	}
+
+	response, err := helpers.CreatePaginationResponse(queryCount, books, "books", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// CreateSalesOrderDraftRequest represents the request body for submitting a draft order
+type CreateSalesOrderDraftRequest struct {
+	PaymentType    string                             `json:"payment_type"` // 'T' or 'K'
+	Periode        int                                `json:"periode"`
+	Year           string                             `json:"year"`
+	CurriculumID   *string                            `json:"curriculum_id"`
+	MerkBukuID     *string                            `json:"merk_buku_id"`
+	JenjangStudiID *string                            `json:"jenjang_studi_id"`
+	Note           *string                            `json:"note"`
+	Items          []CreateSalesOrderDraftItemRequest `json:"items"`
+}
+
+// CreateSalesOrderDraftItemRequest represents a book on a draft order
+type CreateSalesOrderDraftItemRequest struct {
+	BookID   string `json:"book_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// generateOrderNumber generates sequential draft order number: ORD + YYYYMMDD + 8-digit sequence
+// Example: ORD2023120500000001
+func generateOrderNumber(db *gorm.DB) (string, error) {
+	prefix := "ORD"
+	dateStr := time.Now().Format("20060102") // YYYYMMDD
+	pattern := prefix + dateStr + "%"
+
+	var maxNumber string
+	err := db.Model(&models.SalesOrderDraft{}).
+		Where("no_order LIKE ?", pattern).
+		Select("COALESCE(MAX(no_order), '')").
+		Scan(&maxNumber).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if maxNumber != "" {
+		// Extract sequence part (last 8 digits)
+		seqStr := maxNumber[len(prefix)+8:] // Skip prefix (3) + date (8)
+		if seq, err := strconv.Atoi(seqStr); err == nil {
+			nextSeq = seq + 1
+		}
+	}
+
+	return fmt.Sprintf("%s%s%08d", prefix, dateStr, nextSeq), nil
+}
+
+// CreatePortalOrder godoc
+// @Summary Submit a draft order
+// @Description Submit an order for the sales associate linked to the logged in user. It waits as a pending draft until staff approve it into a sales transaction, setting prices and the warehouse, or reject it. Stock is not reserved until approval. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateSalesOrderDraftRequest true "Draft order"
+// @Success 201 {object} models.SalesOrderDraft "Submitted draft order"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/orders [post]
+func CreatePortalOrder(c *fiber.Ctx) error {
+	var req CreateSalesOrderDraftRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Year == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "year is required",
+		})
+	}
+
+	if req.Periode <= 0 {
+		req.Periode = 1 // Default to 1 if not provided
+	}
+
+	if req.PaymentType != "T" && req.PaymentType != "K" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "payment_type must be either 'T' (cash) or 'K' (credit)",
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	items := make([]models.SalesOrderDraftItem, 0, len(req.Items))
+	bookIDs := make([]uuid.UUID, 0, len(req.Items))
+	for _, item := range req.Items {
+		bookID, err := uuid.Parse(item.BookID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Book with ID %s not found", item.BookID),
+			})
+		}
+		if item.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Quantity must be greater than 0",
+			})
+		}
+		items = append(items, models.SalesOrderDraftItem{BookID: bookID, Quantity: item.Quantity})
+		bookIDs = append(bookIDs, bookID)
+	}
+
+	// Every book must exist
+	var found []uuid.UUID
+	if err := config.DB.Model(&models.Book{}).Where("id IN ?", bookIDs).Pluck("id", &found).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check books",
+		})
+	}
+	existing := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+	for _, item := range items {
+		if !existing[item.BookID] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Book with ID %s not found", item.BookID),
+			})
+		}
+	}
+
+	draft := models.SalesOrderDraft{
+		SalesAssociateID: portalSalesAssociateID(c),
+		PaymentType:      req.PaymentType,
+		Periode:          req.Periode,
+		Year:             req.Year,
+		CurriculumID:     helpers.ParseUUIDPtr(req.CurriculumID),
+		MerkBukuID:       helpers.ParseUUIDPtr(req.MerkBukuID),
+		JenjangStudiID:   helpers.ParseUUIDPtr(req.JenjangStudiID),
+		Note:             req.Note,
+		Status:           models.DraftStatusPending,
+		Items:            items,
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		noOrder, err := generateOrderNumber(tx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to generate order number")
+		}
+		draft.NoOrder = noOrder
+
+		if err := tx.Create(&draft).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to create draft order")
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(draft)
+}
+
+// GetPortalOrders godoc
+// @Summary Get my draft orders
+// @Description Draft orders of the sales associate linked to the logged in user, newest first. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param status query int false "Exact match: 0 (pending), 1 (approved), 2 (rejected)"
+// @Success 200 {object} map[string]interface{} "List of draft orders with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/portal/orders [get]
+func GetPortalOrders(c *fiber.Ctx) error {
+	return listSalesOrderDrafts(c, config.DB.Where("sales_associate_id = ?", portalSalesAssociateID(c)))
+}
+
+// GetPortalOrder godoc
+// @Summary Get one of my draft orders
+// @Description A draft order of the sales associate linked to the logged in user with its books. Drafts of other associates are not found. Requires portal:access.
+// @Tags Portal
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Draft order ID (UUID)"
+// @Success 200 {object} models.SalesOrderDraft "Draft order"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Not linked to a sales associate"
+// @Failure 404 {object} map[string]interface{} "Draft order not found"
+// @Router /api/portal/orders/{id} [get]
+func GetPortalOrder(c *fiber.Ctx) error {
+	return getSalesOrderDraft(c, config.DB.Where("sales_associate_id = ?", portalSalesAssociateID(c)))
+}
@@ -17,6 +17,8 @@ import (
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted publishers (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all publishers with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -30,6 +32,12 @@ func GetAllPublishers(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at DESC")
 	queryCount := config.DB.Model(&models.Publisher{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -47,8 +55,13 @@ func GetAllPublishers(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("City", withDeleted), models.Publisher{}, "publishers")
+	}
+
 	// Apply pagination and fetch data
-	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City").Find(&publishers).Error; err != nil {
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City", withDeleted).Find(&publishers).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch all publishers",
 		})
@@ -81,7 +94,7 @@ func GetPublisher(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var publisher models.Publisher
-	if err := config.DB.Preload("City").Where("id = ?", id).First(&publisher).Error; err != nil {
+	if err := config.DB.Preload("City", withDeleted).Where("id = ?", id).First(&publisher).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Publisher not found",
 		})
@@ -113,7 +126,7 @@ func CreatePublisher(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Create(&publisher).Error; err != nil {
+	if err := auditedDB(c).Create(&publisher).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create publisher",
 		})
@@ -153,7 +166,7 @@ func UpdatePublisher(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&publisher).Updates(publisher).Error; err != nil {
+	if err := auditedDB(c).Model(&publisher).Updates(publisher).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update publisher",
 		})
@@ -164,34 +177,49 @@ func UpdatePublisher(c *fiber.Ctx) error {
 
 // DeletePublisher godoc
 // @Summary Delete a publisher
-// @Description Delete a publisher by ID
+// @Description Soft delete a publisher by ID. Deleted publishers are hidden from lists and lookups and can be restored. Publishers of books and suppliers of open purchase transactions cannot be deleted.
 // @Tags Publishers
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Publisher ID (UUID)"
+// @Param force query bool false "Reassign blocking rows to replacement_id, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the publisher taking over the blocking rows, required with force"
 // @Success 200 {object} map[string]interface{} "Publisher deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "Publisher not found"
+// @Failure 409 {object} map[string]interface{} "Publisher is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/publishers/{id} [delete]
 func DeletePublisher(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.Publisher{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete publisher",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var publisher models.Publisher
+	if err := config.DB.Where("id = ?", id).First(&publisher).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Publisher not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "Publisher deleted successfully",
-	})
+	return deleteMasterRecord(c, &publisher, publisher.ID, "publishers", "Publisher", "Publisher deleted successfully")
+}
+
+// RestorePublisher godoc
+// @Summary Restore a deleted publisher
+// @Description Undo the soft delete of a publisher. Fails when another publisher has taken its code or name since.
+// @Tags Publishers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Publisher ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored publisher"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted publisher not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/publishers/{id}/restore [post]
+func RestorePublisher(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Publisher{}, "Publisher", "publisher")
 }
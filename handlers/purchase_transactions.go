@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"pustaka-backend/models"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +19,7 @@ import (
 type CreatePurchaseTransactionRequest struct {
 	SupplierID   string                             `json:"supplier_id"`
 	PurchaseDate models.Date                        `json:"purchase_date"`
+	WarehouseID  *string                            `json:"warehouse_id"` // Receiving warehouse, defaults to the default warehouse
 	Note         *string                            `json:"note"`
 	Items        []CreatePurchaseTransactionItemReq `json:"items"`
 }
@@ -32,8 +35,9 @@ type CreatePurchaseTransactionItemReq struct {
 type UpdatePurchaseTransactionRequest struct {
 	SupplierID   *string                            `json:"supplier_id"`
 	PurchaseDate *models.Date                       `json:"purchase_date"`
+	WarehouseID  *string                            `json:"warehouse_id"`
 	Note         *string                            `json:"note"`
-	Status       *int                               `json:"status"`
+	Status       *int                               `json:"status"` // Rejected, status moves through the receipt, complete, close and cancel endpoints
 	Items        []CreatePurchaseTransactionItemReq `json:"items,omitempty"`
 }
 
@@ -81,11 +85,13 @@ func generatePurchaseInvoiceNumber(db *gorm.DB) (string, error) {
 // @Param purchase_date_to query string false "End date for date range filter (ISO format: YYYY-MM-DD)"
 // @Param total_amount_min query number false "Minimum total amount"
 // @Param total_amount_max query number false "Maximum total amount"
-// @Param status query int false "Exact match: 0 (Pending), 1 (Selesai), 2 (Dibatalkan)"
+// @Param status query int false "Exact match: 0 (Pending), 1 (Selesai), 2 (Dibatalkan), 3 (Diterima Sebagian), 4 (Ditutup Kurang)"
+// @Param created_by_id query string false "Filter by the user who entered the transaction"
 // @Param created_at_from query string false "Start date for date range filter (ISO format: YYYY-MM-DDTHH:mm:ss.sssZ)"
 // @Param created_at_to query string false "End date for date range filter (ISO format: YYYY-MM-DDTHH:mm:ss.sssZ)"
 // @Param sort_by query string false "Field to sort by: no_invoice, supplier_name, purchase_date, total_amount, status, created_at"
 // @Param sort_order query string false "Sort order: asc or desc (default: desc)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all purchase transactions with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -168,6 +174,12 @@ func GetAllPurchaseTransactions(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("purchase_transactions.status = ?", status)
 	}
 
+	// Filter by the user who entered the transaction
+	if createdByID := c.Query("created_by_id"); createdByID != "" {
+		query = query.Where("purchase_transactions.created_by_id = ?", createdByID)
+		queryCount = queryCount.Where("purchase_transactions.created_by_id = ?", createdByID)
+	}
+
 	// Sorting
 	sortBy := c.Query("sort_by")
 	sortOrder := c.Query("sort_order")
@@ -209,18 +221,26 @@ func GetAllPurchaseTransactions(c *fiber.Ctx) error {
 		query = query.Order("purchase_transactions.created_at " + sortOrder)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("Supplier", withDeleted).Preload("Warehouse", withDeleted).Preload("CreatedBy").Preload("UpdatedBy"), models.PurchaseTransaction{}, "purchase_transactions")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.
 		Offset(pagination.Offset).Limit(pagination.Limit).
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.BidangStudi").
-		Preload("Items.Book.JenjangStudi").
-		Preload("Items.Book.Curriculum").
-		Preload("Items.Book.Publisher").
-		Preload("Items.Book.JenisBuku").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.BidangStudi", withDeleted).
+		Preload("Items.Book.JenjangStudi", withDeleted).
+		Preload("Items.Book.Curriculum", withDeleted).
+		Preload("Items.Book.Publisher", withDeleted).
+		Preload("Items.Book.JenisBuku", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch purchase transactions",
@@ -255,15 +275,18 @@ func GetPurchaseTransaction(c *fiber.Ctx) error {
 
 	var transaction models.PurchaseTransaction
 	if err := config.DB.
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.BidangStudi").
-		Preload("Items.Book.JenjangStudi").
-		Preload("Items.Book.Curriculum").
-		Preload("Items.Book.Publisher").
-		Preload("Items.Book.JenisBuku").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.BidangStudi", withDeleted).
+		Preload("Items.Book.JenjangStudi", withDeleted).
+		Preload("Items.Book.Curriculum", withDeleted).
+		Preload("Items.Book.Publisher", withDeleted).
+		Preload("Items.Book.JenisBuku", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Where("id = ?", id).First(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Purchase transaction not found",
@@ -317,8 +340,18 @@ func CreatePurchaseTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Verify receiving warehouse exists (resolved to the default warehouse on completion if empty)
+	if req.WarehouseID != nil && *req.WarehouseID != "" {
+		var warehouse models.Warehouse
+		if err := config.DB.Where("id = ?", *req.WarehouseID).First(&warehouse).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Warehouse not found",
+			})
+		}
+	}
+
 	// Start a database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -385,6 +418,7 @@ func CreatePurchaseTransaction(c *fiber.Ctx) error {
 		PurchaseDate: req.PurchaseDate,
 		TotalAmount:  totalAmount,
 		Status:       models.PurchaseStatusPending, // 0 = pending
+		WarehouseID:  helpers.ParseUUIDPtr(req.WarehouseID),
 		Note:         req.Note,
 	}
 
@@ -417,10 +451,13 @@ func CreatePurchaseTransaction(c *fiber.Ctx) error {
 	// Fetch the created transaction with all relations
 	var createdTransaction models.PurchaseTransaction
 	config.DB.
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Where("id = ?", transaction.ID).First(&createdTransaction)
 
 	return c.Status(fiber.StatusCreated).JSON(createdTransaction)
@@ -428,7 +465,7 @@ func CreatePurchaseTransaction(c *fiber.Ctx) error {
 
 // UpdatePurchaseTransaction godoc
 // @Summary Update a purchase transaction
-// @Description Update an existing purchase transaction by ID. Cannot update completed or cancelled transactions.
+// @Description Update an existing purchase transaction by ID. Only pending transactions can be updated, and the status cannot be set here.
 // @Tags Purchase Transactions
 // @Accept json
 // @Produce json
@@ -465,8 +502,15 @@ func UpdatePurchaseTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Receiving, completing, closing and cancelling move stock and cost, so the status is never set directly
+	if req.Status != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "status cannot be updated, use the goods receipt, complete, close or cancel endpoints",
+		})
+	}
+
 	// Start a database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -491,12 +535,21 @@ func UpdatePurchaseTransaction(c *fiber.Ctx) error {
 		updates["purchase_date"] = *req.PurchaseDate
 	}
 
-	if req.Note != nil {
-		updates["note"] = *req.Note
+	if req.WarehouseID != nil {
+		if *req.WarehouseID != "" {
+			var warehouse models.Warehouse
+			if err := tx.Where("id = ?", *req.WarehouseID).First(&warehouse).Error; err != nil {
+				tx.Rollback()
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Warehouse not found",
+				})
+			}
+		}
+		updates["warehouse_id"] = helpers.ParseUUIDPtr(req.WarehouseID)
 	}
 
-	if req.Status != nil {
-		updates["status"] = *req.Status
+	if req.Note != nil {
+		updates["note"] = *req.Note
 	}
 
 	// Handle items updates
@@ -578,10 +631,13 @@ func UpdatePurchaseTransaction(c *fiber.Ctx) error {
 	// Fetch the updated transaction with all relations
 	var updatedTransaction models.PurchaseTransaction
 	config.DB.
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Where("id = ?", id).First(&updatedTransaction)
 
 	return c.JSON(updatedTransaction)
@@ -589,7 +645,7 @@ func UpdatePurchaseTransaction(c *fiber.Ctx) error {
 
 // DeletePurchaseTransaction godoc
 // @Summary Delete a purchase transaction
-// @Description Delete a purchase transaction by ID. If status is completed, stock will be restored.
+// @Description Delete a purchase transaction by ID. Stock received through goods receipts is taken back out of the receiving warehouse and the average cost of the books is restored. The delete is refused when part of the received stock has already been sold, moved or allocated to backorders.
 // @Tags Purchase Transactions
 // @Accept json
 // @Produce json
@@ -598,42 +654,65 @@ func UpdatePurchaseTransaction(c *fiber.Ctx) error {
 // @Success 200 {object} map[string]interface{} "Transaction deleted successfully"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 409 {object} map[string]interface{} "Received stock has already been used"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/purchase-transactions/{id} [delete]
 func DeletePurchaseTransaction(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	// Verify transaction exists and get items
-	var transaction models.PurchaseTransaction
-	if err := config.DB.Preload("Items").Where("id = ?", id).First(&transaction).Error; err != nil {
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Verify transaction exists and get items, locked so no goods are received while it is deleted
+	transaction, err := lockPurchaseForReceipt(tx, id)
+	if err != nil {
+		tx.Rollback()
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Purchase transaction not found",
 		})
 	}
 
-	// Start database transaction
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
+	// If goods were received, restore stock (decrease it in the receiving warehouse)
+	if transaction.Status == models.PurchaseStatusCompleted ||
+		transaction.Status == models.PurchaseStatusPartiallyReceived ||
+		transaction.Status == models.PurchaseStatusClosedShort {
+		warehouseID, err := warehouseIDOrDefault(tx, transaction.WarehouseID)
+		if err != nil {
 			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve receiving warehouse",
+			})
 		}
-	}()
 
-	// If transaction was completed, restore stock (decrease it)
-	if transaction.Status == models.PurchaseStatusCompleted {
 		for _, item := range transaction.Items {
-			var book models.Book
-			if err := tx.Where("id = ?", item.BookID).First(&book).Error; err == nil {
-				newStock := book.Stock - item.Quantity
-				if newStock < 0 {
-					newStock = 0 // Prevent negative stock
-				}
-				if err := tx.Model(&book).Update("stock", newStock).Error; err != nil {
-					tx.Rollback()
-					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-						"error": "Failed to restore book stock",
+			if item.ReceivedQuantity <= 0 {
+				continue
+			}
+
+			if err := releaseAtCost(tx, item.BookID, item.ReceivedQuantity, item.Price); err != nil {
+				tx.Rollback()
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to restore book average cost",
+				})
+			}
+
+			// The received stock must still be in the warehouse, otherwise the sales, transfers
+			// or backorders that used it would be left without stock
+			if err := adjustWarehouseStock(tx, item.BookID, warehouseID, -item.ReceivedQuantity); err != nil {
+				tx.Rollback()
+				if errors.Is(err, errInsufficientStock) {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+						"error": "Received stock of this purchase has already been sold, moved or allocated to backorders",
 					})
 				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to restore book stock",
+				})
 			}
 		}
 	}
@@ -660,7 +739,7 @@ func DeletePurchaseTransaction(c *fiber.Ctx) error {
 
 // CompletePurchaseTransaction godoc
 // @Summary Mark a purchase transaction as completed
-// @Description Mark a pending purchase transaction as completed. This will increase book stock for all items.
+// @Description Mark a pending or partially received purchase transaction as completed. Everything still outstanding is received as one goods receipt, book stock is increased and the received stock is allocated to waiting backorders, oldest sales transaction first.
 // @Tags Purchase Transactions
 // @Accept json
 // @Produce json
@@ -675,53 +754,44 @@ func DeletePurchaseTransaction(c *fiber.Ctx) error {
 func CompletePurchaseTransaction(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	// Verify transaction exists and get items
-	var transaction models.PurchaseTransaction
-	if err := config.DB.Preload("Items").Where("id = ?", id).First(&transaction).Error; err != nil {
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Verify transaction exists and get items, locked against concurrent receipts
+	transaction, err := lockPurchaseForReceipt(tx, id)
+	if err != nil {
+		tx.Rollback()
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Purchase transaction not found",
 		})
 	}
 
-	// Check if transaction is pending
-	if transaction.Status != models.PurchaseStatusPending {
+	// Check if goods can still be received
+	if !canReceivePurchase(transaction.Status) {
+		tx.Rollback()
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Only pending transactions can be completed",
+			"error": "Only pending or partially received transactions can be completed",
 		})
 	}
 
-	// Start database transaction
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Increase stock for all items
+	// Receive everything that is still outstanding as one goods receipt
+	quantities := make(map[uuid.UUID]int)
 	for _, item := range transaction.Items {
-		var book models.Book
-		if err := tx.Where("id = ?", item.BookID).First(&book).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Book with ID %s not found", item.BookID.String()),
-			})
-		}
-
-		// Increase stock
-		if err := tx.Model(&book).Update("stock", book.Stock+item.Quantity).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update book stock",
-			})
+		if outstanding := outstandingQuantity(item); outstanding > 0 {
+			quantities[item.ID] = outstanding
 		}
 	}
 
-	// Update transaction status to completed
-	if err := tx.Model(&transaction).Update("status", models.PurchaseStatusCompleted).Error; err != nil {
+	receipt, allocations, err := receiveGoods(tx, &transaction, quantities, models.Date{}, nil)
+	if err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update transaction status",
+			"error": "Failed to receive goods",
 		})
 	}
 
@@ -734,15 +804,20 @@ func CompletePurchaseTransaction(c *fiber.Ctx) error {
 
 	// Fetch the updated transaction with all relations
 	config.DB.
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Where("id = ?", id).First(&transaction)
 
 	return c.JSON(fiber.Map{
-		"message":              "Purchase transaction completed successfully. Stock has been increased.",
-		"purchase_transaction": transaction,
+		"message":               "Purchase transaction completed successfully. Stock has been increased.",
+		"purchase_transaction":  transaction,
+		"goods_receipt":         receipt,
+		"backorder_allocations": allocations,
 	})
 }
 
@@ -758,6 +833,7 @@ func CompletePurchaseTransaction(c *fiber.Ctx) error {
 // @Failure 400 {object} map[string]interface{} "Transaction cannot be cancelled"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 409 {object} map[string]interface{} "Transaction was changed concurrently"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/purchase-transactions/{id}/cancel [post]
 func CancelPurchaseTransaction(c *fiber.Ctx) error {
@@ -777,12 +853,20 @@ func CancelPurchaseTransaction(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update transaction status to cancelled
-	if err := config.DB.Model(&transaction).Update("status", models.PurchaseStatusCancelled).Error; err != nil {
+	// Update transaction status to cancelled, unless goods were received in the meantime
+	result := auditedDB(c).Model(&transaction).
+		Where("status = ?", models.PurchaseStatusPending).
+		Update("status", models.PurchaseStatusCancelled)
+	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to cancel transaction",
 		})
 	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Purchase transaction is no longer pending",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"message": "Purchase transaction cancelled successfully",
@@ -823,7 +907,7 @@ func UploadPurchaseReceipt(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Model(&transaction).Update("receipt_image_url", req.ReceiptImageUrl).Error; err != nil {
+	if err := auditedDB(c).Model(&transaction).Update("receipt_image_url", req.ReceiptImageUrl).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update receipt image",
 		})
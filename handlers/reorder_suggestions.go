@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Default windows used when suggesting purchase quantities
+const (
+	defaultVelocityWindowDays = 30 // Days of sales used to compute velocity
+	defaultCoverageDays       = 30 // Days of expected sales the new stock should cover
+)
+
+// ReorderSuggestionParams controls how reorder suggestions are computed
+type ReorderSuggestionParams struct {
+	WindowDays   int      `json:"window_days"`   // Sales velocity window in days (default: 30)
+	CoverageDays int      `json:"coverage_days"` // Days of sales to cover with new stock (default: 30)
+	WarehouseID  *string  `json:"warehouse_id"`  // Only consider stock and sales of this warehouse
+	PublisherIDs []string `json:"publisher_ids"` // Only suggest books of these publishers
+}
+
+// ReorderSuggestionItem represents the suggested purchase of a single book
+type ReorderSuggestionItem struct {
+	BookID            uuid.UUID `json:"book_id"`
+	BookName          string    `json:"book_name"`
+	Stock             int       `json:"stock"`
+	IncomingQuantity  int       `json:"incoming_quantity"` // Quantity on pending purchase transactions
+	MinStock          int       `json:"min_stock"`
+	ReorderQuantity   int       `json:"reorder_quantity"`
+	SoldQuantity      int       `json:"sold_quantity"` // Sold within the velocity window
+	DailyVelocity     float64   `json:"daily_velocity"`
+	TargetStock       int       `json:"target_stock"`
+	SuggestedQuantity int       `json:"suggested_quantity"`
+	LastPurchasePrice float64   `json:"last_purchase_price"`
+	EstimatedAmount   float64   `json:"estimated_amount"`
+}
+
+// ReorderSuggestionGroup represents the suggested purchases from a single publisher
+type ReorderSuggestionGroup struct {
+	PublisherID     uuid.UUID               `json:"publisher_id"`
+	PublisherCode   string                  `json:"publisher_code"`
+	PublisherName   string                  `json:"publisher_name"`
+	TotalQuantity   int                     `json:"total_quantity"`
+	EstimatedAmount float64                 `json:"estimated_amount"`
+	Items           []ReorderSuggestionItem `json:"items"`
+}
+
+// CreatePurchaseDraftsRequest represents the request body for turning reorder suggestions into purchase transactions
+type CreatePurchaseDraftsRequest struct {
+	ReorderSuggestionParams
+	PurchaseDate models.Date `json:"purchase_date"`
+	Note         *string     `json:"note"`
+}
+
+// reorderCandidate is a raw row of the reorder suggestion query
+type reorderCandidate struct {
+	BookID            uuid.UUID
+	BookName          string
+	PublisherID       uuid.UUID
+	PublisherCode     string
+	PublisherName     string
+	Stock             int
+	MinStock          int
+	ReorderQuantity   int
+	SoldQuantity      int
+	IncomingQuantity  int
+	LastPurchasePrice float64
+}
+
+// normalize fills in defaults for missing windows
+func (p *ReorderSuggestionParams) normalize() {
+	if p.WindowDays <= 0 {
+		p.WindowDays = defaultVelocityWindowDays
+	}
+	if p.CoverageDays <= 0 {
+		p.CoverageDays = defaultCoverageDays
+	}
+}
+
+// computeReorderSuggestions returns the books that need restocking grouped by publisher.
+// A book needs restocking when stock plus pending purchases is below its target stock,
+// which is min_stock plus the expected sales over the coverage period.
+func computeReorderSuggestions(db *gorm.DB, params ReorderSuggestionParams) ([]ReorderSuggestionGroup, error) {
+	params.normalize()
+
+	since := time.Now().AddDate(0, 0, -params.WindowDays)
+	hasWarehouse := params.WarehouseID != nil && *params.WarehouseID != ""
+
+	var sql strings.Builder
+	var args []interface{}
+
+	stockExpr := "b.stock"
+	if hasWarehouse {
+		stockExpr = "COALESCE(bs.quantity, 0)"
+	}
+
+	sql.WriteString(`
+		SELECT b.id AS book_id, b.name AS book_name,
+			p.id AS publisher_id, p.code AS publisher_code, p.name AS publisher_name,
+			` + stockExpr + ` AS stock, b.min_stock, b.reorder_quantity,
+			COALESCE(sold.quantity, 0) AS sold_quantity,
+			COALESCE(incoming.quantity, 0) AS incoming_quantity,
+			COALESCE(last_purchase.price, 0) AS last_purchase_price
+		FROM books b
+		JOIN publishers p ON p.id = b.publisher_id`)
+
+	if hasWarehouse {
+		sql.WriteString(`
+		LEFT JOIN book_stocks bs ON bs.book_id = b.id AND bs.warehouse_id = ?`)
+		args = append(args, *params.WarehouseID)
+	}
+
+	// Sales velocity from non-cancelled sales within the window
+	sql.WriteString(`
+		LEFT JOIN (
+			SELECT sti.book_id, SUM(sti.quantity) AS quantity
+			FROM sales_transaction_items sti
+			JOIN sales_transactions st ON st.id = sti.transaction_id
+			WHERE st.transaction_date >= ? AND st.status <> ?`)
+	args = append(args, since, models.SalesStatusCancelled)
+	if hasWarehouse {
+		sql.WriteString(` AND st.warehouse_id = ?`)
+		args = append(args, *params.WarehouseID)
+	}
+	sql.WriteString(`
+			GROUP BY sti.book_id
+		) sold ON sold.book_id = b.id`)
+
+	// Stock already on order and not received yet
+	sql.WriteString(`
+		LEFT JOIN (
+			SELECT pti.book_id, SUM(pti.quantity - pti.received_quantity) AS quantity
+			FROM purchase_transaction_items pti
+			JOIN purchase_transactions pt ON pt.id = pti.purchase_transaction_id
+			WHERE pt.status IN ?`)
+	args = append(args, []int{models.PurchaseStatusPending, models.PurchaseStatusPartiallyReceived})
+	if hasWarehouse {
+		sql.WriteString(` AND pt.warehouse_id = ?`)
+		args = append(args, *params.WarehouseID)
+	}
+	sql.WriteString(`
+			GROUP BY pti.book_id
+		) incoming ON incoming.book_id = b.id`)
+
+	// Price of the most recent purchase that delivered goods
+	sql.WriteString(`
+		LEFT JOIN LATERAL (
+			SELECT pti.price
+			FROM purchase_transaction_items pti
+			JOIN purchase_transactions pt ON pt.id = pti.purchase_transaction_id
+			WHERE pti.book_id = b.id AND pti.received_quantity > 0
+			ORDER BY pt.purchase_date DESC, pt.created_at DESC
+			LIMIT 1
+		) last_purchase ON TRUE
+		WHERE b.deleted_at IS NULL AND (b.min_stock > 0 OR COALESCE(sold.quantity, 0) > 0)`)
+
+	if len(params.PublisherIDs) > 0 {
+		sql.WriteString(` AND b.publisher_id IN ?`)
+		args = append(args, params.PublisherIDs)
+	}
+
+	sql.WriteString(`
+		ORDER BY p.name ASC, b.name ASC`)
+
+	var candidates []reorderCandidate
+	if err := db.Raw(sql.String(), args...).Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	groups := []ReorderSuggestionGroup{}
+	groupIndex := make(map[uuid.UUID]int)
+
+	for _, candidate := range candidates {
+		velocity := float64(candidate.SoldQuantity) / float64(params.WindowDays)
+		target := candidate.MinStock + int(math.Ceil(velocity*float64(params.CoverageDays)))
+		available := candidate.Stock + candidate.IncomingQuantity
+		if available >= target {
+			continue
+		}
+
+		suggested := target - available
+		if suggested < candidate.ReorderQuantity {
+			suggested = candidate.ReorderQuantity
+		}
+
+		item := ReorderSuggestionItem{
+			BookID:            candidate.BookID,
+			BookName:          candidate.BookName,
+			Stock:             candidate.Stock,
+			IncomingQuantity:  candidate.IncomingQuantity,
+			MinStock:          candidate.MinStock,
+			ReorderQuantity:   candidate.ReorderQuantity,
+			SoldQuantity:      candidate.SoldQuantity,
+			DailyVelocity:     math.Round(velocity*100) / 100,
+			TargetStock:       target,
+			SuggestedQuantity: suggested,
+			LastPurchasePrice: candidate.LastPurchasePrice,
+			EstimatedAmount:   candidate.LastPurchasePrice * float64(suggested),
+		}
+
+		idx, ok := groupIndex[candidate.PublisherID]
+		if !ok {
+			groups = append(groups, ReorderSuggestionGroup{
+				PublisherID:   candidate.PublisherID,
+				PublisherCode: candidate.PublisherCode,
+				PublisherName: candidate.PublisherName,
+			})
+			idx = len(groups) - 1
+			groupIndex[candidate.PublisherID] = idx
+		}
+
+		groups[idx].Items = append(groups[idx].Items, item)
+		groups[idx].TotalQuantity += item.SuggestedQuantity
+		groups[idx].EstimatedAmount += item.EstimatedAmount
+	}
+
+	return groups, nil
+}
+
+// GetReorderSuggestions godoc
+// @Summary Get reorder suggestions
+// @Description Suggest purchase quantities grouped by publisher from each book's min_stock and reorder_quantity plus recent sales velocity. Quantities already on pending purchase transactions are taken into account.
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param window_days query int false "Sales velocity window in days (default: 30)"
+// @Param coverage_days query int false "Days of expected sales the new stock should cover (default: 30)"
+// @Param warehouse_id query string false "Only consider stock and sales of this warehouse"
+// @Param publisher_id query string false "Only suggest books of this publisher"
+// @Success 200 {object} map[string]interface{} "Reorder suggestions grouped by publisher"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/reports/reorder-suggestions [get]
+func GetReorderSuggestions(c *fiber.Ctx) error {
+	params := ReorderSuggestionParams{
+		WindowDays:   c.QueryInt("window_days", defaultVelocityWindowDays),
+		CoverageDays: c.QueryInt("coverage_days", defaultCoverageDays),
+	}
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		params.WarehouseID = &warehouseID
+	}
+	if publisherID := c.Query("publisher_id"); publisherID != "" {
+		params.PublisherIDs = []string{publisherID}
+	}
+	params.normalize()
+
+	groups, err := computeReorderSuggestions(config.DB, params)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute reorder suggestions",
+		})
+	}
+
+	totalBooks := 0
+	totalQuantity := 0
+	estimatedAmount := 0.0
+	for _, group := range groups {
+		totalBooks += len(group.Items)
+		totalQuantity += group.TotalQuantity
+		estimatedAmount += group.EstimatedAmount
+	}
+
+	return c.JSON(fiber.Map{
+		"window_days":   params.WindowDays,
+		"coverage_days": params.CoverageDays,
+		"data":          groups,
+		"summary": fiber.Map{
+			"total_publishers": len(groups),
+			"total_books":      totalBooks,
+			"total_quantity":   totalQuantity,
+			"estimated_amount": estimatedAmount,
+		},
+	})
+}
+
+// CreatePurchaseDraftsFromSuggestions godoc
+// @Summary Create purchase transactions from reorder suggestions
+// @Description Turn the current reorder suggestions into pending purchase transactions, one per publisher. Items are priced at the last completed purchase price.
+// @Tags Purchase Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreatePurchaseDraftsRequest false "Suggestion parameters"
+// @Success 201 {object} map[string]interface{} "Created purchase transactions"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/purchase-transactions/from-suggestions [post]
+func CreatePurchaseDraftsFromSuggestions(c *fiber.Ctx) error {
+	var req CreatePurchaseDraftsRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	req.normalize()
+
+	// Verify receiving warehouse exists (resolved to the default warehouse on completion if empty)
+	if req.WarehouseID != nil && *req.WarehouseID != "" {
+		var warehouse models.Warehouse
+		if err := config.DB.Where("id = ?", *req.WarehouseID).First(&warehouse).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Warehouse not found",
+			})
+		}
+	}
+
+	purchaseDate := req.PurchaseDate
+	if purchaseDate.IsZero() {
+		purchaseDate = models.Date{Time: time.Now()}
+	}
+
+	// Start a database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	groups, err := computeReorderSuggestions(tx, req.ReorderSuggestionParams)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute reorder suggestions",
+		})
+	}
+
+	if len(groups) == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No books need to be reordered",
+		})
+	}
+
+	var transactionIDs []uuid.UUID
+	for _, group := range groups {
+		noInvoice, err := generatePurchaseInvoiceNumber(tx)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to generate invoice number",
+			})
+		}
+
+		note := req.Note
+		if note == nil {
+			generated := fmt.Sprintf("Generated from reorder suggestions (%d day sales window)", req.WindowDays)
+			note = &generated
+		}
+
+		transaction := models.PurchaseTransaction{
+			SupplierID:   group.PublisherID,
+			NoInvoice:    noInvoice,
+			PurchaseDate: purchaseDate,
+			TotalAmount:  group.EstimatedAmount,
+			Status:       models.PurchaseStatusPending,
+			WarehouseID:  helpers.ParseUUIDPtr(req.WarehouseID),
+			Note:         note,
+		}
+
+		if err := tx.Create(&transaction).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create purchase transaction",
+			})
+		}
+
+		transactionItems := make([]models.PurchaseTransactionItem, 0, len(group.Items))
+		for _, item := range group.Items {
+			transactionItems = append(transactionItems, models.PurchaseTransactionItem{
+				PurchaseTransactionID: transaction.ID,
+				BookID:                item.BookID,
+				Quantity:              item.SuggestedQuantity,
+				Price:                 item.LastPurchasePrice,
+				Subtotal:              item.EstimatedAmount,
+			})
+		}
+		if err := tx.Create(&transactionItems).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create purchase transaction items",
+			})
+		}
+
+		transactionIDs = append(transactionIDs, transaction.ID)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the created transactions with all relations
+	var transactions []models.PurchaseTransaction
+	config.DB.
+		Preload("Supplier", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id IN ?", transactionIDs).
+		Order("no_invoice ASC").
+		Find(&transactions)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":               fmt.Sprintf("%d purchase transactions created from reorder suggestions", len(transactionIDs)),
+		"purchase_transactions": transactions,
+	})
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"math"
+
 	"pustaka-backend/config"
 	"pustaka-backend/helpers"
 	"pustaka-backend/models"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // PurchasingReportSummary represents the summary for purchasing report
@@ -33,6 +36,54 @@ type BooksStockSummary struct {
 	LowStockCount int `json:"low_stock_count"`
 }
 
+// WarehouseStockSummary represents the total stock held in a single warehouse
+type WarehouseStockSummary struct {
+	WarehouseID   string `json:"warehouse_id"`
+	WarehouseCode string `json:"warehouse_code"`
+	WarehouseName string `json:"warehouse_name"`
+	TotalStock    int    `json:"total_stock"`
+}
+
+// StockValuationLine represents the value of a book's stock at its weighted average cost
+type StockValuationLine struct {
+	BookID       string  `json:"book_id"`
+	BookName     string  `json:"book_name"`
+	Year         string  `json:"year"`
+	MerkBukuID   *string `json:"merk_buku_id"`
+	MerkBukuName *string `json:"merk_buku_name"`
+	Quantity     int     `json:"quantity"`
+	AverageCost  float64 `json:"average_cost"`
+	TotalValue   float64 `json:"total_value"`
+}
+
+// StockValuationSummary represents the totals of the stock valuation report
+type StockValuationSummary struct {
+	TotalBooks    int     `json:"total_books"`
+	TotalQuantity int     `json:"total_quantity"`
+	TotalValue    float64 `json:"total_value"`
+}
+
+// GrossMarginLine represents revenue, cost of goods sold and gross margin for one group
+type GrossMarginLine struct {
+	GroupID       *string `json:"group_id"`
+	Code          *string `json:"code"`
+	Name          *string `json:"name"`
+	TotalItems    int     `json:"total_items"`
+	Revenue       float64 `json:"revenue"`
+	COGS          float64 `gorm:"column:cogs" json:"cogs"`
+	GrossMargin   float64 `json:"gross_margin"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
+// GrossMarginSummary represents the totals of the gross margin report
+type GrossMarginSummary struct {
+	TotalItems    int     `json:"total_items"`
+	Revenue       float64 `json:"revenue"`
+	COGS          float64 `gorm:"column:cogs" json:"cogs"`
+	GrossMargin   float64 `json:"gross_margin"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
 // CreditReportSummary represents the summary for credits report
 type CreditReportSummary struct {
 	TotalOutstanding  float64 `json:"total_outstanding"`
@@ -70,10 +121,12 @@ type CreditReportItem struct {
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Param start_date query string false "Start date (YYYY-MM-DD)"
 // @Param end_date query string false "End date (YYYY-MM-DD)"
 // @Param supplier_id query string false "Filter by supplier ID"
 // @Param status query int false "Filter by status (0=pending, 1=completed, 2=cancelled)"
+// @Param created_by_id query string false "Filter by the user who entered the purchase"
 // @Success 200 {object} map[string]interface{} "Purchasing report with summary and pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -85,15 +138,16 @@ func GetPurchasingReport(c *fiber.Ctx) error {
 	pagination := helpers.GetPaginationParams(c)
 
 	query := config.DB.Order("purchase_date DESC").
-		Preload("Supplier").
+		Preload("Supplier", withDeleted).
+		Preload("CreatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku")
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted)
 
 	queryCount := config.DB.Model(&models.PurchaseTransaction{})
 
 	// add params for not using pagination
-	if c.Query("all") == "true" {
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
 		pagination.Limit = -1 // No limit
 		pagination.Offset = 0 // No offset
 	}
@@ -121,6 +175,12 @@ func GetPurchasingReport(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("status = ?", status)
 	}
 
+	// Filter by the user who entered the purchase
+	if createdByID := c.Query("created_by_id"); createdByID != "" {
+		query = query.Where("created_by_id = ?", createdByID)
+		queryCount = queryCount.Where("created_by_id = ?", createdByID)
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -149,6 +209,11 @@ func GetPurchasingReport(c *fiber.Ctx) error {
 		})
 	}
 
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, reportData, "purchasing_report", "Supplier", "CreatedBy")
+	}
+
 	// Create pagination response
 	response, err := helpers.CreatePaginationResponse(queryCount, reportData, "data", pagination.Page, pagination.Limit)
 	if err != nil {
@@ -173,11 +238,13 @@ func GetPurchasingReport(c *fiber.Ctx) error {
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Param start_date query string false "Start date (YYYY-MM-DD)"
 // @Param end_date query string false "End date (YYYY-MM-DD)"
 // @Param payment_type query string false "Filter by payment type (T=cash, K=credit, all=both)"
-// @Param status query int false "Filter by status (0=booking, 1=paid-off, 2=installment)"
+// @Param status query int false "Filter by status (0=booking, 1=paid-off, 2=installment, 3=cancelled). Cancelled transactions are excluded unless requested."
 // @Param sales_associate_id query string false "Filter by sales associate ID"
+// @Param created_by_id query string false "Filter by the user who entered the transaction"
 // @Success 200 {object} map[string]interface{} "Sales report with summary and pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -189,18 +256,19 @@ func GetSalesReport(c *fiber.Ctx) error {
 	pagination := helpers.GetPaginationParams(c)
 
 	query := config.DB.Order("transaction_date DESC").
-		Preload("Biller").
-		Preload("SalesAssociate").
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("CreatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
 		Preload("Payments").
 		Preload("Shippings")
 
 	queryCount := config.DB.Model(&models.SalesTransaction{})
 
 	// add params for not using pagination
-	if c.Query("all") == "true" {
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
 		pagination.Limit = -1 // No limit
 		pagination.Offset = 0 // No offset
 	}
@@ -222,10 +290,13 @@ func GetSalesReport(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("payment_type = ?", paymentType)
 	}
 
-	// Filter by status
+	// Filter by status (cancelled transactions are left out of the totals by default)
 	if status := c.Query("status"); status != "" {
 		query = query.Where("status = ?", status)
 		queryCount = queryCount.Where("status = ?", status)
+	} else {
+		query = query.Where("status != ?", models.SalesStatusCancelled)
+		queryCount = queryCount.Where("status != ?", models.SalesStatusCancelled)
 	}
 
 	// Filter by sales associate
@@ -234,6 +305,12 @@ func GetSalesReport(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("sales_associate_id = ?", salesAssociateID)
 	}
 
+	// Filter by the user who entered the transaction
+	if createdByID := c.Query("created_by_id"); createdByID != "" {
+		query = query.Where("created_by_id = ?", createdByID)
+		queryCount = queryCount.Where("created_by_id = ?", createdByID)
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -270,6 +347,11 @@ func GetSalesReport(c *fiber.Ctx) error {
 		}
 	}
 
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, reportData, "sales_report", "Biller", "SalesAssociate", "CreatedBy")
+	}
+
 	// Create pagination response
 	response, err := helpers.CreatePaginationResponse(queryCount, reportData, "data", pagination.Page, pagination.Limit)
 	if err != nil {
@@ -286,7 +368,7 @@ func GetSalesReport(c *fiber.Ctx) error {
 
 // GetBooksStockReport godoc
 // @Summary Get books stock report
-// @Description Get a report of all books with their current stock levels
+// @Description Get a report of all books with their current stock levels, broken down per warehouse
 // @Tags Reports
 // @Accept json
 // @Produce json
@@ -294,11 +376,14 @@ func GetSalesReport(c *fiber.Ctx) error {
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Param jenis_buku_id query string false "Filter by jenis buku ID"
 // @Param jenjang_studi_id query string false "Filter by jenjang studi ID"
 // @Param curriculum_id query string false "Filter by curriculum ID"
 // @Param kelas query string false "Filter by kelas code"
-// @Param low_stock_threshold query int false "Low stock threshold (default: 10)"
+// @Param warehouse_id query string false "Report stock held in a single warehouse"
+// @Param low_stock_threshold query int false "Low stock threshold for books without min_stock (default: 10)"
+// @Param below_min_stock query bool false "Only books at or below their min_stock"
 // @Param sort_by query string false "Sort by field (stock, name, created_at)"
 // @Param sort_order query string false "Sort order (asc, desc)"
 // @Success 200 {object} map[string]interface{} "Books stock report with summary and pagination"
@@ -317,17 +402,17 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 	orderClause := sortBy + " " + sortOrder
 
 	query := config.DB.Order(orderClause).
-		Preload("MerkBuku").
-		Preload("JenisBuku").
-		Preload("JenjangStudi").
-		Preload("BidangStudi").
-		Preload("Curriculum").
-		Preload("Publisher")
+		Preload("MerkBuku", withDeleted).
+		Preload("JenisBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("BidangStudi", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("Publisher", withDeleted)
 
 	queryCount := config.DB.Model(&models.Book{})
 
 	// add params for not using pagination
-	if c.Query("all") == "true" {
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
 		pagination.Limit = -1 // No limit
 		pagination.Offset = 0 // No offset
 	}
@@ -356,6 +441,21 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("kelas = ?", kelas)
 	}
 
+	// Filter books at or below their reorder point
+	if c.Query("below_min_stock") == "true" {
+		query = query.Where("min_stock > 0 AND stock <= min_stock")
+		queryCount = queryCount.Where("min_stock > 0 AND stock <= min_stock")
+	}
+
+	// Filter by warehouse: only stock held in that warehouse is reported
+	warehouseID := c.Query("warehouse_id")
+	if warehouseID != "" {
+		query = query.Preload("Stocks", "warehouse_id = ?", warehouseID)
+	} else {
+		query = query.Preload("Stocks")
+	}
+	query = query.Preload("Stocks.Warehouse", withDeleted)
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&books).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -363,6 +463,20 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 		})
 	}
 
+	if warehouseID != "" {
+		for i := range books {
+			books[i].Stock = 0
+			for _, stock := range books[i].Stocks {
+				books[i].Stock += stock.Quantity
+			}
+		}
+	}
+
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, books, "books_stock_report", "MerkBuku", "JenisBuku", "JenjangStudi", "BidangStudi", "Curriculum", "Publisher")
+	}
+
 	// Calculate summary
 	var summary BooksStockSummary
 	summary.TotalBooks = len(books)
@@ -373,9 +487,14 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 		lowStockThreshold = threshold
 	}
 
+	// Books with a reorder point are low on stock at their min_stock, others at the threshold
 	for _, book := range books {
 		summary.TotalStock += book.Stock
-		if book.Stock <= lowStockThreshold {
+		threshold := lowStockThreshold
+		if book.MinStock > 0 {
+			threshold = book.MinStock
+		}
+		if book.Stock <= threshold {
 			summary.LowStockCount++
 		}
 	}
@@ -388,13 +507,286 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 		})
 	}
 
+	// Total stock per warehouse
+	var byWarehouse []WarehouseStockSummary
+	warehouseQuery := config.DB.Table("warehouses").
+		Select("warehouses.id AS warehouse_id, warehouses.code AS warehouse_code, warehouses.name AS warehouse_name, COALESCE(SUM(book_stocks.quantity), 0) AS total_stock").
+		Joins("LEFT JOIN book_stocks ON book_stocks.warehouse_id = warehouses.id").
+		Where("warehouses.deleted_at IS NULL").
+		Group("warehouses.id, warehouses.code, warehouses.name").
+		Order("warehouses.name ASC")
+	if warehouseID != "" {
+		warehouseQuery = warehouseQuery.Where("warehouses.id = ?", warehouseID)
+	}
+	if err := warehouseQuery.Scan(&byWarehouse).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate stock per warehouse",
+		})
+	}
+
 	// Add summary to response
 	response["summary"] = summary
+	response["by_warehouse"] = byWarehouse
 	response["low_stock_threshold"] = lowStockThreshold
 
 	return c.JSON(response)
 }
 
+// GetStockValuationReport godoc
+// @Summary Get stock valuation report
+// @Description Get the value of the books in stock at their moving weighted average cost, with totals and a breakdown per merk buku. Deleted books are left out.
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Param warehouse_id query string false "Value only the stock held in a single warehouse"
+// @Param merk_buku_id query string false "Filter by merk buku ID"
+// @Param publisher_id query string false "Filter by publisher ID"
+// @Param include_empty query bool false "Include books without stock (default: false)"
+// @Success 200 {object} map[string]interface{} "Stock valuation with summary and pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/reports/stock-valuation [get]
+func GetStockValuationReport(c *fiber.Ctx) error {
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	// add params for not using pagination
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	// Quantity is the total stock, or the stock of a single warehouse
+	warehouseID := c.Query("warehouse_id")
+	quantityExpr := "books.stock"
+	if warehouseID != "" {
+		quantityExpr = "book_stocks.quantity"
+	}
+
+	// Deleted books keep their stock rows but are no longer part of the inventory
+	base := func() *gorm.DB {
+		query := config.DB.Table("books").Where("books.deleted_at IS NULL")
+		if warehouseID != "" {
+			query = query.Joins("JOIN book_stocks ON book_stocks.book_id = books.id AND book_stocks.warehouse_id = ?", warehouseID)
+		}
+		if merkBukuID := c.Query("merk_buku_id"); merkBukuID != "" {
+			query = query.Where("books.merk_buku_id = ?", merkBukuID)
+		}
+		if publisherID := c.Query("publisher_id"); publisherID != "" {
+			query = query.Where("books.publisher_id = ?", publisherID)
+		}
+		if c.Query("include_empty") != "true" {
+			query = query.Where(quantityExpr + " > 0")
+		}
+		return query
+	}
+
+	var lines []StockValuationLine
+	if err := base().
+		Select("books.id AS book_id, books.name AS book_name, books.year, merk_buku.id AS merk_buku_id, merk_buku.name AS merk_buku_name, " +
+			quantityExpr + " AS quantity, books.average_cost, " + quantityExpr + " * books.average_cost AS total_value").
+		Joins("LEFT JOIN merk_buku ON merk_buku.id = books.merk_buku_id").
+		Order("total_value DESC, books.name ASC").
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Scan(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch stock valuation report",
+		})
+	}
+
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, lines, "stock_valuation_report")
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(base(), lines, "data", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	// Totals over every matching book, not only the current page
+	var summary StockValuationSummary
+	if err := base().
+		Select("COUNT(*) AS total_books, COALESCE(SUM(" + quantityExpr + "), 0) AS total_quantity, COALESCE(SUM(" + quantityExpr + " * books.average_cost), 0) AS total_value").
+		Scan(&summary).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate stock valuation summary",
+		})
+	}
+
+	// Value per merk buku
+	var byMerkBuku []StockValuationLine
+	if err := base().
+		Select("merk_buku.id AS merk_buku_id, merk_buku.name AS merk_buku_name, COALESCE(SUM(" + quantityExpr + "), 0) AS quantity, COALESCE(SUM(" + quantityExpr + " * books.average_cost), 0) AS total_value").
+		Joins("LEFT JOIN merk_buku ON merk_buku.id = books.merk_buku_id").
+		Group("merk_buku.id, merk_buku.name").
+		Order("total_value DESC").
+		Scan(&byMerkBuku).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate stock value per merk buku",
+		})
+	}
+
+	byMerkBukuData := make([]fiber.Map, 0, len(byMerkBuku))
+	for _, line := range byMerkBuku {
+		byMerkBukuData = append(byMerkBukuData, fiber.Map{
+			"merk_buku_id":   line.MerkBukuID,
+			"merk_buku_name": line.MerkBukuName,
+			"quantity":       line.Quantity,
+			"total_value":    line.TotalValue,
+		})
+	}
+
+	response["summary"] = summary
+	response["by_merk_buku"] = byMerkBukuData
+
+	return c.JSON(response)
+}
+
+// marginPercent returns the gross margin as a percentage of revenue
+func marginPercent(revenue, margin float64) float64 {
+	if revenue == 0 {
+		return 0
+	}
+	return math.Round(margin/revenue*10000) / 100
+}
+
+// GetGrossMarginReport godoc
+// @Summary Get gross margin report
+// @Description Get revenue, cost of goods sold and gross margin of non-cancelled sales, grouped per sale, per merk buku or per sales associate. Revenue is the item subtotal after promotion and discount, shipping is not included.
+// @Tags Reports
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Param group_by query string false "Grouping: sale, merk_buku, sales_associate (default: sale)"
+// @Param start_date query string false "Start date (YYYY-MM-DD)"
+// @Param end_date query string false "End date (YYYY-MM-DD)"
+// @Param sales_associate_id query string false "Filter by sales associate ID"
+// @Param merk_buku_id query string false "Filter by merk buku ID"
+// @Param created_by_id query string false "Filter by the user who entered the sale"
+// @Success 200 {object} map[string]interface{} "Gross margin per group with summary and pagination"
+// @Failure 400 {object} map[string]interface{} "Invalid group_by"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/reports/gross-margin [get]
+func GetGrossMarginReport(c *fiber.Ctx) error {
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	// add params for not using pagination
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	// Group key and label per grouping. Sales are labelled with their sales associate.
+	groupBy := c.Query("group_by", "sale")
+	var groupSelect, groupColumns, orderClause string
+	switch groupBy {
+	case "sale":
+		groupSelect = "sales_transactions.id::text AS group_id, sales_transactions.no_invoice AS code, sales_associates.name AS name"
+		groupColumns = "sales_transactions.id, sales_transactions.no_invoice, sales_associates.name, sales_transactions.transaction_date"
+		orderClause = "sales_transactions.transaction_date DESC"
+	case "merk_buku":
+		groupSelect = "merk_buku.id::text AS group_id, merk_buku.code AS code, merk_buku.name AS name"
+		groupColumns = "merk_buku.id, merk_buku.code, merk_buku.name"
+		orderClause = "gross_margin DESC"
+	case "sales_associate":
+		groupSelect = "sales_associates.id::text AS group_id, sales_associates.code AS code, sales_associates.name AS name"
+		groupColumns = "sales_associates.id, sales_associates.code, sales_associates.name"
+		orderClause = "gross_margin DESC"
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "group_by must be one of: sale, merk_buku, sales_associate",
+		})
+	}
+
+	base := func() *gorm.DB {
+		query := config.DB.Table("sales_transaction_items").
+			Joins("JOIN sales_transactions ON sales_transactions.id = sales_transaction_items.transaction_id").
+			Joins("JOIN books ON books.id = sales_transaction_items.book_id").
+			Joins("LEFT JOIN merk_buku ON merk_buku.id = books.merk_buku_id").
+			Joins("LEFT JOIN sales_associates ON sales_associates.id = sales_transactions.sales_associate_id").
+			Where("sales_transactions.status <> ?", models.SalesStatusCancelled)
+
+		if startDate := c.Query("start_date"); startDate != "" {
+			query = query.Where("sales_transactions.transaction_date >= ?", startDate)
+		}
+		if endDate := c.Query("end_date"); endDate != "" {
+			query = query.Where("sales_transactions.transaction_date <= ?", endDate+" 23:59:59")
+		}
+		if salesAssociateID := c.Query("sales_associate_id"); salesAssociateID != "" {
+			query = query.Where("sales_transactions.sales_associate_id = ?", salesAssociateID)
+		}
+		if merkBukuID := c.Query("merk_buku_id"); merkBukuID != "" {
+			query = query.Where("books.merk_buku_id = ?", merkBukuID)
+		}
+		if createdByID := c.Query("created_by_id"); createdByID != "" {
+			query = query.Where("sales_transactions.created_by_id = ?", createdByID)
+		}
+		return query
+	}
+
+	totalsSelect := "COALESCE(SUM(sales_transaction_items.quantity), 0) AS total_items, " +
+		"COALESCE(SUM(sales_transaction_items.subtotal), 0) AS revenue, " +
+		"COALESCE(SUM(sales_transaction_items.cogs), 0) AS cogs, " +
+		"COALESCE(SUM(sales_transaction_items.subtotal - sales_transaction_items.cogs), 0) AS gross_margin"
+
+	var lines []GrossMarginLine
+	if err := base().
+		Select(groupSelect + ", " + totalsSelect).
+		Group(groupColumns).
+		Order(orderClause).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Scan(&lines).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch gross margin report",
+		})
+	}
+	for i := range lines {
+		lines[i].MarginPercent = marginPercent(lines[i].Revenue, lines[i].GrossMargin)
+	}
+
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, lines, "gross_margin_report")
+	}
+
+	// Count the groups rather than the item rows
+	queryCount := config.DB.Table("(?) AS grouped", base().Select(groupSelect).Group(groupColumns))
+	response, err := helpers.CreatePaginationResponse(queryCount, lines, "data", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	var summary GrossMarginSummary
+	if err := base().Select(totalsSelect).Scan(&summary).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to calculate gross margin summary",
+		})
+	}
+	summary.MarginPercent = marginPercent(summary.Revenue, summary.GrossMargin)
+
+	response["summary"] = summary
+	response["group_by"] = groupBy
+
+	return c.JSON(response)
+}
+
 // GetCreditsReport godoc
 // @Summary Get credits (piutang) report
 // @Description Get a report of all outstanding credit transactions (remaining balances)
@@ -405,9 +797,11 @@ func GetBooksStockReport(c *fiber.Ctx) error {
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
 // @Param all query bool false "Get all records without pagination"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Param start_date query string false "Start date (YYYY-MM-DD)"
 // @Param end_date query string false "End date (YYYY-MM-DD)"
 // @Param sales_associate_id query string false "Filter by sales associate ID"
+// @Param created_by_id query string false "Filter by the user who entered the transaction"
 // @Param overdue_only query bool false "Show only overdue transactions"
 // @Success 200 {object} map[string]interface{} "Credits report with summary and pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
@@ -419,23 +813,25 @@ func GetCreditsReport(c *fiber.Ctx) error {
 	// Get pagination parameters
 	pagination := helpers.GetPaginationParams(c)
 
-	// Only get credit transactions that are not fully paid
+	// Only get credit transactions that are not fully paid or cancelled
+	outstandingStatuses := []int{models.SalesStatusBooking, models.SalesStatusInstallment}
 	query := config.DB.Order("transaction_date ASC").
 		Where("payment_type = ?", "K").
-		Where("status != ?", 1). // Not paid-off
-		Preload("Biller").
-		Preload("SalesAssociate").
-		Preload("SalesAssociate.City").
+		Where("status IN ?", outstandingStatuses).
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("SalesAssociate.City", withDeleted).
+		Preload("CreatedBy").
 		Preload("Items").
-		Preload("Items.Book").
+		Preload("Items.Book", withDeleted).
 		Preload("Payments")
 
 	queryCount := config.DB.Model(&models.SalesTransaction{}).
 		Where("payment_type = ?", "K").
-		Where("status != ?", 1) // Not paid-off
+		Where("status IN ?", outstandingStatuses)
 
 	// add params for not using pagination
-	if c.Query("all") == "true" {
+	if c.Query("all") == "true" || helpers.IsExportRequest(c) {
 		pagination.Limit = -1 // No limit
 		pagination.Offset = 0 // No offset
 	}
@@ -457,6 +853,12 @@ func GetCreditsReport(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("sales_associate_id = ?", salesAssociateID)
 	}
 
+	// Filter by the user who entered the transaction
+	if createdByID := c.Query("created_by_id"); createdByID != "" {
+		query = query.Where("created_by_id = ?", createdByID)
+		queryCount = queryCount.Where("created_by_id = ?", createdByID)
+	}
+
 	// Apply pagination and fetch data
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -500,6 +902,11 @@ func GetCreditsReport(c *fiber.Ctx) error {
 		summary.TotalOutstanding += remainingAmount
 	}
 
+	// Export every matching row as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportRows(c, reportItems, "credits_report", "Transaction", "Transaction.Biller", "Transaction.SalesAssociate", "Transaction.CreatedBy")
+	}
+
 	// Create pagination response with the filtered report items
 	// Note: We need to count the filtered items, not use the queryCount
 	// because we filter out fully paid transactions in the loop
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"pustaka-backend/config"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// roleNamePattern keeps role names usable in URLs, tokens and log lines
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// RoleRequest is the body for creating or updating a role. Omitted fields are left unchanged
+// on update; permissions replace the current set when given.
+type RoleRequest struct {
+	Name        *string  `json:"name" example:"gudang_surabaya"`
+	Description *string  `json:"description" example:"Warehouse staff in Surabaya"`
+	Permissions []string `json:"permissions" example:"books:read,inventory:read,inventory:write"`
+}
+
+// validateRolePermissions normalizes the permissions and rejects unknown ones
+func validateRolePermissions(permissions []string) (models.PermissionList, error) {
+	list := models.PermissionList(permissions).Normalize()
+	for _, permission := range list {
+		if !models.IsKnownPermission(permission) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Unknown permission: "+permission)
+		}
+	}
+	return list, nil
+}
+
+// validateRoleName checks the format of the name and that no other role uses it
+func validateRoleName(name string, excludeID *uuid.UUID) error {
+	if !roleNamePattern.MatchString(name) {
+		return fiber.NewError(fiber.StatusBadRequest, "Role name must be 2-50 lowercase letters, digits or underscores, starting with a letter")
+	}
+
+	query := config.DB.Model(&models.Role{}).Where("name = ?", name)
+	if excludeID != nil {
+		query = query.Where("id <> ?", *excludeID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check role name")
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, "Role name already exists")
+	}
+	return nil
+}
+
+// loadRolePermissions returns the permissions of the named roles. Roles that do not exist are
+// left out and so grant nothing.
+func loadRolePermissions(names ...string) (map[string]models.PermissionList, error) {
+	var roles []models.Role
+	if err := config.DB.Select("name", "permissions").Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	permissions := make(map[string]models.PermissionList, len(roles))
+	for _, role := range roles {
+		permissions[role.Name] = role.Permissions
+	}
+	return permissions, nil
+}
+
+// callerHasPermission reports whether the role of the logged-in user grants the permission,
+// for handlers where only part of a request needs more than the route's permission
+func callerHasPermission(c *fiber.Ctx, permission string) (bool, error) {
+	caller, _ := c.Locals("userRole").(string)
+	held, err := loadRolePermissions(caller)
+	if err != nil {
+		return false, err
+	}
+	return held[caller].Has(permission), nil
+}
+
+// missingPermission returns the first permission of granted that held does not cover
+func missingPermission(held, granted models.PermissionList) (string, bool) {
+	for _, permission := range granted {
+		if !held.Has(permission) {
+			return permission, true
+		}
+	}
+	return "", false
+}
+
+// authorizeRolePermissions refuses a role change that grants, or touches a role holding, a
+// permission the logged-in user does not hold themselves. Without it roles:write could be
+// used to hand out "*".
+func authorizeRolePermissions(c *fiber.Ctx, permissions models.PermissionList) error {
+	caller, _ := c.Locals("userRole").(string)
+	held, err := loadRolePermissions(caller)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check permissions")
+	}
+	if permission, missing := missingPermission(held[caller], permissions); missing {
+		return fiber.NewError(fiber.StatusForbidden, "Cannot grant a permission you do not hold: "+permission)
+	}
+	return nil
+}
+
+// authorizeUserRoles refuses managing users of roles that grant a permission the logged-in
+// user does not hold. users:write can then neither assign the admin role nor change the
+// password or 2FA of an admin account, that takes "*".
+func authorizeUserRoles(c *fiber.Ctx, roles ...string) error {
+	caller, _ := c.Locals("userRole").(string)
+	permissions, err := loadRolePermissions(append([]string{caller}, roles...)...)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check permissions")
+	}
+	for _, role := range roles {
+		if permission, missing := missingPermission(permissions[caller], permissions[role]); missing {
+			return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("Managing users of role %s requires %s", role, permission))
+		}
+	}
+	return nil
+}
+
+// GetPermissions godoc
+// @Summary List available permissions
+// @Description List every permission that can be granted to a role. A permission is "<resource>:<action>"; "*" grants everything.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Available permissions"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Router /api/roles/permissions [get]
+func GetPermissions(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"permissions": models.Permissions,
+	})
+}
+
+// GetAllRoles godoc
+// @Summary Get all roles
+// @Description Retrieve all roles with their permissions and the number of users assigned to each
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "List of roles"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/roles [get]
+func GetAllRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := config.DB.Order("is_system DESC, name ASC").Find(&roles).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch roles",
+		})
+	}
+
+	type roleUserCount struct {
+		Role  string
+		Count int64
+	}
+	var counts []roleUserCount
+	if err := config.DB.Model(&models.User{}).Select("role, COUNT(*) AS count").Group("role").Scan(&counts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count users per role",
+		})
+	}
+	userCounts := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		userCounts[count.Role] = count.Count
+	}
+
+	roleList := make([]fiber.Map, 0, len(roles))
+	for _, role := range roles {
+		roleList = append(roleList, fiber.Map{
+			"id":          role.ID,
+			"name":        role.Name,
+			"description": role.Description,
+			"permissions": role.Permissions,
+			"is_system":   role.IsSystem,
+			"user_count":  userCounts[role.Name],
+			"created_at":  role.CreatedAt,
+			"updated_at":  role.UpdatedAt,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"roles": roleList,
+	})
+}
+
+// GetRole godoc
+// @Summary Get a role by ID
+// @Description Retrieve a single role with its permissions
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Role details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Router /api/roles/{id} [get]
+func GetRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var role models.Role
+	if err := config.DB.Where("id = ?", id).First(&role).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"role": role,
+	})
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a role with a set of permissions. See GET /api/roles/permissions for the available permissions. Only permissions the caller holds can be granted.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RoleRequest true "Role details"
+// @Success 201 {object} models.Role "Created role"
+// @Failure 400 {object} map[string]interface{} "Invalid name or unknown permission"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required, or a granted permission is not held by the caller"
+// @Failure 409 {object} map[string]interface{} "Role name already exists"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/roles [post]
+func CreateRole(c *fiber.Ctx) error {
+	var req RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.Name == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+	name := strings.TrimSpace(*req.Name)
+	if err := validateRoleName(name, nil); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	permissions, err := validateRolePermissions(req.Permissions)
+	if err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := authorizeRolePermissions(c, permissions); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	role := models.Role{
+		Name:        name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := auditedDB(c).Create(&role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create role",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(role)
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Update the name, description or permissions of a role. Renaming a role moves its users along. System roles cannot be changed, and only roles whose old and new permissions the caller holds can be changed.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID (UUID)"
+// @Param request body RoleRequest true "Updated role details"
+// @Success 200 {object} models.Role "Updated role"
+// @Failure 400 {object} map[string]interface{} "Invalid name, unknown permission or system role"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required, or the role grants a permission not held by the caller"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 409 {object} map[string]interface{} "Role name already exists"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/roles/{id} [put]
+func UpdateRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var role models.Role
+	if err := config.DB.Where("id = ?", id).First(&role).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+
+	if role.IsSystem {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "System roles cannot be changed",
+		})
+	}
+
+	var req RoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name != role.Name {
+			if err := validateRoleName(name, &role.ID); err != nil {
+				return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			updates["name"] = name
+		}
+	}
+	if req.Description != nil {
+		updates["description"] = req.Description
+	}
+	if req.Permissions != nil {
+		permissions, err := validateRolePermissions(req.Permissions)
+		if err != nil {
+			return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		updates["permissions"] = permissions
+	}
+
+	if len(updates) > 0 {
+		// Both what the role grants now and what it will grant must be held by the caller
+		touched := role.Permissions
+		if permissions, ok := updates["permissions"].(models.PermissionList); ok {
+			touched = append(append(models.PermissionList{}, touched...), permissions...)
+		}
+		if err := authorizeRolePermissions(c, touched); err != nil {
+			return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := auditedDB(c).Model(&role).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update role",
+			})
+		}
+	}
+
+	if err := config.DB.Where("id = ?", role.ID).First(&role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch updated role",
+		})
+	}
+
+	return c.JSON(role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a role by ID. System roles and roles that are still assigned to users cannot be deleted.
+// @Tags Roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Role ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Role deleted successfully"
+// @Failure 400 {object} map[string]interface{} "System role"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "Role not found"
+// @Failure 409 {object} map[string]interface{} "Role is assigned to users"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/roles/{id} [delete]
+func DeleteRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var role models.Role
+	if err := config.DB.Where("id = ?", id).First(&role).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Role not found",
+		})
+	}
+
+	if role.IsSystem {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "System roles cannot be deleted",
+		})
+	}
+
+	var userCount int64
+	if err := config.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&userCount).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check role users",
+		})
+	}
+	if userCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      "Cannot delete a role that is assigned to users",
+			"user_count": userCount,
+		})
+	}
+
+	if err := auditedDB(c).Delete(&role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete role",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Role deleted successfully",
+	})
+}
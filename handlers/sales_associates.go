@@ -57,6 +57,8 @@ type UpdateSalesAssociateRequest struct {
 // @Param search query string false "Search by code, name, or description"
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted sales associates (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all sales associates with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -70,6 +72,12 @@ func GetAllSalesAssociates(c *fiber.Ctx) error {
 	query := config.DB.Order("created_at DESC")
 	queryCount := config.DB.Model(&models.SalesAssociate{})
 
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
 	// add params for not using pagination
 	if c.Query("all") == "true" {
 		pagination.Limit = -1 // No limit
@@ -87,8 +95,13 @@ func GetAllSalesAssociates(c *fiber.Ctx) error {
 		queryCount = queryCount.Where(cond, args...)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("City", withDeleted), models.SalesAssociate{}, "sales_associates")
+	}
+
 	// Apply pagination and fetch data
-	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City").Find(&salesAssociates).Error; err != nil {
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City", withDeleted).Find(&salesAssociates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch all sales associates",
 		})
@@ -121,7 +134,7 @@ func GetSalesAssociate(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	var salesAssociate models.SalesAssociate
-	if err := config.DB.Preload("City").Where("id = ?", id).First(&salesAssociate).Error; err != nil {
+	if err := config.DB.Preload("City", withDeleted).Where("id = ?", id).First(&salesAssociate).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "SalesAssociate not found",
 		})
@@ -193,7 +206,7 @@ func CreateSalesAssociate(c *fiber.Ctx) error {
 		salesAssociate.JenisPembayaran = *req.JenisPembayaran
 	}
 
-	if err := config.DB.Create(&salesAssociate).Error; err != nil {
+	if err := auditedDB(c).Create(&salesAssociate).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create sales associate",
 		})
@@ -300,7 +313,7 @@ func UpdateSalesAssociate(c *fiber.Ctx) error {
 		updates["file_url"] = *req.FileUrl
 	}
 
-	if err := config.DB.Model(&salesAssociate).Updates(updates).Error; err != nil {
+	if err := auditedDB(c).Model(&salesAssociate).Updates(updates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update sales associate",
 		})
@@ -312,34 +325,49 @@ func UpdateSalesAssociate(c *fiber.Ctx) error {
 
 // DeleteSalesAssociate godoc
 // @Summary Delete a sales associate
-// @Description Delete a sales associate by ID
+// @Description Soft delete a sales associate by ID. Deleted sales associates are hidden from lists and lookups and can be restored. Sales associates with open sales transactions, pending draft orders or linked user accounts cannot be deleted. A forced delete moves the open documents to replacement_id and detaches linked user accounts instead of handing them over.
 // @Tags SalesAssociates
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "SalesAssociate ID (UUID)"
+// @Param force query bool false "Reassign blocking documents to replacement_id and detach user accounts, then delete (requires master_data:force_delete)"
+// @Param replacement_id query string false "ID of the sales associate taking over the blocking documents, required with force when there are any"
 // @Success 200 {object} map[string]interface{} "SalesAssociate deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid replacement_id"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission master_data:force_delete required to force"
 // @Failure 404 {object} map[string]interface{} "SalesAssociate not found"
+// @Failure 409 {object} map[string]interface{} "Sales associate is still in use, with the list of blockers"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/sales-associates/{id} [delete]
 func DeleteSalesAssociate(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	result := config.DB.Delete(&models.SalesAssociate{}, "id = ?", id)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete sales associate",
-		})
-	}
-
-	if result.RowsAffected == 0 {
+	var salesAssociate models.SalesAssociate
+	if err := config.DB.Where("id = ?", id).First(&salesAssociate).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "SalesAssociate not found",
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "SalesAssociate deleted successfully",
-	})
+	return deleteMasterRecord(c, &salesAssociate, salesAssociate.ID, "sales_associates", "Sales associate", "SalesAssociate deleted successfully")
+}
+
+// RestoreSalesAssociate godoc
+// @Summary Restore a deleted sales associate
+// @Description Undo the soft delete of a sales associate. Fails when another sales associate has taken its code or name since.
+// @Tags SalesAssociates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "SalesAssociate ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored sales associate"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted sales associate not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-associates/{id}/restore [post]
+func RestoreSalesAssociate(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.SalesAssociate{}, "Sales associate", "sales_associate")
 }
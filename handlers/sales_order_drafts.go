@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ApproveSalesOrderDraftRequest represents the request body for approving a draft order
+type ApproveSalesOrderDraftRequest struct {
+	TransactionDate *string `json:"transaction_date"` // Defaults to today
+	WarehouseID     *string `json:"warehouse_id"`     // Defaults to the default warehouse
+	AllowBackorder  bool    `json:"allow_backorder"`  // Accept lines exceeding stock, the shortfall is backordered
+}
+
+// RejectSalesOrderDraftRequest represents the request body for rejecting a draft order
+type RejectSalesOrderDraftRequest struct {
+	Reason string `json:"reason"`
+}
+
+// listSalesOrderDrafts responds with a page of the draft orders matching scope, newest first
+func listSalesOrderDrafts(c *fiber.Ctx, scope *gorm.DB) error {
+	var drafts []models.SalesOrderDraft
+
+	pagination := helpers.GetPaginationParams(c)
+
+	query := scope.Session(&gorm.Session{}).Order("created_at DESC")
+	queryCount := scope.Session(&gorm.Session{}).Model(&models.SalesOrderDraft{})
+
+	if status := c.Query("status"); status != "" {
+		if _, err := strconv.Atoi(status); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid status",
+			})
+		}
+		query = query.Where("status = ?", status)
+		queryCount = queryCount.Where("status = ?", status)
+	}
+
+	if err := query.
+		Preload("SalesAssociate", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&drafts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch draft orders",
+		})
+	}
+
+	response, err := helpers.CreatePaginationResponse(queryCount, drafts, "sales_order_drafts", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// getSalesOrderDraft responds with the draft order of the id parameter if it matches scope
+func getSalesOrderDraft(c *fiber.Ctx, scope *gorm.DB) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Draft order not found",
+		})
+	}
+
+	var draft models.SalesOrderDraft
+	if err := scope.
+		Preload("SalesAssociate", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("ReviewedBy").
+		Preload("CreatedBy").
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).
+		First(&draft).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Draft order not found",
+		})
+	}
+
+	return c.JSON(draft)
+}
+
+// GetAllSalesOrderDrafts godoc
+// @Summary Get all draft orders
+// @Description Draft orders submitted by sales associates through the portal, newest first. Requires sales:read.
+// @Tags Sales Order Drafts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param status query int false "Exact match: 0 (pending), 1 (approved), 2 (rejected)"
+// @Param sales_associate_id query string false "Filter by sales associate"
+// @Success 200 {object} map[string]interface{} "List of draft orders with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-order-drafts [get]
+func GetAllSalesOrderDrafts(c *fiber.Ctx) error {
+	scope := config.DB
+	if salesAssociateID := c.Query("sales_associate_id"); salesAssociateID != "" {
+		scope = scope.Where("sales_associate_id = ?", salesAssociateID)
+	}
+	return listSalesOrderDrafts(c, scope)
+}
+
+// GetSalesOrderDraft godoc
+// @Summary Get a draft order by ID
+// @Description Retrieve a draft order with its books. Requires sales:read.
+// @Tags Sales Order Drafts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Draft order ID (UUID)"
+// @Success 200 {object} models.SalesOrderDraft "Draft order"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "Draft order not found"
+// @Router /api/sales-order-drafts/{id} [get]
+func GetSalesOrderDraft(c *fiber.Ctx) error {
+	return getSalesOrderDraft(c, config.DB)
+}
+
+// ApproveSalesOrderDraft godoc
+// @Summary Approve a draft order
+// @Description Turn a pending draft order into a sales transaction at the books' list prices, taking the books from stock of the warehouse. Promotions and discounts can be set afterwards by updating the transaction. Requires sales:write.
+// @Tags Sales Order Drafts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Draft order ID (UUID)"
+// @Param request body ApproveSalesOrderDraftRequest false "Approval details"
+// @Success 200 {object} map[string]interface{} "Approved draft and created transaction"
+// @Failure 400 {object} map[string]interface{} "Draft is not pending, or insufficient stock"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "Draft order not found"
+// @Failure 409 {object} map[string]interface{} "Draft was reviewed meanwhile"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-order-drafts/{id}/approve [post]
+func ApproveSalesOrderDraft(c *fiber.Ctx) error {
+	var draft models.SalesOrderDraft
+	if err := config.DB.Preload("Items").Where("id = ?", c.Params("id")).First(&draft).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Draft order not found",
+		})
+	}
+
+	if draft.Status != models.DraftStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending draft orders can be approved",
+		})
+	}
+
+	var req ApproveSalesOrderDraftRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	transactionDate, _ := time.Parse(helpers.DateFormat, time.Now().Format(helpers.DateFormat))
+	if req.TransactionDate != nil && *req.TransactionDate != "" {
+		parsed, err := helpers.ParseDateString(req.TransactionDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		transactionDate = *parsed
+	}
+
+	// Get Default Biller ID
+	var defaultBiller models.Biller
+	if err := config.DB.Select("id").First(&defaultBiller).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get default biller",
+		})
+	}
+
+	order := CreateTransactionRequest{
+		SalesAssociateID: draft.SalesAssociateID.String(),
+		PaymentType:      draft.PaymentType,
+		Periode:          draft.Periode,
+		Year:             draft.Year,
+		CurriculumID:     uuidString(draft.CurriculumID),
+		MerkBukuID:       uuidString(draft.MerkBukuID),
+		JenjangStudiID:   uuidString(draft.JenjangStudiID),
+		WarehouseID:      req.WarehouseID,
+		AllowBackorder:   req.AllowBackorder,
+	}
+	for _, item := range draft.Items {
+		order.Items = append(order.Items, CreateTransactionItemRequest{
+			BookID:   item.BookID.String(),
+			Quantity: item.Quantity,
+		})
+	}
+
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	transaction, orderErr := createSalesOrder(tx, order, defaultBiller.ID, transactionDate)
+	if orderErr != nil {
+		tx.Rollback()
+		return c.Status(orderErr.status).JSON(orderErr.body)
+	}
+
+	// Only a draft still pending is approved, so two reviewers cannot both book it
+	result := tx.Model(&models.SalesOrderDraft{}).
+		Where("id = ? AND status = ?", draft.ID, models.DraftStatusPending).
+		Updates(map[string]interface{}{
+			"status":               models.DraftStatusApproved,
+			"sales_transaction_id": transaction.ID,
+			"reviewed_by_id":       currentUserID(c),
+			"reviewed_at":          time.Now(),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to approve draft order",
+		})
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Draft order has already been reviewed",
+		})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":           "Draft order approved",
+		"draft_id":          draft.ID,
+		"sales_transaction": transaction,
+	})
+}
+
+// RejectSalesOrderDraft godoc
+// @Summary Reject a draft order
+// @Description Reject a pending draft order with a reason shown to the sales associate. Requires sales:write.
+// @Tags Sales Order Drafts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Draft order ID (UUID)"
+// @Param request body RejectSalesOrderDraftRequest true "Rejection reason"
+// @Success 200 {object} map[string]interface{} "Draft order rejected"
+// @Failure 400 {object} map[string]interface{} "Missing reason or draft is not pending"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "Draft order not found"
+// @Failure 409 {object} map[string]interface{} "Draft was reviewed meanwhile"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-order-drafts/{id}/reject [post]
+func RejectSalesOrderDraft(c *fiber.Ctx) error {
+	var draft models.SalesOrderDraft
+	if err := config.DB.Where("id = ?", c.Params("id")).First(&draft).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Draft order not found",
+		})
+	}
+
+	var req RejectSalesOrderDraftRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason is required",
+		})
+	}
+
+	if draft.Status != models.DraftStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending draft orders can be rejected",
+		})
+	}
+
+	result := auditedDB(c).Model(&models.SalesOrderDraft{}).
+		Where("id = ? AND status = ?", draft.ID, models.DraftStatusPending).
+		Updates(map[string]interface{}{
+			"status":           models.DraftStatusRejected,
+			"rejection_reason": reason,
+			"reviewed_by_id":   currentUserID(c),
+			"reviewed_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reject draft order",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Draft order has already been reviewed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Draft order rejected",
+	})
+}
+
+// uuidString formats an optional ID for a request struct
+func uuidString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
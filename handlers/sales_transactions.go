@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"pustaka-backend/models"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -23,12 +25,15 @@ type CreateTransactionRequest struct {
 	CurriculumID     *string                        `json:"curriculum_id"`
 	MerkBukuID       *string                        `json:"merk_buku_id"`
 	JenjangStudiID   *string                        `json:"jenjang_studi_id"`
+	WarehouseID      *string                        `json:"warehouse_id"`    // Defaults to the default warehouse
+	AllowBackorder   bool                           `json:"allow_backorder"` // Accept lines exceeding stock, the shortfall is backordered
 	Items            []CreateTransactionItemRequest `json:"items"`
 }
 
 // CreateTransactionItemRequest represents an item in the transaction
 type CreateTransactionItemRequest struct {
 	BookID    string  `json:"book_id"`
+	BundleID  string  `json:"bundle_id"` // Sell a book bundle instead of a single book, quantity is the number of bundles
 	Quantity  int     `json:"quantity"`
 	Promotion float64 `json:"promotion"` // Flat amount deduction from price
 	Discount  float64 `json:"discount"`  // Percentage discount (0-100) applied after promotion
@@ -93,11 +98,13 @@ func generateInvoiceNumber(db *gorm.DB) (string, error) {
 // @Param transaction_date_from query string false "Start date for date range filter (ISO format: YYYY-MM-DD)"
 // @Param transaction_date_to query string false "End date for date range filter (ISO format: YYYY-MM-DD)"
 // @Param payment_type query string false "Exact match: T (Tunai/Cash) or K (Kredit/Credit)"
-// @Param status query int false "Exact match: 0 (Pesanan), 1 (Lunas), 2 (Angsuran)"
+// @Param status query int false "Exact match: 0 (Pesanan), 1 (Lunas), 2 (Angsuran), 3 (Dibatalkan)"
 // @Param total_amount_min query number false "Minimum total amount"
 // @Param total_amount_max query number false "Maximum total amount"
+// @Param created_by_id query string false "Filter by the user who entered the transaction"
 // @Param sort_by query string false "Field to sort by: no_invoice, sales_associate_name, transaction_date, payment_type, total_amount, status"
 // @Param sort_order query string false "Sort order: asc or desc (default: desc)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of all sales transactions with pagination"
 // @Failure 401 {object} map[string]interface{} "Unauthorized"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -168,6 +175,12 @@ func GetAllSalesTransactions(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("sales_transactions.payment_type = ?", paymentType)
 	}
 
+	// Filter by the user who entered the transaction
+	if createdByID := c.Query("created_by_id"); createdByID != "" {
+		query = query.Where("sales_transactions.created_by_id = ?", createdByID)
+		queryCount = queryCount.Where("sales_transactions.created_by_id = ?", createdByID)
+	}
+
 	// Filter by total amount range
 	if totalAmountMin := c.Query("total_amount_min"); totalAmountMin != "" {
 		if minAmount, err := strconv.ParseFloat(totalAmountMin, 64); err == nil {
@@ -219,21 +232,40 @@ func GetAllSalesTransactions(c *fiber.Ctx) error {
 		query = query.Order("sales_transactions.created_at " + sortOrder)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		exportQuery := query.
+			Preload("Biller", withDeleted).
+			Preload("SalesAssociate", withDeleted).
+			Preload("SalesAssociate.City", withDeleted).
+			Preload("Curriculum", withDeleted).
+			Preload("MerkBuku", withDeleted).
+			Preload("JenjangStudi", withDeleted).
+			Preload("Warehouse", withDeleted).
+			Preload("CreatedBy").
+			Preload("UpdatedBy")
+		return helpers.ExportQuery(c, exportQuery, models.SalesTransaction{}, "sales_transactions")
+	}
+
 	// Apply pagination and fetch data
 	if err := query.
 		Offset(pagination.Offset).Limit(pagination.Limit).
-		Preload("Biller").
-		Preload("SalesAssociate").
-		Preload("SalesAssociate.City").
-		Preload("Curriculum").
-		Preload("MerkBuku").
-		Preload("JenjangStudi").
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("SalesAssociate.City", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
+		Preload("Items.Bundle").
 		Preload("Payments").
 		Preload("Shippings").
-		Preload("Shippings.Expedition").
+		Preload("Shippings.Expedition", withDeleted).
 		Find(&transactions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch sales transactions",
@@ -253,7 +285,7 @@ func GetAllSalesTransactions(c *fiber.Ctx) error {
 
 // GetSalesTransaction godoc
 // @Summary Get a sales transaction by ID
-// @Description Retrieve a single sales transaction by its ID with all related entities
+// @Description Retrieve a single sales transaction by its ID with all related entities and the lines still pending on backorder
 // @Tags Sales Transactions
 // @Accept json
 // @Produce json
@@ -268,23 +300,27 @@ func GetSalesTransaction(c *fiber.Ctx) error {
 
 	var transaction models.SalesTransaction
 	if err := config.DB.
-		Preload("Biller").
-		Preload("SalesAssociate").
-		Preload("SalesAssociate.City").
-		Preload("Curriculum").
-		Preload("MerkBuku").
-		Preload("JenjangStudi").
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("SalesAssociate.City", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.BidangStudi").
-		Preload("Items.Book.JenjangStudi").
-		Preload("Items.Book.Curriculum").
-		Preload("Items.Book.Publisher").
-		Preload("Items.Book.JenisBuku").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.BidangStudi", withDeleted).
+		Preload("Items.Book.JenjangStudi", withDeleted).
+		Preload("Items.Book.Curriculum", withDeleted).
+		Preload("Items.Book.Publisher", withDeleted).
+		Preload("Items.Book.JenisBuku", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
+		Preload("Items.Bundle").
 		Preload("Payments").
 		Preload("Shippings").
-		Preload("Shippings.Expedition").
+		Preload("Shippings.Expedition", withDeleted).
 		Where("id = ?", id).First(&transaction).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Transaction not found",
@@ -292,13 +328,14 @@ func GetSalesTransaction(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"transaction": transaction,
+		"transaction":        transaction,
+		"pending_backorders": pendingBackorders(transaction.Items),
 	})
 }
 
 // CreateSalesTransaction godoc
 // @Summary Create a new sales transaction
-// @Description Create a new sales transaction with items and optional installments
+// @Description Create a new sales transaction with items and optional installments. With allow_backorder, lines exceeding stock reserve what is available and the rest is backordered. An item with bundle_id sells a book bundle: it becomes one item per book of the bundle, each taking its books from stock, with the bundle price spread over the books in proportion to their list price.
 // @Tags Sales Transactions
 // @Accept json
 // @Produce json
@@ -366,136 +403,262 @@ func CreateSalesTransaction(c *fiber.Ctx) error {
 		}
 	}
 
+	// The transaction date is required
+	transactionDate, err := helpers.ParseDateString(req.TransactionDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if transactionDate == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "transaction_date is required",
+		})
+	}
+
 	// Start a database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
+	transaction, orderErr := createSalesOrder(tx, req, defaultBiller.ID, *transactionDate)
+	if orderErr != nil {
+		tx.Rollback()
+		return c.Status(orderErr.status).JSON(orderErr.body)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the complete transaction with all relations
+	var createdTransaction models.SalesTransaction
+	config.DB.
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
+		Preload("Items.Bundle").
+		Preload("Payments").
+		Preload("Shippings").
+		Preload("Shippings.Expedition", withDeleted).
+		Where("id = ?", transaction.ID).First(&createdTransaction)
+
+	return c.Status(fiber.StatusCreated).JSON(createdTransaction)
+}
+
+// salesOrderError is a request error found while booking a sales order, with the response
+// to send
+type salesOrderError struct {
+	status int
+	body   fiber.Map
+}
+
+// createSalesOrder books a sales transaction with its items in tx, taking the books from
+// stock of the order's warehouse. It is used for orders entered by staff and for approved
+// portal drafts; the caller commits or rolls back tx.
+func createSalesOrder(tx *gorm.DB, req CreateTransactionRequest, billerID uuid.UUID, transactionDate time.Time) (*models.SalesTransaction, *salesOrderError) {
+	// Resolve the warehouse the order ships from
+	warehouse, err := resolveWarehouse(tx, req.WarehouseID)
+	if err != nil {
+		return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+			"error": "Warehouse not found",
+		}}
+	}
+
 	// Calculate total amount from items and validate stock
 	var totalItemsPrice float64
 	var transactionItems []models.SalesTransactionItem
-	var booksToUpdate []models.Book
 
 	for _, item := range req.Items {
+		// A bundle line expands into one item per book of the bundle
+		if item.BundleID != "" {
+			if item.BookID != "" {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": "An item takes either book_id or bundle_id, not both",
+				}}
+			}
+
+			bundle, err := findSellableBundle(tx, item.BundleID)
+			if err != nil {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": fmt.Sprintf("Bundle with ID %s not found", item.BundleID),
+				}}
+			}
+			if !bundle.IsActive || len(bundle.Items) == 0 {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": fmt.Sprintf("Bundle %s is not available for sale", bundle.Name),
+				}}
+			}
+			for _, component := range bundle.Items {
+				// Deleted books are not loaded
+				if component.Book == nil {
+					return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+						"error": fmt.Sprintf("Bundle %s contains a deleted book", bundle.Name),
+					}}
+				}
+			}
+
+			if item.Quantity <= 0 {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": "Quantity must be greater than 0",
+				}}
+			}
+			if item.Promotion < 0 {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": "Promotion cannot be negative",
+				}}
+			}
+			if item.Discount < 0 || item.Discount > 100 {
+				return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+					"error": "Discount must be between 0 and 100",
+				}}
+			}
+
+			// The bundle price, after promotion and discount, is spread over the books for reporting
+			subtotal := roundCents(calculateItemSubtotal(bundle.Price, item.Quantity, item.Promotion, item.Discount))
+			totalItemsPrice += subtotal
+			shares := spreadBundlePrice(subtotal, bundle.Items)
+
+			for i, component := range bundle.Items {
+				quantity := component.Quantity * item.Quantity
+
+				backordered, availableStock, err := reserveSalesStock(tx, component.BookID, warehouse.ID, quantity, req.AllowBackorder)
+				if errors.Is(err, errInsufficientStock) {
+					return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+						"error":           fmt.Sprintf("Insufficient stock for book: %s (bundle %s)", component.Book.Name, bundle.Name),
+						"available_stock": availableStock,
+						"requested":       quantity,
+					}}
+				}
+				if err != nil {
+					return nil, &salesOrderError{status: fiber.StatusInternalServerError, body: fiber.Map{
+						"error": "Failed to update book stock",
+					}}
+				}
+
+				bundleID := bundle.ID
+				transactionItems = append(transactionItems, models.SalesTransactionItem{
+					BookID:              component.BookID,
+					Quantity:            quantity,
+					BackorderedQuantity: backordered,
+					Price:               roundCents(shares[i] / float64(quantity)),
+					Subtotal:            shares[i],
+					UnitCost:            component.Book.AverageCost,
+					COGS:                component.Book.AverageCost * float64(quantity),
+					BundleID:            &bundleID,
+					BundleQuantity:      item.Quantity,
+				})
+			}
+			continue
+		}
+
 		// Fetch book to get current price and stock
 		var book models.Book
 		if err := tx.Where("id = ?", item.BookID).First(&book).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
 				"error": fmt.Sprintf("Book with ID %s not found", item.BookID),
-			})
+			}}
 		}
 
 		// Validate quantity
 		if item.Quantity <= 0 {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
 				"error": "Quantity must be greater than 0",
-			})
-		}
-
-		// Validate stock availability
-		if book.Stock < item.Quantity {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error":           fmt.Sprintf("Insufficient stock for book: %s", book.Name),
-				"available_stock": book.Stock,
-				"requested":       item.Quantity,
-			})
+			}}
 		}
 
 		// Validate promotion and discount values
 		if item.Promotion < 0 {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
 				"error": "Promotion cannot be negative",
-			})
+			}}
 		}
 		if item.Discount < 0 || item.Discount > 100 {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
 				"error": "Discount must be between 0 and 100",
-			})
+			}}
 		}
 
 		// Calculate subtotal with promotion and discount
 		subtotal := calculateItemSubtotal(book.Price, item.Quantity, item.Promotion, item.Discount)
 		totalItemsPrice += subtotal
 
-		// Reduce stock
-		book.Stock -= item.Quantity
-		booksToUpdate = append(booksToUpdate, book)
+		// Reduce stock in the warehouse, backordering the shortfall if allowed
+		backordered, availableStock, err := reserveSalesStock(tx, book.ID, warehouse.ID, item.Quantity, req.AllowBackorder)
+		if errors.Is(err, errInsufficientStock) {
+			return nil, &salesOrderError{status: fiber.StatusBadRequest, body: fiber.Map{
+				"error":           fmt.Sprintf("Insufficient stock for book: %s", book.Name),
+				"available_stock": availableStock,
+				"requested":       item.Quantity,
+			}}
+		}
+		if err != nil {
+			return nil, &salesOrderError{status: fiber.StatusInternalServerError, body: fiber.Map{
+				"error": "Failed to update book stock",
+			}}
+		}
 
 		// Create transaction item (we'll save this after creating the transaction)
 		transactionItems = append(transactionItems, models.SalesTransactionItem{
-			BookID:    book.ID,
-			Quantity:  item.Quantity,
-			Price:     book.Price,
-			Promotion: item.Promotion,
-			Discount:  item.Discount,
-			Subtotal:  subtotal,
+			BookID:              book.ID,
+			Quantity:            item.Quantity,
+			BackorderedQuantity: backordered,
+			Price:               book.Price,
+			Promotion:           item.Promotion,
+			Discount:            item.Discount,
+			Subtotal:            subtotal,
+			UnitCost:            book.AverageCost,
+			COGS:                book.AverageCost * float64(item.Quantity),
 		})
 	}
 
-	// Update book stocks
-	for _, book := range booksToUpdate {
-		if err := tx.Model(&book).Update("stock", book.Stock).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update book stock",
-			})
-		}
-	}
-
 	// Calculate total amount (items only, shipping added separately)
 	totalAmount := totalItemsPrice
 
 	// Generate invoice number
 	noInvoice, err := generateInvoiceNumber(tx)
 	if err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return nil, &salesOrderError{status: fiber.StatusInternalServerError, body: fiber.Map{
 			"error": "Failed to generate invoice number",
-		})
+		}}
 	}
 
 	// Create the transaction
-	transactionDate, err := helpers.ParseDateString(req.TransactionDate)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
-	if transactionDate == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "transaction_date is required",
-		})
-	}
-
 	transaction := models.SalesTransaction{
-		BillerID:         &defaultBiller.ID,
+		BillerID:         &billerID,
 		SalesAssociateID: helpers.ParseUUID(req.SalesAssociateID),
 		NoInvoice:        noInvoice,
 		PaymentType:      req.PaymentType,
-		TransactionDate:  *transactionDate,
+		TransactionDate:  transactionDate,
 		TotalAmount:      totalAmount,
-		Status:           0, // Default to booking
+		Status:           models.SalesStatusBooking,
 		Periode:          req.Periode,
 		Year:             req.Year,
 		CurriculumID:     helpers.ParseUUIDPtr(req.CurriculumID),
 		MerkBukuID:       helpers.ParseUUIDPtr(req.MerkBukuID),
 		JenjangStudiID:   helpers.ParseUUIDPtr(req.JenjangStudiID),
+		WarehouseID:      &warehouse.ID,
 	}
 
 	// Save the transaction
 	if err := tx.Create(&transaction).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return nil, &salesOrderError{status: fiber.StatusInternalServerError, body: fiber.Map{
 			"error": "Failed to create transaction",
-		})
+		}}
 	}
 
 	// Save transaction items
@@ -503,36 +666,12 @@ func CreateSalesTransaction(c *fiber.Ctx) error {
 		transactionItems[i].TransactionID = transaction.ID
 	}
 	if err := tx.Create(&transactionItems).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return nil, &salesOrderError{status: fiber.StatusInternalServerError, body: fiber.Map{
 			"error": "Failed to create transaction items",
-		})
-	}
-
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
+		}}
 	}
 
-	// Fetch the complete transaction with all relations
-	var createdTransaction models.SalesTransaction
-	config.DB.
-		Preload("Biller").
-		Preload("SalesAssociate").
-		Preload("Curriculum").
-		Preload("MerkBuku").
-		Preload("JenjangStudi").
-		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
-		Preload("Payments").
-		Preload("Shippings").
-		Preload("Shippings.Expedition").
-		Where("id = ?", transaction.ID).First(&createdTransaction)
-
-	return c.Status(fiber.StatusCreated).JSON(createdTransaction)
+	return &transaction, nil
 }
 
 // UpdateTransactionRequest represents the request body for updating a transaction
@@ -540,18 +679,18 @@ type UpdateTransactionRequest struct {
 	SalesAssociateID *string                        `json:"sales_associate_id"`
 	PaymentType      *string                        `json:"payment_type"`
 	TransactionDate  *string                        `json:"transaction_date"`
-	Status           *int                           `json:"status"`
 	Periode          *int                           `json:"periode"`
 	Year             *string                        `json:"year"`
 	CurriculumID     *string                        `json:"curriculum_id"`
 	MerkBukuID       *string                        `json:"merk_buku_id"`
 	JenjangStudiID   *string                        `json:"jenjang_studi_id"`
+	AllowBackorder   bool                           `json:"allow_backorder"` // Accept added quantities exceeding stock, the shortfall is backordered
 	Items            []CreateTransactionItemRequest `json:"items,omitempty"`
 }
 
 // UpdateSalesTransaction godoc
 // @Summary Update a sales transaction
-// @Description Update an existing sales transaction by ID
+// @Description Update an existing sales transaction by ID. The status is not editable here, it follows the payments and the cancel endpoint.
 // @Tags Sales Transactions
 // @Accept json
 // @Produce json
@@ -574,6 +713,13 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Cancelled transactions are kept for reference only
+	if transaction.Status == models.SalesStatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot update a cancelled transaction",
+		})
+	}
+
 	// check existing transaction biller id is nil
 	// if nil, then get default biller id from biller table (first record)
 	// set transaction biller id to default biller id
@@ -594,8 +740,32 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 		})
 	}
 
+	// Bundle lines are expanded when the sale is created and cannot be edited afterwards
+	for _, item := range req.Items {
+		if item.BundleID != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Bundles can only be added when creating a transaction",
+			})
+		}
+	}
+
+	// Items are locked once payments or shippings have been recorded
+	if len(req.Items) > 0 {
+		locked, err := salesTransactionHasActivity(config.DB, transaction.ID.String())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check transaction payments and shippings",
+			})
+		}
+		if locked {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot edit items once payments or shippings exist",
+			})
+		}
+	}
+
 	// Start a database transaction for atomic updates
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -644,10 +814,6 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 		}
 	}
 
-	if req.Status != nil {
-		updates["status"] = *req.Status
-	}
-
 	if req.Periode != nil {
 		updates["periode"] = *req.Periode
 	}
@@ -670,6 +836,14 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 
 	// Handle items updates with stock management
 	if req.Items != nil && len(req.Items) > 0 {
+		warehouseID, err := warehouseIDOrDefault(tx, transaction.WarehouseID)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to resolve transaction warehouse",
+			})
+		}
+
 		// Get existing items for this transaction
 		var existingItems []models.SalesTransactionItem
 		if err := tx.Where("transaction_id = ?", transaction.ID).Find(&existingItems).Error; err != nil {
@@ -682,6 +856,12 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 		// Create a map of existing items by book_id for quick lookup
 		existingItemsMap := make(map[string]models.SalesTransactionItem)
 		for _, item := range existingItems {
+			if item.BundleID != nil {
+				tx.Rollback()
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Cannot edit the items of a transaction that contains bundles, cancel it and create a new one",
+				})
+			}
 			existingItemsMap[item.BookID.String()] = item
 		}
 
@@ -739,27 +919,35 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 			if existingItem, exists := existingItemsMap[itemReq.BookID]; exists {
 				// Calculate stock adjustment (difference between old and new quantity)
 				quantityDiff := itemReq.Quantity - existingItem.Quantity
+				backordered := existingItem.BackorderedQuantity
 
 				if quantityDiff > 0 {
-					// Need more stock - check availability
-					if book.Stock < quantityDiff {
+					// Need more stock - reserve what is available
+					additionalBackordered, availableStock, err := reserveSalesStock(tx, book.ID, warehouseID, quantityDiff, req.AllowBackorder)
+					if errors.Is(err, errInsufficientStock) {
 						tx.Rollback()
 						return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 							"error":                fmt.Sprintf("Insufficient stock for book: %s", book.Name),
-							"available_stock":      book.Stock,
+							"available_stock":      availableStock,
 							"additional_requested": quantityDiff,
 						})
 					}
-					// Reduce stock
-					if err := tx.Model(&book).Update("stock", book.Stock-quantityDiff).Error; err != nil {
+					if err != nil {
 						tx.Rollback()
 						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 							"error": "Failed to update book stock",
 						})
 					}
+					backordered += additionalBackordered
 				} else if quantityDiff < 0 {
-					// Returning stock
-					if err := tx.Model(&book).Update("stock", book.Stock-quantityDiff).Error; err != nil {
+					// Less is needed - cancel backordered quantity first, then return reserved stock
+					fromBackorder := -quantityDiff
+					if fromBackorder > backordered {
+						fromBackorder = backordered
+					}
+					backordered -= fromBackorder
+
+					if err := adjustWarehouseStock(tx, book.ID, warehouseID, -quantityDiff-fromBackorder); err != nil {
 						tx.Rollback()
 						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 							"error": "Failed to update book stock",
@@ -767,13 +955,22 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 					}
 				}
 
+				// Keep the cost recorded at sale time, older lines without one take the current average
+				unitCost := existingItem.UnitCost
+				if unitCost == 0 {
+					unitCost = book.AverageCost
+				}
+
 				// Update existing item
 				if err := tx.Model(&existingItem).Updates(map[string]interface{}{
-					"quantity":  itemReq.Quantity,
-					"price":     book.Price,
-					"promotion": itemReq.Promotion,
-					"discount":  itemReq.Discount,
-					"subtotal":  subtotal,
+					"quantity":             itemReq.Quantity,
+					"backordered_quantity": backordered,
+					"price":                book.Price,
+					"promotion":            itemReq.Promotion,
+					"discount":             itemReq.Discount,
+					"subtotal":             subtotal,
+					"unit_cost":            unitCost,
+					"cogs":                 unitCost * float64(itemReq.Quantity),
 				}).Error; err != nil {
 					tx.Rollback()
 					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -781,18 +978,17 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 					})
 				}
 			} else {
-				// New item - check stock availability
-				if book.Stock < itemReq.Quantity {
+				// New item - reduce stock, backordering the shortfall if allowed
+				backordered, availableStock, err := reserveSalesStock(tx, book.ID, warehouseID, itemReq.Quantity, req.AllowBackorder)
+				if errors.Is(err, errInsufficientStock) {
 					tx.Rollback()
 					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 						"error":           fmt.Sprintf("Insufficient stock for book: %s", book.Name),
-						"available_stock": book.Stock,
+						"available_stock": availableStock,
 						"requested":       itemReq.Quantity,
 					})
 				}
-
-				// Reduce stock
-				if err := tx.Model(&book).Update("stock", book.Stock-itemReq.Quantity).Error; err != nil {
+				if err != nil {
 					tx.Rollback()
 					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 						"error": "Failed to update book stock",
@@ -801,13 +997,16 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 
 				// Create new item
 				newItem := models.SalesTransactionItem{
-					TransactionID: transaction.ID,
-					BookID:        book.ID,
-					Quantity:      itemReq.Quantity,
-					Price:         book.Price,
-					Promotion:     itemReq.Promotion,
-					Discount:      itemReq.Discount,
-					Subtotal:      subtotal,
+					TransactionID:       transaction.ID,
+					BookID:              book.ID,
+					Quantity:            itemReq.Quantity,
+					BackorderedQuantity: backordered,
+					Price:               book.Price,
+					Promotion:           itemReq.Promotion,
+					Discount:            itemReq.Discount,
+					Subtotal:            subtotal,
+					UnitCost:            book.AverageCost,
+					COGS:                book.AverageCost * float64(itemReq.Quantity),
 				}
 				if err := tx.Create(&newItem).Error; err != nil {
 					tx.Rollback()
@@ -821,15 +1020,12 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 		// Delete items that are no longer in the request and restore stock
 		for bookID, existingItem := range existingItemsMap {
 			if !requestedBookIDs[bookID] {
-				// Restore stock for removed item
-				var book models.Book
-				if err := tx.Where("id = ?", existingItem.BookID).First(&book).Error; err == nil {
-					if err := tx.Model(&book).Update("stock", book.Stock+existingItem.Quantity).Error; err != nil {
-						tx.Rollback()
-						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-							"error": "Failed to restore book stock",
-						})
-					}
+				// Restore reserved stock for removed item
+				if err := adjustWarehouseStock(tx, existingItem.BookID, warehouseID, reservedQuantity(existingItem)); err != nil {
+					tx.Rollback()
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+						"error": "Failed to restore book stock",
+					})
 				}
 
 				if err := tx.Delete(&existingItem).Error; err != nil {
@@ -870,17 +1066,21 @@ func UpdateSalesTransaction(c *fiber.Ctx) error {
 
 	// Fetch the updated transaction with all relations
 	config.DB.
-		Preload("Biller").
-		Preload("SalesAssociate").
-		Preload("Curriculum").
-		Preload("MerkBuku").
-		Preload("JenjangStudi").
+		Preload("Biller", withDeleted).
+		Preload("SalesAssociate", withDeleted).
+		Preload("Curriculum", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Warehouse", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Preload("Items").
-		Preload("Items.Book").
-		Preload("Items.Book.MerkBuku").
+		Preload("Items.Book", withDeleted).
+		Preload("Items.Book.MerkBuku", withDeleted).
+		Preload("Items.Bundle").
 		Preload("Payments").
 		Preload("Shippings").
-		Preload("Shippings.Expedition").
+		Preload("Shippings.Expedition", withDeleted).
 		Where("id = ?", id).First(&transaction)
 
 	return c.JSON(transaction)
@@ -911,23 +1111,20 @@ func DeleteSalesTransaction(c *fiber.Ctx) error {
 	}
 
 	// Start database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Restore stock for all items
-	for _, item := range transaction.Items {
-		var book models.Book
-		if err := tx.Where("id = ?", item.BookID).First(&book).Error; err == nil {
-			if err := tx.Model(&book).Update("stock", book.Stock+item.Quantity).Error; err != nil {
-				tx.Rollback()
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to restore book stock",
-				})
-			}
+	// Restore stock for all items (cancelled transactions already returned their stock)
+	if transaction.Status != models.SalesStatusCancelled {
+		if err := restoreSalesItemsStock(tx, transaction.WarehouseID, transaction.Items); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to restore book stock",
+			})
 		}
 	}
 
@@ -950,3 +1147,137 @@ func DeleteSalesTransaction(c *fiber.Ctx) error {
 		"message": "Transaction deleted successfully",
 	})
 }
+
+// salesTransactionHasActivity reports whether payments or shippings have been recorded for a sales transaction
+func salesTransactionHasActivity(db *gorm.DB, transactionID string) (bool, error) {
+	var paymentCount int64
+	if err := db.Model(&models.Payment{}).Where("sales_transaction_id = ?", transactionID).Count(&paymentCount).Error; err != nil {
+		return false, err
+	}
+
+	var shippingCount int64
+	if err := db.Model(&models.Shipping{}).Where("sales_transaction_id = ?", transactionID).Count(&shippingCount).Error; err != nil {
+		return false, err
+	}
+
+	return paymentCount > 0 || shippingCount > 0, nil
+}
+
+// restoreSalesItemsStock puts the reserved quantity of each sales item back into the warehouse it shipped from.
+// Backordered quantities were never taken from stock and are simply dropped.
+func restoreSalesItemsStock(tx *gorm.DB, warehouseID *uuid.UUID, items []models.SalesTransactionItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	resolvedID, err := warehouseIDOrDefault(tx, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := adjustWarehouseStock(tx, item.BookID, resolvedID, reservedQuantity(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CancelSalesTransaction godoc
+// @Summary Cancel a sales transaction
+// @Description Cancel a booked sales transaction that has no payments or shippings. Stock is restored for all items and the document is kept with status 3 (cancelled).
+// @Tags Sales Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Transaction cancelled successfully"
+// @Failure 400 {object} map[string]interface{} "Transaction cannot be cancelled"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Transaction not found"
+// @Failure 409 {object} map[string]interface{} "Transaction was changed concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-transactions/{id}/cancel [post]
+func CancelSalesTransaction(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transaction models.SalesTransaction
+	if err := config.DB.Preload("Items").Where("id = ?", id).First(&transaction).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Transaction not found",
+		})
+	}
+
+	if transaction.Status == models.SalesStatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Transaction is already cancelled",
+		})
+	}
+
+	if !models.CanTransitionSalesStatus(transaction.Status, models.SalesStatusCancelled) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only booked transactions can be cancelled",
+		})
+	}
+
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Claim the transaction first, so a concurrent cancel finds it cancelled and stock is
+	// restored once
+	result := tx.Model(&models.SalesTransaction{}).
+		Where("id = ? AND status = ?", transaction.ID, transaction.Status).
+		Update("status", models.SalesStatusCancelled)
+	if result.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel transaction",
+		})
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Transaction has been changed concurrently, reload it",
+		})
+	}
+	transaction.Status = models.SalesStatusCancelled
+
+	hasActivity, err := salesTransactionHasActivity(tx, transaction.ID.String())
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check transaction payments and shippings",
+		})
+	}
+	if hasActivity {
+		tx.Rollback()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot cancel a transaction with payments or shippings",
+		})
+	}
+
+	// Put stock back for all items
+	if err := restoreSalesItemsStock(tx, transaction.WarehouseID, transaction.Items); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore book stock",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":     "Transaction cancelled successfully. Stock has been restored.",
+		"transaction": transaction,
+	})
+}
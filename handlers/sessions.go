@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAccessTokenMinutes = 15
+	defaultRefreshTokenHours  = 720
+)
+
+// RefreshTokenRequest is the body of the refresh and logout endpoints
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" example:"q0J3vXoFh1c7cU2x6mXv7yI0bKpJm3mN9y0n3pQ2b3A"`
+}
+
+// accessTokenTTL is the lifetime of access tokens, JWT_ACCESS_EXPIRE_MINUTES (default 15)
+func accessTokenTTL() time.Duration {
+	minutes, _ := strconv.Atoi(os.Getenv("JWT_ACCESS_EXPIRE_MINUTES"))
+	if minutes <= 0 {
+		minutes = defaultAccessTokenMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// refreshTokenTTL is the lifetime of refresh tokens, JWT_REFRESH_EXPIRE_HOURS (default 720)
+func refreshTokenTTL() time.Duration {
+	hours, _ := strconv.Atoi(os.Getenv("JWT_REFRESH_EXPIRE_HOURS"))
+	if hours <= 0 {
+		hours = defaultRefreshTokenHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// signAccessToken issues a short-lived JWT for the user
+func signAccessToken(user models.User) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL())
+	claims := Claims{
+		UserID:       user.ID.String(),
+		Email:        user.Email,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return tokenString, expiresAt, err
+}
+
+// issueRefreshToken stores a new refresh token for the user in the given family and returns
+// the token itself, which is only ever shown to the client
+func issueRefreshToken(tx *gorm.DB, c *fiber.Ctx, user models.User, familyID uuid.UUID) (string, models.RefreshToken, error) {
+	token, hash, err := helpers.GenerateToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:       user.ID,
+		FamilyID:     familyID,
+		TokenHash:    hash,
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    time.Now().Add(refreshTokenTTL()),
+	}
+	if userAgent := c.Get(fiber.HeaderUserAgent); userAgent != "" {
+		refreshToken.UserAgent = &userAgent
+	}
+	if ip := c.IP(); ip != "" {
+		refreshToken.IPAddress = &ip
+	}
+
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return token, refreshToken, nil
+}
+
+// sessionTokens is the token part of the login and refresh responses
+func sessionTokens(accessToken string, accessExpiresAt time.Time, refreshToken string, stored models.RefreshToken) fiber.Map {
+	return fiber.Map{
+		"token":              accessToken,
+		"token_type":         "Bearer",
+		"expires_at":         accessExpiresAt,
+		"refresh_token":      refreshToken,
+		"refresh_expires_at": stored.ExpiresAt,
+	}
+}
+
+// startSession finishes a successful login: it starts a new session (refresh token family)
+// and responds with its tokens and the user
+func startSession(c *fiber.Ctx, user models.User) error {
+	var refreshToken string
+	var stored models.RefreshToken
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		refreshToken, stored, err = issueRefreshToken(tx, c, user, uuid.New())
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create session",
+		})
+	}
+
+	accessToken, accessExpiresAt, err := signAccessToken(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	// A successful login clears the failures of the email, not those of the IP address
+	email := normalizeLoginEmail(user.Email)
+	loginAttempts.DeleteLockout("email:" + email)
+	recordLoginAttempt(c, email, &user.ID, models.LoginReasonSuccess)
+
+	response := sessionTokens(accessToken, accessExpiresAt, refreshToken, stored)
+	response["user"] = fiber.Map{
+		"id":                 user.ID,
+		"email":              user.Email,
+		"full_name":          user.FullName,
+		"role":               user.Role,
+		"two_factor_enabled": user.TwoFactorEnabled,
+	}
+	return c.JSON(response)
+}
+
+// revokeUserSessions logs the user out everywhere: access tokens stop working because the
+// token version changes, and all refresh tokens are revoked
+func revokeUserSessions(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RefreshSession godoc
+// @Summary Refresh the access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. The old refresh token stops working. Presenting a refresh token that was already exchanged revokes the whole session, since the token may have been stolen.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token from login or the previous refresh"
+// @Success 200 {object} map[string]interface{} "New access and refresh tokens"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Invalid, expired or revoked refresh token"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/refresh [post]
+func RefreshSession(c *fiber.Ctx) error {
+	var req RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "refresh_token is required",
+		})
+	}
+
+	var stored models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", helpers.HashToken(req.RefreshToken)).First(&stored).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid refresh token",
+		})
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil {
+		// A token that was already exchanged is being reused: end the session for everyone
+		if stored.ReplacedByID != nil {
+			auditedDB(c).Model(&models.RefreshToken{}).
+				Where("family_id = ? AND revoked_at IS NULL", stored.FamilyID).
+				Update("revoked_at", now)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Refresh token has been revoked",
+		})
+	}
+	if now.After(stored.ExpiresAt) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Refresh token has expired",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", stored.UserID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid refresh token",
+		})
+	}
+	if user.TokenVersion != stored.TokenVersion {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Refresh token has been revoked",
+		})
+	}
+
+	var refreshToken string
+	var replacement models.RefreshToken
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		refreshToken, replacement, err = issueRefreshToken(tx, c, user, stored.FamilyID)
+		if err != nil {
+			return err
+		}
+
+		// Only one refresh may win when the same token is sent twice at the same time
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", stored.ID).
+			Updates(map[string]interface{}{"revoked_at": now, "replaced_by_id": replacement.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusUnauthorized, "Refresh token has been revoked")
+		}
+		return nil
+	})
+	if err != nil {
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh session",
+		})
+	}
+
+	accessToken, accessExpiresAt, err := signAccessToken(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(sessionTokens(accessToken, accessExpiresAt, refreshToken, replacement))
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description End the session of the refresh token. The access token of the session keeps working until it expires, which is at most JWT_ACCESS_EXPIRE_MINUTES.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token of the session"
+// @Success 200 {object} map[string]interface{} "Logged out"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/logout [post]
+func Logout(c *fiber.Ctx) error {
+	var req RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "refresh_token is required",
+		})
+	}
+
+	// Unknown tokens are ignored, logging out twice is not an error
+	var stored models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", helpers.HashToken(req.RefreshToken)).First(&stored).Error; err == nil {
+		if err := auditedDB(c).Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", stored.FamilyID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to log out",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out",
+	})
+}
+
+// LogoutAll godoc
+// @Summary Log out all sessions
+// @Description End every session of the current user, on all devices. All access and refresh tokens stop working immediately, including the one used for this request.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Logged out of all sessions"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/logout-all [post]
+func LogoutAll(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Locals("userID").(string))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid token claims",
+		})
+	}
+
+	if err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		return revokeUserSessions(tx, userID)
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out of all sessions",
+	})
+}
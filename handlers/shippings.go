@@ -48,7 +48,9 @@ func GetTransactionShippings(c *fiber.Ctx) error {
 
 	var shippings []models.Shipping
 	if err := config.DB.
-		Preload("Expedition").
+		Preload("Expedition", withDeleted).
+		Preload("CreatedBy").
+		Preload("UpdatedBy").
 		Where("sales_transaction_id = ?", transactionID).
 		Order("created_at ASC").
 		Find(&shippings).Error; err != nil {
@@ -96,6 +98,12 @@ func CreateShipping(c *fiber.Ctx) error {
 		})
 	}
 
+	if transaction.Status == models.SalesStatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot add shipping to a cancelled transaction",
+		})
+	}
+
 	var req CreateShippingRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -126,7 +134,7 @@ func CreateShipping(c *fiber.Ctx) error {
 	}
 
 	// Start database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -165,7 +173,7 @@ func CreateShipping(c *fiber.Ctx) error {
 	}
 
 	// Fetch the shipping with expedition details
-	config.DB.Preload("Expedition").Where("id = ?", shipping.ID).First(&shipping)
+	config.DB.Preload("Expedition", withDeleted).Preload("CreatedBy").Preload("UpdatedBy").Where("id = ?", shipping.ID).First(&shipping)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":                   "Shipping created successfully",
@@ -220,7 +228,7 @@ func UpdateShipping(c *fiber.Ctx) error {
 	}
 
 	// Start database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -289,7 +297,7 @@ func UpdateShipping(c *fiber.Ctx) error {
 	}
 
 	// Fetch updated shipping with expedition
-	config.DB.Preload("Expedition").Where("id = ?", shipping.ID).First(&shipping)
+	config.DB.Preload("Expedition", withDeleted).Preload("CreatedBy").Preload("UpdatedBy").Where("id = ?", shipping.ID).First(&shipping)
 
 	return c.JSON(fiber.Map{
 		"message":                   "Shipping updated successfully",
@@ -335,7 +343,7 @@ func DeleteShipping(c *fiber.Ctx) error {
 	}
 
 	// Start database transaction
-	tx := config.DB.Begin()
+	tx := auditedDB(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
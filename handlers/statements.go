@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Types of statement entries
+const (
+	StatementEntryInvoice = "invoice"
+	StatementEntryPayment = "payment"
+)
+
+// StatementEntry is an invoice or a payment on a sales associate statement. Invoices are
+// debits, payments and their discounts are credits.
+type StatementEntry struct {
+	Date               time.Time `json:"date"`
+	Type               string    `json:"type"`      // invoice or payment
+	Reference          string    `json:"reference"` // Invoice or payment number
+	SalesTransactionID uuid.UUID `json:"sales_transaction_id"`
+	NoInvoice          string    `json:"no_invoice"`
+	Debit              float64   `json:"debit"`
+	Credit             float64   `json:"credit"`
+	Balance            float64   `json:"balance"` // Running balance after the entry
+}
+
+// SalesAssociateStatement is the account of a sales associate over a period: what they owed
+// at the start, what was invoiced and paid during the period, and what they owe at the end
+type SalesAssociateStatement struct {
+	SalesAssociateID uuid.UUID        `json:"sales_associate_id"`
+	StartDate        *string          `json:"start_date"`
+	EndDate          *string          `json:"end_date"`
+	OpeningBalance   float64          `json:"opening_balance"`
+	TotalDebit       float64          `json:"total_debit"`
+	TotalCredit      float64          `json:"total_credit"`
+	ClosingBalance   float64          `json:"closing_balance"`
+	Entries          []StatementEntry `json:"entries"`
+}
+
+type statementPayment struct {
+	SalesTransactionID uuid.UUID
+	NoInvoice          string
+	NoPayment          string
+	PaymentDate        time.Time
+	Amount             float64
+	DiscountAmount     float64
+}
+
+// buildSalesAssociateStatement returns the statement of a sales associate between the dates
+// (YYYY-MM-DD, both optional and inclusive). Cancelled transactions and their payments are left
+// out.
+func buildSalesAssociateStatement(salesAssociateID uuid.UUID, startDate, endDate string) (*SalesAssociateStatement, error) {
+	var start, end *time.Time
+	if startDate != "" {
+		parsed, err := time.Parse(helpers.DateFormat, startDate)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid start_date, expected YYYY-MM-DD")
+		}
+		start = &parsed
+	}
+	if endDate != "" {
+		parsed, err := time.Parse(helpers.DateFormat, endDate)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid end_date, expected YYYY-MM-DD")
+		}
+		// Up to the end of the day
+		parsed = parsed.AddDate(0, 0, 1)
+		end = &parsed
+	}
+
+	invoiceQuery := config.DB.
+		Where("sales_associate_id = ? AND status <> ?", salesAssociateID, models.SalesStatusCancelled)
+	paymentQuery := config.DB.Model(&models.Payment{}).
+		Select("payments.sales_transaction_id, sales_transactions.no_invoice, payments.no_payment, payments.payment_date, payments.amount, payments.discount_amount").
+		Joins("JOIN sales_transactions ON sales_transactions.id = payments.sales_transaction_id").
+		Where("sales_transactions.sales_associate_id = ? AND sales_transactions.status <> ?", salesAssociateID, models.SalesStatusCancelled)
+	if end != nil {
+		invoiceQuery = invoiceQuery.Where("transaction_date < ?", *end)
+		paymentQuery = paymentQuery.Where("payments.payment_date < ?", *end)
+	}
+
+	var invoices []models.SalesTransaction
+	if err := invoiceQuery.Find(&invoices).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch invoices")
+	}
+	var payments []statementPayment
+	if err := paymentQuery.Scan(&payments).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch payments")
+	}
+
+	entries := make([]StatementEntry, 0, len(invoices)+len(payments))
+	for _, invoice := range invoices {
+		entries = append(entries, StatementEntry{
+			Date:               invoice.TransactionDate,
+			Type:               StatementEntryInvoice,
+			Reference:          invoice.NoInvoice,
+			SalesTransactionID: invoice.ID,
+			NoInvoice:          invoice.NoInvoice,
+			Debit:              invoice.TotalAmount,
+		})
+	}
+	for _, payment := range payments {
+		entries = append(entries, StatementEntry{
+			Date:               payment.PaymentDate,
+			Type:               StatementEntryPayment,
+			Reference:          payment.NoPayment,
+			SalesTransactionID: payment.SalesTransactionID,
+			NoInvoice:          payment.NoInvoice,
+			Credit:             payment.Amount + payment.DiscountAmount,
+		})
+	}
+	// Oldest first, an invoice before the payments made on the same day
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Date.Before(entries[j].Date)
+		}
+		return entries[i].Type == StatementEntryInvoice && entries[j].Type != StatementEntryInvoice
+	})
+
+	statement := &SalesAssociateStatement{
+		SalesAssociateID: salesAssociateID,
+		Entries:          []StatementEntry{},
+	}
+	if startDate != "" {
+		statement.StartDate = &startDate
+	}
+	if endDate != "" {
+		statement.EndDate = &endDate
+	}
+
+	balance := 0.0
+	for _, entry := range entries {
+		balance = roundCents(balance + entry.Debit - entry.Credit)
+		if start != nil && entry.Date.Before(*start) {
+			statement.OpeningBalance = balance
+			continue
+		}
+		entry.Balance = balance
+		statement.TotalDebit = roundCents(statement.TotalDebit + entry.Debit)
+		statement.TotalCredit = roundCents(statement.TotalCredit + entry.Credit)
+		statement.Entries = append(statement.Entries, entry)
+	}
+	statement.ClosingBalance = balance
+
+	return statement, nil
+}
+
+// GetSalesAssociateStatement godoc
+// @Summary Get the statement of a sales associate
+// @Description Opening balance, invoices (debits) and payments with their discounts (credits) with a running balance, and closing balance of a sales associate over a period. Cancelled transactions are left out. Requires reports:read.
+// @Tags Sales Associates
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Sales associate ID (UUID)"
+// @Param start_date query string false "Start of the period (YYYY-MM-DD); earlier entries make up the opening balance"
+// @Param end_date query string false "End of the period (YYYY-MM-DD)"
+// @Success 200 {object} SalesAssociateStatement "Statement"
+// @Failure 400 {object} map[string]interface{} "Invalid date"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Sales associate not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/sales-associates/{id}/statement [get]
+func GetSalesAssociateStatement(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sales associate ID",
+		})
+	}
+
+	var salesAssociate models.SalesAssociate
+	if err := config.DB.Where("id = ?", id).First(&salesAssociate).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sales associate not found",
+		})
+	}
+
+	statement, err := buildSalesAssociateStatement(id, c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(statement)
+}
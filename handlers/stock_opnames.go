@@ -0,0 +1,724 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateStockOpnameRequest represents the request body for opening a stock opname session
+type CreateStockOpnameRequest struct {
+	WarehouseID    *string     `json:"warehouse_id"` // Defaults to the default warehouse
+	MerkBukuID     *string     `json:"merk_buku_id"`
+	JenjangStudiID *string     `json:"jenjang_studi_id"`
+	OpnameDate     models.Date `json:"opname_date"`
+	Note           *string     `json:"note"`
+}
+
+// UpdateStockOpnameCountsRequest represents the request body for entering counted quantities
+type UpdateStockOpnameCountsRequest struct {
+	Items []StockOpnameCountReq `json:"items"`
+}
+
+// StockOpnameCountReq represents the count of a single book in a stock opname session
+type StockOpnameCountReq struct {
+	BookID          string  `json:"book_id"`
+	CountedQuantity *int    `json:"counted_quantity"`
+	Approved        *bool   `json:"approved"`
+	Reason          *string `json:"reason"`
+}
+
+// PostStockOpnameRequest represents the request body for posting a stock opname session
+type PostStockOpnameRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StockOpnameVarianceLine represents a single book line in the variance report
+type StockOpnameVarianceLine struct {
+	BookID          uuid.UUID `json:"book_id"`
+	BookName        string    `json:"book_name"`
+	SystemQuantity  int       `json:"system_quantity"`
+	CountedQuantity *int      `json:"counted_quantity"`
+	Variance        int       `json:"variance"`
+	Approved        bool      `json:"approved"`
+	Reason          *string   `json:"reason"`
+}
+
+// StockOpnameVarianceSummary represents the summary for the variance report
+type StockOpnameVarianceSummary struct {
+	TotalItems     int `json:"total_items"`
+	CountedItems   int `json:"counted_items"`
+	UncountedItems int `json:"uncounted_items"`
+	VarianceItems  int `json:"variance_items"`
+	ApprovedItems  int `json:"approved_items"`
+	TotalSurplus   int `json:"total_surplus"`
+	TotalShortage  int `json:"total_shortage"`
+}
+
+// generateOpnameNumber generates sequential stock opname number: OPN + YYYYMMDD + 8-digit sequence
+// Example: OPN2023120500000001
+func generateOpnameNumber(db *gorm.DB) (string, error) {
+	prefix := "OPN"
+	dateStr := time.Now().Format("20060102") // YYYYMMDD
+	pattern := prefix + dateStr + "%"
+
+	var maxNumber string
+	err := db.Model(&models.StockOpname{}).
+		Where("no_opname LIKE ?", pattern).
+		Select("COALESCE(MAX(no_opname), '')").
+		Scan(&maxNumber).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if maxNumber != "" {
+		// Extract sequence part (last 8 digits)
+		seqStr := maxNumber[len(prefix)+8:] // Skip prefix (3) + date (8)
+		if seq, err := strconv.Atoi(seqStr); err == nil {
+			nextSeq = seq + 1
+		}
+	}
+
+	return fmt.Sprintf("%s%s%08d", prefix, dateStr, nextSeq), nil
+}
+
+// GetAllStockOpnames godoc
+// @Summary Get all stock opname sessions
+// @Description Retrieve all stock opname (physical count) sessions
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param warehouse_id query string false "Filter by warehouse ID"
+// @Param status query int false "Filter by status (0=open, 1=posted, 2=cancelled)"
+// @Param start_date query string false "Filter by opname date from (YYYY-MM-DD)"
+// @Param end_date query string false "Filter by opname date to (YYYY-MM-DD)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "List of stock opname sessions with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-opnames [get]
+func GetAllStockOpnames(c *fiber.Ctx) error {
+	var opnames []models.StockOpname
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("created_at DESC")
+	queryCount := config.DB.Model(&models.StockOpname{})
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		query = query.Where("warehouse_id = ?", warehouseID)
+		queryCount = queryCount.Where("warehouse_id = ?", warehouseID)
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+		queryCount = queryCount.Where("status = ?", status)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("opname_date >= ?", startDate)
+		queryCount = queryCount.Where("opname_date >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("opname_date <= ?", endDate)
+		queryCount = queryCount.Where("opname_date <= ?", endDate)
+	}
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("Warehouse", withDeleted).Preload("MerkBuku", withDeleted).Preload("JenjangStudi", withDeleted), models.StockOpname{}, "stock_opnames")
+	}
+
+	if err := query.
+		Preload("Warehouse", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&opnames).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch stock opnames",
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, opnames, "stock_opnames", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetStockOpname godoc
+// @Summary Get a stock opname session by ID
+// @Description Retrieve a single stock opname session with all counted lines
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock opname ID (UUID)"
+// @Success 200 {object} models.StockOpname "Stock opname details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock opname not found"
+// @Router /api/stock-opnames/{id} [get]
+func GetStockOpname(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var opname models.StockOpname
+	if err := config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&opname).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock opname not found",
+		})
+	}
+
+	return c.JSON(opname)
+}
+
+// CreateStockOpname godoc
+// @Summary Open a stock opname session
+// @Description Open a physical count session for a warehouse. The current stock of every matching book is recorded as system quantity.
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateStockOpnameRequest true "Stock opname details"
+// @Success 201 {object} models.StockOpname "Opened stock opname"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-opnames [post]
+func CreateStockOpname(c *fiber.Ctx) error {
+	var req CreateStockOpnameRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	warehouse, err := resolveWarehouse(config.DB, req.WarehouseID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Warehouse not found",
+		})
+	}
+
+	// Only one open session per warehouse, otherwise counts would be posted twice
+	var openCount int64
+	if err := config.DB.Model(&models.StockOpname{}).
+		Where("warehouse_id = ? AND status = ?", warehouse.ID, models.OpnameStatusOpen).
+		Count(&openCount).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check open stock opnames",
+		})
+	}
+	if openCount > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Warehouse already has an open stock opname",
+		})
+	}
+
+	// Snapshot the stock of matching books in the warehouse
+	type bookSnapshot struct {
+		ID       uuid.UUID
+		Quantity int
+	}
+	var snapshots []bookSnapshot
+
+	bookQuery := config.DB.Model(&models.Book{}).
+		Select("books.id, COALESCE(book_stocks.quantity, 0) AS quantity").
+		Joins("LEFT JOIN book_stocks ON book_stocks.book_id = books.id AND book_stocks.warehouse_id = ?", warehouse.ID).
+		Order("books.name ASC")
+
+	if req.MerkBukuID != nil && *req.MerkBukuID != "" {
+		bookQuery = bookQuery.Where("books.merk_buku_id = ?", *req.MerkBukuID)
+	}
+	if req.JenjangStudiID != nil && *req.JenjangStudiID != "" {
+		bookQuery = bookQuery.Where("books.jenjang_studi_id = ?", *req.JenjangStudiID)
+	}
+
+	if err := bookQuery.Scan(&snapshots).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch books",
+		})
+	}
+
+	if len(snapshots) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "No books match the selected filters",
+		})
+	}
+
+	opnameDate := req.OpnameDate
+	if opnameDate.IsZero() {
+		opnameDate = models.Date{Time: time.Now()}
+	}
+
+	// Start a database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	noOpname, err := generateOpnameNumber(tx)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate stock opname number",
+		})
+	}
+
+	opname := models.StockOpname{
+		NoOpname:       noOpname,
+		WarehouseID:    warehouse.ID,
+		MerkBukuID:     helpers.ParseUUIDPtr(req.MerkBukuID),
+		JenjangStudiID: helpers.ParseUUIDPtr(req.JenjangStudiID),
+		OpnameDate:     opnameDate,
+		Status:         models.OpnameStatusOpen,
+		Note:           req.Note,
+	}
+
+	if err := tx.Create(&opname).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create stock opname",
+		})
+	}
+
+	items := make([]models.StockOpnameItem, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		items = append(items, models.StockOpnameItem{
+			StockOpnameID:  opname.ID,
+			BookID:         snapshot.ID,
+			SystemQuantity: snapshot.Quantity,
+		})
+	}
+	if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create stock opname items",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the created session with all relations
+	var createdOpname models.StockOpname
+	config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("MerkBuku", withDeleted).
+		Preload("JenjangStudi", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", opname.ID).First(&createdOpname)
+
+	return c.Status(fiber.StatusCreated).JSON(createdOpname)
+}
+
+// UpdateStockOpnameCounts godoc
+// @Summary Enter counted quantities
+// @Description Enter counted quantities for books in an open stock opname session and approve lines for posting
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock opname ID (UUID)"
+// @Param request body UpdateStockOpnameCountsRequest true "Counted quantities"
+// @Success 200 {object} models.StockOpname "Updated stock opname"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock opname not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-opnames/{id}/counts [put]
+func UpdateStockOpnameCounts(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var opname models.StockOpname
+	if err := config.DB.Preload("Items").Where("id = ?", id).First(&opname).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock opname not found",
+		})
+	}
+
+	if opname.Status != models.OpnameStatusOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only open stock opnames can be counted",
+		})
+	}
+
+	var req UpdateStockOpnameCountsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	itemsByBook := make(map[string]*models.StockOpnameItem, len(opname.Items))
+	for i := range opname.Items {
+		itemsByBook[opname.Items[i].BookID.String()] = &opname.Items[i]
+	}
+
+	// Validate all lines before changing anything
+	for _, count := range req.Items {
+		item, ok := itemsByBook[count.BookID]
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Book with ID %s is not part of this stock opname", count.BookID),
+			})
+		}
+
+		if count.CountedQuantity != nil {
+			if *count.CountedQuantity < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Counted quantity cannot be negative",
+				})
+			}
+			item.CountedQuantity = count.CountedQuantity
+		}
+		if count.Approved != nil {
+			item.Approved = *count.Approved
+		}
+		if count.Reason != nil {
+			item.Reason = count.Reason
+		}
+
+		if item.Approved && item.CountedQuantity == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Book with ID %s must be counted before it can be approved", count.BookID),
+			})
+		}
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		for _, count := range req.Items {
+			item := itemsByBook[count.BookID]
+			if err := tx.Model(item).Updates(map[string]interface{}{
+				"counted_quantity": item.CountedQuantity,
+				"approved":         item.Approved,
+				"reason":           item.Reason,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update counted quantities",
+		})
+	}
+
+	// Fetch the updated session with all relations
+	config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&opname)
+
+	return c.JSON(opname)
+}
+
+// GetStockOpnameVariance godoc
+// @Summary Get stock opname variance report
+// @Description Compare counted quantities against system stock for a stock opname session
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock opname ID (UUID)"
+// @Param only_variance query bool false "Only return counted lines with a variance"
+// @Success 200 {object} map[string]interface{} "Variance lines with summary"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock opname not found"
+// @Router /api/stock-opnames/{id}/variance [get]
+func GetStockOpnameVariance(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var opname models.StockOpname
+	if err := config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&opname).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock opname not found",
+		})
+	}
+
+	onlyVariance := c.Query("only_variance") == "true"
+
+	var summary StockOpnameVarianceSummary
+	lines := make([]StockOpnameVarianceLine, 0, len(opname.Items))
+
+	for _, item := range opname.Items {
+		variance := item.Variance()
+
+		summary.TotalItems++
+		if item.CountedQuantity == nil {
+			summary.UncountedItems++
+		} else {
+			summary.CountedItems++
+		}
+		if variance != 0 {
+			summary.VarianceItems++
+			if variance > 0 {
+				summary.TotalSurplus += variance
+			} else {
+				summary.TotalShortage += -variance
+			}
+		}
+		if item.Approved {
+			summary.ApprovedItems++
+		}
+
+		if onlyVariance && variance == 0 {
+			continue
+		}
+
+		line := StockOpnameVarianceLine{
+			BookID:          item.BookID,
+			SystemQuantity:  item.SystemQuantity,
+			CountedQuantity: item.CountedQuantity,
+			Variance:        variance,
+			Approved:        item.Approved,
+			Reason:          item.Reason,
+		}
+		if item.Book != nil {
+			line.BookName = item.Book.Name
+		}
+		lines = append(lines, line)
+	}
+
+	return c.JSON(fiber.Map{
+		"stock_opname": fiber.Map{
+			"id":          opname.ID,
+			"no_opname":   opname.NoOpname,
+			"warehouse":   opname.Warehouse,
+			"opname_date": opname.OpnameDate,
+			"status":      opname.Status,
+		},
+		"data":    lines,
+		"summary": summary,
+	})
+}
+
+// PostStockOpname godoc
+// @Summary Post a stock opname session
+// @Description Post approved variances of an open stock opname as stock adjustments with a reason. Unapproved lines are left unchanged.
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock opname ID (UUID)"
+// @Param request body PostStockOpnameRequest true "Adjustment reason"
+// @Success 200 {object} map[string]interface{} "Posted stock opname"
+// @Failure 400 {object} map[string]interface{} "Stock opname cannot be posted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock opname not found"
+// @Failure 409 {object} map[string]interface{} "Stock opname posted or cancelled concurrently"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-opnames/{id}/post [post]
+func PostStockOpname(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req PostStockOpnameRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason is required",
+		})
+	}
+
+	var opname models.StockOpname
+	if err := config.DB.Preload("Items").Where("id = ?", id).First(&opname).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock opname not found",
+		})
+	}
+
+	if opname.Status != models.OpnameStatusOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only open stock opnames can be posted",
+		})
+	}
+
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	adjustedItems := 0
+	for _, item := range opname.Items {
+		variance := item.Variance()
+		if !item.Approved || variance == 0 {
+			continue
+		}
+
+		if err := adjustWarehouseStock(tx, item.BookID, opname.WarehouseID, variance); err != nil {
+			tx.Rollback()
+			if errors.Is(err, errInsufficientStock) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Stock of book ID %s changed since the count and cannot be reduced by %d", item.BookID.String(), -variance),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update book stock",
+			})
+		}
+
+		reason := req.Reason
+		if item.Reason != nil && strings.TrimSpace(*item.Reason) != "" {
+			reason = *item.Reason
+		}
+
+		adjustment := models.StockAdjustment{
+			BookID:        item.BookID,
+			WarehouseID:   opname.WarehouseID,
+			StockOpnameID: &opname.ID,
+			Quantity:      variance,
+			Reason:        reason,
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record stock adjustment",
+			})
+		}
+		adjustedItems++
+	}
+
+	// Only a session still open is posted, so two concurrent posts cannot both apply the variances
+	now := time.Now()
+	result := tx.Model(&models.StockOpname{}).
+		Where("id = ? AND status = ?", opname.ID, models.OpnameStatusOpen).
+		Updates(map[string]interface{}{
+			"status":    models.OpnameStatusPosted,
+			"reason":    req.Reason,
+			"posted_at": now,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update stock opname status",
+		})
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Stock opname has already been posted or cancelled",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the posted session with all relations
+	config.DB.
+		Preload("Warehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&opname)
+
+	return c.JSON(fiber.Map{
+		"message":        fmt.Sprintf("Stock opname posted successfully. %d stock adjustments recorded.", adjustedItems),
+		"adjusted_items": adjustedItems,
+		"stock_opname":   opname,
+	})
+}
+
+// CancelStockOpname godoc
+// @Summary Cancel a stock opname session
+// @Description Cancel an open stock opname session. Stock is not affected.
+// @Tags Stock Opnames
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock opname ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Stock opname cancelled successfully"
+// @Failure 400 {object} map[string]interface{} "Stock opname cannot be cancelled"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock opname not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-opnames/{id}/cancel [post]
+func CancelStockOpname(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var opname models.StockOpname
+	if err := config.DB.Where("id = ?", id).First(&opname).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock opname not found",
+		})
+	}
+
+	if opname.Status != models.OpnameStatusOpen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only open stock opnames can be cancelled",
+		})
+	}
+
+	if err := auditedDB(c).Model(&opname).Update("status", models.OpnameStatusCancelled).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel stock opname",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock opname cancelled successfully",
+	})
+}
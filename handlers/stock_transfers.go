@@ -0,0 +1,514 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateStockTransferRequest represents the request body for creating a stock transfer
+type CreateStockTransferRequest struct {
+	FromWarehouseID string                       `json:"from_warehouse_id"`
+	ToWarehouseID   string                       `json:"to_warehouse_id"`
+	TransferDate    models.Date                  `json:"transfer_date"`
+	Note            *string                      `json:"note"`
+	Items           []CreateStockTransferItemReq `json:"items"`
+}
+
+// CreateStockTransferItemReq represents an item in the stock transfer
+type CreateStockTransferItemReq struct {
+	BookID   string `json:"book_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// generateTransferNumber generates sequential transfer number: TRF + YYYYMMDD + 8-digit sequence
+// Example: TRF2023120500000001
+func generateTransferNumber(db *gorm.DB) (string, error) {
+	prefix := "TRF"
+	dateStr := time.Now().Format("20060102") // YYYYMMDD
+	pattern := prefix + dateStr + "%"
+
+	var maxNumber string
+	err := db.Model(&models.StockTransfer{}).
+		Where("no_transfer LIKE ?", pattern).
+		Select("COALESCE(MAX(no_transfer), '')").
+		Scan(&maxNumber).Error
+
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if maxNumber != "" {
+		// Extract sequence part (last 8 digits)
+		seqStr := maxNumber[len(prefix)+8:] // Skip prefix (3) + date (8)
+		if seq, err := strconv.Atoi(seqStr); err == nil {
+			nextSeq = seq + 1
+		}
+	}
+
+	return fmt.Sprintf("%s%s%08d", prefix, dateStr, nextSeq), nil
+}
+
+// GetAllStockTransfers godoc
+// @Summary Get all stock transfers
+// @Description Retrieve all stock transfers between warehouses
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param no_transfer query string false "Partial match on transfer number"
+// @Param warehouse_id query string false "Filter by source or destination warehouse ID"
+// @Param status query int false "Filter by status (0=pending, 1=completed, 2=cancelled)"
+// @Param start_date query string false "Filter by transfer date from (YYYY-MM-DD)"
+// @Param end_date query string false "Filter by transfer date to (YYYY-MM-DD)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "List of stock transfers with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-transfers [get]
+func GetAllStockTransfers(c *fiber.Ctx) error {
+	var transfers []models.StockTransfer
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("created_at DESC")
+	queryCount := config.DB.Model(&models.StockTransfer{})
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	if noTransfer := c.Query("no_transfer"); noTransfer != "" {
+		query = query.Where("no_transfer ILIKE ?", "%"+noTransfer+"%")
+		queryCount = queryCount.Where("no_transfer ILIKE ?", "%"+noTransfer+"%")
+	}
+
+	if warehouseID := c.Query("warehouse_id"); warehouseID != "" {
+		cond := "from_warehouse_id = ? OR to_warehouse_id = ?"
+		query = query.Where(cond, warehouseID, warehouseID)
+		queryCount = queryCount.Where(cond, warehouseID, warehouseID)
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+		queryCount = queryCount.Where("status = ?", status)
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("transfer_date >= ?", startDate)
+		queryCount = queryCount.Where("transfer_date >= ?", startDate)
+	}
+
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("transfer_date <= ?", endDate)
+		queryCount = queryCount.Where("transfer_date <= ?", endDate)
+	}
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("FromWarehouse", withDeleted).Preload("ToWarehouse", withDeleted), models.StockTransfer{}, "stock_transfers")
+	}
+
+	if err := query.
+		Preload("FromWarehouse", withDeleted).
+		Preload("ToWarehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Offset(pagination.Offset).Limit(pagination.Limit).
+		Find(&transfers).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch stock transfers",
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, transfers, "stock_transfers", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetStockTransfer godoc
+// @Summary Get a stock transfer by ID
+// @Description Retrieve a single stock transfer by its ID with warehouses and items
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock transfer ID (UUID)"
+// @Success 200 {object} models.StockTransfer "Stock transfer details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock transfer not found"
+// @Router /api/stock-transfers/{id} [get]
+func GetStockTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transfer models.StockTransfer
+	if err := config.DB.
+		Preload("FromWarehouse", withDeleted).
+		Preload("ToWarehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock transfer not found",
+		})
+	}
+
+	return c.JSON(transfer)
+}
+
+// CreateStockTransfer godoc
+// @Summary Create a new stock transfer
+// @Description Create a pending stock transfer between two warehouses. Stock is NOT moved until the transfer is completed.
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateStockTransferRequest true "Transfer details"
+// @Success 201 {object} models.StockTransfer "Created stock transfer"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-transfers [post]
+func CreateStockTransfer(c *fiber.Ctx) error {
+	var req CreateStockTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Validate required fields
+	if req.FromWarehouseID == "" || req.ToWarehouseID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from_warehouse_id and to_warehouse_id are required",
+		})
+	}
+
+	if req.FromWarehouseID == req.ToWarehouseID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Source and destination warehouses must be different",
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one item is required",
+		})
+	}
+
+	// Verify both warehouses exist
+	var fromWarehouse, toWarehouse models.Warehouse
+	if err := config.DB.Where("id = ?", req.FromWarehouseID).First(&fromWarehouse).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Source warehouse not found",
+		})
+	}
+	if err := config.DB.Where("id = ?", req.ToWarehouseID).First(&toWarehouse).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Destination warehouse not found",
+		})
+	}
+
+	// Start a database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var transferItems []models.StockTransferItem
+	for _, item := range req.Items {
+		// Validate quantity
+		if item.Quantity <= 0 {
+			tx.Rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Quantity must be greater than 0",
+			})
+		}
+
+		// Fetch book to verify it exists
+		var book models.Book
+		if err := tx.Where("id = ?", item.BookID).First(&book).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Book with ID %s not found", item.BookID),
+			})
+		}
+
+		transferItems = append(transferItems, models.StockTransferItem{
+			BookID:   book.ID,
+			Quantity: item.Quantity,
+		})
+	}
+
+	// Generate transfer number
+	noTransfer, err := generateTransferNumber(tx)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate transfer number",
+		})
+	}
+
+	transfer := models.StockTransfer{
+		NoTransfer:      noTransfer,
+		FromWarehouseID: fromWarehouse.ID,
+		ToWarehouseID:   toWarehouse.ID,
+		TransferDate:    req.TransferDate,
+		Status:          models.TransferStatusPending,
+		Note:            req.Note,
+	}
+
+	if err := tx.Create(&transfer).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create stock transfer",
+		})
+	}
+
+	for i := range transferItems {
+		transferItems[i].StockTransferID = transfer.ID
+	}
+	if err := tx.Create(&transferItems).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create stock transfer items",
+		})
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the created transfer with all relations
+	var createdTransfer models.StockTransfer
+	config.DB.
+		Preload("FromWarehouse", withDeleted).
+		Preload("ToWarehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", transfer.ID).First(&createdTransfer)
+
+	return c.Status(fiber.StatusCreated).JSON(createdTransfer)
+}
+
+// CompleteStockTransfer godoc
+// @Summary Complete a stock transfer
+// @Description Mark a pending stock transfer as completed. This moves stock from the source to the destination warehouse.
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock transfer ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Completed stock transfer"
+// @Failure 400 {object} map[string]interface{} "Transfer cannot be completed"
+// @Failure 409 {object} map[string]interface{} "Transfer was completed or cancelled concurrently"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock transfer not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-transfers/{id}/complete [post]
+func CompleteStockTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transfer models.StockTransfer
+	if err := config.DB.Preload("Items").Where("id = ?", id).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock transfer not found",
+		})
+	}
+
+	if transfer.Status != models.TransferStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending transfers can be completed",
+		})
+	}
+
+	// Start database transaction
+	tx := auditedDB(c).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Claim the transfer first: a concurrent complete or cancel waits for this row and then
+	// finds it no longer pending, so stock is moved once
+	result := tx.Model(&models.StockTransfer{}).
+		Where("id = ? AND status = ?", transfer.ID, models.TransferStatusPending).
+		Update("status", models.TransferStatusCompleted)
+	if result.Error != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update transfer status",
+		})
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Stock transfer has already been completed or cancelled",
+		})
+	}
+
+	// Move stock for all items
+	for _, item := range transfer.Items {
+		if err := adjustWarehouseStock(tx, item.BookID, transfer.FromWarehouseID, -item.Quantity); err != nil {
+			tx.Rollback()
+			if errors.Is(err, errInsufficientStock) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Insufficient stock in source warehouse for book ID %s", item.BookID.String()),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update book stock",
+			})
+		}
+
+		if err := adjustWarehouseStock(tx, item.BookID, transfer.ToWarehouseID, item.Quantity); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update book stock",
+			})
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction",
+		})
+	}
+
+	// Fetch the updated transfer with all relations
+	config.DB.
+		Preload("FromWarehouse", withDeleted).
+		Preload("ToWarehouse", withDeleted).
+		Preload("Items").
+		Preload("Items.Book", withDeleted).
+		Where("id = ?", id).First(&transfer)
+
+	return c.JSON(fiber.Map{
+		"message":        "Stock transfer completed successfully. Stock has been moved.",
+		"stock_transfer": transfer,
+	})
+}
+
+// CancelStockTransfer godoc
+// @Summary Cancel a stock transfer
+// @Description Cancel a pending stock transfer. Stock is not affected since pending transfers don't move stock.
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock transfer ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Stock transfer cancelled successfully"
+// @Failure 400 {object} map[string]interface{} "Transfer cannot be cancelled"
+// @Failure 409 {object} map[string]interface{} "Transfer was completed or cancelled concurrently"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock transfer not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-transfers/{id}/cancel [post]
+func CancelStockTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transfer models.StockTransfer
+	if err := config.DB.Where("id = ?", id).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock transfer not found",
+		})
+	}
+
+	if transfer.Status != models.TransferStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending transfers can be cancelled",
+		})
+	}
+
+	result := auditedDB(c).Model(&models.StockTransfer{}).
+		Where("id = ? AND status = ?", transfer.ID, models.TransferStatusPending).
+		Update("status", models.TransferStatusCancelled)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel stock transfer",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Stock transfer has already been completed or cancelled",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock transfer cancelled successfully",
+	})
+}
+
+// DeleteStockTransfer godoc
+// @Summary Delete a stock transfer
+// @Description Delete a pending or cancelled stock transfer. Completed transfers cannot be deleted.
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Stock transfer ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Stock transfer deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Transfer cannot be deleted"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Stock transfer not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/stock-transfers/{id} [delete]
+func DeleteStockTransfer(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var transfer models.StockTransfer
+	if err := config.DB.Where("id = ?", id).First(&transfer).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Stock transfer not found",
+		})
+	}
+
+	if transfer.Status == models.TransferStatusCompleted {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Completed transfers cannot be deleted",
+		})
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("stock_transfer_id = ?", transfer.ID).Delete(&models.StockTransferItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&transfer).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete stock transfer",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock transfer deleted successfully",
+	})
+}
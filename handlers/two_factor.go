@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"os"
+	"time"
+
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTwoFactorChallengeMinutes = 5 // TWO_FACTOR_CHALLENGE_MINUTES, time to enter the code after the password
+	maxTwoFactorAttempts             = 5 // Wrong codes before a login challenge stops working
+	recoveryCodeCount                = 10
+)
+
+// TwoFactorPasswordRequest is the body of the enroll endpoint
+type TwoFactorPasswordRequest struct {
+	Password string `json:"password" example:"Passw0rd!"`
+}
+
+// TwoFactorCodeRequest holds a code from the authenticator app, or a recovery code where allowed
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"k3j7q-x9m2p"`
+}
+
+// TwoFactorDisableRequest is the body of the disable endpoint
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password" example:"Passw0rd!"`
+	Code         string `json:"code,omitempty" example:"123456"`
+	RecoveryCode string `json:"recovery_code,omitempty" example:"k3j7q-x9m2p"`
+}
+
+// TwoFactorVerifyRequest is the second login step
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" example:"Yv3n1b7QmS2d9kR4tX6wZ0aC8eF5gH2jL7pN3qU1sV9"`
+	Code           string `json:"code,omitempty" example:"123456"`
+	RecoveryCode   string `json:"recovery_code,omitempty" example:"k3j7q-x9m2p"`
+}
+
+// twoFactorIssuer is the account name shown by authenticator apps, TOTP_ISSUER
+func twoFactorIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Pustaka Digital"
+}
+
+// issueLoginChallenge stores a new login challenge for the user and returns the token itself,
+// which is only ever shown to the client
+func issueLoginChallenge(c *fiber.Ctx, user models.User) (string, models.LoginChallenge, error) {
+	token, hash, err := helpers.GenerateToken()
+	if err != nil {
+		return "", models.LoginChallenge{}, err
+	}
+
+	ttl := time.Duration(envInt("TWO_FACTOR_CHALLENGE_MINUTES", defaultTwoFactorChallengeMinutes)) * time.Minute
+	challenge := models.LoginChallenge{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if ip := c.IP(); ip != "" {
+		challenge.IPAddress = &ip
+	}
+
+	if err := auditedDB(c).Create(&challenge).Error; err != nil {
+		return "", models.LoginChallenge{}, err
+	}
+	return token, challenge, nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of the user and stores new ones, returning
+// the codes themselves
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	codes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: helpers.HashToken(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code or, when no code is given, a recovery code of a user
+// with 2FA. Both work only once: the time step of the code is remembered and the recovery code
+// is marked as used.
+func verifySecondFactor(tx *gorm.DB, user models.User, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		if user.TwoFactorSecret == nil {
+			return false, nil
+		}
+		step, ok := helpers.ValidateTOTP(*user.TwoFactorSecret, code, time.Now())
+		if !ok || step <= user.TwoFactorLastStep {
+			return false, nil
+		}
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+			UpdateColumn("two_factor_last_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, helpers.HashToken(helpers.NormalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		return result.RowsAffected == 1, result.Error
+	}
+
+	return false, nil
+}
+
+// currentUser loads the user of the access token
+func currentUser(c *fiber.Ctx) (models.User, error) {
+	var user models.User
+	err := config.DB.Where("id = ?", c.Locals("userID").(string)).First(&user).Error
+	return user, err
+}
+
+// EnrollTwoFactor godoc
+// @Summary Start two-factor enrollment
+// @Description Create a new TOTP secret for the current user. Show provisioning_uri as a QR code (or the secret for manual entry) in an authenticator app, then send a code to POST /api/auth/2fa/confirm. 2FA is not active until confirmed.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorPasswordRequest true "Current password"
+// @Success 200 {object} map[string]interface{} "secret and provisioning_uri"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized or wrong password"
+// @Failure 409 {object} map[string]interface{} "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/2fa/enroll [post]
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid password",
+		})
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate secret",
+		})
+	}
+	if err := auditedDB(c).Model(&models.User{}).Where("id = ?", user.ID).
+		Update("two_factor_secret", secret).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start enrollment",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":           secret,
+		"provisioning_uri": helpers.TOTPProvisioningURI(twoFactorIssuer(), user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enable 2FA with a code from the authenticator app enrolled at POST /api/auth/2fa/enroll. Returns the recovery codes, which are shown only this once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} map[string]interface{} "recovery_codes"
+// @Failure 400 {object} map[string]interface{} "Invalid code or no enrollment started"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 409 {object} map[string]interface{} "Two-factor authentication is already enabled"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/2fa/confirm [post]
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if user.TwoFactorEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Two-factor authentication is already enabled",
+		})
+	}
+	if user.TwoFactorSecret == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start the enrollment first",
+		})
+	}
+
+	step, ok := helpers.ValidateTOTP(*user.TwoFactorSecret, req.Code, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid two-factor code",
+		})
+	}
+
+	var codes []string
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled":   true,
+			"two_factor_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to enable two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Turn 2FA off for the current user with the password and a TOTP or recovery code. Not allowed for admins, who must use 2FA.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorDisableRequest true "Password and code"
+// @Success 200 {object} map[string]interface{} "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]interface{} "Invalid request body or invalid code"
+// @Failure 401 {object} map[string]interface{} "Unauthorized or wrong password"
+// @Failure 403 {object} map[string]interface{} "Admins must use two-factor authentication"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/2fa/disable [post]
+func DisableTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorDisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if user.Role == "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Admins must use two-factor authentication",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid password",
+		})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		ok, err := verifySecondFactor(tx, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid two-factor code")
+		}
+		return clearTwoFactor(tx, user.ID)
+	})
+	if err != nil {
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to disable two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// clearTwoFactor turns 2FA off and deletes the secret and recovery codes
+func clearTwoFactor(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_secret":    nil,
+		"two_factor_enabled":   false,
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Replace recovery codes
+// @Description Create new recovery codes for the current user with a TOTP code. The old codes stop working. The new codes are shown only this once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} map[string]interface{} "recovery_codes"
+// @Failure 400 {object} map[string]interface{} "Invalid code or 2FA not enabled"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two-factor authentication is not enabled",
+		})
+	}
+
+	var codes []string
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		// Only a TOTP code is accepted, a leaked recovery code must not renew the others
+		ok, err := verifySecondFactor(tx, user, req.Code, "")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid two-factor code")
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			return c.Status(fiberErr.Code).JSON(fiber.Map{
+				"error": fiberErr.Message,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create recovery codes",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// VerifyTwoFactor godoc
+// @Summary Complete a login with two-factor authentication
+// @Description Second login step for users with 2FA. Exchange the challenge_token from POST /api/auth/login and a TOTP code (or a recovery code) for the session tokens. The challenge expires after TWO_FACTOR_CHALLENGE_MINUTES (default 5) or 5 wrong codes, and wrong codes count towards the login lockout.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorVerifyRequest true "Login challenge and code"
+// @Success 200 {object} map[string]interface{} "Login successful with tokens and user data"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Invalid or expired login challenge, or invalid code"
+// @Failure 429 {object} map[string]interface{} "Too many failed attempts, retry_after holds the seconds to wait"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/auth/2fa/verify [post]
+func VerifyTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "challenge_token and code or recovery_code are required",
+		})
+	}
+
+	var challenge models.LoginChallenge
+	if err := config.DB.Where("token_hash = ?", helpers.HashToken(req.ChallengeToken)).First(&challenge).Error; err != nil ||
+		challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) || challenge.FailedAttempts >= maxTwoFactorAttempts {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired login challenge",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", challenge.UserID).First(&user).Error; err != nil || !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired login challenge",
+		})
+	}
+
+	email := normalizeLoginEmail(user.Email)
+	retryAfter, err := loginRetryAfter(email, c.IP(), time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check login attempts",
+		})
+	}
+	if retryAfter > 0 {
+		return respondLoginLocked(c, email, retryAfter)
+	}
+
+	ok, err := verifySecondFactor(auditedDB(c), user, req.Code, req.RecoveryCode)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify code",
+		})
+	}
+	if !ok {
+		auditedDB(c).Model(&models.LoginChallenge{}).Where("id = ?", challenge.ID).
+			UpdateColumn("failed_attempts", gorm.Expr("failed_attempts + 1"))
+		recordLoginAttempt(c, email, &user.ID, models.LoginReasonInvalidTwoFactor)
+		if err := registerLoginFailure(email, c.IP(), time.Now()); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to record login attempt",
+			})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid two-factor code",
+		})
+	}
+
+	// A challenge completes one login only
+	result := auditedDB(c).Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to verify code",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired login challenge",
+		})
+	}
+
+	return startSession(c, user)
+}
+
+// ResetUserTwoFactor godoc
+// @Summary Reset a user's two-factor authentication
+// @Description Turn 2FA off for a user who lost their authenticator and recovery codes, and log them out everywhere. Admins have to enroll again on their next login. Requires users:write and every permission of the user's role.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Two-factor authentication reset"
+// @Failure 400 {object} map[string]interface{} "Invalid user ID"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/users/{id}/reset-2fa [post]
+func ResetUserTwoFactor(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	// Validate UUID
+	if _, err := uuid.Parse(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	// Resetting the 2FA of an admin would let a users:write holder take the account over
+	if err := authorizeUserRoles(c, user.Role); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeUserSessions(tx, user.ID)
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset two-factor authentication",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication reset",
+	})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // UploadResourceField godoc
@@ -131,7 +132,7 @@ func UploadResourceField(c *fiber.Ctx) error {
 
 	// Update database based on resource type
 	// This is transactional - if DB update fails, we delete the uploaded file
-	err = updateResourceFileURL(resource, field, id, fileURL)
+	err = updateResourceFileURL(auditedDB(c), resource, field, id, fileURL)
 	if err != nil {
 		// Remove uploaded file if database update fails
 		os.Remove(filePath)
@@ -157,7 +158,7 @@ func UploadResourceField(c *fiber.Ctx) error {
 }
 
 // updateResourceFileURL updates the file URL in the database for the specified resource
-func updateResourceFileURL(resource, field, id, fileURL string) error {
+func updateResourceFileURL(db *gorm.DB, resource, field, id, fileURL string) error {
 	// Map field names to database column names
 	fieldColumnMap := map[string]string{
 		"photo": "photo_url",
@@ -176,42 +177,42 @@ func updateResourceFileURL(resource, field, id, fileURL string) error {
 		if err := config.DB.Where("id = ?", id).First(&user).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&user).Update(columnName, fileURL).Error
+		err = db.Model(&user).Update(columnName, fileURL).Error
 
 	case "books":
 		var book models.Book
 		if err := config.DB.Where("id = ?", id).First(&book).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&book).Update(columnName, fileURL).Error
+		err = db.Model(&book).Update(columnName, fileURL).Error
 
 	case "publishers":
 		var publisher models.Publisher
 		if err := config.DB.Where("id = ?", id).First(&publisher).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&publisher).Update(columnName, fileURL).Error
+		err = db.Model(&publisher).Update(columnName, fileURL).Error
 
 	case "expeditions":
 		var expedition models.Expedition
 		if err := config.DB.Where("id = ?", id).First(&expedition).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&expedition).Update(columnName, fileURL).Error
+		err = db.Model(&expedition).Update(columnName, fileURL).Error
 
 	case "sales-associates":
 		var salesAssociate models.SalesAssociate
 		if err := config.DB.Where("id = ?", id).First(&salesAssociate).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&salesAssociate).Update(columnName, fileURL).Error
+		err = db.Model(&salesAssociate).Update(columnName, fileURL).Error
 
 	case "billers":
 		var biller models.Biller
 		if err := config.DB.Where("id = ?", id).First(&biller).Error; err != nil {
 			return fmt.Errorf("resource not found")
 		}
-		err = config.DB.Model(&biller).Update(columnName, fileURL).Error
+		err = db.Model(&biller).Update(columnName, fileURL).Error
 
 	default:
 		return fmt.Errorf("invalid resource")
@@ -301,7 +302,7 @@ func DeleteResourceField(c *fiber.Ctx) error {
 	}
 
 	// Update database field to NULL
-	err = updateResourceFileURL(resource, field, id, "")
+	err = updateResourceFileURL(auditedDB(c), resource, field, id, "")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update database",
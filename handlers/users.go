@@ -8,6 +8,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // ValidateUserRequest validates the user request fields
@@ -28,15 +29,43 @@ func ValidateUserRequest(req *models.UserRequest) error {
 	if !helpers.IsStrongPassword(req.Password) {
 		return fiber.NewError(fiber.StatusBadRequest, "Password must be at least 8 characters long, must contain at least one number, one uppercase letter, one lowercase letter, and one special character")
 	}
-	if req.Role != "" && req.Role != "user" && req.Role != "admin" && req.Role != "operator" {
-		return fiber.NewError(fiber.StatusBadRequest, "Role must be either 'user' or 'admin' or 'operator'")
+	return nil
+}
+
+// validateUserRole checks that the role exists in the roles table
+func validateUserRole(role string) error {
+	var count int64
+	if err := config.DB.Model(&models.Role{}).Where("name = ?", role).Count(&count).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check role")
+	}
+	if count == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Role not found: "+role)
 	}
 	return nil
 }
 
+// validateUserSalesAssociate checks that the sales associate to link exists
+func validateUserSalesAssociate(id string) (*uuid.UUID, error) {
+	salesAssociateID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid sales_associate_id")
+	}
+	var count int64
+	if err := config.DB.Model(&models.SalesAssociate{}).Where("id = ?", salesAssociateID).Count(&count).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to check sales associate")
+	}
+	if count == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Sales associate not found")
+	}
+	return &salesAssociateID, nil
+}
+
+// errSalesAssociateRequired is returned for sales_associate users without a linked associate
+var errSalesAssociateRequired = fiber.NewError(fiber.StatusBadRequest, "sales_associate_id is required for the sales_associate role")
+
 // GetAllUsers godoc
-// @Summary Get all users (Admin only)
-// @Description Retrieve all users with pagination and optional search filter. Only accessible by admin users.
+// @Summary Get all users
+// @Description Retrieve all users with pagination and optional search filter. Requires users:read.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -45,8 +74,9 @@ func ValidateUserRequest(req *models.UserRequest) error {
 // @Param limit query int false "Items per page (default: 20)"
 // @Param all query bool false "Get all records without pagination"
 // @Param search query string false "Search by email, full name"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
 // @Success 200 {object} map[string]interface{} "List of users with pagination"
-// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 403 {object} map[string]interface{} "Permission required"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/users [get]
 func GetAllUsers(c *fiber.Ctx) error {
@@ -73,6 +103,11 @@ func GetAllUsers(c *fiber.Ctx) error {
 		queryCount = queryCount.Where("email ILIKE ? OR full_name ILIKE ?", searchTerm, searchTerm)
 	}
 
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Order("created_at DESC"), models.User{}, "users")
+	}
+
 	// Get users
 	var users []models.User
 	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Order("created_at DESC").Find(&users).Error; err != nil {
@@ -89,6 +124,7 @@ func GetAllUsers(c *fiber.Ctx) error {
 			"email":        user.Email,
 			"full_name":    user.FullName,
 			"role":         user.Role,
+			"sales_associate_id": user.SalesAssociateID,
 			"created_at": user.CreatedAt,
 			"updated_at": user.UpdatedAt,
 		})
@@ -105,8 +141,8 @@ func GetAllUsers(c *fiber.Ctx) error {
 }
 
 // GetUser godoc
-// @Summary Get user by ID (Admin only)
-// @Description Retrieve a specific user by their ID. Only accessible by admin users.
+// @Summary Get user by ID
+// @Description Retrieve a specific user by their ID. Requires users:read.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -114,7 +150,7 @@ func GetAllUsers(c *fiber.Ctx) error {
 // @Param id path string true "User ID (UUID)"
 // @Success 200 {object} map[string]interface{} "User details"
 // @Failure 400 {object} map[string]interface{} "Invalid user ID"
-// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 403 {object} map[string]interface{} "Permission required"
 // @Failure 404 {object} map[string]interface{} "User not found"
 // @Router /api/users/{id} [get]
 func GetUser(c *fiber.Ctx) error {
@@ -139,14 +175,15 @@ func GetUser(c *fiber.Ctx) error {
 		"email":        user.Email,
 		"full_name":    user.FullName,
 		"role":         user.Role,
+		"sales_associate_id": user.SalesAssociateID,
 		"created_at": user.CreatedAt,
 		"updated_at": user.UpdatedAt,
 	})
 }
 
 // CreateUser godoc
-// @Summary Create a new user (Admin only)
-// @Description Create a new user with email, password, full name, and role. Only accessible by admin users.
+// @Summary Create a new user
+// @Description Create a new user with email, password, full name, and role. The role must exist in /api/roles and defaults to user, and the caller must hold every permission it grants. sales_associate_id links the user to a sales associate for the portal and is required for the sales_associate role. Requires users:write.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -154,7 +191,7 @@ func GetUser(c *fiber.Ctx) error {
 // @Param request body models.UserRequest true "User creation details"
 // @Success 201 {object} map[string]interface{} "User created successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid request body"
-// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 403 {object} map[string]interface{} "Permission required"
 // @Failure 409 {object} map[string]interface{} "Email already exists"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/users [post]
@@ -172,6 +209,31 @@ func CreateUser(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+	if req.Role == "" {
+		req.Role = "user"
+	}
+	if err := validateUserRole(req.Role); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Link the sales associate the user logs in as on the portal
+	var salesAssociateID *uuid.UUID
+	if req.SalesAssociateID != nil && *req.SalesAssociateID != "" {
+		id, err := validateUserSalesAssociate(*req.SalesAssociateID)
+		if err != nil {
+			return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		salesAssociateID = id
+	}
+	if req.Role == models.RoleSalesAssociate && salesAssociateID == nil {
+		return c.Status(errSalesAssociateRequired.Code).JSON(fiber.Map{
+			"error": errSalesAssociateRequired.Error(),
+		})
+	}
 
 	// Check if user exists
 	var existingUser models.User
@@ -181,6 +243,12 @@ func CreateUser(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := authorizeUserRoles(c, req.Role); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -191,13 +259,14 @@ func CreateUser(c *fiber.Ctx) error {
 
 	// Create user
 	user := models.User{
-		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
-		FullName:     req.FullName,
-		Role:         req.Role,
+		Email:            req.Email,
+		PasswordHash:     string(hashedPassword),
+		FullName:         req.FullName,
+		Role:             req.Role,
+		SalesAssociateID: salesAssociateID,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	if err := auditedDB(c).Create(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create user",
 		})
@@ -210,6 +279,7 @@ func CreateUser(c *fiber.Ctx) error {
 			"email":        user.Email,
 			"full_name":    user.FullName,
 			"role":         user.Role,
+			"sales_associate_id": user.SalesAssociateID,
 			"created_at": user.CreatedAt,
 			"updated_at": user.UpdatedAt,
 		},
@@ -217,8 +287,8 @@ func CreateUser(c *fiber.Ctx) error {
 }
 
 // UpdateUser godoc
-// @Summary Update user (Admin only)
-// @Description Update user information including email, password, full name, and role. Only accessible by admin users.
+// @Summary Update user
+// @Description Update user information including email, password, full name, and role. The role must exist in /api/roles. sales_associate_id links a sales associate for the portal, an empty string removes the link. Changing the email, password or role logs the user out of all sessions. Requires users:write, and the caller must hold every permission of the user's current and new role, so admin accounts can only be changed by holders of "*".
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -227,7 +297,7 @@ func CreateUser(c *fiber.Ctx) error {
 // @Param request body models.UserRequest true "User update details"
 // @Success 200 {object} map[string]interface{} "Updated user details"
 // @Failure 400 {object} map[string]interface{} "Invalid request or user ID"
-// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 403 {object} map[string]interface{} "Permission required"
 // @Failure 404 {object} map[string]interface{} "User not found"
 // @Failure 409 {object} map[string]interface{} "Email already exists"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
@@ -256,6 +326,9 @@ func UpdateUser(c *fiber.Ctx) error {
 		})
 	}
 
+	// Changing how the user logs in or what they may do ends their sessions
+	email, passwordHash, role, tokenVersion := user.Email, user.PasswordHash, user.Role, user.TokenVersion
+
 	// Check if email is being updated and if it already exists
 	if req.Email != "" && req.Email != user.Email {
 		if !helpers.IsValidEmail(req.Email) {
@@ -294,16 +367,47 @@ func UpdateUser(c *fiber.Ctx) error {
 	}
 
 	// Update role if provided
-	if req.Role != "" {
-		if req.Role != "user" && req.Role != "admin" && req.Role != "operator" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Role must be either 'user' or 'admin' or 'operator'",
+	if req.Role != "" && req.Role != user.Role {
+		if err := validateUserRole(req.Role); err != nil {
+			return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+				"error": err.Error(),
 			})
 		}
 		user.Role = req.Role
 	}
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	// Update the linked sales associate if provided, an empty string removes the link
+	if req.SalesAssociateID != nil {
+		if *req.SalesAssociateID == "" {
+			user.SalesAssociateID = nil
+		} else {
+			id, err := validateUserSalesAssociate(*req.SalesAssociateID)
+			if err != nil {
+				return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			user.SalesAssociateID = id
+		}
+	}
+	if user.Role == models.RoleSalesAssociate && user.SalesAssociateID == nil {
+		return c.Status(errSalesAssociateRequired.Code).JSON(fiber.Map{
+			"error": errSalesAssociateRequired.Error(),
+		})
+	}
+
+	// The caller must hold everything the user's current and new role grant
+	if err := authorizeUserRoles(c, role, user.Role); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if user.Email != email || user.PasswordHash != passwordHash || user.Role != role {
+		user.TokenVersion = tokenVersion + 1
+	}
+
+	if err := auditedDB(c).Save(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update user",
 		})
@@ -314,14 +418,15 @@ func UpdateUser(c *fiber.Ctx) error {
 		"email":        user.Email,
 		"full_name":    user.FullName,
 		"role":         user.Role,
+		"sales_associate_id": user.SalesAssociateID,
 		"created_at": user.CreatedAt,
 		"updated_at": user.UpdatedAt,
 	})
 }
 
 // DeleteUser godoc
-// @Summary Delete user (Admin only)
-// @Description Delete a user by their ID. Only accessible by admin users.
+// @Summary Delete user
+// @Description Delete a user by their ID. Requires users:delete and every permission of the user's role.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -329,7 +434,7 @@ func UpdateUser(c *fiber.Ctx) error {
 // @Param id path string true "User ID (UUID)"
 // @Success 200 {object} map[string]interface{} "User deleted successfully"
 // @Failure 400 {object} map[string]interface{} "Invalid user ID"
-// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 403 {object} map[string]interface{} "Permission required"
 // @Failure 404 {object} map[string]interface{} "User not found"
 // @Failure 500 {object} map[string]interface{} "Internal server error"
 // @Router /api/users/{id} [delete]
@@ -350,7 +455,14 @@ func DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := config.DB.Delete(&user).Error; err != nil {
+	if err := authorizeUserRoles(c, user.Role); err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Refresh tokens are deleted with the user, access tokens stop working because the user is gone
+	if err := auditedDB(c).Delete(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete user",
 		})
@@ -360,3 +472,47 @@ func DeleteUser(c *fiber.Ctx) error {
 		"message": "User deleted successfully",
 	})
 }
+
+// RevokeUserSessions godoc
+// @Summary Log a user out of all sessions
+// @Description End every session of a user, e.g. for a lost laptop. All their access and refresh tokens stop working immediately. Requires users:write.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Sessions revoked"
+// @Failure 400 {object} map[string]interface{} "Invalid user ID"
+// @Failure 403 {object} map[string]interface{} "Permission required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/users/{id}/revoke-sessions [post]
+func RevokeUserSessions(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	// Validate UUID
+	if _, err := uuid.Parse(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		return revokeUserSessions(tx, user.ID)
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Sessions revoked",
+	})
+}
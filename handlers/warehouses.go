@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetAllWarehouses godoc
+// @Summary Get all warehouses
+// @Description Retrieve all warehouses with their related city information
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param search query string false "Search by code or name"
+// @Param city_id query string false "Filter by city ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Number of items per page (default: 20)"
+// @Param include_deleted query bool false "Also list deleted warehouses (default: false)"
+// @Param format query string false "Export all matching rows as a file: csv or xlsx"
+// @Success 200 {object} map[string]interface{} "List of all warehouses with pagination"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/warehouses [get]
+func GetAllWarehouses(c *fiber.Ctx) error {
+	var warehouses []models.Warehouse
+
+	// Get pagination parameters
+	pagination := helpers.GetPaginationParams(c)
+
+	query := config.DB.Order("is_default DESC, name ASC")
+	queryCount := config.DB.Model(&models.Warehouse{})
+
+	// Deleted records are only listed on request
+	if includeDeleted(c) {
+		query = query.Unscoped()
+		queryCount = queryCount.Unscoped()
+	}
+
+	// add params for not using pagination
+	if c.Query("all") == "true" {
+		pagination.Limit = -1 // No limit
+		pagination.Offset = 0 // No offset
+	}
+
+	// Filter search
+	if searchQuery := c.Query("search"); searchQuery != "" {
+		searchTerm := "%" + searchQuery + "%"
+		cond := "warehouses.code ILIKE ? OR warehouses.name ILIKE ?"
+		args := []interface{}{searchTerm, searchTerm}
+
+		query = query.Where(cond, args...)
+		queryCount = queryCount.Where(cond, args...)
+	}
+
+	// Filter by city
+	if cityID := c.Query("city_id"); cityID != "" {
+		query = query.Where("warehouses.city_id = ?", cityID)
+		queryCount = queryCount.Where("warehouses.city_id = ?", cityID)
+	}
+
+	// Export the filtered list as a file instead of a page of JSON
+	if helpers.IsExportRequest(c) {
+		return helpers.ExportQuery(c, query.Preload("City", withDeleted), models.Warehouse{}, "warehouses")
+	}
+
+	// Apply pagination and fetch data
+	if err := query.Offset(pagination.Offset).Limit(pagination.Limit).Preload("City", withDeleted).Find(&warehouses).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch all warehouses",
+		})
+	}
+
+	// Create pagination response
+	response, err := helpers.CreatePaginationResponse(queryCount, warehouses, "warehouses", pagination.Page, pagination.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create pagination response",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GetWarehouse godoc
+// @Summary Get a warehouse by ID
+// @Description Retrieve a single warehouse by its ID with related city information
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Warehouse ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Warehouse details"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Warehouse not found"
+// @Router /api/warehouses/{id} [get]
+func GetWarehouse(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var warehouse models.Warehouse
+	if err := config.DB.Preload("City", withDeleted).Where("id = ?", id).First(&warehouse).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"warehouse": warehouse,
+	})
+}
+
+// CreateWarehouse godoc
+// @Summary Create a new warehouse
+// @Description Create a new warehouse entry. Setting is_default moves the default flag from the current default warehouse.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.Warehouse true "Warehouse details"
+// @Success 201 {object} models.Warehouse "Created warehouse"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/warehouses [post]
+func CreateWarehouse(c *fiber.Ctx) error {
+	var warehouse models.Warehouse
+	if err := c.BodyParser(&warehouse); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if warehouse.Code == "" || warehouse.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "code and name are required",
+		})
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if warehouse.IsDefault {
+			if err := clearDefaultWarehouse(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&warehouse).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create warehouse",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(warehouse)
+}
+
+// UpdateWarehouse godoc
+// @Summary Update a warehouse
+// @Description Update an existing warehouse by ID. Setting is_default moves the default flag from the current default warehouse.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Warehouse ID (UUID)"
+// @Param request body models.Warehouse true "Updated warehouse details"
+// @Success 200 {object} models.Warehouse "Updated warehouse"
+// @Failure 400 {object} map[string]interface{} "Invalid request body"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Warehouse not found"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/warehouses/{id} [put]
+func UpdateWarehouse(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var warehouse models.Warehouse
+	if err := config.DB.Where("id = ?", id).First(&warehouse).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse not found",
+		})
+	}
+
+	wasDefault := warehouse.IsDefault
+	if err := c.BodyParser(&warehouse); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	err := auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if warehouse.IsDefault && !wasDefault {
+			if err := clearDefaultWarehouse(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&warehouse).Updates(warehouse).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update warehouse",
+		})
+	}
+
+	return c.JSON(warehouse)
+}
+
+// DeleteWarehouse godoc
+// @Summary Delete a warehouse
+// @Description Soft delete a warehouse by ID. Deleted warehouses are hidden from lists and lookups and can be restored. Warehouses that still hold stock, are the default warehouse, or are used by open sales or purchase transactions, pending stock transfers or open stock opnames cannot be deleted.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Warehouse ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Warehouse deleted successfully"
+// @Failure 400 {object} map[string]interface{} "Warehouse still holds stock"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Warehouse not found"
+// @Failure 409 {object} map[string]interface{} "Warehouse is still in use, with the list of blockers"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/warehouses/{id} [delete]
+func DeleteWarehouse(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var warehouse models.Warehouse
+	if err := config.DB.Where("id = ?", id).First(&warehouse).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Warehouse not found",
+		})
+	}
+
+	if warehouse.IsDefault {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot delete the default warehouse",
+		})
+	}
+
+	var totalStock int64
+	if err := config.DB.Model(&models.BookStock{}).
+		Where("warehouse_id = ?", warehouse.ID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&totalStock).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check warehouse stock",
+		})
+	}
+	if totalStock > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":       "Cannot delete a warehouse that still holds stock",
+			"total_stock": totalStock,
+		})
+	}
+
+	blockers, err := dependencyReport(config.DB, "warehouses", warehouse.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check dependent records",
+		})
+	}
+	if len(blockers) > 0 {
+		return dependencyConflict(c, "Warehouse", blockers)
+	}
+
+	err = auditedDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("warehouse_id = ?", warehouse.ID).Delete(&models.BookStock{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&warehouse).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete warehouse",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Warehouse deleted successfully",
+	})
+}
+
+// RestoreWarehouse godoc
+// @Summary Restore a deleted warehouse
+// @Description Undo the soft delete of a warehouse. It comes back empty and not as the default warehouse. Fails when another warehouse has taken its code or name since.
+// @Tags Warehouses
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Warehouse ID (UUID)"
+// @Success 200 {object} map[string]interface{} "Restored warehouse"
+// @Failure 401 {object} map[string]interface{} "Unauthorized"
+// @Failure 404 {object} map[string]interface{} "Deleted warehouse not found"
+// @Failure 409 {object} map[string]interface{} "Code or name already in use"
+// @Failure 500 {object} map[string]interface{} "Internal server error"
+// @Router /api/warehouses/{id}/restore [post]
+func RestoreWarehouse(c *fiber.Ctx) error {
+	return restoreMasterRecord(c, &models.Warehouse{}, "Warehouse", "warehouse")
+}
+
+// clearDefaultWarehouse removes the default flag from the current default warehouse
+func clearDefaultWarehouse(tx *gorm.DB) error {
+	return tx.Model(&models.Warehouse{}).Where("is_default = ?", true).Update("is_default", false).Error
+}
@@ -0,0 +1,363 @@
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"pustaka-backend/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuditActor is the user and client behind a database change
+type AuditActor struct {
+	UserID    *uuid.UUID
+	IPAddress string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context that makes the audit callbacks record the actor
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// Tables that are not audited: the audit log itself and the login security tables, which keep
+// their own records
+var auditSkippedTables = map[string]bool{
+	"audit_logs":            true,
+	"login_attempts":        true,
+	"login_lockouts":        true,
+	"login_challenges":      true,
+	"refresh_tokens":        true,
+	"password_reset_tokens": true,
+	"user_recovery_codes":   true,
+}
+
+// Columns whose values never go into the audit log; a change only shows up as redacted
+var auditRedactedColumns = map[string]bool{
+	"password_hash":     true,
+	"two_factor_secret": true,
+}
+
+// Columns left out of update diffs: timestamps and counters that change with every write or
+// login
+var auditIgnoredColumns = map[string]bool{
+	"updated_at":           true,
+	"token_version":        true,
+	"two_factor_last_step": true,
+}
+
+const (
+	auditBeforeKey = "audit:before"
+	// The logs are written before gorm commits the transaction of the change
+	auditCommit = "gorm:commit_or_rollback_transaction"
+)
+
+// RegisterAuditCallbacks makes every create, update and delete through db write audit_logs
+// rows in the same transaction, and fills in created_by_id and updated_by_id of models that
+// have them. The actor is taken from the statement context, see WithAuditActor.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("audit:stamp_create", auditStampCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:stamp_update", auditStampUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Before(auditCommit).Register("audit:create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:before_update", auditBeforeChange); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Before(auditCommit).Register("audit:update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("audit:before_delete", auditBeforeChange); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Before(auditCommit).Register("audit:delete", auditAfterDelete)
+}
+
+// auditActorID returns the user of the statement context, if any
+func auditActorID(db *gorm.DB) *uuid.UUID {
+	actor, ok := db.Statement.Context.Value(auditActorKey{}).(AuditActor)
+	if !ok || actor.UserID == nil {
+		return nil
+	}
+	id := *actor.UserID
+	return &id
+}
+
+// auditStampCreate sets created_by_id and updated_by_id of new rows to the actor, for rows given
+// as models as well as column maps
+func auditStampCreate(db *gorm.DB) {
+	stmt := db.Statement
+	userID := auditActorID(db)
+	if db.Error != nil || stmt.Schema == nil || userID == nil {
+		return
+	}
+	for _, column := range []string{"created_by_id", "updated_by_id"} {
+		field := stmt.Schema.LookUpField(column)
+		if field == nil {
+			continue
+		}
+		switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+		case reflect.Struct:
+			db.AddError(field.Set(stmt.Context, rv, userID))
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				db.AddError(field.Set(stmt.Context, reflect.Indirect(rv.Index(i)), userID))
+			}
+		case reflect.Map:
+			stmt.SetColumn(field.DBName, userID)
+		}
+	}
+}
+
+// auditStampUpdate sets updated_by_id of changed rows to the actor
+func auditStampUpdate(db *gorm.DB) {
+	stmt := db.Statement
+	userID := auditActorID(db)
+	if db.Error != nil || stmt.Schema == nil || userID == nil || stmt.Schema.LookUpField("updated_by_id") == nil {
+		return
+	}
+	stmt.SetColumn("updated_by_id", userID)
+	if len(stmt.Selects) > 0 && stmt.Selects[0] != "*" {
+		stmt.Selects = append(stmt.Selects, "updated_by_id")
+	}
+}
+
+// auditable reports whether the statement changes an audited table with a primary key
+func auditable(db *gorm.DB) bool {
+	stmt := db.Statement
+	return db.Error == nil && stmt.Schema != nil && stmt.Schema.PrioritizedPrimaryField != nil &&
+		!auditSkippedTables[stmt.Table]
+}
+
+// auditSession is a fresh query on the connection of the statement, so it sees the changes of
+// the running transaction
+func auditSession(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table)
+}
+
+// auditRowsQuery selects the rows the statement is about to change: those matching its WHERE
+// clause and, when the model value has a primary key, that row
+func auditRowsQuery(db *gorm.DB) (*gorm.DB, bool) {
+	stmt := db.Statement
+	query := auditSession(db)
+	conditions := false
+
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		if expr, ok := where.Expression.(clause.Where); ok && len(expr.Exprs) > 0 {
+			query = query.Clauses(expr)
+			conditions = true
+		}
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if value, isZero := field.ValueOf(stmt.Context, stmt.ReflectValue); !isZero {
+				query = query.Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: value})
+				conditions = true
+			}
+		}
+	}
+	return query, conditions
+}
+
+// auditBeforeChange keeps the rows as they were before an update or delete
+func auditBeforeChange(db *gorm.DB) {
+	db.Statement.Settings.Delete(auditBeforeKey)
+	if !auditable(db) {
+		return
+	}
+	query, ok := auditRowsQuery(db)
+	if !ok {
+		return
+	}
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	db.Statement.Settings.Store(auditBeforeKey, rows)
+}
+
+func auditAfterCreate(db *gorm.DB) {
+	if !auditable(db) {
+		return
+	}
+	stmt := db.Statement
+
+	var logs []models.AuditLog
+	addRow := func(rv reflect.Value) {
+		row := map[string]interface{}{}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			value, _ := field.ValueOf(stmt.Context, rv)
+			row[field.DBName] = value
+		}
+		logs = append(logs, newAuditLog(db, models.AuditActionCreate, auditEntityID(db, row), nil, redactAuditValues(row)))
+	}
+
+	switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		addRow(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			addRow(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Map:
+		// Created from a column map on a model, e.g. db.Model(&City{}).Create(values)
+		if values, ok := stmt.Dest.(map[string]interface{}); ok {
+			row := make(map[string]interface{}, len(values))
+			for column, value := range values {
+				if field := stmt.Schema.LookUpField(column); field != nil {
+					column = field.DBName
+				}
+				row[column] = value
+			}
+			logs = append(logs, newAuditLog(db, models.AuditActionCreate, auditEntityID(db, row), nil, redactAuditValues(row)))
+		}
+	}
+	writeAuditLogs(db, logs)
+}
+
+func auditAfterUpdate(db *gorm.DB) {
+	before, ok := auditBeforeRows(db)
+	if !ok || len(before) == 0 {
+		return
+	}
+
+	pk := db.Statement.Schema.PrioritizedPrimaryField.DBName
+	ids := make([]interface{}, len(before))
+	for i, row := range before {
+		ids[i] = row[pk]
+	}
+	var after []map[string]interface{}
+	if err := auditSession(db).Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).Find(&after).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	afterByID := make(map[string]map[string]interface{}, len(after))
+	for _, row := range after {
+		afterByID[auditEntityID(db, row)] = row
+	}
+
+	var logs []models.AuditLog
+	for _, old := range before {
+		id := auditEntityID(db, old)
+		from, to := diffAuditValues(old, afterByID[id])
+		if len(from) == 0 && len(to) == 0 {
+			continue
+		}
+		logs = append(logs, newAuditLog(db, models.AuditActionUpdate, id, from, to))
+	}
+	writeAuditLogs(db, logs)
+}
+
+func auditAfterDelete(db *gorm.DB) {
+	before, ok := auditBeforeRows(db)
+	if !ok {
+		return
+	}
+	logs := make([]models.AuditLog, 0, len(before))
+	for _, row := range before {
+		logs = append(logs, newAuditLog(db, models.AuditActionDelete, auditEntityID(db, row), redactAuditValues(row), nil))
+	}
+	writeAuditLogs(db, logs)
+}
+
+// auditBeforeRows returns the rows kept by auditBeforeChange once the change has succeeded
+func auditBeforeRows(db *gorm.DB) ([]map[string]interface{}, bool) {
+	if !auditable(db) {
+		return nil, false
+	}
+	value, ok := db.Statement.Settings.Load(auditBeforeKey)
+	if !ok {
+		return nil, false
+	}
+	rows, ok := value.([]map[string]interface{})
+	return rows, ok
+}
+
+func auditEntityID(db *gorm.DB, row map[string]interface{}) string {
+	value := row[db.Statement.Schema.PrioritizedPrimaryField.DBName]
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// diffAuditValues returns the old and new values of the columns that changed
+func diffAuditValues(before, after map[string]interface{}) (models.AuditValues, models.AuditValues) {
+	from := models.AuditValues{}
+	to := models.AuditValues{}
+	for column, old := range before {
+		if auditIgnoredColumns[column] {
+			continue
+		}
+		value, ok := after[column]
+		if !ok {
+			continue
+		}
+		oldJSON, _ := json.Marshal(old)
+		newJSON, _ := json.Marshal(value)
+		if string(oldJSON) == string(newJSON) {
+			continue
+		}
+		if auditRedactedColumns[column] {
+			from[column], to[column] = "[redacted]", "[redacted]"
+			continue
+		}
+		from[column], to[column] = old, value
+	}
+	return from, to
+}
+
+// redactAuditValues copies a row without the values of secret columns
+func redactAuditValues(row map[string]interface{}) models.AuditValues {
+	values := make(models.AuditValues, len(row))
+	for column, value := range row {
+		if auditRedactedColumns[column] {
+			value = "[redacted]"
+		}
+		values[column] = value
+	}
+	return values
+}
+
+func newAuditLog(db *gorm.DB, action, entityID string, before, after models.AuditValues) models.AuditLog {
+	log := models.AuditLog{
+		Action:     action,
+		EntityType: db.Statement.Table,
+		EntityID:   entityID,
+		Before:     before,
+		After:      after,
+	}
+	if actor, ok := db.Statement.Context.Value(auditActorKey{}).(AuditActor); ok {
+		log.UserID = actor.UserID
+		if actor.IPAddress != "" {
+			ip := actor.IPAddress
+			log.IPAddress = &ip
+		}
+	}
+	return log
+}
+
+// writeAuditLogs stores the logs in the transaction of the change, so a failed audit write
+// rolls the change back
+func writeAuditLogs(db *gorm.DB, logs []models.AuditLog) {
+	if len(logs) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&logs).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+	}
+}
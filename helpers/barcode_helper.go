@@ -0,0 +1,363 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Barcode symbologies supported by EncodeBarcode
+const (
+	SymbologyEAN13   = "ean13"
+	SymbologyCode128 = "code128"
+)
+
+// barcodeQuietZone is the blank margin, in modules, on both sides of the bars
+const barcodeQuietZone = 10
+
+// Barcode is an encoded barcode: one entry per module, true for a bar
+type Barcode struct {
+	Symbology string
+	Text      string
+	Modules   []bool
+}
+
+// BarcodeLabel is a printable label: an optional title above the bars and the
+// barcode text plus an optional caption (e.g. the price) below them
+type BarcodeLabel struct {
+	Barcode Barcode
+	Title   string
+	Caption string
+}
+
+var ean13LCodes = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// ean13Parity selects L (odd) or G (even) codes for the left half, by first digit
+var ean13Parity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// code128Patterns holds the bar/space widths of every Code 128 symbol; 106 is the stop pattern
+var code128Patterns = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+func appendPattern(modules []bool, pattern string) []bool {
+	for _, r := range pattern {
+		modules = append(modules, r == '1')
+	}
+	return modules
+}
+
+// EncodeEAN13 encodes a 13 digit EAN code (such as an ISBN-13) with a valid check digit
+func EncodeEAN13(code string) (Barcode, error) {
+	if !IsValidEAN13(code) {
+		return Barcode{}, errors.New("EAN-13 code must be 13 digits with a valid check digit")
+	}
+
+	modules := appendPattern(nil, "101")
+	parity := ean13Parity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		pattern := ean13LCodes[code[i]-'0']
+		if parity[i-1] == 'G' {
+			pattern = reversePattern(invertPattern(pattern))
+		}
+		modules = appendPattern(modules, pattern)
+	}
+	modules = appendPattern(modules, "01010")
+	for i := 7; i <= 12; i++ {
+		modules = appendPattern(modules, invertPattern(ean13LCodes[code[i]-'0']))
+	}
+	modules = appendPattern(modules, "101")
+
+	return Barcode{Symbology: SymbologyEAN13, Text: code, Modules: modules}, nil
+}
+
+// EncodeCode128 encodes printable ASCII text using Code 128 code set B
+func EncodeCode128(text string) (Barcode, error) {
+	if text == "" {
+		return Barcode{}, errors.New("Code 128 text cannot be empty")
+	}
+
+	symbols := []int{code128StartB}
+	checksum := code128StartB
+	for i := 0; i < len(text); i++ {
+		if text[i] < 32 || text[i] > 126 {
+			return Barcode{}, fmt.Errorf("character %q cannot be encoded in Code 128", text[i])
+		}
+		value := int(text[i]) - 32
+		symbols = append(symbols, value)
+		checksum += value * (i + 1)
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	var modules []bool
+	for _, symbol := range symbols {
+		bar := true
+		for _, width := range code128Patterns[symbol] {
+			for w := 0; w < int(width-'0'); w++ {
+				modules = append(modules, bar)
+			}
+			bar = !bar
+		}
+	}
+
+	return Barcode{Symbology: SymbologyCode128, Text: text, Modules: modules}, nil
+}
+
+// EncodeBarcode encodes ISBNs and other EAN-13 codes as EAN-13 (ISBN-10 is converted to
+// ISBN-13 first) and anything else, such as a SKU, as Code 128
+func EncodeBarcode(value string) (Barcode, error) {
+	normalized := NormalizeISBN(value)
+	if IsValidEAN13(normalized) {
+		return EncodeEAN13(normalized)
+	}
+	if IsValidISBN10(normalized) {
+		return EncodeEAN13(ISBN10To13(normalized))
+	}
+	return EncodeCode128(value)
+}
+
+func invertPattern(pattern string) string {
+	inverted := []byte(pattern)
+	for i := range inverted {
+		if inverted[i] == '1' {
+			inverted[i] = '0'
+		} else {
+			inverted[i] = '1'
+		}
+	}
+	return string(inverted)
+}
+
+func reversePattern(pattern string) string {
+	reversed := []byte(pattern)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+	return string(reversed)
+}
+
+// labelLayout holds the pixel geometry shared by the SVG and PNG renderers
+type labelLayout struct {
+	moduleWidth int
+	barHeight   int
+	lineHeight  int
+	width       int
+	titleTop    int
+	barsTop     int
+	textTop     int
+	captionTop  int
+	height      int
+}
+
+func newLabelLayout(label BarcodeLabel, moduleWidth, barHeight int) labelLayout {
+	l := labelLayout{moduleWidth: moduleWidth, barHeight: barHeight, lineHeight: 10 * moduleWidth}
+	l.width = (len(label.Barcode.Modules) + 2*barcodeQuietZone) * moduleWidth
+
+	y := l.lineHeight / 2
+	if label.Title != "" {
+		l.titleTop = y
+		y += l.lineHeight
+	}
+	l.barsTop = y
+	y += barHeight + l.lineHeight/4
+	l.textTop = y
+	y += l.lineHeight
+	if label.Caption != "" {
+		l.captionTop = y
+		y += l.lineHeight
+	}
+	l.height = y + l.lineHeight/2
+	return l
+}
+
+// RenderBarcodeSVG renders a label as an SVG document
+func RenderBarcodeSVG(label BarcodeLabel, moduleWidth, barHeight int) []byte {
+	l := newLabelLayout(label, moduleWidth, barHeight)
+	fontSize := l.lineHeight * 8 / 10
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, l.width, l.height, l.width, l.height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, l.width, l.height)
+
+	text := func(top int, value string) {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle" dominant-baseline="hanging">%s</text>`,
+			l.width/2, top, fontSize, html.EscapeString(value))
+	}
+
+	if label.Title != "" {
+		text(l.titleTop, label.Title)
+	}
+
+	// Runs of adjacent bars are drawn as one rectangle
+	for i := 0; i < len(label.Barcode.Modules); {
+		if !label.Barcode.Modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(label.Barcode.Modules) && label.Barcode.Modules[i] {
+			i++
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#000000"/>`,
+			(barcodeQuietZone+start)*moduleWidth, l.barsTop, (i-start)*moduleWidth, barHeight)
+	}
+
+	text(l.textTop, label.Barcode.Text)
+	if label.Caption != "" {
+		text(l.captionTop, label.Caption)
+	}
+
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// RenderBarcodePNG renders a label as a PNG image. Text is drawn with a built-in 5x7 font,
+// so lowercase letters are printed in uppercase and unsupported characters as '?'.
+func RenderBarcodePNG(label BarcodeLabel, moduleWidth, barHeight int) ([]byte, error) {
+	l := newLabelLayout(label, moduleWidth, barHeight)
+
+	img := image.NewGray(image.Rect(0, 0, l.width, l.height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for i, bar := range label.Barcode.Modules {
+		if !bar {
+			continue
+		}
+		x0 := (barcodeQuietZone + i) * moduleWidth
+		for x := x0; x < x0+moduleWidth; x++ {
+			for y := l.barsTop; y < l.barsTop+barHeight; y++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	if label.Title != "" {
+		drawText(img, label.Title, l.titleTop, moduleWidth)
+	}
+	drawText(img, label.Barcode.Text, l.textTop, moduleWidth)
+	if label.Caption != "" {
+		drawText(img, label.Caption, l.captionTop, moduleWidth)
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// drawText draws a line of text centred horizontally, each font pixel scale x scale pixels wide.
+// Text that does not fit is cut off at the label edges.
+func drawText(img *image.Gray, text string, top, scale int) {
+	const cellWidth = 6 // 5 pixel glyph plus 1 pixel spacing
+
+	runes := []rune(text)
+	width := len(runes) * cellWidth * scale
+	left := (img.Bounds().Dx() - width) / 2
+
+	for i, r := range runes {
+		glyph := glyphFor(r)
+		for row := 0; row < 7; row++ {
+			for col := 0; col < 5; col++ {
+				if glyph[row]&(1<<(4-col)) == 0 {
+					continue
+				}
+				x0 := left + (i*cellWidth+col)*scale
+				y0 := top + row*scale
+				for x := x0; x < x0+scale; x++ {
+					for y := y0; y < y0+scale; y++ {
+						if image.Pt(x, y).In(img.Bounds()) {
+							img.SetGray(x, y, color.Gray{Y: 0})
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func glyphFor(r rune) [7]uint8 {
+	if r >= 'a' && r <= 'z' {
+		r -= 'a' - 'A'
+	}
+	if glyph, ok := barcodeFont[r]; ok {
+		return glyph
+	}
+	return barcodeFont['?']
+}
+
+// barcodeFont is a 5x7 bitmap font, one byte per row with bit 4 as the leftmost pixel
+var barcodeFont = map[rune][7]uint8{
+	' ': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',': {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'?': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
@@ -0,0 +1,378 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// exportBatchSize is the number of records loaded per query while streaming an export
+const exportBatchSize = 500
+
+// maxExportDepth limits how deep nested relations are flattened, e.g. publisher_city_name
+const maxExportDepth = 2
+
+// ExportColumn is one column of a spreadsheet export
+type ExportColumn struct {
+	Header string
+	index  []int // Field index path into the record struct
+}
+
+// IsExportRequest reports whether a list endpoint was asked for a file instead of JSON
+func IsExportRequest(c *fiber.Ctx) bool {
+	format := c.Query("format")
+	return format != "" && format != "json"
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// isExportScalar reports whether a field type is written as a single cell. Structs that
+// implement fmt.Stringer, such as uuid.UUID and models.Date, count as scalars.
+func isExportScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Struct:
+		return t == timeType || t.Implements(stringerType)
+	case reflect.Array:
+		return t.Implements(stringerType)
+	}
+	return false
+}
+
+// jsonFieldName returns the JSON name of a struct field, or an empty string when it is not serialized
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// ExportColumns derives the export columns of a struct type. Scalar fields become columns named
+// after their JSON name. Relations listed in relations (GORM preload paths such as "Publisher" and
+// "Publisher.City") are flattened into prefixed columns like publisher_city_name, leaving out
+// their IDs and timestamps. Has-many relations are not exported.
+func ExportColumns(t reflect.Type, relations []string) []ExportColumn {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return exportColumns(t, exportPreloads(relations), "", "", nil, 0)
+}
+
+func exportColumns(t reflect.Type, preloads map[string]bool, prefix, path string, index []int, depth int) []ExportColumn {
+	// Foreign keys are left out when their relation is exported
+	relations := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && !isExportScalar(fieldType) && exportRelationIncluded(preloads, path, field.Name) {
+			relations[jsonFieldName(field)+"_id"] = true
+		}
+	}
+
+	var columns []ExportColumn
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonFieldName(field)
+		if name == "" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if field.Anonymous && !isExportScalar(field.Type) && field.Type.Kind() == reflect.Struct {
+			columns = append(columns, exportColumns(field.Type, preloads, prefix, path, fieldIndex, depth)...)
+			continue
+		}
+
+		if isExportScalar(field.Type) {
+			if relations[name] {
+				continue
+			}
+			if depth > 0 && (name == "id" || name == "created_at" || name == "updated_at" || strings.HasSuffix(name, "_id")) {
+				continue
+			}
+			columns = append(columns, ExportColumn{Header: prefix + name, index: fieldIndex})
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct || depth >= maxExportDepth || !exportRelationIncluded(preloads, path, field.Name) {
+			continue
+		}
+		columns = append(columns, exportColumns(fieldType, preloads, prefix+name+"_", joinPreloadPath(path, field.Name), fieldIndex, depth+1)...)
+	}
+	return columns
+}
+
+func joinPreloadPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func exportRelationIncluded(preloads map[string]bool, path, name string) bool {
+	return preloads[joinPreloadPath(path, name)]
+}
+
+// exportPreloads expands GORM preload paths with the parents of nested paths
+func exportPreloads(names []string) map[string]bool {
+	preloads := make(map[string]bool)
+	for _, name := range names {
+		parts := strings.Split(name, ".")
+		for i := range parts {
+			preloads[strings.Join(parts[:i+1], ".")] = true
+		}
+	}
+	return preloads
+}
+
+// exportValue reads a column of a record as nil, string, int64, uint64, float64 or bool
+func exportValue(record reflect.Value, column ExportColumn) interface{} {
+	v := record
+	for _, i := range column.index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	if v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	default:
+		return v.String()
+	}
+}
+
+// exportCell formats a value for a CSV cell
+func exportCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// exportRowWriter writes rows in the requested file format
+type exportRowWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (w *csvRowWriter) WriteRow(values []interface{}) error {
+	w.record = w.record[:0]
+	for _, value := range values {
+		w.record = append(w.record, exportCell(value))
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvRowWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// exportFormat validates the requested file format
+func exportFormat(c *fiber.Ctx) (string, error) {
+	format := c.Query("format")
+	if format != "csv" && format != "xlsx" {
+		return "", fmt.Errorf("format must be csv or xlsx")
+	}
+	return format, nil
+}
+
+// setExportHeaders marks the response as a file download
+func setExportHeaders(c *fiber.Ctx, format, name string) {
+	if format == "xlsx" {
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	}
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102_150405"), format)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+func newExportRowWriter(w *bufio.Writer, format, sheetName string, columns []ExportColumn) (exportRowWriter, error) {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+
+	if format == "xlsx" {
+		return NewXLSXWriter(w, sheetName, header)
+	}
+
+	// The BOM makes Excel open UTF-8 CSV files with the right encoding
+	w.WriteString("\xef\xbb\xbf")
+	writer := &csvRowWriter{writer: csv.NewWriter(w)}
+	return writer, writer.writer.Write(header)
+}
+
+func writeExportRecords(writer exportRowWriter, records reflect.Value, columns []ExportColumn) error {
+	values := make([]interface{}, len(columns))
+	for i := 0; i < records.Len(); i++ {
+		for j, column := range columns {
+			values[j] = exportValue(records.Index(i), column)
+		}
+		if err := writer.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportQuery streams every record matched by a list query as a CSV or XLSX attachment.
+// The query keeps its filters, sort order and preloads; pagination is ignored. Records are
+// loaded in batches so large exports are not held in memory. model is the record type,
+// e.g. models.Book{}, and name is used for the file and sheet name.
+func ExportQuery(c *fiber.Ctx, query *gorm.DB, model interface{}, name string) error {
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordType := reflect.TypeOf(model)
+	var preloads []string
+	for name := range query.Statement.Preloads {
+		preloads = append(preloads, name)
+	}
+	columns := ExportColumns(recordType, preloads)
+
+	// Sort by primary key last so the batches do not overlap
+	base := query.Session(&gorm.Session{}).Order(clause.OrderByColumn{Column: clause.PrimaryColumn})
+	loadBatch := func(offset int) (reflect.Value, error) {
+		batch := reflect.New(reflect.SliceOf(recordType))
+		err := base.Offset(offset).Limit(exportBatchSize).Find(batch.Interface()).Error
+		return batch.Elem(), err
+	}
+
+	// Load the first batch before streaming so a failing query can still return an error
+	first, err := loadBatch(0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export " + strings.ReplaceAll(name, "_", " "),
+		})
+	}
+
+	setExportHeaders(c, format, name)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer, err := newExportRowWriter(w, format, name, columns)
+		if err != nil {
+			return
+		}
+
+		batch := first
+		for offset := 0; ; {
+			if err := writeExportRecords(writer, batch, columns); err != nil {
+				return
+			}
+			w.Flush()
+
+			if batch.Len() < exportBatchSize {
+				break
+			}
+			offset += exportBatchSize
+			if batch, err = loadBatch(offset); err != nil {
+				return
+			}
+		}
+		writer.Close()
+		w.Flush()
+	})
+	return nil
+}
+
+// ExportRows writes an already loaded slice, such as report lines, as a CSV or XLSX attachment.
+// relations lists the loaded relations to flatten, as GORM preload paths.
+func ExportRows(c *fiber.Ctx, rows interface{}, name string, relations ...string) error {
+	format, err := exportFormat(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	records := reflect.ValueOf(rows)
+	columns := ExportColumns(records.Type(), relations)
+
+	setExportHeaders(c, format, name)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer, err := newExportRowWriter(w, format, name, columns)
+		if err != nil {
+			return
+		}
+		if err := writeExportRecords(writer, records, columns); err != nil {
+			return
+		}
+		writer.Close()
+		w.Flush()
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// NormalizeISBN strips hyphens and spaces from an ISBN and upper-cases the ISBN-10 check character
+func NormalizeISBN(isbn string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(strings.TrimSpace(isbn)))
+}
+
+// IsValidISBN10 validates a normalized ISBN-10, including its mod 11 check character
+func IsValidISBN10(isbn string) bool {
+	if len(isbn) != 10 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var digit int
+		switch {
+		case isbn[i] >= '0' && isbn[i] <= '9':
+			digit = int(isbn[i] - '0')
+		case isbn[i] == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+		sum += digit * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// IsValidISBN13 validates a normalized ISBN-13 (978/979 prefix), including its mod 10 check digit
+func IsValidISBN13(isbn string) bool {
+	if len(isbn) != 13 || !(strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")) {
+		return false
+	}
+	return IsValidEAN13(isbn)
+}
+
+// IsValidEAN13 validates a 13 digit EAN code and its check digit
+func IsValidEAN13(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	for i := 0; i < 13; i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+	}
+	return EAN13CheckDigit(code[:12]) == code[12]
+}
+
+// EAN13CheckDigit returns the check digit for the first 12 digits of an EAN-13 code
+func EAN13CheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		digit := int(first12[i] - '0')
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// IsValidISBN validates an ISBN-10 or ISBN-13, with or without hyphens
+func IsValidISBN(isbn string) bool {
+	normalized := NormalizeISBN(isbn)
+	return IsValidISBN10(normalized) || IsValidISBN13(normalized)
+}
+
+// ISBN10To13 converts a normalized ISBN-10 to its ISBN-13 form with the 978 prefix
+func ISBN10To13(isbn10 string) string {
+	if len(isbn10) != 10 {
+		return ""
+	}
+	first12 := "978" + isbn10[:9]
+	return first12 + string(EAN13CheckDigit(first12))
+}
+
+// ISBN13To10 converts a normalized 978-prefixed ISBN-13 to its ISBN-10 form.
+// 979-prefixed ISBNs have no ISBN-10 form and return an empty string.
+func ISBN13To10(isbn13 string) string {
+	if len(isbn13) != 13 || !strings.HasPrefix(isbn13, "978") {
+		return ""
+	}
+
+	body := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X"
+	}
+	return body + string(rune('0'+check))
+}
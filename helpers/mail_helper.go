@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends mail through the SMTP server configured by SMTP_HOST, SMTP_PORT (default
+// 587), SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM. The settings are read on every send, so
+// the zero value can be used before the environment is loaded.
+type SMTPMailer struct{}
+
+func (SMTPMailer) Send(to, subject, body string) error {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		return errors.New("SMTP_HOST and SMTP_FROM must be set to send mail")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(host, port), auth, from, []string{to}, buildMessage(from, to, subject, body))
+}
+
+// buildMessage formats a plain text email with its headers
+func buildMessage(from, to, subject, body string) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(msg.String())
+}
+
+// MailMessage is an email kept by CaptureMailer
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// CaptureMailer keeps emails in memory instead of sending them, for tests and local development
+type CaptureMailer struct {
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+func (m *CaptureMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, MailMessage{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Messages returns a copy of the captured emails, oldest first
+func (m *CaptureMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchConfig is the PostgreSQL text search configuration used for the book catalog
+const SearchConfig = "pustaka_indonesian"
+
+// indonesianStopwords are common words that carry no meaning in a catalog search. The database
+// configuration has no stopword file, so they are removed from the query instead.
+var indonesianStopwords = map[string]bool{
+	"ada": true, "adalah": true, "akan": true, "atau": true, "bagi": true, "dalam": true,
+	"dan": true, "dari": true, "dengan": true, "di": true, "ini": true, "itu": true,
+	"jika": true, "juga": true, "karena": true, "ke": true, "kepada": true, "oleh": true,
+	"pada": true, "para": true, "sebagai": true, "serta": true, "tentang": true, "tidak": true,
+	"untuk": true, "yang": true,
+}
+
+// SearchTerms splits a search string into lowercase words, dropping stopwords and punctuation.
+// Input that looks like an ISBN is kept as one term without hyphens so it matches the stored ISBN.
+func SearchTerms(input string) []string {
+	input = strings.TrimSpace(input)
+	if strings.Trim(input, "0123456789Xx-") == "" && strings.ContainsAny(input, "0123456789") {
+		if isbn := NormalizeISBN(input); len(isbn) >= 4 {
+			return []string{strings.ToLower(isbn)}
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if !indonesianStopwords[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// BuildTSQuery turns a search string into to_tsquery input that matches all words. With prefix
+// set every word also matches longer words, which suits search-as-you-type. It returns an empty
+// string when nothing is left to search for.
+func BuildTSQuery(input string, prefix bool) string {
+	terms := SearchTerms(input)
+	if prefix {
+		for i := range terms {
+			terms[i] += ":*"
+		}
+	}
+	return strings.Join(terms, " & ")
+}
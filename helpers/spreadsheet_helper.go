@@ -0,0 +1,325 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadSpreadsheet reads all rows of a CSV file or of the first sheet of an XLSX workbook.
+// The format is chosen from the file extension. Trailing empty cells are kept as empty strings.
+func ReadSpreadsheet(filename string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return readCSV(data)
+	case ".xlsx":
+		return readXLSX(data)
+	default:
+		return nil, fmt.Errorf("unsupported file type, expected .csv or .xlsx")
+	}
+}
+
+// readCSV parses comma or semicolon separated values. Excel with an Indonesian locale saves
+// CSV with semicolons, so the delimiter is guessed from the header line.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	header := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		header = data[:i]
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV file: %w", err)
+	}
+	return rows, nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readZipXML(files map[string]*zip.File, name string, v interface{}) (bool, error) {
+	file, ok := files[name]
+	if !ok {
+		return false, nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return true, err
+	}
+	defer rc.Close()
+	return true, xml.NewDecoder(rc).Decode(v)
+}
+
+// readXLSX reads the first worksheet of an XLSX workbook
+func readXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath := "xl/worksheets/sheet1.xml"
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if ok, err := readZipXML(files, "xl/workbook.xml", &workbook); !ok || err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: workbook is missing")
+	}
+	if _, err := readZipXML(files, "xl/_rels/workbook.xml.rels", &rels); err == nil && len(workbook.Sheets) > 0 {
+		for _, rel := range rels.Relationships {
+			if rel.ID == workbook.Sheets[0].RID {
+				if strings.HasPrefix(rel.Target, "/") {
+					sheetPath = strings.TrimPrefix(rel.Target, "/")
+				} else {
+					sheetPath = path.Join("xl", rel.Target)
+				}
+				break
+			}
+		}
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if _, err := readZipXML(files, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: %w", err)
+	}
+
+	var sheet xlsxSheet
+	if ok, err := readZipXML(files, sheetPath, &sheet); !ok || err != nil {
+		return nil, fmt.Errorf("invalid XLSX file: worksheet is missing")
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var values []string
+		for _, cell := range row.Cells {
+			column := len(values)
+			if cell.Ref != "" {
+				column = xlsxColumnIndex(cell.Ref)
+			}
+			for len(values) < column {
+				values = append(values, "")
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, fmt.Errorf("invalid XLSX file: bad shared string in cell %s", cell.Ref)
+				}
+				value = sharedStrings.Items[index].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			case "", "n":
+				value = cell.Value
+				// Long numbers such as ISBNs are stored in exponent form
+				if strings.ContainsAny(value, "eE") {
+					if f, err := strconv.ParseFloat(value, 64); err == nil {
+						value = strconv.FormatFloat(f, 'f', -1, 64)
+					}
+				}
+			default:
+				value = cell.Value
+			}
+			values = append(values, value)
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+// xlsxColumnIndex returns the zero based column of a cell reference such as "AB12"
+func xlsxColumnIndex(ref string) int {
+	column := 0
+	for i := 0; i < len(ref); i++ {
+		ch := ref[i]
+		if ch >= 'a' && ch <= 'z' {
+			ch -= 'a' - 'A'
+		}
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		column = column*26 + int(ch-'A'+1)
+	}
+	return column - 1
+}
+
+// XLSXWriter streams rows into a single sheet XLSX workbook. Rows are written straight to the
+// underlying writer, so large exports do not have to be held in memory.
+type XLSXWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	row     int
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+
+// Style 1 is a bold font, used for the header row
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`
+
+// NewXLSXWriter starts a workbook with one sheet and writes the header row in bold
+func NewXLSXWriter(w io.Writer, sheetName string, header []string) (*XLSXWriter, error) {
+	archive := zip.NewWriter(w)
+
+	var name bytes.Buffer
+	xml.EscapeText(&name, []byte(sheetName))
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="` + name.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		pw, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(pw, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	writer := &XLSXWriter{archive: archive, sheet: sheet}
+	if len(header) > 0 {
+		values := make([]interface{}, len(header))
+		for i, h := range header {
+			values[i] = h
+		}
+		if err := writer.writeRow(values, ` s="1"`); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+// WriteRow appends a row. Numbers and booleans are written as typed cells, nil as an empty
+// cell and anything else as text.
+func (x *XLSXWriter) WriteRow(values []interface{}) error {
+	return x.writeRow(values, "")
+}
+
+func (x *XLSXWriter) writeRow(values []interface{}, style string) error {
+	x.row++
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<row r="%d">`, x.row)
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			fmt.Fprintf(&b, `<c%s/>`, style)
+		case int, int32, int64, uint, uint32, uint64:
+			fmt.Fprintf(&b, `<c%s><v>%d</v></c>`, style, v)
+		case float32:
+			fmt.Fprintf(&b, `<c%s><v>%s</v></c>`, style, strconv.FormatFloat(float64(v), 'f', -1, 32))
+		case float64:
+			fmt.Fprintf(&b, `<c%s><v>%s</v></c>`, style, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			flag := 0
+			if v {
+				flag = 1
+			}
+			fmt.Fprintf(&b, `<c%s t="b"><v>%d</v></c>`, style, flag)
+		default:
+			fmt.Fprintf(&b, `<c%s t="inlineStr"><is><t xml:space="preserve">`, style)
+			xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+
+	_, err := x.sheet.Write(b.Bytes())
+	return err
+}
+
+// Close finishes the sheet and the workbook. It does not close the underlying writer.
+func (x *XLSXWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"pustaka-backend/config"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
 	"pustaka-backend/routes"
 
 	_ "pustaka-backend/docs"
@@ -44,6 +46,16 @@ func main() {
 	// Connect to database
 	config.ConnectDB()
 
+	// Every create, update and delete writes an audit_logs row
+	if err := helpers.RegisterAuditCallbacks(config.DB); err != nil {
+		log.Fatal("Failed to register audit callbacks: ", err)
+	}
+
+	// Login lockouts are kept in Postgres unless a single node keeps them in memory
+	if os.Getenv("LOGIN_ATTEMPT_STORE") == "memory" {
+		handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		// Increase buffer sizes to handle large headers/cookies (fixes "Request Header Fields Too Large" error)
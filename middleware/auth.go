@@ -1,16 +1,22 @@
 package middleware
 
 import (
+    "errors"
     "os"
     "strings"
+    "pustaka-backend/config"
+    "pustaka-backend/models"
     "github.com/gofiber/fiber/v2"
     "github.com/golang-jwt/jwt/v5"
+    "github.com/google/uuid"
+    "gorm.io/gorm"
 )
 
 type Claims struct {
-    UserID string `json:"user_id"`
-    Email  string `json:"email"`
-    Role   string `json:"role"`
+    UserID       string `json:"user_id"`
+    Email        string `json:"email"`
+    Role         string `json:"role"`
+    TokenVersion int    `json:"ver"`
     jwt.RegisteredClaims
 }
 
@@ -41,15 +47,41 @@ func AuthRequired() fiber.Handler {
         }
 
         claims, ok := token.Claims.(*Claims)
-        if !ok {
+        if ok {
+            _, err = uuid.Parse(claims.UserID)
+        }
+        if !ok || err != nil {
             return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
                 "error": "Invalid token claims",
             })
         }
 
+        // Revoked sessions and deleted users lose access at once, and role changes apply
+        // without logging in again
+        var user models.User
+        if err := config.DB.Select("id", "role", "token_version", "two_factor_enabled", "sales_associate_id").Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+            if !errors.Is(err, gorm.ErrRecordNotFound) {
+                return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+                    "error": "Failed to check session",
+                })
+            }
+            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+                "error": "Session has been revoked",
+            })
+        }
+        if user.TokenVersion != claims.TokenVersion {
+            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+                "error": "Session has been revoked",
+            })
+        }
+
         c.Locals("userID", claims.UserID)
         c.Locals("userEmail", claims.Email)
-        c.Locals("userRole", claims.Role)
+        c.Locals("userRole", user.Role)
+        c.Locals("twoFactorEnabled", user.TwoFactorEnabled)
+        if user.SalesAssociateID != nil {
+            c.Locals("salesAssociateID", user.SalesAssociateID.String())
+        }
 
         return c.Next()
     }
@@ -66,3 +98,17 @@ func AdminOnly() fiber.Handler {
         return c.Next()
     }
 }
+
+// TwoFactorEnrolled blocks admins without 2FA. Register it after the routes they need to
+// enroll (the 2FA and profile routes), which stay reachable.
+func TwoFactorEnrolled() fiber.Handler {
+    return func(c *fiber.Ctx) error {
+        if c.Locals("userRole") == "admin" && c.Locals("twoFactorEnabled") != true {
+            return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+                "error":                          "Admins must enable two-factor authentication",
+                "two_factor_enrollment_required": true,
+            })
+        }
+        return c.Next()
+    }
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"pustaka-backend/config"
+	"pustaka-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RequirePermission allows the request only when the role of the logged-in user grants the
+// permission. It must run after AuthRequired. Permissions are read from the roles table on
+// every request, so changes to a role apply without logging in again.
+func RequirePermission(permission string) fiber.Handler {
+	return RequirePermissionFunc(func(c *fiber.Ctx) string {
+		return permission
+	}, permission)
+}
+
+// RequirePermissionFunc is RequirePermission for routes whose permission depends on the
+// request, e.g. on a path parameter. resolve returns the permission needed, or an empty string
+// when the request needs none. known lists the permissions resolve can return, so a typo fails
+// at startup instead of locking everyone out.
+func RequirePermissionFunc(resolve func(c *fiber.Ctx) string, known ...string) fiber.Handler {
+	for _, permission := range known {
+		if !models.IsKnownPermission(permission) {
+			panic(fmt.Sprintf("middleware: unknown permission %q", permission))
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		permission := resolve(c)
+		if permission == "" {
+			return c.Next()
+		}
+
+		var role models.Role
+		if roleName, _ := c.Locals("userRole").(string); roleName != "" {
+			err := config.DB.Select("permissions").Where("name = ?", roleName).First(&role).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check permissions",
+				})
+			}
+		}
+
+		// Users without a role, or with a role that no longer exists, have no permissions
+		if !role.Permissions.Has(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Permission required: " + permission,
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// SalesAssociateRequired allows the request only for users linked to a sales associate. It
+// must run after AuthRequired, which puts the linked associate in Locals("salesAssociateID").
+// Portal handlers scope every query to that associate.
+func SalesAssociateRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if id, _ := c.Locals("salesAssociateID").(string); id == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "User is not linked to a sales associate",
+			})
+		}
+		return c.Next()
+	}
+}
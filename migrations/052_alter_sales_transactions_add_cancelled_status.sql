@@ -0,0 +1,13 @@
+-- UP
+-- Migration: Add cancelled status to sales_transactions
+-- Description: Cancelled transactions keep their document but return their stock
+--   0 = booking, 1 = paid-off, 2 = installment, 3 = cancelled
+
+ALTER TABLE sales_transactions DROP CONSTRAINT IF EXISTS sales_transactions_status_check;
+ALTER TABLE sales_transactions ADD CONSTRAINT sales_transactions_status_check CHECK (status IN (0, 1, 2, 3));
+
+COMMENT ON COLUMN sales_transactions.status IS 'Status: 0=booking, 1=paid-off, 2=installment, 3=cancelled';
+
+-- DOWN
+-- ALTER TABLE sales_transactions DROP CONSTRAINT IF EXISTS sales_transactions_status_check;
+-- ALTER TABLE sales_transactions ADD CONSTRAINT sales_transactions_status_check CHECK (status IN (0, 1, 2));
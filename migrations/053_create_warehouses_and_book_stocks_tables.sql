@@ -0,0 +1,93 @@
+-- UP
+-- Migration: Create warehouses, book_stocks and stock_transfers tables
+-- Description: Stock is held per book per warehouse
+--   - books.stock is kept as the total across all warehouses
+--   - Existing stock is moved into the default (Jakarta) warehouse
+--   - Purchases receive into a warehouse, sales ship from a warehouse
+
+CREATE TABLE IF NOT EXISTS warehouses (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    description TEXT,
+    address TEXT,
+    city_id UUID REFERENCES cities(id) ON DELETE SET NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Only one default warehouse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_is_default ON warehouses(is_default) WHERE is_default;
+CREATE INDEX IF NOT EXISTS idx_warehouses_city_id ON warehouses(city_id);
+
+CREATE TABLE IF NOT EXISTS book_stocks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (book_id, warehouse_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_stocks_warehouse_id ON book_stocks(warehouse_id);
+
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    no_transfer VARCHAR(50) UNIQUE NOT NULL,
+    from_warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    to_warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    transfer_date DATE NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0 CHECK (status IN (0, 1, 2)),
+    note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_transfer_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    stock_transfer_id UUID NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_status ON stock_transfers(status);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_items_transfer_id ON stock_transfer_items(stock_transfer_id);
+
+ALTER TABLE sales_transactions ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id) ON DELETE RESTRICT;
+ALTER TABLE purchase_transactions ADD COLUMN IF NOT EXISTS warehouse_id UUID REFERENCES warehouses(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_sales_transactions_warehouse_id ON sales_transactions(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_transactions_warehouse_id ON purchase_transactions(warehouse_id);
+
+-- Default warehouse, existing stock lives here
+INSERT INTO warehouses (code, name, city_id, is_default)
+SELECT 'GDG-JKT', 'Gudang Jakarta', (SELECT id FROM cities WHERE name ILIKE '%jakarta%' ORDER BY name LIMIT 1), TRUE
+WHERE NOT EXISTS (SELECT 1 FROM warehouses WHERE is_default);
+
+INSERT INTO book_stocks (book_id, warehouse_id, quantity)
+SELECT b.id, w.id, b.stock
+FROM books b, warehouses w
+WHERE w.is_default AND b.stock > 0
+ON CONFLICT (book_id, warehouse_id) DO NOTHING;
+
+UPDATE sales_transactions SET warehouse_id = (SELECT id FROM warehouses WHERE is_default) WHERE warehouse_id IS NULL;
+UPDATE purchase_transactions SET warehouse_id = (SELECT id FROM warehouses WHERE is_default) WHERE warehouse_id IS NULL;
+
+COMMENT ON TABLE warehouses IS 'Stock locations (central warehouse and regional depots)';
+COMMENT ON COLUMN warehouses.is_default IS 'Warehouse used when a document does not name one';
+COMMENT ON TABLE book_stocks IS 'Stock per book per warehouse (books.stock is the total)';
+COMMENT ON TABLE stock_transfers IS 'Stock transfer documents between warehouses (TRF prefix)';
+COMMENT ON COLUMN stock_transfers.status IS 'Status: 0=pending, 1=completed, 2=cancelled';
+
+-- DOWN
+-- ALTER TABLE purchase_transactions DROP COLUMN IF EXISTS warehouse_id;
+-- ALTER TABLE sales_transactions DROP COLUMN IF EXISTS warehouse_id;
+-- DROP TABLE IF EXISTS stock_transfer_items;
+-- DROP TABLE IF EXISTS stock_transfers;
+-- DROP TABLE IF EXISTS book_stocks;
+-- DROP TABLE IF EXISTS warehouses;
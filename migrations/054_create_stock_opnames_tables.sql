@@ -0,0 +1,60 @@
+-- UP
+-- Migration: Create stock_opnames, stock_opname_items and stock_adjustments tables
+-- Description: Physical stock count (stock opname) sessions per warehouse
+--   - Opening a session snapshots the system stock of the selected books
+--   - Counted quantities are entered per book and compared against the snapshot
+--   - Posting turns approved variances into stock_adjustments with a reason
+
+CREATE TABLE IF NOT EXISTS stock_opnames (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    no_opname VARCHAR(50) UNIQUE NOT NULL,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    merk_buku_id UUID REFERENCES merk_buku(id) ON DELETE SET NULL,
+    jenjang_studi_id UUID REFERENCES jenjang_studi(id) ON DELETE SET NULL,
+    opname_date DATE NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0 CHECK (status IN (0, 1, 2)),
+    note TEXT,
+    reason TEXT,
+    posted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS stock_opname_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    stock_opname_id UUID NOT NULL REFERENCES stock_opnames(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    system_quantity INTEGER NOT NULL,
+    counted_quantity INTEGER CHECK (counted_quantity >= 0),
+    approved BOOLEAN NOT NULL DEFAULT FALSE,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (stock_opname_id, book_id)
+);
+
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    stock_opname_id UUID REFERENCES stock_opnames(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL CHECK (quantity <> 0),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_opnames_status ON stock_opnames(status);
+CREATE INDEX IF NOT EXISTS idx_stock_opnames_warehouse_id ON stock_opnames(warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_stock_opname_items_opname_id ON stock_opname_items(stock_opname_id);
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_book_id ON stock_adjustments(book_id);
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_opname_id ON stock_adjustments(stock_opname_id);
+
+COMMENT ON TABLE stock_opnames IS 'Physical stock count sessions (OPN prefix)';
+COMMENT ON COLUMN stock_opnames.status IS 'Status: 0=open, 1=posted, 2=cancelled';
+COMMENT ON COLUMN stock_opname_items.system_quantity IS 'Warehouse stock when the session was opened';
+COMMENT ON TABLE stock_adjustments IS 'Manual stock changes with a reason, e.g. posted stock opname variances';
+
+-- DOWN
+-- DROP TABLE IF EXISTS stock_adjustments;
+-- DROP TABLE IF EXISTS stock_opname_items;
+-- DROP TABLE IF EXISTS stock_opnames;
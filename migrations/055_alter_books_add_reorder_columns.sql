@@ -0,0 +1,14 @@
+-- UP
+-- Migration: Add reorder point columns to books
+-- Description: min_stock is the reorder point and reorder_quantity the minimum order size.
+--   Both are used together with recent sales velocity to suggest purchase orders.
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS min_stock INTEGER NOT NULL DEFAULT 0 CHECK (min_stock >= 0);
+ALTER TABLE books ADD COLUMN IF NOT EXISTS reorder_quantity INTEGER NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
+
+COMMENT ON COLUMN books.min_stock IS 'Reorder point: restock when available stock falls to this level (0 = not managed)';
+COMMENT ON COLUMN books.reorder_quantity IS 'Minimum quantity to order when restocking';
+
+-- DOWN
+-- ALTER TABLE books DROP COLUMN IF EXISTS reorder_quantity;
+-- ALTER TABLE books DROP COLUMN IF EXISTS min_stock;
@@ -0,0 +1,20 @@
+-- UP
+-- Migration: Add backordered_quantity to sales_transaction_items
+-- Description: Orders may exceed available stock. The available part is taken from stock,
+--   the rest is backordered and allocated oldest first when purchases are completed.
+
+ALTER TABLE sales_transaction_items ADD COLUMN IF NOT EXISTS backordered_quantity INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE sales_transaction_items DROP CONSTRAINT IF EXISTS sales_transaction_items_backordered_quantity_check;
+ALTER TABLE sales_transaction_items ADD CONSTRAINT sales_transaction_items_backordered_quantity_check
+    CHECK (backordered_quantity >= 0 AND backordered_quantity <= quantity);
+
+CREATE INDEX IF NOT EXISTS idx_sales_transaction_items_backordered
+    ON sales_transaction_items(book_id) WHERE backordered_quantity > 0;
+
+COMMENT ON COLUMN sales_transaction_items.backordered_quantity IS 'Part of quantity still owed to the customer, not yet taken from stock';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_sales_transaction_items_backordered;
+-- ALTER TABLE sales_transaction_items DROP CONSTRAINT IF EXISTS sales_transaction_items_backordered_quantity_check;
+-- ALTER TABLE sales_transaction_items DROP COLUMN IF EXISTS backordered_quantity;
@@ -0,0 +1,57 @@
+-- UP
+-- Migration: Create goods_receipts tables and track received quantities on purchases
+-- Description: Purchases can be delivered in several batches
+--   - Each delivery is a goods receipt (GRN prefix) that increases stock
+--   - purchase_transaction_items.received_quantity tracks received versus ordered
+--   - Status 3 = partially received, 4 = closed with a shortfall
+
+ALTER TABLE purchase_transaction_items ADD COLUMN IF NOT EXISTS received_quantity INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE purchase_transaction_items DROP CONSTRAINT IF EXISTS purchase_transaction_items_received_quantity_check;
+ALTER TABLE purchase_transaction_items ADD CONSTRAINT purchase_transaction_items_received_quantity_check
+    CHECK (received_quantity >= 0 AND received_quantity <= quantity);
+
+-- Completed purchases received everything in one step
+UPDATE purchase_transaction_items pti
+SET received_quantity = pti.quantity
+FROM purchase_transactions pt
+WHERE pt.id = pti.purchase_transaction_id AND pt.status = 1;
+
+ALTER TABLE purchase_transactions DROP CONSTRAINT IF EXISTS purchase_transactions_status_check;
+ALTER TABLE purchase_transactions ADD CONSTRAINT purchase_transactions_status_check CHECK (status IN (0, 1, 2, 3, 4));
+
+CREATE TABLE IF NOT EXISTS goods_receipts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    no_receipt VARCHAR(50) UNIQUE NOT NULL,
+    purchase_transaction_id UUID NOT NULL REFERENCES purchase_transactions(id) ON DELETE CASCADE,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+    receipt_date DATE NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS goods_receipt_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    goods_receipt_id UUID NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    purchase_transaction_item_id UUID NOT NULL REFERENCES purchase_transaction_items(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_goods_receipts_purchase_transaction_id ON goods_receipts(purchase_transaction_id);
+CREATE INDEX IF NOT EXISTS idx_goods_receipt_items_goods_receipt_id ON goods_receipt_items(goods_receipt_id);
+
+COMMENT ON COLUMN purchase_transactions.status IS 'Status: 0=pending, 1=completed, 2=cancelled, 3=partially received, 4=closed short';
+COMMENT ON COLUMN purchase_transaction_items.received_quantity IS 'Quantity received so far through goods receipts';
+COMMENT ON TABLE goods_receipts IS 'Deliveries received against a purchase transaction (GRN prefix)';
+
+-- DOWN
+-- DROP TABLE IF EXISTS goods_receipt_items;
+-- DROP TABLE IF EXISTS goods_receipts;
+-- ALTER TABLE purchase_transactions DROP CONSTRAINT IF EXISTS purchase_transactions_status_check;
+-- ALTER TABLE purchase_transactions ADD CONSTRAINT purchase_transactions_status_check CHECK (status IN (0, 1, 2));
+-- ALTER TABLE purchase_transaction_items DROP CONSTRAINT IF EXISTS purchase_transaction_items_received_quantity_check;
+-- ALTER TABLE purchase_transaction_items DROP COLUMN IF EXISTS received_quantity;
@@ -0,0 +1,38 @@
+-- UP
+-- Migration: Add weighted average cost to books and cost of goods sold to sales items
+-- Description: Inventory is valued at a moving weighted average purchase cost
+--   - books.average_cost is recalculated whenever purchased goods are received
+--   - sales_transaction_items.unit_cost keeps the average cost at sale time
+--   - sales_transaction_items.cogs = unit_cost * quantity
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS average_cost NUMERIC(15, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE sales_transaction_items ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(15, 2) NOT NULL DEFAULT 0;
+ALTER TABLE sales_transaction_items ADD COLUMN IF NOT EXISTS cogs NUMERIC(15, 2) NOT NULL DEFAULT 0;
+
+-- Start from the average price of everything received so far
+UPDATE books b
+SET average_cost = received.average_cost
+FROM (
+    SELECT pti.book_id, ROUND(SUM(pti.price * pti.received_quantity) / SUM(pti.received_quantity), 2) AS average_cost
+    FROM purchase_transaction_items pti
+    WHERE pti.received_quantity > 0
+    GROUP BY pti.book_id
+) received
+WHERE received.book_id = b.id;
+
+-- Past sales are costed at that starting average
+UPDATE sales_transaction_items sti
+SET unit_cost = b.average_cost,
+    cogs = ROUND(b.average_cost * sti.quantity, 2)
+FROM books b
+WHERE b.id = sti.book_id;
+
+COMMENT ON COLUMN books.average_cost IS 'Moving weighted average purchase cost per unit';
+COMMENT ON COLUMN sales_transaction_items.unit_cost IS 'Average cost of the book at sale time';
+COMMENT ON COLUMN sales_transaction_items.cogs IS 'Cost of goods sold: unit_cost * quantity';
+
+-- DOWN
+-- ALTER TABLE sales_transaction_items DROP COLUMN IF EXISTS cogs;
+-- ALTER TABLE sales_transaction_items DROP COLUMN IF EXISTS unit_cost;
+-- ALTER TABLE books DROP COLUMN IF EXISTS average_cost;
@@ -0,0 +1,15 @@
+-- UP
+-- Migration: Link books to their previous edition
+-- Description: Each school year a catalog subset is rolled into a new year and periode
+--   - The new book row points at the row it was rolled from
+--   - Sales can then be compared across editions of the same title
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS previous_edition_id UUID REFERENCES books(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_books_previous_edition_id ON books(previous_edition_id);
+
+COMMENT ON COLUMN books.previous_edition_id IS 'Book row this edition was rolled from';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_books_previous_edition_id;
+-- ALTER TABLE books DROP COLUMN IF EXISTS previous_edition_id;
@@ -0,0 +1,22 @@
+-- UP
+-- Migration: Add a unique book code (SKU) and make ISBN searchable
+-- Description: Books can be looked up by scanning a barcode
+--   - code is the internal SKU, unique when set
+--   - isbn is stored without hyphens or spaces so scans match exactly
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS code VARCHAR(50);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_books_code ON books(code) WHERE code IS NOT NULL;
+
+UPDATE books SET isbn = UPPER(REGEXP_REPLACE(isbn, '[-[:space:]]', '', 'g')) WHERE isbn IS NOT NULL;
+UPDATE books SET isbn = NULL WHERE isbn = '';
+
+CREATE INDEX IF NOT EXISTS idx_books_isbn ON books(isbn);
+
+COMMENT ON COLUMN books.code IS 'Internal book code (SKU), unique when set';
+COMMENT ON COLUMN books.isbn IS 'ISBN-10 or ISBN-13 without hyphens';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_books_isbn;
+-- DROP INDEX IF EXISTS idx_books_code;
+-- ALTER TABLE books DROP COLUMN IF EXISTS code;
@@ -0,0 +1,87 @@
+-- UP
+-- Migration: Full-text search over books
+-- Description: Replace the ILIKE search of the book catalog with a ranked tsvector search
+--   - pustaka_indonesian is a copy of the built-in indonesian snowball configuration (PostgreSQL 12+),
+--     or of simple on older servers. Stopwords are removed by the application before querying.
+--   - search_vector is weighted: name, isbn and code (A), author (B), bidang studi and jenjang
+--     studi names (C), description (D)
+--   - Renaming a bidang studi or jenjang studi refreshes the books that use it
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'pustaka_indonesian') THEN
+        IF EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'indonesian') THEN
+            CREATE TEXT SEARCH CONFIGURATION pustaka_indonesian (COPY = pg_catalog.indonesian);
+        ELSE
+            CREATE TEXT SEARCH CONFIGURATION pustaka_indonesian (COPY = pg_catalog.simple);
+        END IF;
+    END IF;
+END
+$$;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+CREATE OR REPLACE FUNCTION books_search_vector_refresh() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('pustaka_indonesian', COALESCE(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(NEW.isbn, '') || ' ' || COALESCE(NEW.code, '')), 'A') ||
+        setweight(to_tsvector('pustaka_indonesian', COALESCE(NEW.author, '')), 'B') ||
+        setweight(to_tsvector('pustaka_indonesian',
+            COALESCE((SELECT name FROM bidang_studi WHERE id = NEW.bidang_studi_id), '') || ' ' ||
+            COALESCE((SELECT name FROM jenjang_studi WHERE id = NEW.jenjang_studi_id), '')), 'C') ||
+        setweight(to_tsvector('pustaka_indonesian', COALESCE(NEW.description, '')), 'D');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_books_search_vector ON books;
+CREATE TRIGGER trg_books_search_vector
+    BEFORE INSERT OR UPDATE OF name, description, author, isbn, code, bidang_studi_id, jenjang_studi_id
+    ON books
+    FOR EACH ROW EXECUTE FUNCTION books_search_vector_refresh();
+
+-- Listing a column in SET fires the column trigger on books even when the value is unchanged
+CREATE OR REPLACE FUNCTION books_search_vector_refresh_bidang_studi() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE books SET bidang_studi_id = bidang_studi_id WHERE bidang_studi_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_bidang_studi_books_search_vector ON bidang_studi;
+CREATE TRIGGER trg_bidang_studi_books_search_vector
+    AFTER UPDATE OF name ON bidang_studi
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION books_search_vector_refresh_bidang_studi();
+
+CREATE OR REPLACE FUNCTION books_search_vector_refresh_jenjang_studi() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE books SET jenjang_studi_id = jenjang_studi_id WHERE jenjang_studi_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_jenjang_studi_books_search_vector ON jenjang_studi;
+CREATE TRIGGER trg_jenjang_studi_books_search_vector
+    AFTER UPDATE OF name ON jenjang_studi
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION books_search_vector_refresh_jenjang_studi();
+
+-- Fill the vector of existing books
+UPDATE books SET name = name;
+
+CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING GIN (search_vector);
+
+COMMENT ON COLUMN books.search_vector IS 'Weighted full-text search document, maintained by trg_books_search_vector';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_books_search_vector;
+-- DROP TRIGGER IF EXISTS trg_jenjang_studi_books_search_vector ON jenjang_studi;
+-- DROP TRIGGER IF EXISTS trg_bidang_studi_books_search_vector ON bidang_studi;
+-- DROP TRIGGER IF EXISTS trg_books_search_vector ON books;
+-- DROP FUNCTION IF EXISTS books_search_vector_refresh_jenjang_studi();
+-- DROP FUNCTION IF EXISTS books_search_vector_refresh_bidang_studi();
+-- DROP FUNCTION IF EXISTS books_search_vector_refresh();
+-- ALTER TABLE books DROP COLUMN IF EXISTS search_vector;
+-- DROP TEXT SEARCH CONFIGURATION IF EXISTS pustaka_indonesian;
@@ -0,0 +1,47 @@
+-- UP
+-- Migration: Create book_bundles tables and link sales lines to the bundle they came from
+-- Description: Schools order class packages (paket buku) instead of single titles
+--   - A bundle lists books with a quantity per package and has its own price
+--   - A bundle line on a sale is stored as one sales_transaction_items row per component,
+--     with the bundle price spread over the components
+--   - bundle_quantity is the number of packages sold on the line
+
+CREATE TABLE IF NOT EXISTS book_bundles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price NUMERIC(15, 2) NOT NULL CHECK (price >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS book_bundle_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    bundle_id UUID NOT NULL REFERENCES book_bundles(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (bundle_id, book_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_book_bundle_items_bundle_id ON book_bundle_items(bundle_id);
+CREATE INDEX IF NOT EXISTS idx_book_bundle_items_book_id ON book_bundle_items(book_id);
+
+ALTER TABLE sales_transaction_items ADD COLUMN IF NOT EXISTS bundle_id UUID REFERENCES book_bundles(id) ON DELETE RESTRICT;
+ALTER TABLE sales_transaction_items ADD COLUMN IF NOT EXISTS bundle_quantity INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_sales_transaction_items_bundle_id ON sales_transaction_items(bundle_id);
+
+COMMENT ON TABLE book_bundles IS 'Class packages (paket buku) sold as a single line';
+COMMENT ON COLUMN sales_transaction_items.bundle_id IS 'Bundle this component line was expanded from, NULL for single titles';
+COMMENT ON COLUMN sales_transaction_items.bundle_quantity IS 'Number of bundles sold on the line the component belongs to';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_sales_transaction_items_bundle_id;
+-- ALTER TABLE sales_transaction_items DROP COLUMN IF EXISTS bundle_quantity;
+-- ALTER TABLE sales_transaction_items DROP COLUMN IF EXISTS bundle_id;
+-- DROP TABLE IF EXISTS book_bundle_items;
+-- DROP TABLE IF EXISTS book_bundles;
@@ -0,0 +1,46 @@
+-- UP
+-- Migration: Create roles table with permission sets
+-- Description: Replaces the admin/user split with roles that grant permissions
+--   - users.role holds the role name
+--   - A permission is "<resource>:<action>", e.g. payments:delete; "*" grants everything
+--   - System roles (admin) cannot be changed or deleted through the API
+--   - The former 'user' role keeps its access (everything except users and roles) and
+--     'operator' users are moved to it
+
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO roles (name, description, permissions, is_system) VALUES
+('admin', 'Full access, including users and roles', '{*}', TRUE),
+('finance', 'Payments and discount rates, read access to sales and purchases',
+    '{master_data:read,books:read,sales:read,payments:read,payments:write,payments:delete,shippings:read,discount_rates:read,discount_rates:write,discount_rates:delete,purchases:read,inventory:read,reports:read}', FALSE),
+('warehouse', 'Stock, goods receipts and shipping',
+    '{master_data:read,books:read,books:write,sales:read,shippings:read,shippings:write,shippings:delete,purchases:read,purchases:write,inventory:read,inventory:write,inventory:delete,reports:read}', FALSE),
+('sales', 'Sales orders and customers',
+    '{master_data:read,master_data:write,books:read,sales:read,sales:write,payments:read,shippings:read,discount_rates:read,inventory:read,reports:read}', FALSE),
+('viewer', 'Read-only access',
+    '{master_data:read,books:read,sales:read,payments:read,shippings:read,discount_rates:read,purchases:read,inventory:read,reports:read}', FALSE),
+('user', 'Former staff role: everything except users and roles',
+    '{master_data:read,master_data:write,master_data:delete,books:read,books:write,books:delete,sales:read,sales:write,sales:delete,payments:read,payments:write,payments:delete,shippings:read,shippings:write,shippings:delete,discount_rates:read,discount_rates:write,discount_rates:delete,purchases:read,purchases:write,purchases:delete,inventory:read,inventory:write,inventory:delete,reports:read}', FALSE)
+ON CONFLICT (name) DO NOTHING;
+
+UPDATE users SET role = 'user' WHERE role IS NULL OR role NOT IN (SELECT name FROM roles);
+
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE ON DELETE RESTRICT;
+
+COMMENT ON TABLE roles IS 'User roles and the permissions they grant';
+COMMENT ON COLUMN roles.permissions IS 'Granted permissions as resource:action, * grants everything';
+COMMENT ON COLUMN users.role IS 'Name of the role in roles';
+
+-- DOWN
+-- ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_role;
+-- ALTER TABLE users ALTER COLUMN role DROP NOT NULL;
+-- DROP TABLE IF EXISTS roles;
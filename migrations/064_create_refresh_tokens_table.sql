@@ -0,0 +1,37 @@
+-- UP
+-- Migration: Create refresh_tokens table and add users.token_version
+-- Description: Short-lived access tokens are renewed with rotating refresh tokens
+--   - Only the SHA-256 hash of a refresh token is stored
+--   - Each refresh replaces the token with a new one in the same family (one family per login)
+--   - Reusing a replaced token revokes the whole family
+--   - Access tokens carry users.token_version; raising it logs the user out everywhere
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    token_version INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    replaced_by_id UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    user_agent TEXT,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+COMMENT ON TABLE refresh_tokens IS 'Refresh tokens of login sessions, stored as hashes';
+COMMENT ON COLUMN refresh_tokens.family_id IS 'Login session the token belongs to, shared by all rotated tokens';
+COMMENT ON COLUMN refresh_tokens.token_version IS 'users.token_version when the token was issued';
+COMMENT ON COLUMN users.token_version IS 'Raised to revoke all access and refresh tokens of the user';
+
+-- DOWN
+-- DROP TABLE IF EXISTS refresh_tokens;
+-- ALTER TABLE users DROP COLUMN IF EXISTS token_version;
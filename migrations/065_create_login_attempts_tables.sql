@@ -0,0 +1,38 @@
+-- UP
+-- Migration: Create login_attempts and login_lockouts tables
+-- Description: Brute-force protection for POST /api/auth/login
+--   - login_attempts is the audit trail of every login, successful or not
+--   - login_lockouts counts recent failures per key ("email:<email>" or "ip:<address>") and
+--     holds the lockout, which doubles with every failure past the limit
+--   - Single-node setups can keep this in memory instead (LOGIN_ATTEMPT_STORE=memory)
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    user_agent TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts(ip_address);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE login_attempts IS 'Audit trail of login attempts';
+COMMENT ON COLUMN login_attempts.reason IS 'success, invalid_credentials or locked';
+COMMENT ON TABLE login_lockouts IS 'Recent failed logins and lockouts per email and per IP address';
+
+-- DOWN
+-- DROP TABLE IF EXISTS login_lockouts;
+-- DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,24 @@
+-- UP
+-- Migration: Create password_reset_tokens table
+-- Description: Self-service password reset (POST /api/auth/forgot-password and /reset-password)
+--   - The token is emailed to the user; only its SHA-256 hash is stored
+--   - Tokens expire after PASSWORD_RESET_EXPIRE_MINUTES (default 30) and can be used once
+--   - Requesting a new token or resetting the password invalidates the older tokens
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+COMMENT ON TABLE password_reset_tokens IS 'One-time password reset tokens, stored as hashes';
+COMMENT ON COLUMN password_reset_tokens.used_at IS 'Set when the token is used or replaced by a newer one';
+
+-- DOWN
+-- DROP TABLE IF EXISTS password_reset_tokens;
@@ -0,0 +1,48 @@
+-- UP
+-- Migration: Add TOTP two-factor authentication
+-- Description: Optional second login step with an authenticator app (RFC 6238)
+--   - users.two_factor_secret is set on enrollment and only used once two_factor_enabled is set
+--     by confirming a code
+--   - Recovery codes are single-use and stored as SHA-256 hashes
+--   - With 2FA enabled, POST /api/auth/login returns a login challenge instead of tokens; the
+--     challenge is exchanged for tokens at POST /api/auth/2fa/verify
+--   - Admin accounts can only use the API after enrolling
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user_id ON login_challenges(user_id);
+
+COMMENT ON COLUMN users.two_factor_last_step IS 'TOTP time step of the last accepted code, older or equal steps are refused';
+COMMENT ON TABLE user_recovery_codes IS 'Single-use 2FA recovery codes, stored as hashes';
+COMMENT ON TABLE login_challenges IS 'Second login step of users with 2FA, valid for a few minutes';
+COMMENT ON COLUMN login_attempts.reason IS 'success, invalid_credentials, locked, two_factor_required or invalid_two_factor';
+
+-- DOWN
+-- DROP TABLE IF EXISTS login_challenges;
+-- DROP TABLE IF EXISTS user_recovery_codes;
+-- ALTER TABLE users DROP COLUMN IF EXISTS two_factor_last_step;
+-- ALTER TABLE users DROP COLUMN IF EXISTS two_factor_enabled;
+-- ALTER TABLE users DROP COLUMN IF EXISTS two_factor_secret;
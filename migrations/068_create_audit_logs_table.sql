@@ -0,0 +1,29 @@
+-- UP
+-- Migration: Create audit_logs table
+-- Description: Who created, changed or deleted which row, and what changed
+--   - Written by GORM callbacks for every table except the audit and login security tables
+--   - entity_type is the table name, entity_id the primary key of the row
+--   - For updates before/after only hold the changed columns; secrets are redacted
+--   - user_id is NULL for changes made outside a request (seeds, scripts)
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(10) NOT NULL,
+    entity_type VARCHAR(100) NOT NULL,
+    entity_id VARCHAR(100) NOT NULL,
+    before JSONB,
+    after JSONB,
+    ip_address VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs(user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+
+COMMENT ON TABLE audit_logs IS 'Audit trail of created, updated and deleted rows';
+COMMENT ON COLUMN audit_logs.action IS 'create, update or delete';
+
+-- DOWN
+-- DROP TABLE IF EXISTS audit_logs;
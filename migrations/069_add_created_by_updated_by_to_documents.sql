@@ -0,0 +1,37 @@
+-- UP
+-- Migration: Record who entered and last changed business documents
+-- Description: Sales transactions, purchase transactions, payments and shippings keep the user
+--   of the access token that created them and that made the last change
+--   - Filled in by the application from the logged in user
+--   - Kept when the user is deleted, the reference is cleared instead
+
+ALTER TABLE sales_transactions ADD COLUMN IF NOT EXISTS created_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE sales_transactions ADD COLUMN IF NOT EXISTS updated_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE purchase_transactions ADD COLUMN IF NOT EXISTS created_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE purchase_transactions ADD COLUMN IF NOT EXISTS updated_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS created_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS updated_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE shippings ADD COLUMN IF NOT EXISTS created_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE shippings ADD COLUMN IF NOT EXISTS updated_by_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sales_transactions_created_by_id ON sales_transactions(created_by_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_transactions_created_by_id ON purchase_transactions(created_by_id);
+CREATE INDEX IF NOT EXISTS idx_payments_created_by_id ON payments(created_by_id);
+CREATE INDEX IF NOT EXISTS idx_shippings_created_by_id ON shippings(created_by_id);
+
+COMMENT ON COLUMN sales_transactions.created_by_id IS 'User who booked the order';
+COMMENT ON COLUMN sales_transactions.updated_by_id IS 'User who made the last change';
+
+-- DOWN
+-- DROP INDEX IF EXISTS idx_shippings_created_by_id;
+-- DROP INDEX IF EXISTS idx_payments_created_by_id;
+-- DROP INDEX IF EXISTS idx_purchase_transactions_created_by_id;
+-- DROP INDEX IF EXISTS idx_sales_transactions_created_by_id;
+-- ALTER TABLE shippings DROP COLUMN IF EXISTS updated_by_id;
+-- ALTER TABLE shippings DROP COLUMN IF EXISTS created_by_id;
+-- ALTER TABLE payments DROP COLUMN IF EXISTS updated_by_id;
+-- ALTER TABLE payments DROP COLUMN IF EXISTS created_by_id;
+-- ALTER TABLE purchase_transactions DROP COLUMN IF EXISTS updated_by_id;
+-- ALTER TABLE purchase_transactions DROP COLUMN IF EXISTS created_by_id;
+-- ALTER TABLE sales_transactions DROP COLUMN IF EXISTS updated_by_id;
+-- ALTER TABLE sales_transactions DROP COLUMN IF EXISTS created_by_id;
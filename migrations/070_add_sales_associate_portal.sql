@@ -0,0 +1,65 @@
+-- UP
+-- Migration: Link users to sales associates and add draft sales orders
+-- Description: Field sales associates log in to a self-service portal
+--   - users.sales_associate_id links a user account to a sales associate
+--   - The sales_associate role only grants portal:access; every portal query is scoped to the
+--     linked associate
+--   - Associates submit draft orders (ORD prefix) that staff approve into a sales transaction
+--     or reject with a reason
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sales_associate_id UUID REFERENCES sales_associates(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_sales_associate_id ON users(sales_associate_id);
+
+INSERT INTO roles (name, description, permissions, is_system) VALUES
+('sales_associate', 'Field sales associate: own transactions, payments, credits and statement, and draft orders through the portal',
+    '{portal:access}', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS sales_order_drafts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    no_order VARCHAR(50) UNIQUE NOT NULL,
+    sales_associate_id UUID NOT NULL REFERENCES sales_associates(id) ON DELETE CASCADE,
+    payment_type VARCHAR(1) NOT NULL DEFAULT 'T' CHECK (payment_type IN ('T', 'K')),
+    periode INTEGER NOT NULL DEFAULT 1,
+    year VARCHAR(4) NOT NULL,
+    curriculum_id UUID REFERENCES curriculum(id) ON DELETE SET NULL,
+    merk_buku_id UUID REFERENCES merk_buku(id) ON DELETE SET NULL,
+    jenjang_studi_id UUID REFERENCES jenjang_studi(id) ON DELETE SET NULL,
+    note TEXT,
+    status INTEGER NOT NULL DEFAULT 0 CHECK (status IN (0, 1, 2)),
+    sales_transaction_id UUID REFERENCES sales_transactions(id) ON DELETE SET NULL,
+    rejection_reason TEXT,
+    reviewed_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    created_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_by_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sales_order_draft_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    draft_id UUID NOT NULL REFERENCES sales_order_drafts(id) ON DELETE CASCADE,
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_order_drafts_sales_associate_id ON sales_order_drafts(sales_associate_id);
+CREATE INDEX IF NOT EXISTS idx_sales_order_drafts_status ON sales_order_drafts(status);
+CREATE INDEX IF NOT EXISTS idx_sales_order_draft_items_draft_id ON sales_order_draft_items(draft_id);
+
+COMMENT ON COLUMN users.sales_associate_id IS 'Sales associate the user logs in as on the portal';
+COMMENT ON TABLE sales_order_drafts IS 'Orders submitted by sales associates, waiting for staff approval (ORD prefix)';
+COMMENT ON COLUMN sales_order_drafts.status IS 'Status: 0=pending, 1=approved, 2=rejected';
+COMMENT ON COLUMN sales_order_drafts.sales_transaction_id IS 'Sales transaction created on approval';
+
+-- DOWN
+-- DROP TABLE IF EXISTS sales_order_draft_items;
+-- DROP TABLE IF EXISTS sales_order_drafts;
+-- UPDATE users SET role = 'user' WHERE role = 'sales_associate';
+-- DELETE FROM roles WHERE name = 'sales_associate';
+-- DROP INDEX IF EXISTS idx_users_sales_associate_id;
+-- ALTER TABLE users DROP COLUMN IF EXISTS sales_associate_id;
@@ -0,0 +1,204 @@
+-- UP
+-- Migration: Soft delete master data
+-- Description: Deleting a master record sets deleted_at instead of removing the row, so the
+--   documents that point at it keep their history and the record can be restored
+--   - Lists and lookups skip deleted rows; the APIs can include them on request
+--   - Codes and names only have to be unique among rows that are not deleted, so a deleted
+--     record does not block creating a new one with the same code
+
+ALTER TABLE cities ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE expeditions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE merk_buku ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE jenis_buku ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE jenjang_studi ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE bidang_studi ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE kelas ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE publishers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE sales_associates ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE billers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE curriculum ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE warehouses ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Unique among rows that are not deleted. Earlier migrations created plain indexes under the
+-- same names, which would make CREATE ... IF NOT EXISTS a no-op, so they are dropped first.
+ALTER TABLE cities DROP CONSTRAINT IF EXISTS cities_code_key;
+ALTER TABLE cities DROP CONSTRAINT IF EXISTS cities_name_key;
+DROP INDEX IF EXISTS idx_cities_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cities_code ON cities(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_cities_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cities_name ON cities(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE expeditions DROP CONSTRAINT IF EXISTS expeditions_code_key;
+ALTER TABLE expeditions DROP CONSTRAINT IF EXISTS expeditions_name_key;
+DROP INDEX IF EXISTS idx_expeditions_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_expeditions_code ON expeditions(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_expeditions_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_expeditions_name ON expeditions(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE merk_buku DROP CONSTRAINT IF EXISTS merk_buku_code_key;
+ALTER TABLE merk_buku DROP CONSTRAINT IF EXISTS merk_buku_name_key;
+DROP INDEX IF EXISTS idx_merk_buku_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_merk_buku_code ON merk_buku(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_merk_buku_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_merk_buku_name ON merk_buku(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE jenis_buku DROP CONSTRAINT IF EXISTS jenis_buku_code_key;
+ALTER TABLE jenis_buku DROP CONSTRAINT IF EXISTS jenis_buku_name_key;
+DROP INDEX IF EXISTS idx_jenis_buku_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jenis_buku_code ON jenis_buku(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_jenis_buku_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jenis_buku_name ON jenis_buku(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE jenjang_studi DROP CONSTRAINT IF EXISTS jenjang_studi_code_key;
+ALTER TABLE jenjang_studi DROP CONSTRAINT IF EXISTS jenjang_studi_name_key;
+DROP INDEX IF EXISTS idx_jenjang_studi_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jenjang_studi_code ON jenjang_studi(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_jenjang_studi_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jenjang_studi_name ON jenjang_studi(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE bidang_studi DROP CONSTRAINT IF EXISTS bidang_studi_code_key;
+ALTER TABLE bidang_studi DROP CONSTRAINT IF EXISTS bidang_studi_name_key;
+DROP INDEX IF EXISTS idx_bidang_studi_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bidang_studi_code ON bidang_studi(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_bidang_studi_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bidang_studi_name ON bidang_studi(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE kelas DROP CONSTRAINT IF EXISTS kelas_code_key;
+ALTER TABLE kelas DROP CONSTRAINT IF EXISTS kelas_name_key;
+DROP INDEX IF EXISTS idx_kelas_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kelas_code ON kelas(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_kelas_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kelas_name ON kelas(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE publishers DROP CONSTRAINT IF EXISTS publishers_code_key;
+ALTER TABLE publishers DROP CONSTRAINT IF EXISTS publishers_name_key;
+DROP INDEX IF EXISTS idx_publishers_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_publishers_code ON publishers(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_publishers_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_publishers_name ON publishers(name) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_books_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_books_code ON books(code) WHERE code IS NOT NULL AND deleted_at IS NULL;
+
+ALTER TABLE sales_associates DROP CONSTRAINT IF EXISTS sales_associates_code_key;
+DROP INDEX IF EXISTS idx_sales_associates_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_associates_code ON sales_associates(code) WHERE deleted_at IS NULL;
+
+ALTER TABLE billers DROP CONSTRAINT IF EXISTS billers_code_key;
+ALTER TABLE billers DROP CONSTRAINT IF EXISTS billers_name_key;
+ALTER TABLE billers DROP CONSTRAINT IF EXISTS billers_npwp_key;
+DROP INDEX IF EXISTS idx_billers_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_billers_code ON billers(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_billers_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_billers_name ON billers(name) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_billers_npwp;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_billers_npwp ON billers(npwp) WHERE deleted_at IS NULL;
+
+ALTER TABLE curriculum DROP CONSTRAINT IF EXISTS curriculum_code_key;
+ALTER TABLE curriculum DROP CONSTRAINT IF EXISTS curriculum_name_key;
+DROP INDEX IF EXISTS idx_curriculum_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_curriculum_code ON curriculum(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_curriculum_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_curriculum_name ON curriculum(name) WHERE deleted_at IS NULL;
+
+ALTER TABLE warehouses DROP CONSTRAINT IF EXISTS warehouses_code_key;
+ALTER TABLE warehouses DROP CONSTRAINT IF EXISTS warehouses_name_key;
+DROP INDEX IF EXISTS idx_warehouses_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_code ON warehouses(code) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS idx_warehouses_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_warehouses_name ON warehouses(name) WHERE deleted_at IS NULL;
+
+COMMENT ON COLUMN books.deleted_at IS 'When the book was deleted; NULL while it is in the catalog';
+COMMENT ON COLUMN sales_associates.deleted_at IS 'When the sales associate was deleted; NULL while active';
+
+-- DOWN
+-- Restoring the table wide unique constraints fails while a deleted row shares a code or name
+-- with another row
+-- DROP INDEX IF EXISTS idx_warehouses_name;
+-- CREATE INDEX IF NOT EXISTS idx_warehouses_name ON warehouses(name);
+-- DROP INDEX IF EXISTS idx_warehouses_code;
+-- CREATE INDEX IF NOT EXISTS idx_warehouses_code ON warehouses(code);
+-- ALTER TABLE warehouses ADD CONSTRAINT warehouses_code_key UNIQUE (code);
+-- ALTER TABLE warehouses ADD CONSTRAINT warehouses_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_curriculum_name;
+-- CREATE INDEX IF NOT EXISTS idx_curriculum_name ON curriculum(name);
+-- DROP INDEX IF EXISTS idx_curriculum_code;
+-- CREATE INDEX IF NOT EXISTS idx_curriculum_code ON curriculum(code);
+-- ALTER TABLE curriculum ADD CONSTRAINT curriculum_code_key UNIQUE (code);
+-- ALTER TABLE curriculum ADD CONSTRAINT curriculum_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_billers_npwp;
+-- CREATE INDEX IF NOT EXISTS idx_billers_npwp ON billers(npwp);
+-- DROP INDEX IF EXISTS idx_billers_name;
+-- CREATE INDEX IF NOT EXISTS idx_billers_name ON billers(name);
+-- DROP INDEX IF EXISTS idx_billers_code;
+-- CREATE INDEX IF NOT EXISTS idx_billers_code ON billers(code);
+-- ALTER TABLE billers ADD CONSTRAINT billers_code_key UNIQUE (code);
+-- ALTER TABLE billers ADD CONSTRAINT billers_name_key UNIQUE (name);
+-- ALTER TABLE billers ADD CONSTRAINT billers_npwp_key UNIQUE (npwp);
+-- DROP INDEX IF EXISTS idx_sales_associates_code;
+-- CREATE INDEX IF NOT EXISTS idx_sales_associates_code ON sales_associates(code);
+-- ALTER TABLE sales_associates ADD CONSTRAINT sales_associates_code_key UNIQUE (code);
+-- DROP INDEX IF EXISTS idx_books_code;
+-- CREATE UNIQUE INDEX IF NOT EXISTS idx_books_code ON books(code) WHERE code IS NOT NULL;
+-- DROP INDEX IF EXISTS idx_publishers_name;
+-- CREATE INDEX IF NOT EXISTS idx_publishers_name ON publishers(name);
+-- DROP INDEX IF EXISTS idx_publishers_code;
+-- CREATE INDEX IF NOT EXISTS idx_publishers_code ON publishers(code);
+-- ALTER TABLE publishers ADD CONSTRAINT publishers_code_key UNIQUE (code);
+-- ALTER TABLE publishers ADD CONSTRAINT publishers_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_kelas_name;
+-- CREATE INDEX IF NOT EXISTS idx_kelas_name ON kelas(name);
+-- DROP INDEX IF EXISTS idx_kelas_code;
+-- CREATE INDEX IF NOT EXISTS idx_kelas_code ON kelas(code);
+-- ALTER TABLE kelas ADD CONSTRAINT kelas_code_key UNIQUE (code);
+-- ALTER TABLE kelas ADD CONSTRAINT kelas_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_bidang_studi_name;
+-- CREATE INDEX IF NOT EXISTS idx_bidang_studi_name ON bidang_studi(name);
+-- DROP INDEX IF EXISTS idx_bidang_studi_code;
+-- CREATE INDEX IF NOT EXISTS idx_bidang_studi_code ON bidang_studi(code);
+-- ALTER TABLE bidang_studi ADD CONSTRAINT bidang_studi_code_key UNIQUE (code);
+-- ALTER TABLE bidang_studi ADD CONSTRAINT bidang_studi_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_jenjang_studi_name;
+-- CREATE INDEX IF NOT EXISTS idx_jenjang_studi_name ON jenjang_studi(name);
+-- DROP INDEX IF EXISTS idx_jenjang_studi_code;
+-- CREATE INDEX IF NOT EXISTS idx_jenjang_studi_code ON jenjang_studi(code);
+-- ALTER TABLE jenjang_studi ADD CONSTRAINT jenjang_studi_code_key UNIQUE (code);
+-- ALTER TABLE jenjang_studi ADD CONSTRAINT jenjang_studi_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_jenis_buku_name;
+-- CREATE INDEX IF NOT EXISTS idx_jenis_buku_name ON jenis_buku(name);
+-- DROP INDEX IF EXISTS idx_jenis_buku_code;
+-- CREATE INDEX IF NOT EXISTS idx_jenis_buku_code ON jenis_buku(code);
+-- ALTER TABLE jenis_buku ADD CONSTRAINT jenis_buku_code_key UNIQUE (code);
+-- ALTER TABLE jenis_buku ADD CONSTRAINT jenis_buku_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_merk_buku_name;
+-- CREATE INDEX IF NOT EXISTS idx_merk_buku_name ON merk_buku(name);
+-- DROP INDEX IF EXISTS idx_merk_buku_code;
+-- CREATE INDEX IF NOT EXISTS idx_merk_buku_code ON merk_buku(code);
+-- ALTER TABLE merk_buku ADD CONSTRAINT merk_buku_code_key UNIQUE (code);
+-- ALTER TABLE merk_buku ADD CONSTRAINT merk_buku_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_expeditions_name;
+-- CREATE INDEX IF NOT EXISTS idx_expeditions_name ON expeditions(name);
+-- DROP INDEX IF EXISTS idx_expeditions_code;
+-- CREATE INDEX IF NOT EXISTS idx_expeditions_code ON expeditions(code);
+-- ALTER TABLE expeditions ADD CONSTRAINT expeditions_code_key UNIQUE (code);
+-- ALTER TABLE expeditions ADD CONSTRAINT expeditions_name_key UNIQUE (name);
+-- DROP INDEX IF EXISTS idx_cities_name;
+-- CREATE INDEX IF NOT EXISTS idx_cities_name ON cities(name);
+-- DROP INDEX IF EXISTS idx_cities_code;
+-- CREATE INDEX IF NOT EXISTS idx_cities_code ON cities(code);
+-- ALTER TABLE cities ADD CONSTRAINT cities_code_key UNIQUE (code);
+-- ALTER TABLE cities ADD CONSTRAINT cities_name_key UNIQUE (name);
+-- ALTER TABLE warehouses DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE curriculum DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE billers DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE sales_associates DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE publishers DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE kelas DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE bidang_studi DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE jenjang_studi DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE jenis_buku DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE merk_buku DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE expeditions DROP COLUMN IF EXISTS deleted_at;
+-- ALTER TABLE cities DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditValues is a JSONB object of column values
+type AuditValues map[string]interface{}
+
+// Scan implements sql.Scanner interface
+func (v *AuditValues) Scan(value interface{}) error {
+	var data []byte
+	switch val := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		data = val
+	case string:
+		data = []byte(val)
+	default:
+		return fmt.Errorf("cannot scan type %T into AuditValues", value)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Value implements driver.Valuer interface
+func (v AuditValues) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// AuditLog records one created, updated or deleted row. For updates Before and After only
+// hold the changed columns; creates have no Before and deletes no After.
+type AuditLog struct {
+	ID         uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID     *uuid.UUID  `gorm:"type:uuid" json:"user_id"`
+	User       *User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Action     string      `gorm:"not null" json:"action"`
+	EntityType string      `gorm:"not null" json:"entity_type"`
+	EntityID   string      `gorm:"not null" json:"entity_id"`
+	Before     AuditValues `gorm:"type:jsonb" json:"before"`
+	After      AuditValues `gorm:"type:jsonb" json:"after"`
+	IPAddress  *string     `json:"ip_address"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -3,15 +3,17 @@ package models
 import (
 	"time"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type BidangStudi struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Code        string    `gorm:"unique;not null" json:"code"`
-	Name        string    `gorm:"unique;not null" json:"name"`
-	Description *string   `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code        string         `gorm:"unique;not null" json:"code"`
+	Name        string         `gorm:"unique;not null" json:"name"`
+	Description *string        `json:"description"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (BidangStudi) TableName() string {
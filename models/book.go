@@ -3,38 +3,60 @@ package models
 import (
 	"time"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Book struct {
-	ID             uuid.UUID     `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Name           string        `gorm:"not null" json:"name"`
-	Description    *string       `json:"description"`
-	Year           string        `gorm:"not null" json:"year"`
-	Author         *string       `json:"author"`
-	ISBN           *string       `json:"isbn"`
-	Periode        int           `gorm:"default:1" json:"periode"`
-	Stock          int           `gorm:"default:0" json:"stock"`
-	NoPages        int           `gorm:"default:1" json:"no_pages"`
-	Kelas          *string       `gorm:"type:varchar(5)" json:"kelas"`
-	MerkBukuID     *uuid.UUID    `gorm:"type:uuid" json:"merk_buku_id"`
-	MerkBuku       *MerkBuku     `gorm:"foreignKey:MerkBukuID" json:"merk_buku,omitempty"`
-	JenisBukuID    *uuid.UUID    `gorm:"type:uuid" json:"jenis_buku_id"`
-	JenisBuku      *JenisBuku    `gorm:"foreignKey:JenisBukuID" json:"jenis_buku,omitempty"`
-	JenjangStudiID *uuid.UUID    `gorm:"type:uuid" json:"jenjang_studi_id"`
-	JenjangStudi   *JenjangStudi `gorm:"foreignKey:JenjangStudiID" json:"jenjang_studi,omitempty"`
-	BidangStudiID  *uuid.UUID    `gorm:"type:uuid" json:"bidang_studi_id"`
-	BidangStudi    *BidangStudi  `gorm:"foreignKey:BidangStudiID" json:"bidang_studi,omitempty"`
-	CurriculumID   *uuid.UUID    `gorm:"type:uuid" json:"curriculum_id"`
-	Curriculum     *Curriculum   `gorm:"foreignKey:CurriculumID" json:"curriculum,omitempty"`
-	PublisherID    *uuid.UUID    `gorm:"type:uuid" json:"publisher_id"`
-	Publisher      *Publisher    `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
-	Price          float64       `gorm:"not null" json:"price"`
-	ImageUrl       *string       `json:"image_url,omitempty"`
-	FileUrl        *string       `json:"file_url,omitempty"`
-	CreatedAt      time.Time     `json:"created_at"`
-	UpdatedAt      time.Time     `json:"updated_at"`
+	ID                uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Description       *string        `json:"description"`
+	Year              string         `gorm:"not null" json:"year"`
+	Author            *string        `json:"author"`
+	Code              *string        `gorm:"unique" json:"code"` // SKU, unique when set
+	ISBN              *string        `json:"isbn"`               // Stored without hyphens
+	Periode           int            `gorm:"default:1" json:"periode"`
+	Stock             int            `gorm:"default:0" json:"stock"`
+	MinStock          int            `gorm:"default:0" json:"min_stock"`        // Reorder point, 0 = not managed
+	ReorderQuantity   int            `gorm:"default:0" json:"reorder_quantity"` // Minimum quantity to order when restocking
+	NoPages           int            `gorm:"default:1" json:"no_pages"`
+	Kelas             *string        `gorm:"type:varchar(5)" json:"kelas"`
+	MerkBukuID        *uuid.UUID     `gorm:"type:uuid" json:"merk_buku_id"`
+	MerkBuku          *MerkBuku      `gorm:"foreignKey:MerkBukuID" json:"merk_buku,omitempty"`
+	JenisBukuID       *uuid.UUID     `gorm:"type:uuid" json:"jenis_buku_id"`
+	JenisBuku         *JenisBuku     `gorm:"foreignKey:JenisBukuID" json:"jenis_buku,omitempty"`
+	JenjangStudiID    *uuid.UUID     `gorm:"type:uuid" json:"jenjang_studi_id"`
+	JenjangStudi      *JenjangStudi  `gorm:"foreignKey:JenjangStudiID" json:"jenjang_studi,omitempty"`
+	BidangStudiID     *uuid.UUID     `gorm:"type:uuid" json:"bidang_studi_id"`
+	BidangStudi       *BidangStudi   `gorm:"foreignKey:BidangStudiID" json:"bidang_studi,omitempty"`
+	CurriculumID      *uuid.UUID     `gorm:"type:uuid" json:"curriculum_id"`
+	Curriculum        *Curriculum    `gorm:"foreignKey:CurriculumID" json:"curriculum,omitempty"`
+	PublisherID       *uuid.UUID     `gorm:"type:uuid" json:"publisher_id"`
+	Publisher         *Publisher     `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
+	Price             float64        `gorm:"not null" json:"price"`
+	AverageCost       float64        `gorm:"not null;default:0" json:"average_cost"` // Moving weighted average purchase cost
+	ImageUrl          *string        `json:"image_url,omitempty"`
+	FileUrl           *string        `json:"file_url,omitempty"`
+	Stocks            []BookStock    `gorm:"foreignKey:BookID" json:"stocks,omitempty"`
+	PreviousEditionID *uuid.UUID     `gorm:"type:uuid" json:"previous_edition_id"`
+	PreviousEdition   *Book          `gorm:"foreignKey:PreviousEditionID" json:"previous_edition,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (Book) TableName() string {
 	return "books"
 }
+
+// BookSummary is a book with its list price but without costs, as shown on portal draft orders
+type BookSummary struct {
+	ID    uuid.UUID `json:"id"`
+	Code  *string   `json:"code"`
+	ISBN  *string   `json:"isbn"`
+	Name  string    `json:"name"`
+	Price float64   `json:"price"`
+}
+
+func (BookSummary) TableName() string {
+	return "books"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BookBundle is a package of books (paket buku) sold as a single line at its own price
+type BookBundle struct {
+	ID          uuid.UUID        `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code        string           `gorm:"unique;not null" json:"code"`
+	Name        string           `gorm:"not null" json:"name"`
+	Description *string          `json:"description"`
+	Price       float64          `gorm:"not null" json:"price"`
+	IsActive    bool             `gorm:"not null;default:true" json:"is_active"`
+	Items       []BookBundleItem `gorm:"foreignKey:BundleID" json:"items,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func (BookBundle) TableName() string {
+	return "book_bundles"
+}
+
+// BookBundleItem is a book in a bundle and how many copies one bundle contains
+type BookBundleItem struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BundleID  uuid.UUID `gorm:"type:uuid;not null" json:"bundle_id"`
+	BookID    uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	Book      *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (BookBundleItem) TableName() string {
+	return "book_bundle_items"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BookStock holds the stock of a book in a single warehouse.
+// Book.Stock is kept as the total across all warehouses.
+type BookStock struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BookID      uuid.UUID  `gorm:"type:uuid;not null" json:"book_id"`
+	WarehouseID uuid.UUID  `gorm:"type:uuid;not null" json:"warehouse_id"`
+	Warehouse   *Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	Quantity    int        `gorm:"not null;default:0" json:"quantity"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (BookStock) TableName() string {
+	return "book_stocks"
+}
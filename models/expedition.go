@@ -3,26 +3,28 @@ package models
 import (
 	"time"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Expedition struct {
-	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Code        string     `gorm:"unique;not null" json:"code"`
-	Name        string     `gorm:"unique;not null" json:"name"`
-	Description *string    `json:"description"`
-	Address     string     `gorm:"not null" json:"address"`
-	CityID      *uuid.UUID `gorm:"type:uuid" json:"city_id"`
-	City        *City      `gorm:"foreignKey:CityID" json:"city,omitempty"`
-	CityCode    string     `gorm:"-" json:"city_code"` // VIRTUAL FIELD (ignored by GORM)
-	Area        *string    `json:"area"`
-	Phone1      string     `gorm:"not null" json:"phone1"`
-	Phone2      *string    `json:"phone2"`
-	Email       *string    `json:"email"`
-	Website     *string    `json:"website"`
-	LogoUrl     *string    `json:"logo_url,omitempty"`
-	FileUrl     *string    `json:"file_url,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code        string         `gorm:"unique;not null" json:"code"`
+	Name        string         `gorm:"unique;not null" json:"name"`
+	Description *string        `json:"description"`
+	Address     string         `gorm:"not null" json:"address"`
+	CityID      *uuid.UUID     `gorm:"type:uuid" json:"city_id"`
+	City        *City          `gorm:"foreignKey:CityID" json:"city,omitempty"`
+	CityCode    string         `gorm:"-" json:"city_code"` // VIRTUAL FIELD (ignored by GORM)
+	Area        *string        `json:"area"`
+	Phone1      string         `gorm:"not null" json:"phone1"`
+	Phone2      *string        `json:"phone2"`
+	Email       *string        `json:"email"`
+	Website     *string        `json:"website"`
+	LogoUrl     *string        `json:"logo_url,omitempty"`
+	FileUrl     *string        `json:"file_url,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (Expedition) TableName() string {
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GoodsReceipt records a delivery received against a purchase transaction
+type GoodsReceipt struct {
+	ID                    uuid.UUID            `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	NoReceipt             string               `gorm:"unique;not null" json:"no_receipt"`
+	PurchaseTransactionID uuid.UUID            `gorm:"type:uuid;not null" json:"purchase_transaction_id"`
+	PurchaseTransaction   *PurchaseTransaction `gorm:"foreignKey:PurchaseTransactionID" json:"purchase_transaction,omitempty"`
+	WarehouseID           uuid.UUID            `gorm:"type:uuid;not null" json:"warehouse_id"`
+	Warehouse             *Warehouse           `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	ReceiptDate           Date                 `gorm:"type:date;not null" json:"receipt_date"`
+	Note                  *string              `json:"note"`
+	Items                 []GoodsReceiptItem   `gorm:"foreignKey:GoodsReceiptID" json:"items,omitempty"`
+	CreatedAt             time.Time            `json:"created_at"`
+	UpdatedAt             time.Time            `json:"updated_at"`
+}
+
+func (GoodsReceipt) TableName() string {
+	return "goods_receipts"
+}
+
+// GoodsReceiptItem is the quantity of a purchase line received in a goods receipt
+type GoodsReceiptItem struct {
+	ID                        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	GoodsReceiptID            uuid.UUID `gorm:"type:uuid;not null" json:"goods_receipt_id"`
+	PurchaseTransactionItemID uuid.UUID `gorm:"type:uuid;not null" json:"purchase_transaction_item_id"`
+	BookID                    uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	Book                      *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Quantity                  int       `gorm:"not null" json:"quantity"`
+	CreatedAt                 time.Time `json:"created_at"`
+	UpdatedAt                 time.Time `json:"updated_at"`
+}
+
+func (GoodsReceiptItem) TableName() string {
+	return "goods_receipt_items"
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons recorded on login attempts
+const (
+	LoginReasonSuccess            = "success"
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonLocked             = "locked"
+	LoginReasonTwoFactorRequired  = "two_factor_required" // Password accepted, waiting for the second step
+	LoginReasonInvalidTwoFactor   = "invalid_two_factor"
+)
+
+// LoginAttempt is the audit row of one login attempt
+type LoginAttempt struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Email     string     `gorm:"not null" json:"email"`
+	IPAddress string     `gorm:"not null" json:"ip_address"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	Success   bool       `gorm:"not null" json:"success"`
+	Reason    string     `gorm:"not null" json:"reason"`
+	UserAgent *string    `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// LoginLockout counts the recent failed logins of an email or IP address. Key is
+// "email:<email>" or "ip:<address>".
+type LoginLockout struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockouts"
+}
@@ -3,17 +3,19 @@ package models
 import (
 	"time"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // MerkBuku represents a book brand
 type MerkBuku struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Code        string    `gorm:"unique;not null" json:"code"`
-	Name        string    `gorm:"unique;not null" json:"name"`
-	Description *string   `json:"description"`
-	BantuanPromosi *int      `json:"bantuan_promosi"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code           string         `gorm:"unique;not null" json:"code"`
+	Name           string         `gorm:"unique;not null" json:"name"`
+	Description    *string        `json:"description"`
+	BantuanPromosi *int           `json:"bantuan_promosi"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 func (MerkBuku) TableName() string {
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordResetToken is a one-time token emailed to a user who forgot their password
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	TokenHash string     `gorm:"unique;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	IPAddress *string    `json:"ip_address"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
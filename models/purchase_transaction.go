@@ -13,10 +13,16 @@ type PurchaseTransaction struct {
 	NoInvoice       string                    `gorm:"unique;not null" json:"no_invoice"`
 	PurchaseDate    Date                      `gorm:"type:date;not null" json:"purchase_date"`
 	TotalAmount     float64                   `gorm:"not null;default:0" json:"total_amount"`
-	Status          int                       `gorm:"not null;default:0" json:"status"` // 0 = pending, 1 = completed, 2 = cancelled
+	Status          int                       `gorm:"not null;default:0" json:"status"` // 0 = pending, 1 = completed, 2 = cancelled, 3 = partially received, 4 = closed short
+	WarehouseID     *uuid.UUID                `gorm:"type:uuid" json:"warehouse_id"`
+	Warehouse       *Warehouse                `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
 	ReceiptImageUrl *string                   `json:"receipt_image_url"`
 	Note            *string                   `json:"note"`
 	Items           []PurchaseTransactionItem `gorm:"foreignKey:PurchaseTransactionID" json:"items,omitempty"`
+	CreatedByID     *uuid.UUID                `gorm:"type:uuid" json:"created_by_id"`
+	CreatedBy       *UserSummary              `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	UpdatedByID     *uuid.UUID                `gorm:"type:uuid" json:"updated_by_id"`
+	UpdatedBy       *UserSummary              `gorm:"foreignKey:UpdatedByID" json:"updated_by,omitempty"`
 	CreatedAt       time.Time                 `json:"created_at"`
 	UpdatedAt       time.Time                 `json:"updated_at"`
 }
@@ -27,7 +33,9 @@ func (PurchaseTransaction) TableName() string {
 
 // Status constants for PurchaseTransaction
 const (
-	PurchaseStatusPending   = 0 // Draft, stock not affected
-	PurchaseStatusCompleted = 1 // Stock increased
-	PurchaseStatusCancelled = 2 // Cancelled, stock not affected
+	PurchaseStatusPending           = 0 // Draft, stock not affected
+	PurchaseStatusCompleted         = 1 // Everything received, stock increased
+	PurchaseStatusCancelled         = 2 // Cancelled, stock not affected
+	PurchaseStatusPartiallyReceived = 3 // Some goods received, the rest is still expected
+	PurchaseStatusClosedShort       = 4 // Closed with a shortfall, the rest will not arrive
 )
@@ -12,6 +12,7 @@ type PurchaseTransactionItem struct {
 	BookID                uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
 	Book                  *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
 	Quantity              int       `gorm:"not null" json:"quantity"`
+	ReceivedQuantity      int       `gorm:"not null;default:0" json:"received_quantity"` // Received so far through goods receipts
 	Price                 float64   `gorm:"not null" json:"price"`
 	Subtotal              float64   `gorm:"not null" json:"subtotal"`
 	CreatedAt             time.Time `json:"created_at"`
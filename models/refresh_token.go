@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is one refresh token of a login session. Tokens of the same login share a
+// FamilyID; refreshing revokes the token and issues its replacement in the same family.
+type RefreshToken struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	FamilyID     uuid.UUID  `gorm:"type:uuid;not null" json:"family_id"`
+	TokenHash    string     `gorm:"unique;not null" json:"-"`
+	TokenVersion int        `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uuid.UUID `gorm:"type:uuid" json:"replaced_by_id"`
+	UserAgent    *string    `json:"user_agent"`
+	IPAddress    *string    `json:"ip_address"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PermissionAll grants every permission
+const PermissionAll = "*"
+
+// Permissions lists every permission a role can be granted, as "<resource>:<action>"
+var Permissions = []string{
+	"master_data:read", "master_data:write", "master_data:delete", "master_data:force_delete",
+	"books:read", "books:write", "books:delete",
+	"sales:read", "sales:write", "sales:delete",
+	"payments:read", "payments:write", "payments:delete",
+	"shippings:read", "shippings:write", "shippings:delete",
+	"discount_rates:read", "discount_rates:write", "discount_rates:delete",
+	"purchases:read", "purchases:write", "purchases:delete",
+	"inventory:read", "inventory:write", "inventory:delete",
+	"reports:read",
+	"users:read", "users:write", "users:delete",
+	"roles:read", "roles:write",
+	"audit_logs:read",
+	"portal:access",
+}
+
+// IsKnownPermission reports whether the permission is in Permissions or is PermissionAll
+func IsKnownPermission(permission string) bool {
+	if permission == PermissionAll {
+		return true
+	}
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionList is a PostgreSQL TEXT[] of permissions
+type PermissionList []string
+
+// Scan implements sql.Scanner interface
+func (l *PermissionList) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		*l = PermissionList{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan type %T into PermissionList", value)
+	}
+
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	list := PermissionList{}
+	for _, p := range strings.Split(s, ",") {
+		// "*" is quoted in the array output
+		if p = strings.Trim(p, `"`); p != "" {
+			list = append(list, p)
+		}
+	}
+	*l = list
+	return nil
+}
+
+// Value implements driver.Valuer interface. Permissions only contain letters, underscores,
+// colons and "*", so they never need escaping.
+func (l PermissionList) Value() (driver.Value, error) {
+	quoted := make([]string, len(l))
+	for i, p := range l {
+		quoted[i] = `"` + p + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+// Has reports whether the list grants the permission
+func (l PermissionList) Has(permission string) bool {
+	for _, p := range l {
+		if p == PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize trims, removes duplicates and sorts the permissions
+func (l PermissionList) Normalize() PermissionList {
+	seen := make(map[string]bool, len(l))
+	list := PermissionList{}
+	for _, p := range l {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Role is a named set of permissions assigned to users through users.role
+type Role struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name        string         `gorm:"unique;not null" json:"name"`
+	Description *string        `json:"description"`
+	Permissions PermissionList `gorm:"type:text[];not null" json:"permissions"`
+	IsSystem    bool           `gorm:"not null;default:false" json:"is_system"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SalesOrderDraft is an order submitted by a sales associate through the portal. Staff approve
+// it into a sales transaction, setting prices, discounts and the warehouse, or reject it.
+type SalesOrderDraft struct {
+	ID                 uuid.UUID             `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	NoOrder            string                `gorm:"unique;not null" json:"no_order"`
+	SalesAssociateID   uuid.UUID             `gorm:"type:uuid;not null" json:"sales_associate_id"`
+	SalesAssociate     *SalesAssociate       `gorm:"foreignKey:SalesAssociateID" json:"sales_associate,omitempty"`
+	PaymentType        string                `gorm:"default:'T';not null" json:"payment_type"` // 'T' for Cash, 'K' for Credit
+	Periode            int                   `gorm:"not null;default:1" json:"periode"`
+	Year               string                `gorm:"not null" json:"year"`
+	CurriculumID       *uuid.UUID            `gorm:"type:uuid" json:"curriculum_id"`
+	Curriculum         *Curriculum           `gorm:"foreignKey:CurriculumID" json:"curriculum,omitempty"`
+	MerkBukuID         *uuid.UUID            `gorm:"type:uuid" json:"merk_buku_id"`
+	MerkBuku           *MerkBuku             `gorm:"foreignKey:MerkBukuID" json:"merk_buku,omitempty"`
+	JenjangStudiID     *uuid.UUID            `gorm:"type:uuid" json:"jenjang_studi_id"`
+	JenjangStudi       *JenjangStudi         `gorm:"foreignKey:JenjangStudiID" json:"jenjang_studi,omitempty"`
+	Note               *string               `json:"note"`
+	Status             int                   `gorm:"not null;default:0" json:"status"`      // 0 = pending, 1 = approved, 2 = rejected
+	SalesTransactionID *uuid.UUID            `gorm:"type:uuid" json:"sales_transaction_id"` // Set on approval
+	RejectionReason    *string               `json:"rejection_reason"`
+	ReviewedByID       *uuid.UUID            `gorm:"type:uuid" json:"reviewed_by_id"`
+	ReviewedBy         *UserSummary          `gorm:"foreignKey:ReviewedByID" json:"reviewed_by,omitempty"`
+	ReviewedAt         *time.Time            `json:"reviewed_at"`
+	Items              []SalesOrderDraftItem `gorm:"foreignKey:DraftID" json:"items,omitempty"`
+	CreatedByID        *uuid.UUID            `gorm:"type:uuid" json:"created_by_id"`
+	CreatedBy          *UserSummary          `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	UpdatedByID        *uuid.UUID            `gorm:"type:uuid" json:"updated_by_id"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
+}
+
+func (SalesOrderDraft) TableName() string {
+	return "sales_order_drafts"
+}
+
+// Status constants for SalesOrderDraft
+const (
+	DraftStatusPending  = 0 // Submitted, waiting for staff
+	DraftStatusApproved = 1 // Turned into a sales transaction
+	DraftStatusRejected = 2 // Rejected by staff with a reason
+)
+
+// SalesOrderDraftItem is a book and quantity requested on a draft order
+type SalesOrderDraftItem struct {
+	ID        uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	DraftID   uuid.UUID    `gorm:"type:uuid;not null" json:"draft_id"`
+	BookID    uuid.UUID    `gorm:"type:uuid;not null" json:"book_id"`
+	Book      *BookSummary `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Quantity  int          `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+func (SalesOrderDraftItem) TableName() string {
+	return "sales_order_draft_items"
+}
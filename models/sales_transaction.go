@@ -15,7 +15,7 @@ type SalesTransaction struct {
 	PaymentType      string                 `gorm:"default:'T';not null" json:"payment_type"` // 'T' for Cash, 'K' for Credit
 	TransactionDate  time.Time              `gorm:"not null" json:"transaction_date"`
 	TotalAmount      float64                `gorm:"not null;default:0" json:"total_amount"`
-	Status           int                    `gorm:"not null;default:0" json:"status"` // 0 = booking, 1 = paid-off, 2 = installment
+	Status           int                    `gorm:"not null;default:0" json:"status"` // 0 = booking, 1 = paid-off, 2 = installment, 3 = cancelled
 	Periode          int                    `gorm:"not null;default:1" json:"periode"`
 	Year             string                 `gorm:"not null" json:"year"`
 	CurriculumID     *uuid.UUID             `gorm:"type:uuid" json:"curriculum_id"`
@@ -24,9 +24,15 @@ type SalesTransaction struct {
 	MerkBuku         *MerkBuku              `gorm:"foreignKey:MerkBukuID" json:"merk_buku,omitempty"`
 	JenjangStudiID   *uuid.UUID             `gorm:"type:uuid" json:"jenjang_studi_id"`
 	JenjangStudi     *JenjangStudi          `gorm:"foreignKey:JenjangStudiID" json:"jenjang_studi,omitempty"`
+	WarehouseID      *uuid.UUID             `gorm:"type:uuid" json:"warehouse_id"`
+	Warehouse        *Warehouse             `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
 	Items            []SalesTransactionItem `gorm:"foreignKey:TransactionID" json:"items,omitempty"`
 	Payments         []Payment              `gorm:"foreignKey:SalesTransactionID" json:"payments,omitempty"`
 	Shippings        []Shipping             `gorm:"foreignKey:SalesTransactionID" json:"shippings,omitempty"`
+	CreatedByID      *uuid.UUID             `gorm:"type:uuid" json:"created_by_id"`
+	CreatedBy        *UserSummary           `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+	UpdatedByID      *uuid.UUID             `gorm:"type:uuid" json:"updated_by_id"`
+	UpdatedBy        *UserSummary           `gorm:"foreignKey:UpdatedByID" json:"updated_by,omitempty"`
 	CreatedAt        time.Time              `json:"created_at"`
 	UpdatedAt        time.Time              `json:"updated_at"`
 }
@@ -34,3 +40,33 @@ type SalesTransaction struct {
 func (SalesTransaction) TableName() string {
 	return "sales_transactions"
 }
+
+// Status constants for SalesTransaction
+const (
+	SalesStatusBooking     = 0 // Booked, no payment received yet
+	SalesStatusPaidOff     = 1 // Fully paid (lunas)
+	SalesStatusInstallment = 2 // Partially paid (angsuran)
+	SalesStatusCancelled   = 3 // Cancelled, stock returned, document kept
+)
+
+// salesStatusTransitions lists the statuses a sales transaction may move to from each status.
+// Cancelled is terminal.
+var salesStatusTransitions = map[int][]int{
+	SalesStatusBooking:     {SalesStatusInstallment, SalesStatusPaidOff, SalesStatusCancelled},
+	SalesStatusInstallment: {SalesStatusBooking, SalesStatusPaidOff},
+	SalesStatusPaidOff:     {SalesStatusBooking, SalesStatusInstallment},
+}
+
+// CanTransitionSalesStatus reports whether a sales transaction may move from one status to another.
+// Staying on the same status is always allowed.
+func CanTransitionSalesStatus(from, to int) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range salesStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -6,17 +6,23 @@ import (
 )
 
 type SalesTransactionItem struct {
-	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	TransactionID uuid.UUID `gorm:"type:uuid;not null" json:"transaction_id"`
-	BookID        uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
-	Book          *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
-	Quantity      int       `gorm:"not null" json:"quantity"`
-	Price         float64   `gorm:"not null" json:"price"`
-	Promotion     float64   `gorm:"not null;default:0" json:"promotion"`
-	Discount      float64   `gorm:"not null;default:0" json:"discount"`
-	Subtotal      float64   `gorm:"not null" json:"subtotal"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                  uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TransactionID       uuid.UUID   `gorm:"type:uuid;not null" json:"transaction_id"`
+	BookID              uuid.UUID   `gorm:"type:uuid;not null" json:"book_id"`
+	Book                *Book       `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Quantity            int         `gorm:"not null" json:"quantity"`
+	BackorderedQuantity int         `gorm:"not null;default:0" json:"backordered_quantity"` // Part of quantity still owed, not yet taken from stock
+	Price               float64     `gorm:"not null" json:"price"`
+	Promotion           float64     `gorm:"not null;default:0" json:"promotion"`
+	Discount            float64     `gorm:"not null;default:0" json:"discount"`
+	Subtotal            float64     `gorm:"not null" json:"subtotal"`
+	UnitCost            float64     `gorm:"not null;default:0" json:"unit_cost"`        // Average cost of the book at sale time
+	COGS                float64     `gorm:"column:cogs;not null;default:0" json:"cogs"` // Cost of goods sold, unit_cost * quantity
+	BundleID            *uuid.UUID  `gorm:"type:uuid" json:"bundle_id"`                 // Bundle the line was expanded from, nil for single titles
+	Bundle              *BookBundle `gorm:"foreignKey:BundleID" json:"bundle,omitempty"`
+	BundleQuantity      int         `gorm:"not null;default:0" json:"bundle_quantity"` // Number of bundles sold on the line
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
 }
 
 func (SalesTransactionItem) TableName() string {
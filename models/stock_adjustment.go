@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockAdjustment records a stock change that is not caused by a sale, purchase or transfer
+type StockAdjustment struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BookID        uuid.UUID  `gorm:"type:uuid;not null" json:"book_id"`
+	Book          *Book      `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	WarehouseID   uuid.UUID  `gorm:"type:uuid;not null" json:"warehouse_id"`
+	Warehouse     *Warehouse `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	StockOpnameID *uuid.UUID `gorm:"type:uuid" json:"stock_opname_id"`
+	Quantity      int        `gorm:"not null" json:"quantity"` // Positive adds stock, negative removes stock
+	Reason        string     `gorm:"not null" json:"reason"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (StockAdjustment) TableName() string {
+	return "stock_adjustments"
+}
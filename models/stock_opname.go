@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockOpname is a physical stock count session for a single warehouse
+type StockOpname struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	NoOpname       string            `gorm:"unique;not null" json:"no_opname"`
+	WarehouseID    uuid.UUID         `gorm:"type:uuid;not null" json:"warehouse_id"`
+	Warehouse      *Warehouse        `gorm:"foreignKey:WarehouseID" json:"warehouse,omitempty"`
+	MerkBukuID     *uuid.UUID        `gorm:"type:uuid" json:"merk_buku_id"`
+	MerkBuku       *MerkBuku         `gorm:"foreignKey:MerkBukuID" json:"merk_buku,omitempty"`
+	JenjangStudiID *uuid.UUID        `gorm:"type:uuid" json:"jenjang_studi_id"`
+	JenjangStudi   *JenjangStudi     `gorm:"foreignKey:JenjangStudiID" json:"jenjang_studi,omitempty"`
+	OpnameDate     Date              `gorm:"type:date;not null" json:"opname_date"`
+	Status         int               `gorm:"not null;default:0" json:"status"` // 0 = open, 1 = posted, 2 = cancelled
+	Note           *string           `json:"note"`
+	Reason         *string           `json:"reason"` // Reason recorded on the posted adjustments
+	PostedAt       *time.Time        `json:"posted_at"`
+	Items          []StockOpnameItem `gorm:"foreignKey:StockOpnameID" json:"items,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+func (StockOpname) TableName() string {
+	return "stock_opnames"
+}
+
+// Status constants for StockOpname
+const (
+	OpnameStatusOpen      = 0 // Counting in progress, stock not changed
+	OpnameStatusPosted    = 1 // Approved variances posted as stock adjustments
+	OpnameStatusCancelled = 2 // Cancelled, stock not changed
+)
+
+// StockOpnameItem is the count of a single book within a stock opname session.
+// SystemQuantity is the warehouse stock when the session was opened.
+type StockOpnameItem struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	StockOpnameID   uuid.UUID `gorm:"type:uuid;not null" json:"stock_opname_id"`
+	BookID          uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	Book            *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	SystemQuantity  int       `gorm:"not null" json:"system_quantity"`
+	CountedQuantity *int      `json:"counted_quantity"` // nil until the book has been counted
+	Approved        bool      `gorm:"not null;default:false" json:"approved"`
+	Reason          *string   `json:"reason"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (StockOpnameItem) TableName() string {
+	return "stock_opname_items"
+}
+
+// Variance returns counted minus system quantity, or 0 when the book has not been counted
+func (i StockOpnameItem) Variance() int {
+	if i.CountedQuantity == nil {
+		return 0
+	}
+	return *i.CountedQuantity - i.SystemQuantity
+}
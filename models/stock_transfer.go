@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockTransfer moves book stock from one warehouse to another
+type StockTransfer struct {
+	ID              uuid.UUID           `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	NoTransfer      string              `gorm:"unique;not null" json:"no_transfer"`
+	FromWarehouseID uuid.UUID           `gorm:"type:uuid;not null" json:"from_warehouse_id"`
+	FromWarehouse   *Warehouse          `gorm:"foreignKey:FromWarehouseID" json:"from_warehouse,omitempty"`
+	ToWarehouseID   uuid.UUID           `gorm:"type:uuid;not null" json:"to_warehouse_id"`
+	ToWarehouse     *Warehouse          `gorm:"foreignKey:ToWarehouseID" json:"to_warehouse,omitempty"`
+	TransferDate    Date                `gorm:"type:date;not null" json:"transfer_date"`
+	Status          int                 `gorm:"not null;default:0" json:"status"` // 0 = pending, 1 = completed, 2 = cancelled
+	Note            *string             `json:"note"`
+	Items           []StockTransferItem `gorm:"foreignKey:StockTransferID" json:"items,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+func (StockTransfer) TableName() string {
+	return "stock_transfers"
+}
+
+// Status constants for StockTransfer
+const (
+	TransferStatusPending   = 0 // Draft, stock not moved
+	TransferStatusCompleted = 1 // Stock moved between warehouses
+	TransferStatusCancelled = 2 // Cancelled, stock not moved
+)
+
+// StockTransferItem is a single book line of a stock transfer
+type StockTransferItem struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	StockTransferID uuid.UUID `gorm:"type:uuid;not null" json:"stock_transfer_id"`
+	BookID          uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	Book            *Book     `gorm:"foreignKey:BookID" json:"book,omitempty"`
+	Quantity        int       `gorm:"not null" json:"quantity"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (StockTransferItem) TableName() string {
+	return "stock_transfer_items"
+}
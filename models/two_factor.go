@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+// LoginChallenge is issued by Login after the password of a user with 2FA was accepted, and is
+// exchanged for the session tokens together with a TOTP or recovery code
+type LoginChallenge struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	TokenHash      string     `gorm:"unique;not null" json:"-"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt         *time.Time `json:"used_at"`
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`
+	IPAddress      *string    `json:"ip_address"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (LoginChallenge) TableName() string {
+	return "login_challenges"
+}
@@ -6,14 +6,20 @@ import (
 )
 
 type User struct {
-    ID           uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-    Email        string    `gorm:"unique;not null" json:"email"`
-    PasswordHash string    `gorm:"not null" json:"-"`
-    FullName     string    `gorm:"not null" json:"full_name"`
-    Role         string    `gorm:"default:'user'" json:"role"`
-    PhotoUrl     *string   `json:"photo_url,omitempty"`
-    CreatedAt    time.Time `json:"created_at"`
-    UpdatedAt    time.Time `json:"updated_at"`
+    ID                uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+    Email             string          `gorm:"unique;not null" json:"email"`
+    PasswordHash      string          `gorm:"not null" json:"-"`
+    FullName          string          `gorm:"not null" json:"full_name"`
+    Role              string          `gorm:"default:'user'" json:"role"`
+    PhotoUrl          *string         `json:"photo_url,omitempty"`
+    TokenVersion      int             `gorm:"not null;default:0" json:"-"`
+    TwoFactorSecret   *string         `json:"-"`
+    TwoFactorEnabled  bool            `gorm:"not null;default:false" json:"two_factor_enabled"`
+    TwoFactorLastStep int64           `gorm:"not null;default:0" json:"-"`
+    SalesAssociateID  *uuid.UUID      `gorm:"type:uuid" json:"sales_associate_id"` // Sales associate the user logs in as on the portal
+    SalesAssociate    *SalesAssociate `gorm:"foreignKey:SalesAssociateID" json:"sales_associate,omitempty"`
+    CreatedAt         time.Time       `json:"created_at"`
+    UpdatedAt         time.Time       `json:"updated_at"`
 }
 
 type UserRequest struct {
@@ -21,8 +27,26 @@ type UserRequest struct {
 	Password string `json:"password,omitempty" example:"newpassword123"`
 	FullName string `json:"full_name,omitempty" example:"John Doe Updated"`
 	Role     string `json:"role,omitempty" example:"admin"`
+	// Sales associate to link for the portal, required for the sales_associate role. On update
+	// an empty string removes the link.
+	SalesAssociateID *string `json:"sales_associate_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
 }
 
+// RoleSalesAssociate is the portal role of field sales associates, scoped to their linked
+// sales associate
+const RoleSalesAssociate = "sales_associate"
+
 func (User) TableName() string {
     return "users"
 }
+
+// UserSummary is the name of a user, as shown on the documents the user entered
+type UserSummary struct {
+    ID       uuid.UUID `json:"id"`
+    FullName string    `json:"full_name"`
+    Email    string    `json:"email"`
+}
+
+func (UserSummary) TableName() string {
+    return "users"
+}
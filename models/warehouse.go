@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Warehouse represents a stock location (central warehouse or regional depot)
+type Warehouse struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code        string         `gorm:"unique;not null" json:"code"`
+	Name        string         `gorm:"unique;not null" json:"name"`
+	Description *string        `json:"description"`
+	Address     *string        `json:"address"`
+	CityID      *uuid.UUID     `gorm:"type:uuid" json:"city_id"`
+	City        *City          `gorm:"foreignKey:CityID" json:"city,omitempty"`
+	IsDefault   bool           `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+func (Warehouse) TableName() string {
+	return "warehouses"
+}
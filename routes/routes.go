@@ -17,171 +17,309 @@ func Setup(app *fiber.App) {
 	// Public routes
 	auth := api.Group("/auth")
 	auth.Post("/login", handlers.Login)
+	auth.Post("/refresh", handlers.RefreshSession)
+	auth.Post("/logout", handlers.Logout)
+	auth.Post("/forgot-password", handlers.ForgotPassword)
+	auth.Post("/reset-password", handlers.ResetPassword)
+	auth.Post("/2fa/verify", handlers.VerifyTwoFactor)
 
 	// Protected routes
 	api.Use(middleware.AuthRequired())
+	auth.Post("/logout-all", handlers.LogoutAll)
+
+	auth.Post("/2fa/enroll", handlers.EnrollTwoFactor)
+	auth.Post("/2fa/confirm", handlers.ConfirmTwoFactor)
+	auth.Post("/2fa/disable", handlers.DisableTwoFactor)
+	auth.Post("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
 
 	// User routes
 	api.Get("/me", handlers.GetMe)
 	api.Put("/me", handlers.UpdateMe)
 
+	// Admins can only use the routes above until they enable 2FA
+	api.Use(middleware.TwoFactorEnrolled())
+
 	// Upload routes
-	api.Post("/upload/:resource/:field/:id", handlers.UploadResourceField)
-	api.Delete("/upload/:resource/:field/:id", handlers.DeleteResourceField)
+	uploadAccess := middleware.RequirePermissionFunc(uploadPermission, "users:write", "books:write", "master_data:write")
+	api.Post("/upload/:resource/:field/:id", uploadAccess, handlers.UploadResourceField)
+	api.Delete("/upload/:resource/:field/:id", uploadAccess, handlers.DeleteResourceField)
 
 	// Cities routes
 	cities := api.Group("/cities")
-	cities.Get("/", handlers.GetAllCities)
-	cities.Get("/:id", handlers.GetCity)
-	cities.Post("/", handlers.CreateCity)
-	cities.Put("/:id", handlers.UpdateCity)
-	cities.Delete("/:id", handlers.DeleteCity)
+	cities.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllCities)
+	cities.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetCity)
+	cities.Post("/import", middleware.RequirePermission("master_data:write"), handlers.ImportCities)
+	cities.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateCity)
+	cities.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateCity)
+	cities.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteCity)
+	cities.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreCity)
 
 	// Expeditions routes
 	expeditions := api.Group("/expeditions")
-	expeditions.Get("/", handlers.GetAllExpeditions)
-	expeditions.Get("/:id", handlers.GetExpedition)
-	expeditions.Post("/", handlers.CreateExpedition)
-	expeditions.Put("/:id", handlers.UpdateExpedition)
-	expeditions.Delete("/:id", handlers.DeleteExpedition)
+	expeditions.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllExpeditions)
+	expeditions.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetExpedition)
+	expeditions.Post("/import", middleware.RequirePermission("master_data:write"), handlers.ImportExpeditions)
+	expeditions.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateExpedition)
+	expeditions.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateExpedition)
+	expeditions.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteExpedition)
+	expeditions.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreExpedition)
 
 	// MerkBuku routes
 	merkBuku := api.Group("/merk-buku")
-	merkBuku.Get("/", handlers.GetAllMerkBuku)
-	merkBuku.Get("/:id", handlers.GetMerkBuku)
-	merkBuku.Post("/", handlers.CreateMerkBuku)
-	merkBuku.Put("/:id", handlers.UpdateMerkBuku)
-	merkBuku.Delete("/:id", handlers.DeleteMerkBuku)
+	merkBuku.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllMerkBuku)
+	merkBuku.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetMerkBuku)
+	merkBuku.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateMerkBuku)
+	merkBuku.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateMerkBuku)
+	merkBuku.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteMerkBuku)
+	merkBuku.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreMerkBuku)
 
 	// JenisBuku routes
 	jenisBuku := api.Group("/jenis-buku")
-	jenisBuku.Get("/", handlers.GetAllJenisBuku)
-	jenisBuku.Get("/:id", handlers.GetJenisBuku)
-	jenisBuku.Post("/", handlers.CreateJenisBuku)
-	jenisBuku.Put("/:id", handlers.UpdateJenisBuku)
-	jenisBuku.Delete("/:id", handlers.DeleteJenisBuku)
+	jenisBuku.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllJenisBuku)
+	jenisBuku.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetJenisBuku)
+	jenisBuku.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateJenisBuku)
+	jenisBuku.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateJenisBuku)
+	jenisBuku.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteJenisBuku)
+	jenisBuku.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreJenisBuku)
 
 	// JenjangStudi routes
 	jenjangStudi := api.Group("/jenjang-studi")
-	jenjangStudi.Get("/", handlers.GetAllJenjangStudi)
-	jenjangStudi.Get("/:id", handlers.GetJenjangStudi)
-	jenjangStudi.Post("/", handlers.CreateJenjangStudi)
-	jenjangStudi.Put("/:id", handlers.UpdateJenjangStudi)
-	jenjangStudi.Delete("/:id", handlers.DeleteJenjangStudi)
+	jenjangStudi.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllJenjangStudi)
+	jenjangStudi.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetJenjangStudi)
+	jenjangStudi.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateJenjangStudi)
+	jenjangStudi.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateJenjangStudi)
+	jenjangStudi.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteJenjangStudi)
+	jenjangStudi.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreJenjangStudi)
 
 	// BidangStudi routes
 	bidangStudi := api.Group("/bidang-studi")
-	bidangStudi.Get("/", handlers.GetAllBidangStudi)
-	bidangStudi.Get("/:id", handlers.GetBidangStudi)
-	bidangStudi.Post("/", handlers.CreateBidangStudi)
-	bidangStudi.Put("/:id", handlers.UpdateBidangStudi)
-	bidangStudi.Delete("/:id", handlers.DeleteBidangStudi)
+	bidangStudi.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllBidangStudi)
+	bidangStudi.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetBidangStudi)
+	bidangStudi.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateBidangStudi)
+	bidangStudi.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateBidangStudi)
+	bidangStudi.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteBidangStudi)
+	bidangStudi.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreBidangStudi)
 
 	// Kelas routes
 	kelas := api.Group("/kelas")
-	kelas.Get("/", handlers.GetAllKelas)
-	kelas.Get("/:id", handlers.GetKelas)
-	kelas.Post("/", handlers.CreateKelas)
-	kelas.Put("/:id", handlers.UpdateKelas)
-	kelas.Delete("/:id", handlers.DeleteKelas)
+	kelas.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllKelas)
+	kelas.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetKelas)
+	kelas.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateKelas)
+	kelas.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateKelas)
+	kelas.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteKelas)
+	kelas.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreKelas)
 
 	// Publishers routes
 	publishers := api.Group("/publishers")
-	publishers.Get("/", handlers.GetAllPublishers)
-	publishers.Get("/:id", handlers.GetPublisher)
-	publishers.Post("/", handlers.CreatePublisher)
-	publishers.Put("/:id", handlers.UpdatePublisher)
-	publishers.Delete("/:id", handlers.DeletePublisher)
+	publishers.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllPublishers)
+	publishers.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetPublisher)
+	publishers.Post("/import", middleware.RequirePermission("master_data:write"), handlers.ImportPublishers)
+	publishers.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreatePublisher)
+	publishers.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdatePublisher)
+	publishers.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeletePublisher)
+	publishers.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestorePublisher)
 
 	// Books routes
 	books := api.Group("/books")
-	books.Get("/", handlers.GetAllBooks)
-	books.Get("/:id", handlers.GetBook)
-	books.Post("/", handlers.CreateBook)
-	books.Put("/:id", handlers.UpdateBook)
-	books.Delete("/:id", handlers.DeleteBook)
+	books.Get("/", middleware.RequirePermission("books:read"), handlers.GetAllBooks)
+	books.Get("/lookup", middleware.RequirePermission("books:read"), handlers.LookupBook)
+	books.Get("/typeahead", middleware.RequirePermission("books:read"), handlers.GetBookSuggestions)
+	books.Get("/import/template", middleware.RequirePermission("books:read"), handlers.GetBookImportTemplate)
+	books.Post("/import", middleware.RequirePermission("books:write"), handlers.ImportBooks)
+	books.Get("/:id", middleware.RequirePermission("books:read"), handlers.GetBook)
+	books.Get("/:id/barcode", middleware.RequirePermission("books:read"), handlers.GetBookBarcode)
+	books.Post("/", middleware.RequirePermission("books:write"), handlers.CreateBook)
+	books.Post("/roll-edition", middleware.RequirePermission("books:write"), handlers.RollBookEdition)
+	books.Put("/:id", middleware.RequirePermission("books:write"), handlers.UpdateBook)
+	books.Delete("/:id", middleware.RequirePermission("books:delete"), handlers.DeleteBook)
+	books.Post("/:id/restore", middleware.RequirePermission("books:delete"), handlers.RestoreBook)
+
+	// BookBundles routes
+	bookBundles := api.Group("/book-bundles")
+	bookBundles.Get("/", middleware.RequirePermission("books:read"), handlers.GetAllBookBundles)
+	bookBundles.Get("/:id", middleware.RequirePermission("books:read"), handlers.GetBookBundle)
+	bookBundles.Post("/", middleware.RequirePermission("books:write"), handlers.CreateBookBundle)
+	bookBundles.Put("/:id", middleware.RequirePermission("books:write"), handlers.UpdateBookBundle)
+	bookBundles.Delete("/:id", middleware.RequirePermission("books:delete"), handlers.DeleteBookBundle)
 
 	// SalesAssociates routes
 	salesAssociates := api.Group("/sales-associates")
-	salesAssociates.Get("/", handlers.GetAllSalesAssociates)
-	salesAssociates.Get("/:id", handlers.GetSalesAssociate)
-	salesAssociates.Post("/", handlers.CreateSalesAssociate)
-	salesAssociates.Put("/:id", handlers.UpdateSalesAssociate)
-	salesAssociates.Delete("/:id", handlers.DeleteSalesAssociate)
+	salesAssociates.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllSalesAssociates)
+	salesAssociates.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetSalesAssociate)
+	salesAssociates.Get("/:id/statement", middleware.RequirePermission("reports:read"), handlers.GetSalesAssociateStatement)
+	salesAssociates.Post("/import", middleware.RequirePermission("master_data:write"), handlers.ImportSalesAssociates)
+	salesAssociates.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateSalesAssociate)
+	salesAssociates.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateSalesAssociate)
+	salesAssociates.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteSalesAssociate)
+	salesAssociates.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreSalesAssociate)
 
 	// SalesTransactions routes
 	salesTransactions := api.Group("/sales-transactions")
-	salesTransactions.Get("/", handlers.GetAllSalesTransactions)
-	salesTransactions.Get("/:id", handlers.GetSalesTransaction)
-	salesTransactions.Post("/", handlers.CreateSalesTransaction)
-	salesTransactions.Put("/:id", handlers.UpdateSalesTransaction)
-	salesTransactions.Delete("/:id", handlers.DeleteSalesTransaction)
+	salesTransactions.Get("/", middleware.RequirePermission("sales:read"), handlers.GetAllSalesTransactions)
+	salesTransactions.Get("/backorders", middleware.RequirePermission("sales:read"), handlers.GetBackorders)
+	salesTransactions.Get("/:id", middleware.RequirePermission("sales:read"), handlers.GetSalesTransaction)
+	salesTransactions.Post("/", middleware.RequirePermission("sales:write"), handlers.CreateSalesTransaction)
+	salesTransactions.Put("/:id", middleware.RequirePermission("sales:write"), handlers.UpdateSalesTransaction)
+	salesTransactions.Delete("/:id", middleware.RequirePermission("sales:delete"), handlers.DeleteSalesTransaction)
+	salesTransactions.Post("/:id/cancel", middleware.RequirePermission("sales:write"), handlers.CancelSalesTransaction)
 
 	// Payments routes (nested under sales-transactions)
-	salesTransactions.Get("/:transaction_id/payments", handlers.GetTransactionPayments)
-	salesTransactions.Post("/:transaction_id/payments", handlers.CreatePayment)
-	salesTransactions.Delete("/:transaction_id/payments/:id", handlers.DeletePayment)
+	salesTransactions.Get("/:transaction_id/payments", middleware.RequirePermission("payments:read"), handlers.GetTransactionPayments)
+	salesTransactions.Post("/:transaction_id/payments", middleware.RequirePermission("payments:write"), handlers.CreatePayment)
+	salesTransactions.Delete("/:transaction_id/payments/:id", middleware.RequirePermission("payments:delete"), handlers.DeletePayment)
 
 	// Discount value preview (nested under sales-transactions)
-	salesTransactions.Get("/:sales_transaction_id/discount-value", handlers.GetSalesTransactionDiscountValue)
+	salesTransactions.Get("/:sales_transaction_id/discount-value", middleware.RequirePermission("sales:read"), handlers.GetSalesTransactionDiscountValue)
 
 	// Shippings routes (nested under sales-transactions)
-	salesTransactions.Get("/:transaction_id/shippings", handlers.GetTransactionShippings)
-	salesTransactions.Post("/:transaction_id/shippings", handlers.CreateShipping)
-	salesTransactions.Put("/:transaction_id/shippings/:id", handlers.UpdateShipping)
-	salesTransactions.Delete("/:transaction_id/shippings/:id", handlers.DeleteShipping)
+	salesTransactions.Get("/:transaction_id/shippings", middleware.RequirePermission("shippings:read"), handlers.GetTransactionShippings)
+	salesTransactions.Post("/:transaction_id/shippings", middleware.RequirePermission("shippings:write"), handlers.CreateShipping)
+	salesTransactions.Put("/:transaction_id/shippings/:id", middleware.RequirePermission("shippings:write"), handlers.UpdateShipping)
+	salesTransactions.Delete("/:transaction_id/shippings/:id", middleware.RequirePermission("shippings:delete"), handlers.DeleteShipping)
+
+	// Draft orders submitted through the sales associate portal
+	salesOrderDrafts := api.Group("/sales-order-drafts")
+	salesOrderDrafts.Get("/", middleware.RequirePermission("sales:read"), handlers.GetAllSalesOrderDrafts)
+	salesOrderDrafts.Get("/:id", middleware.RequirePermission("sales:read"), handlers.GetSalesOrderDraft)
+	salesOrderDrafts.Post("/:id/approve", middleware.RequirePermission("sales:write"), handlers.ApproveSalesOrderDraft)
+	salesOrderDrafts.Post("/:id/reject", middleware.RequirePermission("sales:write"), handlers.RejectSalesOrderDraft)
 
 	// Billers routes
 	billers := api.Group("/billers")
-	billers.Get("/", handlers.GetAllBillers)
-	billers.Get("/:id", handlers.GetBiller)
-	billers.Post("/", handlers.CreateBiller)
-	billers.Put("/:id", handlers.UpdateBiller)
-	billers.Delete("/:id", handlers.DeleteBiller)
+	billers.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllBillers)
+	billers.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetBiller)
+	billers.Post("/import", middleware.RequirePermission("master_data:write"), handlers.ImportBillers)
+	billers.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateBiller)
+	billers.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateBiller)
+	billers.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteBiller)
+	billers.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreBiller)
 
 	// DiscountRates routes
 	discountRates := api.Group("/discount-rates")
-	discountRates.Get("/", handlers.GetAllDiscountRates)
-	discountRates.Get("/preview/:sales_transaction_id", handlers.GetSalesTransactionDiscountValue)
-	discountRates.Get("/:id", handlers.GetDiscountRate)
-	discountRates.Post("/", handlers.CreateDiscountRate)
-	discountRates.Put("/:id", handlers.UpdateDiscountRate)
-	discountRates.Delete("/:id", handlers.DeleteDiscountRate)
+	discountRates.Get("/", middleware.RequirePermission("discount_rates:read"), handlers.GetAllDiscountRates)
+	discountRates.Get("/preview/:sales_transaction_id", middleware.RequirePermission("discount_rates:read"), handlers.GetSalesTransactionDiscountValue)
+	discountRates.Get("/:id", middleware.RequirePermission("discount_rates:read"), handlers.GetDiscountRate)
+	discountRates.Post("/", middleware.RequirePermission("discount_rates:write"), handlers.CreateDiscountRate)
+	discountRates.Put("/:id", middleware.RequirePermission("discount_rates:write"), handlers.UpdateDiscountRate)
+	discountRates.Delete("/:id", middleware.RequirePermission("discount_rates:delete"), handlers.DeleteDiscountRate)
 
 	// Curriculum routes
 	curriculum := api.Group("/curriculums")
-	curriculum.Get("/", handlers.GetAllCurriculums)
-	curriculum.Get("/:id", handlers.GetCurriculum)
-	curriculum.Post("/", handlers.CreateCurriculum)
-	curriculum.Put("/:id", handlers.UpdateCurriculum)
-	curriculum.Delete("/:id", handlers.DeleteCurriculum)
+	curriculum.Get("/", middleware.RequirePermission("master_data:read"), handlers.GetAllCurriculums)
+	curriculum.Get("/:id", middleware.RequirePermission("master_data:read"), handlers.GetCurriculum)
+	curriculum.Post("/", middleware.RequirePermission("master_data:write"), handlers.CreateCurriculum)
+	curriculum.Put("/:id", middleware.RequirePermission("master_data:write"), handlers.UpdateCurriculum)
+	curriculum.Delete("/:id", middleware.RequirePermission("master_data:delete"), handlers.DeleteCurriculum)
+	curriculum.Post("/:id/restore", middleware.RequirePermission("master_data:delete"), handlers.RestoreCurriculum)
 
 	// PurchaseTransactions routes
 	purchaseTransactions := api.Group("/purchase-transactions")
-	purchaseTransactions.Get("/", handlers.GetAllPurchaseTransactions)
-	purchaseTransactions.Get("/:id", handlers.GetPurchaseTransaction)
-	purchaseTransactions.Post("/", handlers.CreatePurchaseTransaction)
-	purchaseTransactions.Put("/:id", handlers.UpdatePurchaseTransaction)
-	purchaseTransactions.Delete("/:id", handlers.DeletePurchaseTransaction)
-	purchaseTransactions.Post("/:id/complete", handlers.CompletePurchaseTransaction)
-	purchaseTransactions.Post("/:id/cancel", handlers.CancelPurchaseTransaction)
-	purchaseTransactions.Put("/:id/receipt", handlers.UploadPurchaseReceipt)
+	purchaseTransactions.Get("/", middleware.RequirePermission("purchases:read"), handlers.GetAllPurchaseTransactions)
+	purchaseTransactions.Get("/:id", middleware.RequirePermission("purchases:read"), handlers.GetPurchaseTransaction)
+	purchaseTransactions.Post("/", middleware.RequirePermission("purchases:write"), handlers.CreatePurchaseTransaction)
+	purchaseTransactions.Post("/from-suggestions", middleware.RequirePermission("purchases:write"), handlers.CreatePurchaseDraftsFromSuggestions)
+	purchaseTransactions.Put("/:id", middleware.RequirePermission("purchases:write"), handlers.UpdatePurchaseTransaction)
+	purchaseTransactions.Delete("/:id", middleware.RequirePermission("purchases:delete"), handlers.DeletePurchaseTransaction)
+	purchaseTransactions.Post("/:id/complete", middleware.RequirePermission("purchases:write"), handlers.CompletePurchaseTransaction)
+	purchaseTransactions.Post("/:id/cancel", middleware.RequirePermission("purchases:write"), handlers.CancelPurchaseTransaction)
+	purchaseTransactions.Post("/:id/close", middleware.RequirePermission("purchases:write"), handlers.ClosePurchaseTransaction)
+	purchaseTransactions.Get("/:id/goods-receipts", middleware.RequirePermission("purchases:read"), handlers.GetPurchaseGoodsReceipts)
+	purchaseTransactions.Post("/:id/goods-receipts", middleware.RequirePermission("purchases:write"), handlers.CreateGoodsReceipt)
+	purchaseTransactions.Put("/:id/receipt", middleware.RequirePermission("purchases:write"), handlers.UploadPurchaseReceipt)
+
+	// Goods Receipts routes
+	goodsReceipts := api.Group("/goods-receipts")
+	goodsReceipts.Get("/", middleware.RequirePermission("purchases:read"), handlers.GetAllGoodsReceipts)
+	goodsReceipts.Get("/:id", middleware.RequirePermission("purchases:read"), handlers.GetGoodsReceipt)
+
+	// Warehouses routes
+	warehouses := api.Group("/warehouses")
+	warehouses.Get("/", middleware.RequirePermission("inventory:read"), handlers.GetAllWarehouses)
+	warehouses.Get("/:id", middleware.RequirePermission("inventory:read"), handlers.GetWarehouse)
+	warehouses.Post("/", middleware.RequirePermission("inventory:write"), handlers.CreateWarehouse)
+	warehouses.Put("/:id", middleware.RequirePermission("inventory:write"), handlers.UpdateWarehouse)
+	warehouses.Delete("/:id", middleware.RequirePermission("inventory:delete"), handlers.DeleteWarehouse)
+	warehouses.Post("/:id/restore", middleware.RequirePermission("inventory:delete"), handlers.RestoreWarehouse)
+
+	// Stock Transfers routes
+	stockTransfers := api.Group("/stock-transfers")
+	stockTransfers.Get("/", middleware.RequirePermission("inventory:read"), handlers.GetAllStockTransfers)
+	stockTransfers.Get("/:id", middleware.RequirePermission("inventory:read"), handlers.GetStockTransfer)
+	stockTransfers.Post("/", middleware.RequirePermission("inventory:write"), handlers.CreateStockTransfer)
+	stockTransfers.Delete("/:id", middleware.RequirePermission("inventory:delete"), handlers.DeleteStockTransfer)
+	stockTransfers.Post("/:id/complete", middleware.RequirePermission("inventory:write"), handlers.CompleteStockTransfer)
+	stockTransfers.Post("/:id/cancel", middleware.RequirePermission("inventory:write"), handlers.CancelStockTransfer)
+
+	// Stock Opnames routes
+	stockOpnames := api.Group("/stock-opnames")
+	stockOpnames.Get("/", middleware.RequirePermission("inventory:read"), handlers.GetAllStockOpnames)
+	stockOpnames.Get("/:id", middleware.RequirePermission("inventory:read"), handlers.GetStockOpname)
+	stockOpnames.Post("/", middleware.RequirePermission("inventory:write"), handlers.CreateStockOpname)
+	stockOpnames.Put("/:id/counts", middleware.RequirePermission("inventory:write"), handlers.UpdateStockOpnameCounts)
+	stockOpnames.Get("/:id/variance", middleware.RequirePermission("inventory:read"), handlers.GetStockOpnameVariance)
+	stockOpnames.Post("/:id/post", middleware.RequirePermission("inventory:write"), handlers.PostStockOpname)
+	stockOpnames.Post("/:id/cancel", middleware.RequirePermission("inventory:write"), handlers.CancelStockOpname)
 
 	// Reports routes
 	reports := api.Group("/reports")
-	reports.Get("/purchases", handlers.GetPurchasingReport)
-	reports.Get("/sales", handlers.GetSalesReport)
-	reports.Get("/books-stock", handlers.GetBooksStockReport)
-	reports.Get("/credits", handlers.GetCreditsReport)
-
-	// Admin Only routes
-	api.Use(middleware.AdminOnly())
+	reports.Get("/purchases", middleware.RequirePermission("reports:read"), handlers.GetPurchasingReport)
+	reports.Get("/sales", middleware.RequirePermission("reports:read"), handlers.GetSalesReport)
+	reports.Get("/books-stock", middleware.RequirePermission("reports:read"), handlers.GetBooksStockReport)
+	reports.Get("/stock-valuation", middleware.RequirePermission("reports:read"), handlers.GetStockValuationReport)
+	reports.Get("/gross-margin", middleware.RequirePermission("reports:read"), handlers.GetGrossMarginReport)
+	reports.Get("/edition-sales", middleware.RequirePermission("reports:read"), handlers.GetEditionSalesReport)
+	reports.Get("/reorder-suggestions", middleware.RequirePermission("reports:read"), handlers.GetReorderSuggestions)
+	reports.Get("/credits", middleware.RequirePermission("reports:read"), handlers.GetCreditsReport)
 
 	// Users routes
 	users := api.Group("/users")
-	users.Get("/", handlers.GetAllUsers)
-	users.Get("/:id", handlers.GetUser)
-	users.Post("/", handlers.CreateUser)
-	users.Put("/:id", handlers.UpdateUser)
-	users.Delete("/:id", handlers.DeleteUser)
+	users.Get("/", middleware.RequirePermission("users:read"), handlers.GetAllUsers)
+	users.Get("/:id", middleware.RequirePermission("users:read"), handlers.GetUser)
+	users.Post("/", middleware.RequirePermission("users:write"), handlers.CreateUser)
+	users.Put("/:id", middleware.RequirePermission("users:write"), handlers.UpdateUser)
+	users.Delete("/:id", middleware.RequirePermission("users:delete"), handlers.DeleteUser)
+	users.Post("/:id/revoke-sessions", middleware.RequirePermission("users:write"), handlers.RevokeUserSessions)
+	users.Post("/:id/unlock", middleware.RequirePermission("users:write"), handlers.UnlockUser)
+	users.Post("/:id/reset-2fa", middleware.RequirePermission("users:write"), handlers.ResetUserTwoFactor)
+
+	// Roles routes
+	roles := api.Group("/roles")
+	roles.Get("/", middleware.RequirePermission("roles:read"), handlers.GetAllRoles)
+	roles.Get("/permissions", middleware.RequirePermission("roles:read"), handlers.GetPermissions)
+	roles.Get("/:id", middleware.RequirePermission("roles:read"), handlers.GetRole)
+	roles.Post("/", middleware.RequirePermission("roles:write"), handlers.CreateRole)
+	roles.Put("/:id", middleware.RequirePermission("roles:write"), handlers.UpdateRole)
+	roles.Delete("/:id", middleware.RequirePermission("roles:write"), handlers.DeleteRole)
+
+	// Audit log routes
+	api.Get("/audit-logs", middleware.RequirePermission("audit_logs:read"), handlers.GetAuditLogs)
+
+	// Sales associate portal, scoped to the sales associate linked to the user
+	portal := api.Group("/portal", middleware.RequirePermission("portal:access"), middleware.SalesAssociateRequired())
+	portal.Get("/sales-transactions", handlers.GetPortalSalesTransactions)
+	portal.Get("/sales-transactions/:id", handlers.GetPortalSalesTransaction)
+	portal.Get("/payments", handlers.GetPortalPayments)
+	portal.Get("/credits", handlers.GetPortalCredits)
+	portal.Get("/statement", handlers.GetPortalStatement)
+	portal.Get("/books", handlers.GetPortalBooks)
+	portal.Get("/orders", handlers.GetPortalOrders)
+	portal.Get("/orders/:id", handlers.GetPortalOrder)
+	portal.Post("/orders", handlers.CreatePortalOrder)
+}
+
+// uploadPermission returns the permission needed to change a file of the resource. Users may
+// always change their own photo.
+func uploadPermission(c *fiber.Ctx) string {
+	switch c.Params("resource") {
+	case "users":
+		if userID, _ := c.Locals("userID").(string); userID != "" && userID == c.Params("id") {
+			return ""
+		}
+		return "users:write"
+	case "books":
+		return "books:write"
+	default:
+		// Unknown resources are rejected by the handler
+		return "master_data:write"
+	}
 }
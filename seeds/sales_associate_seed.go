@@ -113,7 +113,7 @@ func SalesAssociateSeeder(db *gorm.DB) error {
 
 	salesAssociatesData, err := ReadSalesAssociatesCSV("./seeds/files/sales_associates.csv", db)
 	if err != nil {
-		return fmt.Errorf("failed to load sales_associates.csv: %w", err.Error())
+		return fmt.Errorf("failed to load sales_associates.csv: %w", err)
 	}
 
 	fmt.Println("Loaded:", len(salesAssociatesData))
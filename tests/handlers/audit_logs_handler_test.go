@@ -0,0 +1,207 @@
+package handlers_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/config"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditValuesArg matches the JSON of an audit_logs before or after column
+type auditValuesArg map[string]interface{}
+
+func (expected auditValuesArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &actual); err != nil {
+		return false
+	}
+	if len(actual) != len(expected) {
+		return false
+	}
+	for column, value := range expected {
+		if actual[column] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateIsAudited(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+	assert.NoError(t, helpers.RegisterAuditCallbacks(config.DB))
+
+	userID := uuid.New()
+	cityID := uuid.New()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", userID.String())
+		return c.Next()
+	})
+	app.Put("/cities/:id", handlers.UpdateCity)
+
+	created := time.Now()
+	cityRows := func(code, name string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "code", "name", "created_at", "updated_at"}).
+			AddRow(cityID, code, name, created, time.Now())
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1`)).
+		WithArgs(cityID.String()).
+		WillReturnRows(cityRows("JKT", "Jakarta"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "id" = $1`)).
+		WithArgs(cityID).
+		WillReturnRows(cityRows("JKT", "Jakarta"))
+	mock.ExpectExec(`UPDATE "cities" SET .+ WHERE "cities"\."deleted_at" IS NULL AND "id" = .+`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "id" = $1`)).
+		WithArgs(cityID.String()).
+		WillReturnRows(cityRows("JKT", "Jakarta Raya"))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+		WithArgs(userID, models.AuditActionUpdate, "cities", cityID.String(),
+			auditValuesArg{"name": "Jakarta"}, auditValuesArg{"name": "Jakarta Raya"}, "0.0.0.0", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(models.City{ID: cityID, Code: "JKT", Name: "Jakarta Raya"})
+	req := httptest.NewRequest("PUT", "/cities/"+cityID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditLogs(t *testing.T) {
+	app := fiber.New()
+	app.Get("/audit-logs", handlers.GetAuditLogs)
+
+	t.Run("Filter by entity and user", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		userID := uuid.New()
+		entityID := uuid.New().String()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_logs" WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3 ORDER BY created_at DESC LIMIT 20`)).
+			WithArgs("books", entityID, userID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "action", "entity_type", "entity_id", "before", "after", "ip_address", "created_at"}).
+				AddRow(uuid.New(), userID, "update", "books", entityID, `{"price":1000}`, `{"price":1200}`, "10.0.0.1", time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."id" = $1`)).
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "full_name"}).AddRow(userID, "admin@example.com", "Admin"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "audit_logs" WHERE entity_type = $1 AND entity_id = $2 AND user_id = $3`)).
+			WithArgs("books", entityID, userID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest("GET", "/audit-logs?entity_type=books&entity_id="+entityID+"&user_id="+userID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		logs := response["audit_logs"].([]interface{})
+		assert.Len(t, logs, 1)
+		entry := logs[0].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"price": float64(1200)}, entry["after"])
+		assert.Equal(t, "Admin", entry["user"].(map[string]interface{})["full_name"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid action", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/audit-logs?action=read", nil)
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestForceDeleteReassignmentIsAudited(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+	assert.NoError(t, helpers.RegisterAuditCallbacks(config.DB))
+
+	userID := uuid.New()
+	jenisBukuID := uuid.New()
+	replacementID := uuid.New()
+	bookID := uuid.New()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", userID.String())
+		c.Locals("userRole", "admin")
+		return c.Next()
+	})
+	app.Delete("/jenis-buku/:id", handlers.DeleteJenisBuku)
+
+	jenisBukuRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "code", "name"}).AddRow(jenisBukuID, "LKS", "Lembar Kerja")
+	}
+	bookRows := func(jenisBuku uuid.UUID) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "jenis_buku_id"}).AddRow(bookID, "Matematika 1", jenisBuku.String())
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE id = $1`)).
+		WithArgs(jenisBukuID.String()).
+		WillReturnRows(jenisBukuRows())
+	expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE "jenis_buku"."deleted_at" IS NULL AND "jenis_buku"."id" = $1 ORDER BY "jenis_buku"."id" LIMIT 1 FOR UPDATE`)).
+		WithArgs(jenisBukuID).
+		WillReturnRows(jenisBukuRows())
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE jenis_buku_id = $1 AND "books"."deleted_at" IS NULL`)).
+		WithArgs(jenisBukuID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "jenis_buku" WHERE id = $1 AND deleted_at IS NULL`)).
+		WithArgs(replacementID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE jenis_buku_id = $1`)).
+		WithArgs(jenisBukuID).
+		WillReturnRows(bookRows(jenisBukuID))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "jenis_buku_id"=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "id" = $1`)).
+		WithArgs(bookID).
+		WillReturnRows(bookRows(replacementID))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+		WithArgs(userID, models.AuditActionUpdate, "books", bookID.String(),
+			auditValuesArg{"jenis_buku_id": jenisBukuID.String()}, auditValuesArg{"jenis_buku_id": replacementID.String()}, "0.0.0.0", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE "id" = $1`)).
+		WithArgs(jenisBukuID).
+		WillReturnRows(jenisBukuRows())
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "jenis_buku" SET "deleted_at"=$1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+		WithArgs(userID, models.AuditActionDelete, "jenis_buku", jenisBukuID.String(),
+			sqlmock.AnyArg(), nil, "0.0.0.0", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("DELETE", "/jenis-buku/"+jenisBukuID.String()+"?force=true&replacement_id="+replacementID.String(), nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -36,6 +36,10 @@ func TestLogin(t *testing.T) {
 	assert.NoError(t, err)
 	defer testutil.CloseMockDB(db)
 
+	// Keep the lockout counters out of the mocked database
+	handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
 	app := fiber.New()
 	app.Post("/login", handlers.Login)
 
@@ -51,6 +55,13 @@ func TestLogin(t *testing.T) {
 			WithArgs("user@example.com").
 			WillReturnRows(rows)
 
+		// Login starts a session with a refresh token
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+			WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
 		reqBody := LoginRequest{
 			Email:    "user@example.com",
 			Password: "password123",
@@ -68,7 +79,9 @@ func TestLogin(t *testing.T) {
 		json.Unmarshal(respBody, &response)
 
 		assert.NotEmpty(t, response["token"])
+		assert.NotEmpty(t, response["refresh_token"])
 		assert.NotNil(t, response["user"])
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Invalid request body", func(t *testing.T) {
@@ -157,6 +170,10 @@ func TestGetMe(t *testing.T) {
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
 			WithArgs("550e8400-e29b-41d4-a716-446655440000").
 			WillReturnRows(rows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "roles" WHERE name = $1`)).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "permissions"}).
+				AddRow(uuid.New(), "user", `{books:read,sales:read}`))
 
 		req := httptest.NewRequest("GET", "/me", nil)
 		resp, _ := app.Test(req)
@@ -170,6 +187,8 @@ func TestGetMe(t *testing.T) {
 		assert.Equal(t, "user@example.com", response["email"])
 		assert.Equal(t, "Test User", response["full_name"])
 		assert.Equal(t, "user", response["role"])
+		assert.Equal(t, []interface{}{"books:read", "sales:read"}, response["permissions"])
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("User not found", func(t *testing.T) {
@@ -216,9 +235,9 @@ func TestUpdateMe(t *testing.T) {
 			WithArgs("550e8400-e29b-41d4-a716-446655440000").
 			WillReturnRows(rows)
 
-		// Mock: Update user
+		// Mock: Update only the full name, leaving token_version untouched
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "full_name"=$1,"updated_at"=$2 WHERE "id" = $3`)).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -280,14 +299,19 @@ func TestUpdateMe(t *testing.T) {
 			WithArgs("550e8400-e29b-41d4-a716-446655440000").
 			WillReturnRows(rows)
 
-		// Mock: Update user with new password
+		// Mock: Update the password hash and revoke every session
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "password_hash"=$1,"updated_at"=$2 WHERE "id" = $3`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "token_version"=token_version + 1 WHERE id = $1`)).
+			WithArgs(userID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1,"updated_at"=$2 WHERE user_id = $3 AND revoked_at IS NULL`)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
 		mock.ExpectCommit()
 
 		updateData := map[string]interface{}{
-			"password": "newpassword123",
+			"password": "NewPassword123!",
 		}
 
 		body, _ := json.Marshal(updateData)
@@ -305,6 +329,24 @@ func TestUpdateMe(t *testing.T) {
 		assert.Equal(t, "Test User", response["full_name"])
 	})
 
+	t.Run("Weak password is rejected", func(t *testing.T) {
+		userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+
+		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
+			AddRow(userID, "user@example.com", "oldhashedpass", "Test User", "user", time.Now(), time.Now())
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs("550e8400-e29b-41d4-a716-446655440000").
+			WillReturnRows(rows)
+
+		body, _ := json.Marshal(map[string]interface{}{"password": "newpassword123"})
+		req := httptest.NewRequest("PUT", "/me", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
 	t.Run("Successfully update full name and password", func(t *testing.T) {
 		userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
 
@@ -316,15 +358,19 @@ func TestUpdateMe(t *testing.T) {
 			WithArgs("550e8400-e29b-41d4-a716-446655440000").
 			WillReturnRows(rows)
 
-		// Mock: Update user
+		// Mock: Update user and revoke every session
 		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "full_name"=$1,"password_hash"=$2,"updated_at"=$3 WHERE "id" = $4`)).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "token_version"=token_version + 1`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 
 		updateData := map[string]interface{}{
 			"full_name": "Updated Name",
-			"password":  "newpassword123",
+			"password":  "NewPassword123!",
 		}
 
 		body, _ := json.Marshal(updateData)
@@ -339,7 +385,8 @@ func TestUpdateMe(t *testing.T) {
 		json.Unmarshal(respBody, &response)
 
 		assert.Equal(t, "user@example.com", response["email"])
-		// The response will have the updated full_name
-		assert.NotNil(t, response["full_name"])
+		assert.Equal(t, "Updated Name", response["full_name"])
 	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
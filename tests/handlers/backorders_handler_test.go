@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletePurchaseTransactionAllocatesBackorders(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/purchase-transactions/:id/complete", handlers.CompletePurchaseTransaction)
+
+	purchaseID := uuid.New()
+	warehouseID := uuid.New()
+	bookID := uuid.New()
+	stockID := uuid.New()
+	olderSaleID := uuid.New()
+	newerSaleID := uuid.New()
+	olderItemID := uuid.New()
+	newerItemID := uuid.New()
+	purchaseItemID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transactions" WHERE id = $1 ORDER BY "purchase_transactions"."id" LIMIT 1 FOR UPDATE`)).
+		WithArgs(purchaseID.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status", "warehouse_id"}).
+			AddRow(purchaseID, uuid.New(), "PRC2024010100000001", time.Now(), 500000.0, models.PurchaseStatusPending, warehouseID))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transaction_items" WHERE "purchase_transaction_items"."purchase_transaction_id" = $1`)).
+		WithArgs(purchaseID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "purchase_transaction_id", "book_id", "quantity", "price", "subtotal"}).
+			AddRow(purchaseItemID, purchaseID, bookID, 10, 50000.0, 500000.0))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_receipt), '') FROM "goods_receipts"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "average_cost"=ROUND(`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Receive 10 into an empty warehouse
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+		WithArgs(10, sqlmock.AnyArg(), stockID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transaction_items" SET "received_quantity"=received_quantity + $1,"updated_at"=$2 WHERE id = $3 AND received_quantity + $4 <= quantity`)).
+		WithArgs(10, sqlmock.AnyArg(), purchaseItemID, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "goods_receipts"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "goods_receipt_items"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+	// Allocate to the waiting lines, oldest first
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 10))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sales_transaction_items.*, sales_transactions.no_invoice FROM "sales_transaction_items" JOIN sales_transactions`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity", "backordered_quantity", "no_invoice"}).
+			AddRow(olderItemID, olderSaleID, bookID, 8, 6, "INV2024010100000001").
+			AddRow(newerItemID, newerSaleID, bookID, 12, 12, "INV2024010200000001"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 10))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+		WithArgs(4, sqlmock.AnyArg(), stockID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transaction_items" SET "backordered_quantity"=$1`)).
+		WithArgs(0, sqlmock.AnyArg(), olderItemID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 4))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+		WithArgs(0, sqlmock.AnyArg(), stockID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transaction_items" SET "backordered_quantity"=$1`)).
+		WithArgs(8, sqlmock.AnyArg(), newerItemID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transactions" SET`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/purchase-transactions/"+purchaseID.String()+"/complete", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var response struct {
+		Allocations []handlers.BackorderAllocation `json:"backorder_allocations"`
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+
+	assert.Len(t, response.Allocations, 2)
+	assert.Equal(t, olderSaleID, response.Allocations[0].SalesTransactionID)
+	assert.Equal(t, 6, response.Allocations[0].AllocatedQuantity)
+	assert.Equal(t, 0, response.Allocations[0].RemainingQuantity)
+	assert.Equal(t, newerSaleID, response.Allocations[1].SalesTransactionID)
+	assert.Equal(t, 4, response.Allocations[1].AllocatedQuantity)
+	assert.Equal(t, 8, response.Allocations[1].RemainingQuantity)
+}
@@ -35,7 +35,7 @@ func TestGetAllBidangStudi(t *testing.T) {
 			AddRow(bidangStudiID1, "BS001", "Mathematics", "Test Description", time.Now(), time.Now()).
 			AddRow(bidangStudiID2, "BS002", "Science", "Test Description", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" ORDER BY name ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE "bidang_studi"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WillReturnRows(rows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -66,12 +66,12 @@ func TestGetAllBidangStudi(t *testing.T) {
 		bidangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(bidangStudiID, "BS001", "Science", &description, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3 ORDER BY name ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WithArgs("%BS001%", "%BS001%", "%BS001%").
 			WillReturnRows(bidangStudiRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL`)).
 			WithArgs("%BS001%", "%BS001%", "%BS001%").
 			WillReturnRows(countRows)
 
@@ -98,12 +98,12 @@ func TestGetAllBidangStudi(t *testing.T) {
 		bidangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(bidangStudiID, "BS001", "Science", nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3 ORDER BY name ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WithArgs("%Science%", "%Science%", "%Science%").
 			WillReturnRows(bidangStudiRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL`)).
 			WithArgs("%Science%", "%Science%", "%Science%").
 			WillReturnRows(countRows)
 
@@ -130,12 +130,12 @@ func TestGetAllBidangStudi(t *testing.T) {
 		bidangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(bidangStudiID, "BS001", "Science", "meta-science", time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3 ORDER BY name ASC LIMIT 20`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WithArgs("%meta-science%", "%meta-science%", "%meta-science%").
 			WillReturnRows(bidangStudiRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "bidang_studi" WHERE (bidang_studi.code ILIKE $1 OR bidang_studi.name ILIKE $2 OR bidang_studi.description ILIKE $3) AND "bidang_studi"."deleted_at" IS NULL`)).
 			WithArgs("%meta-science%", "%meta-science%", "%meta-science%").
 			WillReturnRows(countRows)
 
@@ -268,7 +268,7 @@ func TestUpdateBidangStudi(t *testing.T) {
 			WillReturnRows(rows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "bidang_studi" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "bidang_studi" SET .+ WHERE "bidang_studi"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -326,9 +326,17 @@ func TestDeleteBidangStudi(t *testing.T) {
 	t.Run("Successfully delete bidang studi", func(t *testing.T) {
 		bidangStudiID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "bidang_studi" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE id = $1 AND "bidang_studi"."deleted_at" IS NULL`)).
 			WithArgs(bidangStudiID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bidangStudiID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE "bidang_studi"."deleted_at" IS NULL AND "bidang_studi"."id" = $1 ORDER BY "bidang_studi"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(bidangStudiID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bidangStudiID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "bidang_studi" SET "deleted_at"=$1 WHERE "bidang_studi"."id" = $2 AND "bidang_studi"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), bidangStudiID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -347,11 +355,9 @@ func TestDeleteBidangStudi(t *testing.T) {
 	t.Run("BidangStudi not found", func(t *testing.T) {
 		bidangStudiID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "bidang_studi" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bidang_studi" WHERE id = $1 AND "bidang_studi"."deleted_at" IS NULL`)).
 			WithArgs(bidangStudiID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/bidang-studi/"+bidangStudiID.String(), nil)
 		resp, _ := app.Test(req)
@@ -37,7 +37,7 @@ func TestGetAllBillers(t *testing.T) {
 			AddRow(billerID1, "BIL001", "Biller A", "Test Description", "12.345.678.9-012.345", "Jl. Test 1", cityID1, "021-111", "021-112", "021-113", "biller_a@example.com", "www.biller-a.com", nil, time.Now(), time.Now()).
 			AddRow(billerID2, "BIL002", "Biller B", nil, "98.765.432.1-098.765", "Jl. Test 2", cityID2, "021-222", nil, nil, nil, nil, nil, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" ORDER BY created_at DESC`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE "billers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WillReturnRows(billerRows)
 
 		cityRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
@@ -77,7 +77,7 @@ func TestGetAllBillers(t *testing.T) {
 		billerRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "npwp", "address", "city_id", "phone1", "phone2", "fax", "email", "website", "logo_url", "created_at", "updated_at"}).
 			AddRow(billerID, "BIL001", "Biller A", &description, "12.345.678.9-012.345", "Jl. Test", cityID, "021-111", nil, nil, nil, nil, nil, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE (billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3) AND "billers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%BIL001%", "%BIL001%", "%BIL001%").
 			WillReturnRows(billerRows)
 
@@ -116,7 +116,7 @@ func TestGetAllBillers(t *testing.T) {
 		billerRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "npwp", "address", "city_id", "phone1", "phone2", "fax", "email", "website", "logo_url", "created_at", "updated_at"}).
 			AddRow(billerID, "BIL001", "BillerA", nil, "12.345.678.9-012.345", "Jl. Test", cityID, "021-111", nil, nil, nil, nil, nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE (billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3) AND "billers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%BillerA%", "%BillerA%", "%BillerA%").
 			WillReturnRows(billerRows)
 
@@ -155,7 +155,7 @@ func TestGetAllBillers(t *testing.T) {
 		billerRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "npwp", "address", "city_id", "phone1", "phone2", "fax", "email", "website", "logo_url", "created_at", "updated_at"}).
 			AddRow(billerID, "BIL001", "BillerA", "Yang di Ampera", "12.345.678.9-012.345", "Jl. Test", cityID, "021-111", nil, nil, nil, nil, nil, time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE (billers.code ILIKE $1 OR billers.name ILIKE $2 OR billers.description ILIKE $3) AND "billers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%ampera%", "%ampera%", "%ampera%").
 			WillReturnRows(billerRows)
 
@@ -315,7 +315,7 @@ func TestUpdateBiller(t *testing.T) {
 			WillReturnRows(billerRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "billers" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "billers" SET .+ WHERE "billers"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -383,9 +383,17 @@ func TestDeleteBiller(t *testing.T) {
 	t.Run("Successfully delete biller", func(t *testing.T) {
 		billerID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "billers" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE id = $1 AND "billers"."deleted_at" IS NULL`)).
 			WithArgs(billerID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(billerID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE "billers"."deleted_at" IS NULL AND "billers"."id" = $1 ORDER BY "billers"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(billerID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(billerID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "billers" SET "deleted_at"=$1 WHERE "billers"."id" = $2 AND "billers"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), billerID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -404,11 +412,9 @@ func TestDeleteBiller(t *testing.T) {
 	t.Run("Biller not found", func(t *testing.T) {
 		billerID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "billers" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "billers" WHERE id = $1 AND "billers"."deleted_at" IS NULL`)).
 			WithArgs(billerID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/billers/"+billerID.String(), nil)
 		resp, _ := app.Test(req)
@@ -0,0 +1,188 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupBook(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books/lookup", handlers.LookupBook)
+
+	get := func(barcode string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("GET", "/books/lookup?barcode="+barcode, nil)
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("ISBN-10 scan matches the stored ISBN-13", func(t *testing.T) {
+		newEditionID := uuid.New()
+		oldEditionID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn IN ($1,$2) OR UPPER(code) = UPPER($3)) AND "books"."deleted_at" IS NULL ORDER BY year DESC, periode DESC`)).
+			WithArgs("0306406152", "9780306406157", "0-306-40615-2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "isbn", "year", "periode"}).
+				AddRow(newEditionID, "Matematika 1", "9780306406157", "2025", 1).
+				AddRow(oldEditionID, "Matematika 1", "9780306406157", "2024", 1))
+
+		status, response := get("0-306-40615-2")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "isbn", response["matched_by"])
+		assert.Equal(t, newEditionID.String(), response["book"].(map[string]interface{})["id"])
+		assert.Len(t, response["other_editions"], 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("SKU scan matches the book code", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE UPPER(code) = UPPER($1) AND "books"."deleted_at" IS NULL ORDER BY year DESC, periode DESC`)).
+			WithArgs("mtk-01").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "code"}).
+				AddRow(uuid.New(), "Matematika 1", "MTK-01"))
+
+		status, response := get("mtk-01")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "code", response["matched_by"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown barcode", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE UPPER(code) = UPPER($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		status, _ := get("UNKNOWN")
+
+		assert.Equal(t, fiber.StatusNotFound, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Barcode is required", func(t *testing.T) {
+		status, response := get("")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "barcode is required", response["error"])
+	})
+}
+
+func TestCreateBookIdentifiers(t *testing.T) {
+	app := fiber.New()
+	app.Post("/books", handlers.CreateBook)
+
+	post := func(book models.Book) (int, map[string]interface{}) {
+		body, _ := json.Marshal(book)
+		req := httptest.NewRequest("POST", "/books", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("ISBN is stored without hyphens", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		isbn := "978-0-306-40615-7"
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+				AddRow(uuid.New(), time.Now(), time.Now()))
+		mock.ExpectCommit()
+
+		status, response := post(models.Book{Name: "Matematika 1", ISBN: &isbn})
+
+		assert.Equal(t, fiber.StatusCreated, status)
+		assert.Equal(t, "9780306406157", response["isbn"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ISBN with a wrong check digit is rejected", func(t *testing.T) {
+		isbn := "978-0-306-40615-8"
+
+		status, response := post(models.Book{Name: "Matematika 1", ISBN: &isbn})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.True(t, strings.HasPrefix(response["error"].(string), "Invalid ISBN"))
+	})
+
+	t.Run("Duplicate code is rejected", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		code := " MTK-01 "
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (code = $1 AND id <> $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("MTK-01", uuid.Nil).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		status, response := post(models.Book{Name: "Matematika 1", Code: &code})
+
+		assert.Equal(t, fiber.StatusConflict, status)
+		assert.Equal(t, "Book code already exists", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBookBarcode(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books/:id/barcode", handlers.GetBookBarcode)
+
+	bookID := uuid.New()
+
+	t.Run("SVG label with ISBN and price", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "isbn", "price"}).
+				AddRow(bookID, "Matematika 1", "9780306406157", 50000.0))
+
+		req := httptest.NewRequest("GET", "/books/"+bookID.String()+"/barcode", nil)
+		resp, _ := app.Test(req)
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "image/svg+xml", resp.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), "Rp 50.000")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Book without ISBN or code", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(bookID, "Matematika 1"))
+
+		req := httptest.NewRequest("GET", "/books/"+bookID.String()+"/barcode?format=png", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
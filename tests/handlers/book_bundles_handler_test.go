@@ -0,0 +1,284 @@
+package handlers_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateBookBundle(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/book-bundles", handlers.CreateBookBundle)
+
+	post := func(body interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/book-bundles", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	price := 150000.0
+
+	t.Run("Bundle without books", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_bundles" WHERE code = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		status, response := post(handlers.BookBundleRequest{
+			Code:  testutil.StringPtr("PKT-4SD"),
+			Name:  testutil.StringPtr("Kelas 4 SD Kurikulum Merdeka paket lengkap"),
+			Price: &price,
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "A bundle needs at least one book", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Duplicate book in bundle", func(t *testing.T) {
+		bookID := uuid.New().String()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_bundles" WHERE code = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		status, response := post(handlers.BookBundleRequest{
+			Code:  testutil.StringPtr("PKT-4SD"),
+			Name:  testutil.StringPtr("Paket 4 SD"),
+			Price: &price,
+			Items: []handlers.BookBundleItemRequest{{BookID: bookID}, {BookID: bookID, Quantity: 2}},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Duplicate book_id in bundle: "+bookID, response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Code already exists", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_bundles" WHERE code = $1`)).
+			WithArgs("PKT-4SD").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		status, response := post(handlers.BookBundleRequest{
+			Code:  testutil.StringPtr(" PKT-4SD "),
+			Name:  testutil.StringPtr("Paket 4 SD"),
+			Price: &price,
+			Items: []handlers.BookBundleItemRequest{{BookID: uuid.New().String()}},
+		})
+
+		assert.Equal(t, fiber.StatusConflict, status)
+		assert.Equal(t, "Bundle code already exists", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCreateSalesTransactionWithBundle(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/sales-transactions", handlers.CreateSalesTransaction)
+
+	warehouseID := uuid.New()
+	bundleID := uuid.New()
+	bookIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	mock.ExpectQuery(`SELECT "id" FROM "billers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "is_default"}).AddRow(warehouseID, "GDG", "Gudang Utama", true))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_bundles" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "price", "is_active"}).
+			AddRow(bundleID, "PKT-4SD", "Paket 4 SD", 100000.0, true))
+	bundleItemRows := sqlmock.NewRows([]string{"id", "bundle_id", "book_id", "quantity"})
+	for _, bookID := range bookIDs {
+		bundleItemRows.AddRow(uuid.New(), bundleID, bookID, 1)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_bundle_items" WHERE "book_bundle_items"."bundle_id" = $1 ORDER BY created_at ASC, id ASC`)).
+		WillReturnRows(bundleItemRows)
+	bookRows := sqlmock.NewRows([]string{"id", "name", "price", "average_cost"})
+	for _, bookID := range bookIDs {
+		bookRows.AddRow(bookID, "Buku", 40000.0, 20000.0)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" IN ($1,$2,$3)`)).
+		WillReturnRows(bookRows)
+
+	// Every book of the bundle is taken from stock
+	for _, bookID := range bookIDs {
+		stockRows := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(uuid.New(), bookID, warehouseID, 10)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(stockRows())
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(stockRows())
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WithArgs(9, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WithArgs(-1, sqlmock.AnyArg(), bookID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_invoice), '') FROM "sales_transactions"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_transactions"`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "T", sqlmock.AnyArg(), 100000.0,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+	// The bundle price is spread over the books, the last one takes the rounding difference
+	itemArgs := func(bookID uuid.UUID, subtotal float64) []driver.Value {
+		return []driver.Value{sqlmock.AnyArg(), bookID, 1, 0, subtotal, 0.0, 0.0, subtotal, 20000.0, 20000.0,
+			bundleID, 1, sqlmock.AnyArg(), sqlmock.AnyArg()}
+	}
+	var args []driver.Value
+	args = append(args, itemArgs(bookIDs[0], 33333.33)...)
+	args = append(args, itemArgs(bookIDs[1], 33333.33)...)
+	args = append(args, itemArgs(bookIDs[2], 33333.34)...)
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_transaction_items"`)).
+		WithArgs(args...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()).AddRow(uuid.New()).AddRow(uuid.New()))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+	requestBody := handlers.CreateTransactionRequest{
+		SalesAssociateID: uuid.New().String(),
+		PaymentType:      "T",
+		TransactionDate:  testutil.StringPtr("2025-07-01"),
+		Year:             "2025",
+		Items: []handlers.CreateTransactionItemRequest{
+			{BundleID: bundleID.String(), Quantity: 1},
+		},
+	}
+	bodyBytes, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/sales-transactions", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateSalesTransactionRejectsBundleWithDeletedBook(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/sales-transactions", handlers.CreateSalesTransaction)
+
+	warehouseID := uuid.New()
+	bundleID := uuid.New()
+	liveBookID, deletedBookID := uuid.New(), uuid.New()
+
+	mock.ExpectQuery(`SELECT "id" FROM "billers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "is_default"}).AddRow(warehouseID, "GDG", "Gudang Utama", true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_bundles" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "price", "is_active"}).
+			AddRow(bundleID, "PKT-4SD", "Paket 4 SD", 100000.0, true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_bundle_items" WHERE "book_bundle_items"."bundle_id" = $1 ORDER BY created_at ASC, id ASC`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "bundle_id", "book_id", "quantity"}).
+			AddRow(uuid.New(), bundleID, liveBookID, 1).
+			AddRow(uuid.New(), bundleID, deletedBookID, 1))
+	// The books of a sellable bundle are loaded without the deleted ones
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" IN ($1,$2) AND "books"."deleted_at" IS NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price"}).AddRow(liveBookID, "Buku", 40000.0))
+	mock.ExpectRollback()
+
+	requestBody := handlers.CreateTransactionRequest{
+		SalesAssociateID: uuid.New().String(),
+		PaymentType:      "T",
+		TransactionDate:  testutil.StringPtr("2025-07-01"),
+		Year:             "2025",
+		Items: []handlers.CreateTransactionItemRequest{
+			{BundleID: bundleID.String(), Quantity: 1},
+		},
+	}
+	bodyBytes, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/sales-transactions", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var response map[string]interface{}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+
+	assert.Equal(t, "Bundle Paket 4 SD contains a deleted book", response["error"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateSalesTransactionRejectsBundle(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Put("/sales-transactions/:id", handlers.UpdateSalesTransaction)
+
+	transactionID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows(salesTransactionColumns).
+			AddRow(transactionID, uuid.New(), uuid.New(), "INV2025070100000001", "T", time.Now(), 100000.0, 0,
+				1, "2025", nil, nil, nil, time.Now(), time.Now()))
+
+	bodyBytes, _ := json.Marshal(handlers.UpdateTransactionRequest{
+		Items: []handlers.CreateTransactionItemRequest{{BundleID: uuid.New().String(), Quantity: 1}},
+	})
+	req := httptest.NewRequest("PUT", "/sales-transactions/"+transactionID.String(), bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	var response map[string]interface{}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+	assert.Equal(t, "Bundles can only be added when creating a transaction", response["error"])
+}
+
+func TestDeleteBookBundle(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Delete("/book-bundles/:id", handlers.DeleteBookBundle)
+
+	bundleID := uuid.New().String()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transaction_items" WHERE bundle_id = $1`)).
+		WithArgs(bundleID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/book-bundles/"+bundleID, nil))
+
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
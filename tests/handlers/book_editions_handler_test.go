@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollBookEdition(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/books/roll-edition", handlers.RollBookEdition)
+
+	curriculumID := uuid.New()
+	firstBookID := uuid.New()
+	secondBookID := uuid.New()
+
+	post := func(body map[string]interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/books/roll-edition", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Dry run previews new prices and skips rolled books", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (NOT (year = $1 AND periode = $2)) AND year = $3 AND curriculum_id = $4`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "year", "periode", "price", "stock"}).
+				AddRow(firstBookID, "Matematika 1", "2024", 1, 48000.0, 120).
+				AddRow(secondBookID, "Bahasa Indonesia 1", "2024", 1, 52000.0, 80))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "previous_edition_id" FROM "books" WHERE (previous_edition_id IN ($1,$2) AND year = $3 AND periode = $4) AND "books"."deleted_at" IS NULL`)).
+			WillReturnRows(sqlmock.NewRows([]string{"previous_edition_id"}).AddRow(secondBookID))
+
+		status, response := post(map[string]interface{}{
+			"year":                     "2024",
+			"curriculum_id":            curriculumID.String(),
+			"target_year":              "2025",
+			"target_periode":           1,
+			"price_adjustment_percent": 5,
+			"round_to":                 500,
+			"dry_run":                  true,
+		})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(1), response["total_created"])
+		assert.Equal(t, float64(1), response["total_skipped"])
+
+		created := response["created"].([]interface{})[0].(map[string]interface{})
+		book := created["book"].(map[string]interface{})
+		assert.Equal(t, firstBookID.String(), book["previous_edition_id"])
+		assert.Equal(t, "2025", book["year"])
+		assert.Equal(t, float64(50500), book["price"])
+		assert.Equal(t, float64(0), book["stock"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Target edition is required", func(t *testing.T) {
+		status, response := post(map[string]interface{}{"year": "2024"})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "target_year and target_periode are required", response["error"])
+	})
+
+	t.Run("A filter is required", func(t *testing.T) {
+		status, _ := post(map[string]interface{}{"target_year": "2025", "target_periode": 1})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
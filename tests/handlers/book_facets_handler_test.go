@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllBooksFacets(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books", handlers.GetAllBooks)
+
+	publisherID := uuid.New()
+	otherPublisherID := uuid.New()
+	bidangStudiID := uuid.New()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (books.publisher_id = $1 AND books.price >= $2) AND "books"."deleted_at" IS NULL ORDER BY books.created_at DESC LIMIT 20`)).
+		WithArgs(publisherID.String(), "10000").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (books.publisher_id = $1 AND books.price >= $2) AND "books"."deleted_at" IS NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	// Other facets keep every filter
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.bidang_studi_id AS value, bidang_studi.name AS label, COUNT(*) AS count FROM "books" LEFT JOIN bidang_studi ON bidang_studi.id = books.bidang_studi_id WHERE books.deleted_at IS NULL AND (books.publisher_id = $1 AND books.price >= $2) GROUP BY books.bidang_studi_id, bidang_studi.name ORDER BY count DESC, value`)).
+		WithArgs(publisherID.String(), "10000").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}).
+			AddRow(bidangStudiID.String(), "Matematika", 2).
+			AddRow(nil, nil, 1))
+	for _, facet := range []string{"jenis_buku_id", "jenjang_studi_id", "curriculum_id"} {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.`+facet+` AS value`)).
+			WithArgs(publisherID.String(), "10000").
+			WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}))
+	}
+
+	// The publisher facet leaves out its own filter
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.publisher_id AS value, publishers.name AS label, COUNT(*) AS count FROM "books" LEFT JOIN publishers ON publishers.id = books.publisher_id WHERE books.deleted_at IS NULL AND books.price >= $1 GROUP BY books.publisher_id, publishers.name ORDER BY count DESC, value`)).
+		WithArgs("10000").
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}).
+			AddRow(publisherID.String(), "Erlangga", 3).
+			AddRow(otherPublisherID.String(), "Yudhistira", 5))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.merk_buku_id AS value`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.periode AS value, NULL AS label, COUNT(*) AS count FROM "books" WHERE books.deleted_at IS NULL AND (books.publisher_id = $1 AND books.price >= $2) GROUP BY "books"."periode" ORDER BY count DESC, value`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}).AddRow(1, nil, 3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.year AS value`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}).AddRow("2025", nil, 3))
+	// Deleted kelas sharing the code of a current one are not joined
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.kelas AS value, kelas.name AS label, COUNT(*) AS count FROM "books" LEFT JOIN kelas ON kelas.code = books.kelas AND kelas.deleted_at IS NULL WHERE`)).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "label", "count"}))
+
+	// The price facet leaves out both price filters
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(books.price), MAX(books.price), COUNT(*) FILTER (WHERE books.price >= 0 AND books.price < 25000)`)).
+		WithArgs(publisherID.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max", "r0", "r1", "r2", "r3", "r4"}).
+			AddRow(15000.0, 120000.0, 1, 1, 0, 1, 0))
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/books?facets=true&publisher_id="+publisherID.String()+"&price_min=10000", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var response struct {
+		Facets struct {
+			BidangStudiID []handlers.BookFacetValue `json:"bidang_studi_id"`
+			PublisherID   []handlers.BookFacetValue `json:"publisher_id"`
+			Periode       []handlers.BookFacetValue `json:"periode"`
+			Price         handlers.BookPriceFacet   `json:"price"`
+		} `json:"facets"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	assert.NoError(t, json.Unmarshal(body, &response))
+
+	assert.Len(t, response.Facets.BidangStudiID, 2)
+	assert.Equal(t, "Matematika", *response.Facets.BidangStudiID[0].Label)
+	assert.Nil(t, response.Facets.BidangStudiID[1].Value)
+	assert.Equal(t, int64(5), response.Facets.PublisherID[1].Count)
+	assert.Equal(t, "1", *response.Facets.Periode[0].Value)
+
+	assert.Equal(t, 15000.0, *response.Facets.Price.Min)
+	assert.Len(t, response.Facets.Price.Ranges, 5)
+	assert.Equal(t, 25000.0, *response.Facets.Price.Ranges[0].To)
+	assert.Equal(t, int64(1), response.Facets.Price.Ranges[3].Count)
+	assert.Nil(t, response.Facets.Price.Ranges[4].To)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
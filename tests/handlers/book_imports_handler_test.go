@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportBooks(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/books/import", handlers.ImportBooks)
+
+	upload := func(filename, content string, dryRun bool) (int, map[string]interface{}) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write([]byte(content))
+		if !dryRun {
+			writer.WriteField("dry_run", "false")
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/books/import", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	merkBukuID := uuid.New()
+	existingID := uuid.New()
+
+	csvFile := "Code,ISBN,Name,Year,Price,Merk Buku Code\n" +
+		"MTK-01,978-0-306-40615-7,Matematika 1,2025,50000,mb01\n" +
+		"IPA-01,,IPA 1,,55000,\n" +
+		",,,,,\n" +
+		"BIN-01,9780306406158,Bahasa Indonesia 1,2025,abc,MB99\n"
+
+	expectLookups := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, name FROM "merk_buku" WHERE UPPER(code) IN ($1,$2) AND deleted_at IS NULL`)).
+			WithArgs("MB01", "MB99").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name"}).AddRow(merkBukuID, "MB01", "Erlangga"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, isbn, name FROM "books" WHERE (code IN ($1,$2,$3) OR isbn IN ($4,$5)) AND "books"."deleted_at" IS NULL`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "isbn", "name"}).AddRow(existingID, "IPA-01", nil, "IPA 1"))
+	}
+
+	t.Run("Dry run reports row errors", func(t *testing.T) {
+		expectLookups()
+
+		status, response := upload("books.csv", csvFile, true)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, true, response["dry_run"])
+		assert.Equal(t, float64(3), response["total_rows"])
+		assert.Equal(t, float64(1), response["created"])
+		assert.Equal(t, float64(1), response["updated"])
+		assert.Equal(t, float64(1), response["failed"])
+
+		rows := response["rows"].([]interface{})
+		assert.Equal(t, "create", rows[0].(map[string]interface{})["action"])
+		assert.Equal(t, "9780306406157", rows[0].(map[string]interface{})["isbn"])
+		assert.Equal(t, "update", rows[1].(map[string]interface{})["action"])
+		assert.Equal(t, existingID.String(), rows[1].(map[string]interface{})["book_id"])
+
+		failedRow := rows[2].(map[string]interface{})
+		assert.Equal(t, float64(5), failedRow["row"])
+		assert.Equal(t, "error", failedRow["action"])
+		assert.Len(t, failedRow["errors"], 3)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nothing is written when a row has errors", func(t *testing.T) {
+		expectLookups()
+
+		status, response := upload("books.csv", csvFile, false)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+		assert.Equal(t, "Some rows have errors, nothing was imported", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Valid XLSX file is imported in one transaction", func(t *testing.T) {
+		var file bytes.Buffer
+		writer, _ := helpers.NewXLSXWriter(&file, "Books", []string{"code", "name", "year", "price"})
+		writer.WriteRow([]interface{}{"MTK-01", "Matematika 1", "2025", 50000})
+		writer.WriteRow([]interface{}{"IPA-01", "IPA 1 Revisi", nil, 56000})
+		writer.Close()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, isbn, name FROM "books" WHERE code IN ($1,$2)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "isbn", "name"}).AddRow(existingID, "IPA-01", nil, "IPA 1"))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+				AddRow(uuid.New(), time.Now(), time.Now()))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, response := upload("books.xlsx", file.String(), false)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(1), response["created"])
+		assert.Equal(t, float64(1), response["updated"])
+		assert.NotNil(t, response["rows"].([]interface{})[0].(map[string]interface{})["book_id"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Database errors are not shown", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, isbn, name FROM "books" WHERE code IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "isbn", "name"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+			WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "books_code_key"`))
+		mock.ExpectRollback()
+
+		status, response := upload("books.csv", "code,name,year,price\nMTK-01,Matematika 1,2025,50000\n", false)
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+		assert.Equal(t, map[string]interface{}{"error": "Failed to import books"}, response)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown columns are rejected", func(t *testing.T) {
+		status, response := upload("books.csv", "code,nama\nMTK-01,Matematika\n", true)
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Unknown columns: nama", response["error"])
+	})
+
+	t.Run("Unsupported file type", func(t *testing.T) {
+		status, _ := upload("books.xls", "code\n", true)
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
+
+func TestGetBookImportTemplate(t *testing.T) {
+	app := fiber.New()
+	app.Get("/books/import/template", handlers.GetBookImportTemplate)
+
+	t.Run("XLSX template", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/books/import/template", nil))
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		rows, err := helpers.ReadSpreadsheet("template.xlsx", body)
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.Equal(t, "code", rows[0][0])
+		assert.Equal(t, "publisher_code", rows[0][len(rows[0])-1])
+	})
+
+	t.Run("CSV template", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/books/import/template?format=csv", nil))
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), "code,isbn,name")
+	})
+}
@@ -0,0 +1,79 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBookSuggestions(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books/typeahead", handlers.GetBookSuggestions)
+
+	get := func(url string) (int, map[string]interface{}) {
+		resp, _ := app.Test(httptest.NewRequest("GET", url, nil))
+		var response map[string]interface{}
+		body, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(body, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Words match as prefixes", func(t *testing.T) {
+		bookID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, isbn, name, author, year, periode, price, stock, ts_rank_cd(search_vector, to_tsquery('pustaka_indonesian', $1)) AS search_rank FROM "books" WHERE search_vector @@ to_tsquery('pustaka_indonesian', $2) AND deleted_at IS NULL ORDER BY search_rank DESC, name LIMIT 5`)).
+			WithArgs("mat:* & kel:*", "mat:* & kel:*").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "isbn", "name", "author", "year", "periode", "price", "stock", "search_rank"}).
+				AddRow(bookID, "MTK-01", "9780306406157", "Matematika Kelas 1", nil, "2025", 1, 50000.0, 12, 0.5))
+
+		status, response := get("/books/typeahead?q=mat%20kel&limit=5")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		suggestions := response["suggestions"].([]interface{})
+		assert.Len(t, suggestions, 1)
+		assert.Equal(t, bookID.String(), suggestions[0].(map[string]interface{})["id"])
+		assert.Equal(t, "Matematika Kelas 1", suggestions[0].(map[string]interface{})["name"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stopwords only return no suggestions without a query", func(t *testing.T) {
+		status, response := get("/books/typeahead?q=yang")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Empty(t, response["suggestions"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetAllBooksSearchSortBy(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books", handlers.GetAllBooks)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $1) AND "books"."deleted_at" IS NULL ORDER BY books.price ASC LIMIT 20`)).
+		WithArgs("matematika").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $1) AND "books"."deleted_at" IS NULL`)).
+		WithArgs("matematika").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/books?search=Matematika&sort_by=price&sort_order=asc", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -35,7 +35,7 @@ func TestGetAllBooks(t *testing.T) {
 			AddRow(bookID1, "Mathematics Grade 1", "Test book description", "2024", nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, 1, nil, 50000.00, time.Now(), time.Now()).
 			AddRow(bookID2, "Science Grade 2", "Test book description", "2024", nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, 1, nil, 75000.00, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" ORDER BY books.created_at DESC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."deleted_at" IS NULL ORDER BY books.created_at DESC LIMIT 20`)).
 			WillReturnRows(bookRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -62,7 +62,7 @@ func TestGetAllBooks(t *testing.T) {
 
 		bookRows := sqlmock.NewRows([]string{"id", "name", "description", "year", "author", "isbn", "stock", "periode", "merk_buku_id", "jenis_buku_id", "jenjang_studi_id", "bidang_studi_id", "kelas", "curriculum_id", "no_pages", "publisher_id", "price", "created_at", "updated_at"})
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" ORDER BY books.created_at DESC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."deleted_at" IS NULL ORDER BY books.created_at DESC LIMIT 20`)).
 			WillReturnRows(bookRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
@@ -87,7 +87,7 @@ func TestGetAllBooks(t *testing.T) {
 		assert.NoError(t, err)
 		defer testutil.CloseMockDB(db3)
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" ORDER BY books.created_at DESC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."deleted_at" IS NULL ORDER BY books.created_at DESC LIMIT 20`)).
 			WillReturnError(gorm.ErrInvalidDB)
 
 		req := httptest.NewRequest("GET", "/books", nil)
@@ -113,13 +113,13 @@ func TestGetAllBooks(t *testing.T) {
 		bookRows := sqlmock.NewRows([]string{"id", "name", "description", "year", "author", "isbn", "stock", "periode", "merk_buku_id", "jenis_buku_id", "jenjang_studi_id", "bidang_studi_id", "kelas", "curriculum_id", "no_pages", "publisher_id", "price", "created_at", "updated_at"}).
 			AddRow(bookID, "Mathematics Grade 1", &description, "2024", nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, 1, nil, 50000.00, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (books.name ILIKE $1 OR books.description ILIKE $2) ORDER BY books.created_at DESC LIMIT 20`)).
-			WithArgs("%Mathematics%", "%Mathematics%").
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT books.*, ts_rank_cd(books.search_vector, to_tsquery('pustaka_indonesian', $1)) AS search_rank FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $2) AND "books"."deleted_at" IS NULL ORDER BY search_rank DESC,books.created_at DESC LIMIT 20`)).
+			WithArgs("mathematics", "mathematics").
 			WillReturnRows(bookRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (books.name ILIKE $1 OR books.description ILIKE $2)`)).
-			WithArgs("%Mathematics%", "%Mathematics%").
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $1) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("mathematics").
 			WillReturnRows(countRows)
 
 		req := httptest.NewRequest("GET", "/books?search=Mathematics", nil)
@@ -146,13 +146,13 @@ func TestGetAllBooks(t *testing.T) {
 		bookRows := sqlmock.NewRows([]string{"id", "name", "description", "year", "author", "isbn", "stock", "periode", "merk_buku_id", "jenis_buku_id", "jenjang_studi_id", "bidang_studi_id", "kelas", "curriculum_id", "no_pages", "publisher_id", "price", "created_at", "updated_at"}).
 			AddRow(bookID, "Science Grade 2", &description, "2024", nil, nil, 0, 1, nil, nil, nil, nil, nil, nil, 1, nil, 75000.00, time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (books.name ILIKE $1 OR books.description ILIKE $2) ORDER BY books.created_at DESC LIMIT 20`)).
-			WithArgs("%Science%", "%Science%").
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT books.*, ts_rank_cd(books.search_vector, to_tsquery('pustaka_indonesian', $1)) AS search_rank FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $2) AND "books"."deleted_at" IS NULL ORDER BY search_rank DESC,books.created_at DESC LIMIT 20`)).
+			WithArgs("science", "science").
 			WillReturnRows(bookRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (books.name ILIKE $1 OR books.description ILIKE $2)`)).
-			WithArgs("%Science%", "%Science%").
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE books.search_vector @@ to_tsquery('pustaka_indonesian', $1) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("science").
 			WillReturnRows(countRows)
 
 		req := httptest.NewRequest("GET", "/books?search=Science", nil)
@@ -329,7 +329,7 @@ func TestUpdateBook(t *testing.T) {
 			WillReturnRows(bookRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "books" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "books" SET .+ WHERE "books"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -347,6 +347,57 @@ func TestUpdateBook(t *testing.T) {
 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	})
 
+	t.Run("Changed code is checked against other books", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		bookID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "code", "isbn", "price"}).
+				AddRow(bookID, "Matematika 1", "MTK-01", "9786020000001", 50000.00))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (code = $1 AND id <> $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("IPA-01", bookID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest("PUT", "/books/"+bookID.String(), bytes.NewReader([]byte(`{"code": "IPA-01"}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Code sent as null is cleared", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		bookID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "code", "price"}).
+				AddRow(bookID, "Matematika 1", "MTK-01", 50000.00))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "books" SET .*"code"=\$\d+.* WHERE "books"\."deleted_at" IS NULL AND "id" = .+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("PUT", "/books/"+bookID.String(), bytes.NewReader([]byte(`{"code": null}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Nil(t, response["code"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Book not found", func(t *testing.T) {
 		db, mock, err := testutil.SetupMockDB()
 		assert.NoError(t, err)
@@ -404,6 +455,34 @@ func TestUpdateBook(t *testing.T) {
 		assert.Equal(t, "Invalid request body", response["error"])
 	})
 
+	t.Run("Direct stock edit is rejected", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		bookID := uuid.New()
+
+		bookRows := sqlmock.NewRows([]string{"id", "name", "description", "year", "author", "isbn", "stock", "periode", "merk_buku_id", "jenis_buku_id", "jenjang_studi_id", "bidang_studi_id", "kelas", "curriculum_id", "no_pages", "publisher_id", "price", "created_at", "updated_at"}).
+			AddRow(bookID, "Mathematics Grade 1", "Test book description", "2024", nil, nil, 10, 1, nil, nil, nil, nil, nil, nil, 1, nil, 50000.00, time.Now(), time.Now())
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WithArgs(bookID.String()).
+			WillReturnRows(bookRows)
+
+		req := httptest.NewRequest("PUT", "/books/"+bookID.String(), bytes.NewReader([]byte(`{"stock": 25}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Stock cannot be edited directly, use a stock opname to adjust stock", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Database error on update", func(t *testing.T) {
 		db, mock, err := testutil.SetupMockDB()
 		assert.NoError(t, err)
@@ -419,7 +498,7 @@ func TestUpdateBook(t *testing.T) {
 			WillReturnRows(bookRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "books" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "books" SET .+ WHERE "books"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnError(gorm.ErrInvalidDB)
 		mock.ExpectRollback()
 
@@ -455,9 +534,27 @@ func TestDeleteBook(t *testing.T) {
 	t.Run("Successfully delete book", func(t *testing.T) {
 		bookID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "books" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1 AND "books"."deleted_at" IS NULL`)).
 			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."deleted_at" IS NULL AND "books"."id" = $1 ORDER BY "books"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(bookID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "purchase_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_transfer_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opname_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_draft_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_bundle_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "deleted_at"=$1 WHERE "books"."id" = $2 AND "books"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), bookID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -476,11 +573,9 @@ func TestDeleteBook(t *testing.T) {
 	t.Run("Book not found", func(t *testing.T) {
 		bookID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "books" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1 AND "books"."deleted_at" IS NULL`)).
 			WithArgs(bookID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/books/"+bookID.String(), nil)
 		resp, _ := app.Test(req)
@@ -497,9 +592,27 @@ func TestDeleteBook(t *testing.T) {
 	t.Run("Database error on delete", func(t *testing.T) {
 		bookID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "books" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1 AND "books"."deleted_at" IS NULL`)).
 			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."deleted_at" IS NULL AND "books"."id" = $1 ORDER BY "books"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(bookID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "purchase_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_transfer_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opname_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_draft_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "book_bundle_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "deleted_at"=$1 WHERE "books"."id" = $2 AND "books"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), bookID.String()).
 			WillReturnError(gorm.ErrInvalidDB)
 		mock.ExpectRollback()
 
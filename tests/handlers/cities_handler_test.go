@@ -15,6 +15,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -35,7 +36,7 @@ func TestGetAllCities(t *testing.T) {
 			AddRow(cityID1, "JKT", "Jakarta", time.Now(), time.Now()).
 			AddRow(cityID2, "BDG", "Bandung", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" ORDER BY name ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "cities"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WillReturnRows(cityRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -55,6 +56,30 @@ func TestGetAllCities(t *testing.T) {
 		assert.NotNil(t, response["pagination"])
 	})
 
+	t.Run("Deleted cities on request", func(t *testing.T) {
+		cityRows := sqlmock.NewRows([]string{"id", "code", "name", "deleted_at"}).
+			AddRow(uuid.New(), "SBY", "Surabaya", time.Now())
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" ORDER BY name ASC LIMIT 20`)).
+			WillReturnRows(cityRows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest("GET", "/cities?include_deleted=true", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		cities := response["cities"].([]interface{})
+		assert.Len(t, cities, 1)
+		assert.NotNil(t, cities[0].(map[string]interface{})["deleted_at"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Empty list", func(t *testing.T) {
 		db2, mock2, err := testutil.SetupMockDB()
 		assert.NoError(t, err)
@@ -62,7 +87,7 @@ func TestGetAllCities(t *testing.T) {
 
 		cityRows := sqlmock.NewRows([]string{"id", "code", "name", "created_at", "updated_at"})
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" ORDER BY name ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "cities"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WillReturnRows(cityRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(0)
@@ -92,12 +117,12 @@ func TestGetAllCities(t *testing.T) {
 		cityRows := sqlmock.NewRows([]string{"id", "code", "name", "created_at", "updated_at"}).
 			AddRow(cityID, "JKT", "Jakarta", time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE cities.code ILIKE $1 OR cities.name ILIKE $2 ORDER BY name ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE (cities.code ILIKE $1 OR cities.name ILIKE $2) AND "cities"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WithArgs("%JKT%", "%JKT%").
 			WillReturnRows(cityRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities" WHERE cities.code ILIKE $1 OR cities.name ILIKE $2`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities" WHERE (cities.code ILIKE $1 OR cities.name ILIKE $2) AND "cities"."deleted_at" IS NULL`)).
 			WithArgs("%JKT%", "%JKT%").
 			WillReturnRows(countRows)
 
@@ -124,12 +149,12 @@ func TestGetAllCities(t *testing.T) {
 		cityRows := sqlmock.NewRows([]string{"id", "code", "name", "created_at", "updated_at"}).
 			AddRow(cityID, "JKT", "Jakarta", time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE cities.code ILIKE $1 OR cities.name ILIKE $2 ORDER BY name ASC LIMIT 20`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE (cities.code ILIKE $1 OR cities.name ILIKE $2) AND "cities"."deleted_at" IS NULL ORDER BY name ASC LIMIT 20`)).
 			WithArgs("%Jakarta%", "%Jakarta%").
 			WillReturnRows(cityRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities" WHERE cities.code ILIKE $1 OR cities.name ILIKE $2`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities" WHERE (cities.code ILIKE $1 OR cities.name ILIKE $2) AND "cities"."deleted_at" IS NULL`)).
 			WithArgs("%Jakarta%", "%Jakarta%").
 			WillReturnRows(countRows)
 
@@ -263,7 +288,7 @@ func TestUpdateCity(t *testing.T) {
 			WillReturnRows(cityRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "cities" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "cities" SET .+ WHERE "cities"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -344,9 +369,25 @@ func TestDeleteCity(t *testing.T) {
 	t.Run("Successfully delete city", func(t *testing.T) {
 		cityID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "cities" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1 AND "cities"."deleted_at" IS NULL`)).
 			WithArgs(cityID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(cityID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "cities"."deleted_at" IS NULL AND "cities"."id" = $1 ORDER BY "cities"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(cityID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(cityID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "publishers"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "expeditions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_associates"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "billers"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "warehouses"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cities" SET "deleted_at"=$1 WHERE "cities"."id" = $2 AND "cities"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), cityID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -365,11 +406,9 @@ func TestDeleteCity(t *testing.T) {
 	t.Run("City not found", func(t *testing.T) {
 		cityID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "cities" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1 AND "cities"."deleted_at" IS NULL`)).
 			WithArgs(cityID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/cities/"+cityID.String(), nil)
 		resp, _ := app.Test(req)
@@ -383,3 +422,80 @@ func TestDeleteCity(t *testing.T) {
 		assert.Equal(t, "City not found", response["error"])
 	})
 }
+
+func TestRestoreCity(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/cities/:id/restore", handlers.RestoreCity)
+
+	t.Run("Successfully restore city", func(t *testing.T) {
+		cityID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1 AND deleted_at IS NOT NULL`)).
+			WithArgs(cityID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "deleted_at"}).AddRow(cityID, "SBY", "Surabaya", time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "cities" SET "deleted_at"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+			WithArgs(nil, sqlmock.AnyArg(), cityID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("POST", "/cities/"+cityID.String()+"/restore", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "City restored successfully", response["message"])
+		assert.Nil(t, response["city"].(map[string]interface{})["deleted_at"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("City is not deleted", func(t *testing.T) {
+		cityID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1 AND deleted_at IS NOT NULL`)).
+			WithArgs(cityID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		req := httptest.NewRequest("POST", "/cities/"+cityID.String()+"/restore", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Deleted city not found", response["error"])
+	})
+
+	t.Run("Code taken by another city", func(t *testing.T) {
+		cityID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1 AND deleted_at IS NOT NULL`)).
+			WithArgs(cityID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "deleted_at"}).AddRow(cityID, "SBY", "Surabaya", time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "cities" SET "deleted_at"=\$1,"updated_at"=\$2 WHERE "id" = \$3`).
+			WillReturnError(&pgconn.PgError{Code: "23505"})
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", "/cities/"+cityID.String()+"/restore", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Another city already uses the same code or name", response["error"])
+	})
+}
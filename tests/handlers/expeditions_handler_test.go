@@ -37,7 +37,7 @@ func TestGetAllExpeditions(t *testing.T) {
 			AddRow(expeditionID1, "EXP001", "JNE", "Test Description", "Jl. Test 1", cityID1, "Area 1", "021-111", "021-112", "jne@example.com", "www.jne.com", time.Now(), time.Now()).
 			AddRow(expeditionID2, "EXP002", "TIKI", nil, "Jl. Test 2", cityID2, "Area 2", "021-222", nil, nil, nil, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" ORDER BY created_at DESC`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE "expeditions"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WillReturnRows(expeditionRows)
 
 		cityRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
@@ -77,7 +77,7 @@ func TestGetAllExpeditions(t *testing.T) {
 		expeditionRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "created_at", "updated_at"}).
 			AddRow(expeditionID, "EXP001", "JNE", &description, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE expeditions.code ILIKE $1 OR expeditions.name ILIKE $2 OR expeditions.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE (expeditions.code ILIKE $1 OR expeditions.name ILIKE $2 OR expeditions.description ILIKE $3) AND "expeditions"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%EXP001%", "%EXP001%", "%EXP001%").
 			WillReturnRows(expeditionRows)
 
@@ -116,7 +116,7 @@ func TestGetAllExpeditions(t *testing.T) {
 		expeditionRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "created_at", "updated_at"}).
 			AddRow(expeditionID, "EXP001", "JNE", nil, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE expeditions.code ILIKE $1 OR expeditions.name ILIKE $2 OR expeditions.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE (expeditions.code ILIKE $1 OR expeditions.name ILIKE $2 OR expeditions.description ILIKE $3) AND "expeditions"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%JNE%", "%JNE%", "%JNE%").
 			WillReturnRows(expeditionRows)
 
@@ -272,7 +272,7 @@ func TestUpdateExpedition(t *testing.T) {
 			WillReturnRows(expeditionRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "expeditions" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "expeditions" SET .+ WHERE "expeditions"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -334,9 +334,17 @@ func TestDeleteExpedition(t *testing.T) {
 	t.Run("Successfully delete expedition", func(t *testing.T) {
 		expeditionID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "expeditions" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE id = $1 AND "expeditions"."deleted_at" IS NULL`)).
 			WithArgs(expeditionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expeditionID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE "expeditions"."deleted_at" IS NULL AND "expeditions"."id" = $1 ORDER BY "expeditions"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(expeditionID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(expeditionID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "shippings"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "expeditions" SET "deleted_at"=$1 WHERE "expeditions"."id" = $2 AND "expeditions"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), expeditionID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -355,11 +363,9 @@ func TestDeleteExpedition(t *testing.T) {
 	t.Run("Expedition not found", func(t *testing.T) {
 		expeditionID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "expeditions" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "expeditions" WHERE id = $1 AND "expeditions"."deleted_at" IS NULL`)).
 			WithArgs(expeditionID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/expeditions/"+expeditionID.String(), nil)
 		resp, _ := app.Test(req)
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportBooks(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/books", handlers.GetAllBooks)
+
+	bookID := uuid.New()
+	publisherID := uuid.New()
+	cityID := uuid.New()
+
+	t.Run("CSV export keeps filters and flattens relations", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE books.year = $1 AND "books"."deleted_at" IS NULL ORDER BY books.name ASC,"books"."id" LIMIT 500`)).
+			WithArgs("2025").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "year", "price", "publisher_id"}).
+				AddRow(bookID, "Matematika, Jilid 1", "2025", 50000.0, publisherID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE "publishers"."id" = $1`)).
+			WithArgs(publisherID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "city_id"}).
+				AddRow(publisherID, "ERL", "Erlangga", cityID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE "cities"."id" = $1`)).
+			WithArgs(cityID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(cityID, "Jakarta"))
+
+		req := httptest.NewRequest("GET", "/books?format=csv&year=2025&sort_by=name&sort_order=asc&page=3", nil)
+		resp, _ := app.Test(req)
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Contains(t, resp.Header.Get("Content-Disposition"), `attachment; filename="books_`)
+
+		rows, err := helpers.ReadSpreadsheet("books.csv", body)
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+
+		header := rows[0]
+		column := func(name string) string {
+			for i, h := range header {
+				if h == name {
+					return rows[1][i]
+				}
+			}
+			t.Fatalf("column %s not found in %v", name, header)
+			return ""
+		}
+		assert.Equal(t, bookID.String(), column("id"))
+		assert.Equal(t, "Matematika, Jilid 1", column("name"))
+		assert.Equal(t, "50000", column("price"))
+		assert.Equal(t, "ERL", column("publisher_code"))
+		assert.Equal(t, "Jakarta", column("publisher_city_name"))
+		assert.Equal(t, "", column("merk_buku_name"))
+		assert.NotContains(t, header, "publisher_id")
+		assert.NotContains(t, header, "publisher_city_id")
+		assert.NotContains(t, header, "stocks")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("XLSX export", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock"}).AddRow(bookID, "IPA 1", 12))
+
+		req := httptest.NewRequest("GET", "/books?format=xlsx", nil)
+		resp, _ := app.Test(req)
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		rows, err := helpers.ReadSpreadsheet("books.xlsx", body)
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.Equal(t, "IPA 1", rows[1][1])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/books?format=pdf", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestExportGrossMarginReport(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/reports/gross-margin", handlers.GetGrossMarginReport)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT merk_buku.id::text AS group_id, merk_buku.code AS code, merk_buku.name AS name`)).
+		WillReturnRows(sqlmock.NewRows([]string{"group_id", "code", "name", "total_items", "revenue", "cogs", "gross_margin"}).
+			AddRow(uuid.New().String(), "ERL", "Erlangga", 20, 1000000.0, 600000.0, 400000.0))
+
+	req := httptest.NewRequest("GET", "/reports/gross-margin?group_by=merk_buku&format=csv", nil)
+	resp, _ := app.Test(req)
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(string(body), "\xef\xbb\xbf")), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "margin_percent")
+	assert.Contains(t, lines[1], "Erlangga")
+	assert.Contains(t, lines[1], ",40")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,244 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGoodsReceipt(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/purchase-transactions/:id/goods-receipts", handlers.CreateGoodsReceipt)
+
+	purchaseID := uuid.New()
+	warehouseID := uuid.New()
+	firstItemID := uuid.New()
+	secondItemID := uuid.New()
+	firstBookID := uuid.New()
+	secondBookID := uuid.New()
+	stockID := uuid.New()
+
+	expectPurchase := func(status int, firstReceived int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transactions" WHERE id = $1 ORDER BY "purchase_transactions"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(purchaseID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status", "warehouse_id"}).
+				AddRow(purchaseID, uuid.New(), "PRC2024010100000001", time.Now(), 750000.0, status, warehouseID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transaction_items" WHERE "purchase_transaction_items"."purchase_transaction_id" = $1 FOR UPDATE`)).
+			WithArgs(purchaseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "purchase_transaction_id", "book_id", "quantity", "received_quantity", "price", "subtotal"}).
+				AddRow(firstItemID, purchaseID, firstBookID, 10, firstReceived, 50000.0, 500000.0).
+				AddRow(secondItemID, purchaseID, secondBookID, 5, 0, 50000.0, 250000.0))
+	}
+
+	post := func(body map[string]interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/purchase-transactions/"+purchaseID.String()+"/goods-receipts", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Partial receipt keeps the purchase open", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPending, 0)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_receipt), '') FROM "goods_receipts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "average_cost"=ROUND(`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, firstBookID, warehouseID, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WithArgs(4, sqlmock.AnyArg(), stockID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transaction_items" SET "received_quantity"=received_quantity + $1,"updated_at"=$2 WHERE id = $3 AND received_quantity + $4 <= quantity`)).
+			WithArgs(4, sqlmock.AnyArg(), firstItemID, 4).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "goods_receipts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "goods_receipt_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+		// No backorders waiting for the received book
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, firstBookID, warehouseID, 4))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT sales_transaction_items.*, sales_transactions.no_invoice FROM "sales_transaction_items" JOIN sales_transactions`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity", "backordered_quantity", "no_invoice"}))
+
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transactions" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, response := post(map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"book_id": firstBookID.String(), "quantity": 4},
+			},
+		})
+
+		assert.Equal(t, fiber.StatusCreated, status)
+		assert.Equal(t, float64(models.PurchaseStatusPartiallyReceived), response["status"])
+		assert.Len(t, response["outstanding"], 2)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Line received concurrently beyond its quantity", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPending, 0)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_receipt), '') FROM "goods_receipts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "average_cost"=ROUND(`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, secondBookID, warehouseID, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transaction_items" SET "received_quantity"=received_quantity + $1`)).
+			WithArgs(5, sqlmock.AnyArg(), secondItemID, 5).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		status, response := post(map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"purchase_transaction_item_id": secondItemID.String(), "quantity": 5},
+			},
+		})
+
+		assert.Equal(t, fiber.StatusConflict, status)
+		assert.Equal(t, "Goods were received concurrently, reload the purchase", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Receiving more than outstanding is rejected", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPartiallyReceived, 8)
+		mock.ExpectRollback()
+
+		status, response := post(map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"purchase_transaction_item_id": firstItemID.String(), "quantity": 3},
+			},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, float64(2), response["outstanding"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Completed purchases cannot receive goods", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusCompleted, 10)
+		mock.ExpectRollback()
+
+		status, response := post(map[string]interface{}{
+			"items": []map[string]interface{}{
+				{"book_id": secondBookID.String(), "quantity": 1},
+			},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Goods can only be received for pending or partially received purchases", response["error"])
+	})
+
+	t.Run("Items are required", func(t *testing.T) {
+		status, response := post(map[string]interface{}{"items": []map[string]interface{}{}})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "At least one item is required", response["error"])
+	})
+}
+
+func TestClosePurchaseTransaction(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/purchase-transactions/:id/close", handlers.ClosePurchaseTransaction)
+
+	purchaseID := uuid.New()
+	bookID := uuid.New()
+
+	expectPurchase := func(status int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transactions" WHERE id = $1`)).
+			WithArgs(purchaseID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status"}).
+				AddRow(purchaseID, uuid.New(), "PRC2024010100000001", time.Now(), 500000.0, status))
+	}
+
+	expectClose := func(rowsAffected int64) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transactions" SET "status"=$1,"updated_at"=$2 WHERE status = $3 AND "id" = $4`)).
+			WithArgs(models.PurchaseStatusClosedShort, sqlmock.AnyArg(), models.PurchaseStatusPartiallyReceived, purchaseID).
+			WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	}
+
+	t.Run("Partially received purchase is closed short", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPartiallyReceived)
+		expectClose(1)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transaction_items" WHERE purchase_transaction_id = $1`)).
+			WithArgs(purchaseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "purchase_transaction_id", "book_id", "quantity", "received_quantity", "price", "subtotal"}).
+				AddRow(uuid.New(), purchaseID, bookID, 10, 7, 50000.0, 500000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" = $1`)).
+			WithArgs(bookID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(bookID, "Matematika 1"))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("POST", "/purchase-transactions/"+purchaseID.String()+"/close", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, float64(3), response["total_shortfall"])
+		assert.Equal(t, float64(150000), response["total_shortfall_amount"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Purchase completed by a concurrent receipt is not closed", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPartiallyReceived)
+		expectClose(0)
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", "/purchase-transactions/"+purchaseID.String()+"/close", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Pending purchase cannot be closed short", func(t *testing.T) {
+		expectPurchase(models.PurchaseStatusPending)
+
+		req := httptest.NewRequest("POST", "/purchase-transactions/"+purchaseID.String()+"/close", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
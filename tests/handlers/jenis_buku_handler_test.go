@@ -35,7 +35,7 @@ func TestGetAllJenisBuku(t *testing.T) {
 			AddRow(jenisBukuID1, "JB001", "Textbook", "Test Description", time.Now(), time.Now()).
 			AddRow(jenisBukuID2, "JB002", "Novel", "Test Description", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" ORDER BY created_at ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE "jenis_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WillReturnRows(jenisBukuRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -66,7 +66,7 @@ func TestGetAllJenisBuku(t *testing.T) {
 		jenisBukuRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(jenisBukuID, "JB001", "Textbook", &description, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE jenis_buku.code ILIKE $1 OR jenis_buku.name ILIKE $2 OR jenis_buku.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE (jenis_buku.code ILIKE $1 OR jenis_buku.name ILIKE $2 OR jenis_buku.description ILIKE $3) AND "jenis_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%JB001%", "%JB001%", "%JB001%").
 			WillReturnRows(jenisBukuRows)
 
@@ -97,7 +97,7 @@ func TestGetAllJenisBuku(t *testing.T) {
 		jenisBukuRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(jenisBukuID, "JB001", "Textbook", nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE jenis_buku.code ILIKE $1 OR jenis_buku.name ILIKE $2 OR jenis_buku.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE (jenis_buku.code ILIKE $1 OR jenis_buku.name ILIKE $2 OR jenis_buku.description ILIKE $3) AND "jenis_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%Textbook%", "%Textbook%", "%Textbook%").
 			WillReturnRows(jenisBukuRows)
 
@@ -234,7 +234,7 @@ func TestUpdateJenisBuku(t *testing.T) {
 			WillReturnRows(jenisBukuRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "jenis_buku" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "jenis_buku" SET .+ WHERE "jenis_buku"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -292,9 +292,17 @@ func TestDeleteJenisBuku(t *testing.T) {
 	t.Run("Successfully delete jenis buku", func(t *testing.T) {
 		jenisBukuID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "jenis_buku" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE id = $1 AND "jenis_buku"."deleted_at" IS NULL`)).
 			WithArgs(jenisBukuID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(jenisBukuID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE "jenis_buku"."deleted_at" IS NULL AND "jenis_buku"."id" = $1 ORDER BY "jenis_buku"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(jenisBukuID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(jenisBukuID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "jenis_buku" SET "deleted_at"=$1 WHERE "jenis_buku"."id" = $2 AND "jenis_buku"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), jenisBukuID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -313,11 +321,9 @@ func TestDeleteJenisBuku(t *testing.T) {
 	t.Run("JenisBuku not found", func(t *testing.T) {
 		jenisBukuID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "jenis_buku" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenis_buku" WHERE id = $1 AND "jenis_buku"."deleted_at" IS NULL`)).
 			WithArgs(jenisBukuID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/jenis-buku/"+jenisBukuID.String(), nil)
 		resp, _ := app.Test(req)
@@ -35,7 +35,7 @@ func TestGetAllJenjangStudi(t *testing.T) {
 			AddRow(jenjangStudiID1, "JS001", "SD", "Test Description", "S", time.Now(), time.Now()).
 			AddRow(jenjangStudiID2, "JS002", "SMP", "Test Description", "S", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" ORDER BY created_at ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE "jenjang_studi"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WillReturnRows(rows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -66,7 +66,7 @@ func TestGetAllJenjangStudi(t *testing.T) {
 		jenjangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "period", "created_at", "updated_at"}).
 			AddRow(jenjangStudiID, "JS001", "Elementary", &description, "S", time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE (jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3) AND "jenjang_studi"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%JS001%", "%JS001%", "%JS001%").
 			WillReturnRows(jenjangStudiRows)
 
@@ -97,7 +97,7 @@ func TestGetAllJenjangStudi(t *testing.T) {
 		jenjangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "period", "created_at", "updated_at"}).
 			AddRow(jenjangStudiID, "JS001", "Elementary", nil, "S", time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE (jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3) AND "jenjang_studi"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%Elementary%", "%Elementary%", "%Elementary%").
 			WillReturnRows(jenjangStudiRows)
 
@@ -128,7 +128,7 @@ func TestGetAllJenjangStudi(t *testing.T) {
 		jenjangStudiRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "period", "created_at", "updated_at"}).
 			AddRow(jenjangStudiID, "JS001", "Elementary", "Sekolah Dasar", "S", time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE (jenjang_studi.code ILIKE $1 OR jenjang_studi.name ILIKE $2 OR jenjang_studi.description ILIKE $3) AND "jenjang_studi"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%Sekolah Dasar%", "%Sekolah Dasar%", "%Sekolah Dasar%").
 			WillReturnRows(jenjangStudiRows)
 
@@ -265,7 +265,7 @@ func TestUpdateJenjangStudi(t *testing.T) {
 			WillReturnRows(rows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "jenjang_studi" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "jenjang_studi" SET .+ WHERE "jenjang_studi"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -323,9 +323,23 @@ func TestDeleteJenjangStudi(t *testing.T) {
 	t.Run("Successfully delete jenjang studi", func(t *testing.T) {
 		jenjangStudiID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "jenjang_studi" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE id = $1 AND "jenjang_studi"."deleted_at" IS NULL`)).
 			WithArgs(jenjangStudiID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(jenjangStudiID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE "jenjang_studi"."deleted_at" IS NULL AND "jenjang_studi"."id" = $1 ORDER BY "jenjang_studi"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(jenjangStudiID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(jenjangStudiID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opnames"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "jenjang_studi" SET "deleted_at"=$1 WHERE "jenjang_studi"."id" = $2 AND "jenjang_studi"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), jenjangStudiID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -344,11 +358,9 @@ func TestDeleteJenjangStudi(t *testing.T) {
 	t.Run("JenjangStudi not found", func(t *testing.T) {
 		jenjangStudiID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "jenjang_studi" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jenjang_studi" WHERE id = $1 AND "jenjang_studi"."deleted_at" IS NULL`)).
 			WithArgs(jenjangStudiID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/jenjang-studi/"+jenjangStudiID.String(), nil)
 		resp, _ := app.Test(req)
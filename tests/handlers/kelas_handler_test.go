@@ -35,7 +35,7 @@ func TestGetAllKelas(t *testing.T) {
 			AddRow(kelasID1, "K001", "Class 1", "Test Description", time.Now(), time.Now()).
 			AddRow(kelasID2, "K002", "Class 2", "Test Description", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" ORDER BY created_at ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE "kelas"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WillReturnRows(rows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -66,7 +66,7 @@ func TestGetAllKelas(t *testing.T) {
 		kelasRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(kelasID, "K001", "Grade 1", &description, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE kelas.code ILIKE $1 OR kelas.name ILIKE $2 OR kelas.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE (kelas.code ILIKE $1 OR kelas.name ILIKE $2 OR kelas.description ILIKE $3) AND "kelas"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%K001%", "%K001%", "%K001%").
 			WillReturnRows(kelasRows)
 
@@ -97,7 +97,7 @@ func TestGetAllKelas(t *testing.T) {
 		kelasRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(kelasID, "K001", "Grade", nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE kelas.code ILIKE $1 OR kelas.name ILIKE $2 OR kelas.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE (kelas.code ILIKE $1 OR kelas.name ILIKE $2 OR kelas.description ILIKE $3) AND "kelas"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%Grade%", "%Grade%", "%Grade%").
 			WillReturnRows(kelasRows)
 
@@ -234,7 +234,7 @@ func TestUpdateKelas(t *testing.T) {
 			WillReturnRows(rows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "kelas" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "kelas" SET .+ WHERE "kelas"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -292,9 +292,15 @@ func TestDeleteKelas(t *testing.T) {
 	t.Run("Successfully delete kelas", func(t *testing.T) {
 		kelasID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "kelas" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE id = $1 AND "kelas"."deleted_at" IS NULL`)).
 			WithArgs(kelasID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(kelasID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE "kelas"."deleted_at" IS NULL AND "kelas"."id" = $1 ORDER BY "kelas"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(kelasID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(kelasID))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "kelas" SET "deleted_at"=$1 WHERE "kelas"."id" = $2 AND "kelas"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), kelasID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -313,11 +319,9 @@ func TestDeleteKelas(t *testing.T) {
 	t.Run("Kelas not found", func(t *testing.T) {
 		kelasID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "kelas" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "kelas" WHERE id = $1 AND "kelas"."deleted_at" IS NULL`)).
 			WithArgs(kelasID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/kelas/"+kelasID.String(), nil)
 		resp, _ := app.Test(req)
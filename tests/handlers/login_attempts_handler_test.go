@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestLoginLockout(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	store := handlers.NewMemoryLoginAttemptStore()
+	handlers.SetLoginAttemptStore(store)
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
+	app := fiber.New()
+	app.Post("/login", handlers.Login)
+	app.Post("/users/:id/unlock", handlers.UnlockUser)
+
+	login := func(email string) (*http.Response, map[string]interface{}) {
+		body, _ := json.Marshal(LoginRequest{Email: email, Password: "wrongpassword"})
+		req := httptest.NewRequest("POST", "/login", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp, response
+	}
+
+	t.Run("Email is locked after repeated failures", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+				WithArgs("target@example.com").
+				WillReturnError(gorm.ErrRecordNotFound)
+
+			resp, _ := login("target@example.com")
+			assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+		}
+
+		// The user is not even looked up while locked, differently cased emails share the lockout
+		resp, response := login("Target@Example.com")
+
+		assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "60", resp.Header.Get(fiber.HeaderRetryAfter))
+		assert.Equal(t, float64(60), response["retry_after"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		attempts := store.Attempts()
+		assert.Len(t, attempts, 6)
+		assert.Equal(t, models.LoginReasonInvalidCredentials, attempts[0].Reason)
+		assert.Equal(t, models.LoginReasonLocked, attempts[5].Reason)
+		assert.Equal(t, "target@example.com", attempts[5].Email)
+	})
+
+	t.Run("Other emails are not affected", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+			WithArgs("other@example.com").
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		resp, _ := login("other@example.com")
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Further failures double the lockout", func(t *testing.T) {
+		lockout, _ := store.GetLockout("email:target@example.com")
+		assert.Equal(t, 5, lockout.Failures)
+
+		// Pretend the lockout has run out, the next failure locks for twice as long
+		expired := lockout.LastFailureAt
+		lockout.LockedUntil = &expired
+		store.SaveLockout(*lockout)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+			WithArgs("target@example.com").
+			WillReturnError(gorm.ErrRecordNotFound)
+		resp, _ := login("target@example.com")
+		assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+
+		resp, _ = login("target@example.com")
+		assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "120", resp.Header.Get(fiber.HeaderRetryAfter))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin unlocks the user", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "full_name", "role"}).
+				AddRow(userID, "target@example.com", "Target User", "user"))
+
+		resp, _ := app.Test(httptest.NewRequest("POST", "/users/"+userID.String()+"/unlock", nil))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		lockout, _ := store.GetLockout("email:target@example.com")
+		assert.Nil(t, lockout)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresLoginAttemptStoreAddFailure(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	now := time.Now()
+	lockedUntil := now.Add(time.Minute)
+
+	// The counter is read and incremented by the database in one statement
+	upsert := regexp.QuoteMeta(`INSERT INTO login_lockouts (key, failures, last_failure_at, updated_at) VALUES ($1, 1, $2, $3)`) +
+		`\s+ON CONFLICT \(key\) DO UPDATE SET\s+failures = CASE .+ login_lockouts\.failures \+ 1 END,` +
+		`[\s\S]+RETURNING key, failures, last_failure_at, locked_until, updated_at$`
+	mock.ExpectQuery(upsert).
+		WithArgs("email:target@example.com", now, now, now.Add(-15*time.Minute), now, now.Add(-15*time.Minute), now).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "failures", "last_failure_at", "locked_until", "updated_at"}).
+			AddRow("email:target@example.com", 6, now, lockedUntil, now))
+
+	lockout, err := handlers.PostgresLoginAttemptStore{}.AddFailure("email:target@example.com", now, 15*time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, lockout.Failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
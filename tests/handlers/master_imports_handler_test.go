@@ -0,0 +1,170 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func uploadMasterImport(app *fiber.App, path, filename, content string, dryRun bool) (int, map[string]interface{}) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	if !dryRun {
+		writer.WriteField("dry_run", "false")
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, _ := app.Test(req)
+
+	var response map[string]interface{}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+	return resp.StatusCode, response
+}
+
+func TestImportCities(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/cities/import", handlers.ImportCities)
+
+	existingID := uuid.New()
+
+	t.Run("Cities are upserted on code", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code FROM "cities" WHERE code IN ($1,$2)`)).
+			WithArgs("JKT", "BDG").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(existingID, "JKT"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, name AS value FROM "cities" WHERE name IN ($1,$2)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "value"}).AddRow(existingID, "JKT", "Jakarta"))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cities" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "cities"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, response := uploadMasterImport(app, "/cities/import", "cities.csv", "code,name\nJKT,Jakarta\nBDG,Bandung\n", false)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(1), response["created"])
+		assert.Equal(t, float64(1), response["updated"])
+		rows := response["rows"].([]interface{})
+		assert.Equal(t, existingID.String(), rows[0].(map[string]interface{})["id"])
+		assert.NotNil(t, rows[1].(map[string]interface{})["id"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Name used by another city is rejected", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code FROM "cities" WHERE code IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, name AS value FROM "cities" WHERE name IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "value"}).AddRow(existingID, "JKT", "Jakarta"))
+
+		status, response := uploadMasterImport(app, "/cities/import", "cities.csv", "code,name\nJK2,Jakarta\n", false)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, status)
+		assert.Equal(t, "Some rows have errors, nothing was imported", response["error"])
+		row := response["rows"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, []interface{}{"name Jakarta already belongs to city JKT"}, row["errors"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Database errors are not shown", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code FROM "cities" WHERE code IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code, name AS value FROM "cities" WHERE name IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "value"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "cities"`)).
+			WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "idx_cities_code"`))
+		mock.ExpectRollback()
+
+		status, response := uploadMasterImport(app, "/cities/import", "cities.csv", "code,name\nSBY,Surabaya\n", false)
+
+		assert.Equal(t, fiber.StatusInternalServerError, status)
+		assert.Equal(t, map[string]interface{}{"error": "Failed to import city records"}, response)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown columns are rejected", func(t *testing.T) {
+		status, response := uploadMasterImport(app, "/cities/import", "cities.csv", "code,nama\nJKT,Jakarta\n", true)
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Unknown columns: nama", response["error"])
+	})
+}
+
+func TestImportSalesAssociates(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/sales-associates/import", handlers.ImportSalesAssociates)
+
+	cityID := uuid.New()
+
+	csvFile := "code,name,address,city_code,phone1,email,jenis_pembayaran,join_date,discount\n" +
+		"SA01,Budi,Jl. Merdeka 1,jkt,(021) 555-1234,budi@example.com,k,2025-01-02,10\n" +
+		"SA02,Sari,Jl. Sudirman 2,SBY,12345,sari@,X,02-01-2025,150\n" +
+		"SA01,Budi Lagi,,,,,,,\n"
+
+	t.Run("Dry run validates every row", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code FROM "sales_associates" WHERE code IN ($1,$2,$3)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, code FROM "cities" WHERE UPPER(code) IN ($1,$2)`)).
+			WithArgs("JKT", "SBY").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(cityID, "JKT"))
+
+		status, response := uploadMasterImport(app, "/sales-associates/import", "sales.csv", csvFile, true)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(1), response["created"])
+		assert.Equal(t, float64(2), response["failed"])
+
+		rows := response["rows"].([]interface{})
+		assert.Equal(t, "create", rows[0].(map[string]interface{})["action"])
+		assert.Nil(t, rows[0].(map[string]interface{})["id"])
+
+		invalid := rows[1].(map[string]interface{})
+		assert.Equal(t, "error", invalid["action"])
+		assert.Equal(t, []interface{}{
+			"Unknown city_code SBY",
+			"Invalid phone1 12345, expected 10 to 15 digits",
+			"Invalid email sari@",
+			"jenis_pembayaran must be T (tunai) or K (kredit)",
+			"Invalid join_date 02-01-2025, expected YYYY-MM-DD",
+			"discount must be a number between 0 and 100",
+		}, invalid["errors"])
+
+		duplicate := rows[2].(map[string]interface{})
+		assert.Contains(t, duplicate["errors"], "code SA01 is also used on row 2")
+		assert.Contains(t, duplicate["errors"], "address is required")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("File without code column is rejected", func(t *testing.T) {
+		status, response := uploadMasterImport(app, "/sales-associates/import", "sales.csv", "name\nBudi\n", true)
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "The file needs a code column to match existing records", response["error"])
+	})
+}
@@ -35,7 +35,7 @@ func TestGetAllMerkBuku(t *testing.T) {
 			AddRow(merkBukuID1, "JB001", "Textbook", "Test Description", time.Now(), time.Now()).
 			AddRow(merkBukuID2, "JB002", "Novel", "Test Description", time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" ORDER BY created_at ASC LIMIT 20`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE "merk_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WillReturnRows(merkBukuRows)
 
 		countRows := sqlmock.NewRows([]string{"count"}).AddRow(2)
@@ -66,7 +66,7 @@ func TestGetAllMerkBuku(t *testing.T) {
 		merkBukuRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(merkBukuID, "JB001", "Textbook", &description, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE merk_buku.code ILIKE $1 OR merk_buku.name ILIKE $2 OR merk_buku.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE (merk_buku.code ILIKE $1 OR merk_buku.name ILIKE $2 OR merk_buku.description ILIKE $3) AND "merk_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%JB001%", "%JB001%", "%JB001%").
 			WillReturnRows(merkBukuRows)
 
@@ -97,7 +97,7 @@ func TestGetAllMerkBuku(t *testing.T) {
 		merkBukuRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "created_at", "updated_at"}).
 			AddRow(merkBukuID, "JB001", "Textbook", nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE merk_buku.code ILIKE $1 OR merk_buku.name ILIKE $2 OR merk_buku.description ILIKE $3 ORDER BY created_at ASC LIMIT 20`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE (merk_buku.code ILIKE $1 OR merk_buku.name ILIKE $2 OR merk_buku.description ILIKE $3) AND "merk_buku"."deleted_at" IS NULL ORDER BY created_at ASC LIMIT 20`)).
 			WithArgs("%Textbook%", "%Textbook%", "%Textbook%").
 			WillReturnRows(merkBukuRows)
 
@@ -234,7 +234,7 @@ func TestUpdateMerkBuku(t *testing.T) {
 			WillReturnRows(merkBukuRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "merk_buku" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "merk_buku" SET .+ WHERE "merk_buku"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -292,9 +292,23 @@ func TestDeleteMerkBuku(t *testing.T) {
 	t.Run("Successfully delete merk buku", func(t *testing.T) {
 		merkBukuID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "merk_buku" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE id = $1 AND "merk_buku"."deleted_at" IS NULL`)).
 			WithArgs(merkBukuID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE "merk_buku"."deleted_at" IS NULL AND "merk_buku"."id" = $1 ORDER BY "merk_buku"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(merkBukuID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opnames"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "merk_buku" SET "deleted_at"=$1 WHERE "merk_buku"."id" = $2 AND "merk_buku"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), merkBukuID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -313,11 +327,9 @@ func TestDeleteMerkBuku(t *testing.T) {
 	t.Run("MerkBuku not found", func(t *testing.T) {
 		merkBukuID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "merk_buku" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE id = $1 AND "merk_buku"."deleted_at" IS NULL`)).
 			WithArgs(merkBukuID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/merk-buku/"+merkBukuID.String(), nil)
 		resp, _ := app.Test(req)
@@ -330,5 +342,152 @@ func TestDeleteMerkBuku(t *testing.T) {
 
 		assert.Equal(t, "MerkBuku not found", response["error"])
 	})
+	t.Run("Merk buku still in use", func(t *testing.T) {
+		merkBukuID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE id = $1 AND "merk_buku"."deleted_at" IS NULL`)).
+			WithArgs(merkBukuID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE "merk_buku"."deleted_at" IS NULL AND "merk_buku"."id" = $1 ORDER BY "merk_buku"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(merkBukuID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE merk_buku_id = $1 AND "books"."deleted_at" IS NULL`)).
+			WithArgs(merkBukuID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(37))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions" WHERE merk_buku_id = $1 AND status IN ($2,$3)`)).
+			WithArgs(merkBukuID, models.SalesStatusBooking, models.SalesStatusInstallment).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts" WHERE merk_buku_id = $1 AND status = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opnames" WHERE merk_buku_id = $1 AND status = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("DELETE", "/merk-buku/"+merkBukuID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Merk buku is used by 37 books, 5 open sales transactions", response["error"])
+		blockers := response["blockers"].([]interface{})
+		assert.Len(t, blockers, 2)
+		assert.Equal(t, map[string]interface{}{"table": "books", "label": "books", "count": float64(37)}, blockers[0])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestForceDeleteMerkBuku(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := setupAdminApp()
+	app.Delete("/merk-buku/:id", handlers.DeleteMerkBuku)
+
+	expectMerkBukuInUse := func(merkBukuID uuid.UUID) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE id = $1 AND "merk_buku"."deleted_at" IS NULL`)).
+			WithArgs(merkBukuID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE "merk_buku"."deleted_at" IS NULL AND "merk_buku"."id" = $1 ORDER BY "merk_buku"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(merkBukuID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "stock_opnames"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	}
+
+	t.Run("Dependents move to the replacement", func(t *testing.T) {
+		merkBukuID := uuid.New()
+		replacementID := uuid.New()
+
+		expectMerkBukuInUse(merkBukuID)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "merk_buku" WHERE id = $1 AND deleted_at IS NULL`)).
+			WithArgs(replacementID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "merk_buku_id"=$1,"updated_at"=$2 WHERE merk_buku_id = $3 AND "books"."deleted_at" IS NULL`)).
+			WithArgs(replacementID, sqlmock.AnyArg(), merkBukuID).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transactions" SET "merk_buku_id"=$1,"updated_at"=$2 WHERE merk_buku_id = $3 AND status IN ($4,$5)`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_order_drafts" SET "merk_buku_id"=$1,"updated_at"=$2 WHERE merk_buku_id = $3 AND status = $4`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stock_opnames" SET "merk_buku_id"=$1,"updated_at"=$2 WHERE merk_buku_id = $3 AND status = $4`)).
+			WithArgs(replacementID, sqlmock.AnyArg(), merkBukuID, models.OpnameStatusOpen).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "merk_buku" SET "deleted_at"=$1 WHERE "merk_buku"."id" = $2 AND "merk_buku"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), merkBukuID.String()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("DELETE", "/merk-buku/"+merkBukuID.String()+"?force=true&replacement_id="+replacementID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "MerkBuku deleted successfully", response["message"])
+		assert.Equal(t, replacementID.String(), response["replacement_id"])
+		assert.Len(t, response["reassigned"], 2)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Replacement is required", func(t *testing.T) {
+		merkBukuID := uuid.New()
+
+		expectMerkBukuInUse(merkBukuID)
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("DELETE", "/merk-buku/"+merkBukuID.String()+"?force=true", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "replacement_id must be the ID of another merk buku", response["error"])
+	})
+
+	t.Run("Force needs master_data:force_delete", func(t *testing.T) {
+		userApp := fiber.New()
+		userApp.Use(func(c *fiber.Ctx) error {
+			c.Locals("userRole", "user")
+			return c.Next()
+		})
+		userApp.Delete("/merk-buku/:id", handlers.DeleteMerkBuku)
+
+		merkBukuID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "merk_buku" WHERE id = $1 AND "merk_buku"."deleted_at" IS NULL`)).
+			WithArgs(merkBukuID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(merkBukuID))
+		expectRolePermissions(mock, map[string]string{"user": `{master_data:read,master_data:delete}`})
+
+		req := httptest.NewRequest("DELETE", "/merk-buku/"+merkBukuID.String()+"?force=true&replacement_id="+uuid.New().String(), nil)
+		resp, _ := userApp.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, "Permission required: master_data:force_delete", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
@@ -0,0 +1,245 @@
+package handlers_test
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var passwordResetTokenColumns = []string{"id", "user_id", "token_hash", "expires_at", "used_at"}
+
+func TestForgotPassword(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	mailer := &helpers.CaptureMailer{}
+	handlers.SetMailer(mailer)
+	defer handlers.SetMailer(helpers.SMTPMailer{})
+	handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
+	app := fiber.New()
+	app.Post("/auth/forgot-password", handlers.ForgotPassword)
+
+	post := func(email string) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(handlers.ForgotPasswordRequest{Email: email})
+		req := httptest.NewRequest("POST", "/auth/forgot-password", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Token is emailed and only its hash stored", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+			WithArgs("user@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "full_name", "role"}).
+				AddRow(userID, "user@example.com", "Test User", "user"))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "password_reset_tokens" SET "used_at"=$1 WHERE user_id = $2 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		var storedHash string
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "password_reset_tokens"`)).
+			WithArgs(userID, hashCapture{&storedHash}, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, _ := post("user@example.com")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+
+		messages := mailer.Messages()
+		assert.Len(t, messages, 1)
+		assert.Equal(t, "user@example.com", messages[0].To)
+		token := strings.Fields(strings.SplitN(messages[0].Body, "password: ", 2)[1])[0]
+		assert.Equal(t, helpers.HashToken(token), storedHash)
+		assert.NotContains(t, messages[0].Body, storedHash)
+	})
+
+	t.Run("Unknown email gets the same response", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+			WithArgs("nobody@example.com").
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		status, response := post("nobody@example.com")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "If the email belongs to an account, a password reset link has been sent", response["message"])
+		assert.Len(t, mailer.Messages(), 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failing email gets the same response", func(t *testing.T) {
+		handlers.SetMailer(failingMailer{})
+		defer handlers.SetMailer(mailer)
+
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+			WithArgs("user@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "full_name", "role"}).
+				AddRow(userID, "user@example.com", "Test User", "user"))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "password_reset_tokens" SET "used_at"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "password_reset_tokens"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, response := post("user@example.com")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, "If the email belongs to an account, a password reset link has been sent", response["message"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Requests are throttled per email", func(t *testing.T) {
+		handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+
+		for i := 0; i < 3; i++ {
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+				WithArgs("nobody@example.com").
+				WillReturnError(gorm.ErrRecordNotFound)
+			status, _ := post("nobody@example.com")
+			assert.Equal(t, fiber.StatusOK, status)
+		}
+
+		// The email is not even looked up once the limit is reached
+		status, response := post("Nobody@Example.com")
+
+		assert.Equal(t, fiber.StatusTooManyRequests, status)
+		assert.Equal(t, "Too many password reset requests, try again later", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// failingMailer refuses every message
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("smtp: connection refused")
+}
+
+// hashCapture matches any string argument and keeps its value
+type hashCapture struct {
+	value *string
+}
+
+func (h hashCapture) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	*h.value = s
+	return ok
+}
+
+func TestResetPassword(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
+	app := fiber.New()
+	app.Post("/auth/reset-password", handlers.ResetPassword)
+
+	post := func(body handlers.ResetPasswordRequest) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/auth/reset-password", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	userID := uuid.New()
+
+	t.Run("Password is reset and sessions revoked", func(t *testing.T) {
+		tokenID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "password_reset_tokens" WHERE token_hash = $1`)).
+			WithArgs(helpers.HashToken("reset-token")).
+			WillReturnRows(sqlmock.NewRows(passwordResetTokenColumns).
+				AddRow(tokenID, userID, helpers.HashToken("reset-token"), time.Now().Add(time.Minute), nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "full_name", "role"}).
+				AddRow(userID, "user@example.com", "Test User", "user"))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "password_reset_tokens" SET "used_at"=$1 WHERE id = $2 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), tokenID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "password_reset_tokens" SET "used_at"=$1 WHERE user_id = $2 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "password_hash"=$1,"updated_at"=$2 WHERE id = $3`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "token_version"=token_version + 1 WHERE id = $1`)).
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1,"updated_at"=$2 WHERE user_id = $3 AND revoked_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		status, _ := post(handlers.ResetPasswordRequest{Token: "reset-token", Password: "N3w-Passw0rd"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Used token", func(t *testing.T) {
+		usedAt := time.Now().Add(-time.Minute)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "password_reset_tokens" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows(passwordResetTokenColumns).
+				AddRow(uuid.New(), userID, helpers.HashToken("used-token"), time.Now().Add(time.Minute), usedAt))
+
+		status, response := post(handlers.ResetPasswordRequest{Token: "used-token", Password: "N3w-Passw0rd"})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Invalid or expired reset token", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "password_reset_tokens" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows(passwordResetTokenColumns).
+				AddRow(uuid.New(), userID, helpers.HashToken("old-token"), time.Now().Add(-time.Minute), nil))
+
+		status, response := post(handlers.ResetPasswordRequest{Token: "old-token", Password: "N3w-Passw0rd"})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Invalid or expired reset token", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Weak password", func(t *testing.T) {
+		status, _ := post(handlers.ResetPasswordRequest{Token: "reset-token", Password: "password"})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -167,6 +167,45 @@ func TestCreatePayment(t *testing.T) {
 		assert.Equal(t, "Transaction not found", response["error"])
 	})
 
+	t.Run("Cancelled transaction", func(t *testing.T) {
+		transactionID := uuid.New()
+
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transactionRows := sqlmock.NewRows([]string{
+			"id", "sales_associate_id", "no_invoice", "payment_type",
+			"transaction_date", "total_amount", "status", "periode", "year",
+			"created_at", "updated_at",
+		}).AddRow(
+			transactionID, uuid.New(), "INV2024010100000001", "K",
+			time.Now(), 500000.00, 3, 1, "2024", time.Now(), time.Now(),
+		)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(transactionRows)
+
+		requestBody := handlers.CreatePaymentRequest{
+			PaymentDate: testutil.StringPtr("2024-01-15"),
+			Amount:      100000.0,
+		}
+
+		bodyBytes, _ := json.Marshal(requestBody)
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/payments", transactionID.String()), bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Cannot add payment to a cancelled transaction", response["error"])
+	})
+
 	t.Run("Invalid request body", func(t *testing.T) {
 		transactionID := uuid.New()
 		salesAssociateID := uuid.New()
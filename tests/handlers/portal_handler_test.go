@@ -0,0 +1,365 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// newPortalApp returns an app whose requests come from a user linked to the sales associate
+func newPortalApp(salesAssociateID uuid.UUID) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", uuid.New().String())
+		c.Locals("salesAssociateID", salesAssociateID.String())
+		return c.Next()
+	})
+	return app
+}
+
+func TestGetPortalSalesTransaction(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+	mock.MatchExpectationsInOrder(false)
+
+	salesAssociateID := uuid.New()
+	app := newPortalApp(salesAssociateID)
+	app.Get("/portal/sales-transactions/:id", handlers.GetPortalSalesTransaction)
+
+	t.Run("Own transaction without costs", func(t *testing.T) {
+		transactionID := uuid.New()
+		bookID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1 AND sales_associate_id = $2`)).
+			WithArgs(transactionID, salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sales_associate_id", "no_invoice", "payment_type", "transaction_date", "total_amount", "status"}).
+				AddRow(transactionID, salesAssociateID, "INV2024011500000001", "K", time.Now(), 300000.0, models.SalesStatusInstallment))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items" WHERE "sales_transaction_items"."transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity", "price", "subtotal", "unit_cost", "cogs"}).
+				AddRow(uuid.New(), transactionID, bookID, 10, 30000.0, 300000.0, 18000.0, 180000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "average_cost"}).
+				AddRow(bookID, "Matematika Kelas 1", 30000.0, 18000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "payments" WHERE "payments"."sales_transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sales_transaction_id", "no_payment", "payment_date", "amount", "discount_amount"}).
+				AddRow(uuid.New(), transactionID, "PAY2024012000000001", time.Now(), 100000.0, 5000.0))
+
+		req := httptest.NewRequest("GET", "/portal/sales-transactions/"+transactionID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		var response handlers.PortalTransaction
+		json.Unmarshal(respBody, &response)
+		assert.Equal(t, 100000.0, response.TotalPaid)
+		assert.Equal(t, 195000.0, response.RemainingAmount)
+		assert.Equal(t, "Matematika Kelas 1", response.Items[0].BookName)
+		assert.NotContains(t, string(respBody), "cost")
+		assert.NotContains(t, string(respBody), "cogs")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Transaction of another associate", func(t *testing.T) {
+		transactionID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1 AND sales_associate_id = $2`)).
+			WithArgs(transactionID, salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		req := httptest.NewRequest("GET", "/portal/sales-transactions/"+transactionID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetPortalStatement(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	salesAssociateID := uuid.New()
+	app := newPortalApp(salesAssociateID)
+	app.Get("/portal/statement", handlers.GetPortalStatement)
+
+	t.Run("Opening balance and running balance", func(t *testing.T) {
+		oldInvoiceID := uuid.New()
+		newInvoiceID := uuid.New()
+		day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE (sales_associate_id = $1 AND status <> $2) AND transaction_date < $3`)).
+			WithArgs(salesAssociateID, models.SalesStatusCancelled, day(31)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "no_invoice", "transaction_date", "total_amount"}).
+				AddRow(oldInvoiceID, "INV2024010200000001", day(2), 500000.0).
+				AddRow(newInvoiceID, "INV2024011500000001", day(15), 200000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT payments.sales_transaction_id, sales_transactions.no_invoice, payments.no_payment, payments.payment_date, payments.amount, payments.discount_amount FROM "payments" JOIN sales_transactions ON sales_transactions.id = payments.sales_transaction_id WHERE (sales_transactions.sales_associate_id = $1 AND sales_transactions.status <> $2) AND payments.payment_date < $3`)).
+			WithArgs(salesAssociateID, models.SalesStatusCancelled, day(31)).
+			WillReturnRows(sqlmock.NewRows([]string{"sales_transaction_id", "no_invoice", "no_payment", "payment_date", "amount", "discount_amount"}).
+				AddRow(oldInvoiceID, "INV2024010200000001", "PAY2024010500000001", day(5), 100000.0, 0.0).
+				AddRow(oldInvoiceID, "INV2024010200000001", "PAY2024011500000001", day(15), 150000.0, 10000.0))
+
+		req := httptest.NewRequest("GET", "/portal/statement?start_date=2024-01-10&end_date=2024-01-30", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var statement handlers.SalesAssociateStatement
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &statement)
+
+		assert.Equal(t, salesAssociateID, statement.SalesAssociateID)
+		assert.Equal(t, 400000.0, statement.OpeningBalance)
+		assert.Equal(t, 200000.0, statement.TotalDebit)
+		assert.Equal(t, 160000.0, statement.TotalCredit)
+		assert.Equal(t, 440000.0, statement.ClosingBalance)
+		if assert.Len(t, statement.Entries, 2) {
+			// The invoice comes before the payment of the same day
+			assert.Equal(t, handlers.StatementEntryInvoice, statement.Entries[0].Type)
+			assert.Equal(t, 600000.0, statement.Entries[0].Balance)
+			assert.Equal(t, "PAY2024011500000001", statement.Entries[1].Reference)
+			assert.Equal(t, 440000.0, statement.Entries[1].Balance)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid date", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/portal/statement?start_date=10-01-2024", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestCreatePortalOrder(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	salesAssociateID := uuid.New()
+	app := newPortalApp(salesAssociateID)
+	app.Post("/portal/orders", handlers.CreatePortalOrder)
+
+	post := func(body handlers.CreateSalesOrderDraftRequest) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/portal/orders", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Draft for the linked associate", func(t *testing.T) {
+		bookID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "books" WHERE id IN ($1)`)).
+			WithArgs(bookID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bookID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_order), '') FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_order_draft_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, response := post(handlers.CreateSalesOrderDraftRequest{
+			PaymentType: "K",
+			Year:        "2024",
+			Items:       []handlers.CreateSalesOrderDraftItemRequest{{BookID: bookID.String(), Quantity: 25}},
+		})
+
+		assert.Equal(t, fiber.StatusCreated, status)
+		assert.Equal(t, salesAssociateID.String(), response["sales_associate_id"])
+		assert.Equal(t, float64(models.DraftStatusPending), response["status"])
+		assert.Regexp(t, `^ORD\d{8}00000001$`, response["no_order"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown book", func(t *testing.T) {
+		bookID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "books" WHERE id IN ($1)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		status, response := post(handlers.CreateSalesOrderDraftRequest{
+			PaymentType: "T",
+			Year:        "2024",
+			Items:       []handlers.CreateSalesOrderDraftItemRequest{{BookID: bookID.String(), Quantity: 5}},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Book with ID "+bookID.String()+" not found", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid quantity", func(t *testing.T) {
+		status, response := post(handlers.CreateSalesOrderDraftRequest{
+			PaymentType: "T",
+			Year:        "2024",
+			Items:       []handlers.CreateSalesOrderDraftItemRequest{{BookID: uuid.New().String(), Quantity: 0}},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Quantity must be greater than 0", response["error"])
+	})
+}
+
+func TestApproveSalesOrderDraft(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/sales-order-drafts/:id/approve", handlers.ApproveSalesOrderDraft)
+
+	draftColumns := []string{"id", "no_order", "sales_associate_id", "payment_type", "periode", "year", "status"}
+
+	t.Run("Books the sales transaction", func(t *testing.T) {
+		draftID := uuid.New()
+		salesAssociateID := uuid.New()
+		bookID := uuid.New()
+		warehouseID := uuid.New()
+		stockID := uuid.New()
+		transactionID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_order_drafts" WHERE id = $1`)).
+			WithArgs(draftID.String()).
+			WillReturnRows(sqlmock.NewRows(draftColumns).
+				AddRow(draftID, "ORD2024011500000001", salesAssociateID, "K", 1, "2024", models.DraftStatusPending))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_order_draft_items" WHERE "sales_order_draft_items"."draft_id" = $1`)).
+			WithArgs(draftID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "draft_id", "book_id", "quantity"}).
+				AddRow(uuid.New(), draftID, bookID, 10))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id" FROM "billers"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(warehouseID, "Gudang Utama"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE id = $1`)).
+			WithArgs(bookID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "price", "average_cost"}).
+				AddRow(bookID, "Matematika Kelas 1", 30000.0, 18000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 50))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2 ORDER BY "book_stocks"."id" LIMIT 1 FOR UPDATE`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, 50))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WithArgs(40, sqlmock.AnyArg(), stockID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(no_invoice), '') FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(""))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(transactionID))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "sales_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_order_drafts" SET "reviewed_at"=$1,"reviewed_by_id"=$2,"sales_transaction_id"=$3,"status"=$4,"updated_at"=$5 WHERE id = $6 AND status = $7`)).
+			WithArgs(sqlmock.AnyArg(), nil, transactionID, models.DraftStatusApproved, sqlmock.AnyArg(), draftID, models.DraftStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		body := []byte(`{"transaction_date":"2024-01-16","warehouse_id":"` + warehouseID.String() + `"}`)
+		req := httptest.NewRequest("POST", "/sales-order-drafts/"+draftID.String()+"/approve", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var response struct {
+			Transaction models.SalesTransaction `json:"sales_transaction"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		assert.Equal(t, transactionID, response.Transaction.ID)
+		assert.Equal(t, salesAssociateID, response.Transaction.SalesAssociateID)
+		assert.Equal(t, 300000.0, response.Transaction.TotalAmount)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Draft already reviewed", func(t *testing.T) {
+		draftID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_order_drafts" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows(draftColumns).
+				AddRow(draftID, "ORD2024011500000002", uuid.New(), "T", 1, "2024", models.DraftStatusRejected))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_order_draft_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "draft_id", "book_id", "quantity"}))
+
+		req := httptest.NewRequest("POST", "/sales-order-drafts/"+draftID.String()+"/approve", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestRejectSalesOrderDraft(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/sales-order-drafts/:id/reject", handlers.RejectSalesOrderDraft)
+
+	draftID := uuid.New()
+	expectDraft := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_order_drafts" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "no_order", "sales_associate_id", "status"}).
+				AddRow(draftID, "ORD2024011500000001", uuid.New(), models.DraftStatusPending))
+	}
+	reject := func(reason string) int {
+		bodyBytes, _ := json.Marshal(handlers.RejectSalesOrderDraftRequest{Reason: reason})
+		req := httptest.NewRequest("POST", "/sales-order-drafts/"+draftID.String()+"/reject", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		return resp.StatusCode
+	}
+
+	t.Run("Rejected with a reason", func(t *testing.T) {
+		expectDraft()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_order_drafts" SET "rejection_reason"=$1,"reviewed_at"=$2,"reviewed_by_id"=$3,"status"=$4,"updated_at"=$5 WHERE id = $6 AND status = $7`)).
+			WithArgs("Out of stock until March", sqlmock.AnyArg(), nil, models.DraftStatusRejected, sqlmock.AnyArg(), draftID, models.DraftStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.Equal(t, fiber.StatusOK, reject("Out of stock until March"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reviewed meanwhile", func(t *testing.T) {
+		expectDraft()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_order_drafts" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.Equal(t, fiber.StatusConflict, reject("Duplicate"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing reason", func(t *testing.T) {
+		expectDraft()
+
+		assert.Equal(t, fiber.StatusBadRequest, reject("  "))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
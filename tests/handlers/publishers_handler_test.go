@@ -37,7 +37,7 @@ func TestGetAllPublishers(t *testing.T) {
 			AddRow(publisherID1, "PUB001", "Gramedia", "Test Description", "Jl. Test 1", cityID1, "Area 1", "021-111", "021-112", "gramedia@example.com", "www.gramedia.com", time.Now(), time.Now()).
 			AddRow(publisherID2, "PUB002", "Erlangga", nil, "Jl. Test 2", cityID2, "Area 2", "021-222", nil, nil, nil, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" ORDER BY created_at DESC`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE "publishers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WillReturnRows(publisherRows)
 
 		cityRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
@@ -77,7 +77,7 @@ func TestGetAllPublishers(t *testing.T) {
 		publisherRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "created_at", "updated_at"}).
 			AddRow(publisherID, "PUB001", "Publisher A", &description, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE (publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3) AND "publishers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%PUB001%", "%PUB001%", "%PUB001%").
 			WillReturnRows(publisherRows)
 
@@ -116,7 +116,7 @@ func TestGetAllPublishers(t *testing.T) {
 		publisherRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "created_at", "updated_at"}).
 			AddRow(publisherID, "PUB001", "PublisherA", nil, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE (publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3) AND "publishers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%PublisherA%", "%PublisherA%", "%PublisherA%").
 			WillReturnRows(publisherRows)
 
@@ -155,7 +155,7 @@ func TestGetAllPublishers(t *testing.T) {
 		publisherRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "created_at", "updated_at"}).
 			AddRow(publisherID, "PUB001", "PublisherA", "Yang di Ampera", "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, time.Now(), time.Now())
 
-		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock4.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE (publishers.code ILIKE $1 OR publishers.name ILIKE $2 OR publishers.description ILIKE $3) AND "publishers"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%ampera%", "%ampera%", "%ampera%").
 			WillReturnRows(publisherRows)
 
@@ -312,7 +312,7 @@ func TestUpdatePublisher(t *testing.T) {
 			WillReturnRows(publisherRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "publishers" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "publishers" SET .+ WHERE "publishers"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -374,9 +374,19 @@ func TestDeletePublisher(t *testing.T) {
 	t.Run("Successfully delete publisher", func(t *testing.T) {
 		publisherID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "publishers" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE id = $1 AND "publishers"."deleted_at" IS NULL`)).
 			WithArgs(publisherID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(publisherID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE "publishers"."deleted_at" IS NULL AND "publishers"."id" = $1 ORDER BY "publishers"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(publisherID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(publisherID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "purchase_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "publishers" SET "deleted_at"=$1 WHERE "publishers"."id" = $2 AND "publishers"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), publisherID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -395,11 +405,9 @@ func TestDeletePublisher(t *testing.T) {
 	t.Run("Publisher not found", func(t *testing.T) {
 		publisherID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "publishers" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "publishers" WHERE id = $1 AND "publishers"."deleted_at" IS NULL`)).
 			WithArgs(publisherID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/publishers/"+publisherID.String(), nil)
 		resp, _ := app.Test(req)
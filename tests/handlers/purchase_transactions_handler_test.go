@@ -3,7 +3,9 @@ package handlers_test
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http/httptest"
+	"regexp"
 	"testing"
 	"time"
 
@@ -166,6 +168,32 @@ func TestUpdatePurchaseTransactionWithDateOnly(t *testing.T) {
 
 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	})
+
+	t.Run("Status cannot be set directly", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transactionID := uuid.New()
+
+		mock.ExpectQuery(`SELECT \* FROM "purchase_transactions" WHERE id = \$1`).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status"}).
+				AddRow(transactionID, uuid.New(), "PRC2026013100000002", "2026-01-31", 100000.0, models.PurchaseStatusPending))
+
+		req := httptest.NewRequest("PUT", "/purchase-transactions/"+transactionID.String(), bytes.NewReader([]byte(`{"status": 1}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "status cannot be updated, use the goods receipt, complete, close or cancel endpoints", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestDateOnlyJSONMarshaling(t *testing.T) {
@@ -269,3 +297,102 @@ func TestPurchaseTransactionNotFound(t *testing.T) {
 		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 	})
 }
+
+func TestCancelPurchaseTransaction(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/purchase-transactions/:id/cancel", handlers.CancelPurchaseTransaction)
+
+	t.Run("Goods received after loading keep the purchase open", func(t *testing.T) {
+		transactionID := uuid.New()
+
+		mock.ExpectQuery(`SELECT \* FROM "purchase_transactions" WHERE id = \$1`).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status"}).
+				AddRow(transactionID, uuid.New(), "PRC2026013100000002", "2026-01-31", 100000.0, models.PurchaseStatusPending))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "purchase_transactions" SET "status"=$1,"updated_at"=$2 WHERE status = $3 AND "id" = $4`)).
+			WithArgs(models.PurchaseStatusCancelled, sqlmock.AnyArg(), models.PurchaseStatusPending, transactionID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("POST", "/purchase-transactions/"+transactionID.String()+"/cancel", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeletePurchaseTransaction(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Delete("/purchase-transactions/:id", handlers.DeletePurchaseTransaction)
+
+	transactionID := uuid.New()
+	warehouseID := uuid.New()
+	bookID := uuid.New()
+	stockID := uuid.New()
+
+	expectReceivedPurchase := func(warehouseStock int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transactions" WHERE id = $1 ORDER BY "purchase_transactions"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "no_invoice", "purchase_date", "total_amount", "status", "warehouse_id"}).
+				AddRow(transactionID, uuid.New(), "PRC2026013100000002", "2026-01-31", 350000.0, models.PurchaseStatusCompleted, warehouseID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "purchase_transaction_items" WHERE "purchase_transaction_items"."purchase_transaction_id" = $1 FOR UPDATE`)).
+			WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "purchase_transaction_id", "book_id", "quantity", "received_quantity", "price", "subtotal"}).
+				AddRow(uuid.New(), transactionID, bookID, 7, 7, 50000.0, 350000.0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "average_cost"=CASE WHEN stock > $1`)).
+			WithArgs(7, 7, 50000.0, 7, sqlmock.AnyArg(), bookID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WithArgs(bookID, warehouseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).AddRow(stockID, bookID, warehouseID, warehouseStock))
+	}
+
+	t.Run("Received stock is taken back out", func(t *testing.T) {
+		expectReceivedPurchase(10)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WithArgs(3, sqlmock.AnyArg(), stockID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WithArgs(-7, sqlmock.AnyArg(), bookID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "purchase_transactions" WHERE "purchase_transactions"."id" = $1`)).
+			WithArgs(transactionID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("DELETE", "/purchase-transactions/"+transactionID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Purchase whose stock has been sold cannot be deleted", func(t *testing.T) {
+		// Only 3 of the 7 received books are left in the warehouse
+		expectReceivedPurchase(3)
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("DELETE", "/purchase-transactions/"+transactionID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Received stock of this purchase has already been sold, moved or allocated to backorders", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var reorderCandidateColumns = []string{"book_id", "book_name", "publisher_id", "publisher_code", "publisher_name", "stock", "min_stock", "reorder_quantity", "sold_quantity", "incoming_quantity", "last_purchase_price"}
+
+func TestGetReorderSuggestions(t *testing.T) {
+	app := fiber.New()
+	app.Get("/reports/reorder-suggestions", handlers.GetReorderSuggestions)
+
+	t.Run("Suggestions grouped by publisher", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		publisherID1 := uuid.New()
+		publisherID2 := uuid.New()
+
+		rows := sqlmock.NewRows(reorderCandidateColumns).
+			// Sells 1 a day: target 10 + 30 = 40, suggest 38
+			AddRow(uuid.New(), "Matematika 1", publisherID1, "PUB001", "Erlangga", 2, 10, 20, 30, 0, 40000.0).
+			// Enough stock for the coverage period
+			AddRow(uuid.New(), "IPA 1", publisherID1, "PUB001", "Erlangga", 100, 10, 20, 30, 0, 40000.0).
+			// Below min stock, bumped to the reorder quantity
+			AddRow(uuid.New(), "IPS 1", publisherID2, "PUB002", "Gramedia", 5, 10, 50, 0, 0, 30000.0).
+			// Covered by a pending purchase
+			AddRow(uuid.New(), "PKN 1", publisherID2, "PUB002", "Gramedia", 0, 10, 50, 0, 20, 30000.0)
+
+		mock.ExpectQuery(`FROM books b`).WillReturnRows(rows)
+
+		req := httptest.NewRequest("GET", "/reports/reorder-suggestions", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response struct {
+			WindowDays int                               `json:"window_days"`
+			Data       []handlers.ReorderSuggestionGroup `json:"data"`
+			Summary    map[string]interface{}            `json:"summary"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, 30, response.WindowDays)
+		assert.Len(t, response.Data, 2)
+
+		assert.Equal(t, publisherID1, response.Data[0].PublisherID)
+		assert.Len(t, response.Data[0].Items, 1)
+		assert.Equal(t, 40, response.Data[0].Items[0].TargetStock)
+		assert.Equal(t, 38, response.Data[0].Items[0].SuggestedQuantity)
+
+		assert.Equal(t, publisherID2, response.Data[1].PublisherID)
+		assert.Len(t, response.Data[1].Items, 1)
+		assert.Equal(t, 50, response.Data[1].Items[0].SuggestedQuantity)
+
+		assert.Equal(t, float64(88), response.Summary["total_quantity"])
+	})
+}
+
+func TestCreatePurchaseDraftsFromSuggestions(t *testing.T) {
+	app := fiber.New()
+	app.Post("/purchase-transactions/from-suggestions", handlers.CreatePurchaseDraftsFromSuggestions)
+
+	t.Run("Nothing to reorder", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM books b`).WillReturnRows(sqlmock.NewRows(reorderCandidateColumns))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", "/purchase-transactions/from-suggestions", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "No books need to be reordered", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
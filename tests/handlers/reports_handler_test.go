@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGrossMarginReport(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/reports/gross-margin", handlers.GetGrossMarginReport)
+
+	t.Run("Margin per merk buku", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT merk_buku.id::text AS group_id, merk_buku.code AS code, merk_buku.name AS name`)).
+			WillReturnRows(sqlmock.NewRows([]string{"group_id", "code", "name", "total_items", "revenue", "cogs", "gross_margin"}).
+				AddRow(uuid.New().String(), "ERL", "Erlangga", 20, 1000000.0, 600000.0, 400000.0).
+				AddRow(uuid.New().String(), "YDH", "Yudhistira", 10, 500000.0, 400000.0, 100000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT merk_buku.id::text`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(sales_transaction_items.quantity), 0) AS total_items`)).
+			WillReturnRows(sqlmock.NewRows([]string{"total_items", "revenue", "cogs", "gross_margin"}).
+				AddRow(30, 1500000.0, 1000000.0, 500000.0))
+
+		req := httptest.NewRequest("GET", "/reports/gross-margin?group_by=merk_buku", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response struct {
+			Data    []handlers.GrossMarginLine  `json:"data"`
+			Summary handlers.GrossMarginSummary `json:"summary"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, 40.0, response.Data[0].MarginPercent)
+		assert.Equal(t, 20.0, response.Data[1].MarginPercent)
+		assert.Equal(t, 33.33, response.Summary.MarginPercent)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid grouping", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/reports/gross-margin?group_by=publisher", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestGetStockValuationReport(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Get("/reports/stock-valuation", handlers.GetStockValuationReport)
+
+	t.Run("Deleted books are not valued", func(t *testing.T) {
+		merkBukuID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT books.id AS book_id`) + `.*` + regexp.QuoteMeta(`WHERE books.deleted_at IS NULL AND books.stock > 0`)).
+			WillReturnRows(sqlmock.NewRows([]string{"book_id", "book_name", "merk_buku_id", "merk_buku_name", "quantity", "average_cost", "total_value"}).
+				AddRow(uuid.New(), "Matematika 1", merkBukuID, "Erlangga", 10, 30000.0, 300000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE books.deleted_at IS NULL AND books.stock > 0`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) AS total_books`) + `.*` + regexp.QuoteMeta(`WHERE books.deleted_at IS NULL AND books.stock > 0`)).
+			WillReturnRows(sqlmock.NewRows([]string{"total_books", "total_quantity", "total_value"}).AddRow(1, 10, 300000.0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT merk_buku.id AS merk_buku_id`) + `.*` + regexp.QuoteMeta(`WHERE books.deleted_at IS NULL AND books.stock > 0`)).
+			WillReturnRows(sqlmock.NewRows([]string{"merk_buku_id", "merk_buku_name", "quantity", "total_value"}).AddRow(merkBukuID, "Erlangga", 10, 300000.0))
+
+		resp, _ := app.Test(httptest.NewRequest("GET", "/reports/stock-valuation", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response struct {
+			Summary handlers.StockValuationSummary `json:"summary"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, 300000.0, response.Summary.TotalValue)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,220 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var roleColumns = []string{"id", "name", "description", "permissions", "is_system", "created_at", "updated_at"}
+
+// expectRolePermissions mocks loading the permissions of the caller's role and the roles it manages
+func expectRolePermissions(mock sqlmock.Sqlmock, permissions map[string]string) {
+	rows := sqlmock.NewRows([]string{"name", "permissions"})
+	for name, list := range permissions {
+		rows.AddRow(name, list)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "name","permissions" FROM "roles" WHERE name IN (`)).
+		WillReturnRows(rows)
+}
+
+// setupRoleApp creates an app whose requests are made by a user of the given role
+func setupRoleApp(role string) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userRole", role)
+		return c.Next()
+	})
+	return app
+}
+
+func TestCreateRole(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := setupRoleApp("admin")
+	app.Post("/roles", handlers.CreateRole)
+
+	post := func(body interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/roles", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Successfully create role", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("gudang_surabaya").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+		mock.ExpectBegin()
+		// Permissions are deduplicated and sorted
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "roles"`)).
+			WithArgs("gudang_surabaya", nil, `{"books:read","inventory:read","inventory:write"}`, false, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, response := post(handlers.RoleRequest{
+			Name:        testutil.StringPtr("gudang_surabaya"),
+			Permissions: []string{"inventory:write", "books:read", "inventory:read", "books:read"},
+		})
+
+		assert.Equal(t, fiber.StatusCreated, status)
+		assert.Equal(t, []interface{}{"books:read", "inventory:read", "inventory:write"}, response["permissions"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown permission", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		status, response := post(handlers.RoleRequest{
+			Name:        testutil.StringPtr("kasir"),
+			Permissions: []string{"payments:approve"},
+		})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Unknown permission: payments:approve", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		status, _ := post(handlers.RoleRequest{Name: testutil.StringPtr("Gudang Surabaya")})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+
+	t.Run("Name already exists", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("finance").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		status, response := post(handlers.RoleRequest{Name: testutil.StringPtr("finance")})
+
+		assert.Equal(t, fiber.StatusConflict, status)
+		assert.Equal(t, "Role name already exists", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Permissions the caller does not hold cannot be granted", func(t *testing.T) {
+		app := setupRoleApp("role_manager")
+		app.Post("/roles", handlers.CreateRole)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("superuser").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		expectRolePermissions(mock, map[string]string{"role_manager": `{roles:read,roles:write}`})
+
+		bodyBytes, _ := json.Marshal(handlers.RoleRequest{Name: testutil.StringPtr("superuser"), Permissions: []string{"*"}})
+		req := httptest.NewRequest("POST", "/roles", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, "Cannot grant a permission you do not hold: *", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestUpdateRole(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := setupRoleApp("admin")
+	app.Put("/roles/:id", handlers.UpdateRole)
+
+	put := func(id uuid.UUID, body interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("PUT", "/roles/"+id.String(), bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("System role cannot be changed", func(t *testing.T) {
+		roleID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "roles" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows(roleColumns).
+				AddRow(roleID, "admin", nil, `{"*"}`, true, time.Now(), time.Now()))
+
+		status, response := put(roleID, handlers.RoleRequest{Permissions: []string{"books:read"}})
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "System roles cannot be changed", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Replace permissions", func(t *testing.T) {
+		roleID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "roles" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows(roleColumns).
+				AddRow(roleID, "finance", nil, `{payments:read}`, false, time.Now(), time.Now()))
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "roles" SET "permissions"=$1,"updated_at"=$2 WHERE "id" = $3`)).
+			WithArgs(`{"payments:delete","payments:read"}`, sqlmock.AnyArg(), roleID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "roles" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows(roleColumns).
+				AddRow(roleID, "finance", nil, `{payments:delete,payments:read}`, false, time.Now(), time.Now()))
+
+		status, response := put(roleID, handlers.RoleRequest{Permissions: []string{"payments:read", "payments:delete"}})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, []interface{}{"payments:delete", "payments:read"}, response["permissions"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteRole(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Delete("/roles/:id", handlers.DeleteRole)
+
+	roleID := uuid.New()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "roles" WHERE id = $1`)).
+		WillReturnRows(sqlmock.NewRows(roleColumns).
+			AddRow(roleID, "sales", nil, `{sales:read}`, false, time.Now(), time.Now()))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" WHERE role = $1`)).
+		WithArgs("sales").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	resp, _ := app.Test(httptest.NewRequest("DELETE", "/roles/"+roleID.String(), nil))
+
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	var response map[string]interface{}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+	assert.Equal(t, float64(2), response["user_count"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -38,7 +38,7 @@ func TestGetAllSalesAssociates(t *testing.T) {
 			AddRow(salesAssociateID1, "SA001", "PT Distributor A", "Test Description", "Jl. Test 1", cityID1, "Area 1", "021-111", "021-112", "distributora@example.com", "www.distributora.com", "T", joinDate, nil, discount, time.Now(), time.Now()).
 			AddRow(salesAssociateID2, "SA002", "PT Distributor B", nil, "Jl. Test 2", cityID2, "Area 2", "021-222", nil, nil, nil, "T", joinDate, nil, discount, time.Now(), time.Now())
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" ORDER BY created_at DESC`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE "sales_associates"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WillReturnRows(salesAssociateRows)
 
 		cityRows := sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
@@ -79,7 +79,7 @@ func TestGetAllSalesAssociates(t *testing.T) {
 		salesAssociateRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "jenis_pembayaran", "join_date", "end_join_date", "discount", "created_at", "updated_at"}).
 			AddRow(salesAssociateID, "SA001", "Sales A", &description, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, "T", joinDate, nil, 10.0, time.Now(), time.Now())
 
-		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE sales_associates.code ILIKE $1 OR sales_associates.name ILIKE $2 OR sales_associates.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE (sales_associates.code ILIKE $1 OR sales_associates.name ILIKE $2 OR sales_associates.description ILIKE $3) AND "sales_associates"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%SA001%", "%SA001%", "%SA001%").
 			WillReturnRows(salesAssociateRows)
 
@@ -119,7 +119,7 @@ func TestGetAllSalesAssociates(t *testing.T) {
 		salesAssociateRows := sqlmock.NewRows([]string{"id", "code", "name", "description", "address", "city_id", "area", "phone1", "phone2", "email", "website", "jenis_pembayaran", "join_date", "end_join_date", "discount", "created_at", "updated_at"}).
 			AddRow(salesAssociateID, "SA001", "SalesA", nil, "Jl. Test", cityID, "Area 1", "021-111", nil, nil, nil, "T", joinDate, nil, 10.0, time.Now(), time.Now())
 
-		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE sales_associates.code ILIKE $1 OR sales_associates.name ILIKE $2 OR sales_associates.description ILIKE $3 ORDER BY created_at DESC`)).
+		mock3.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE (sales_associates.code ILIKE $1 OR sales_associates.name ILIKE $2 OR sales_associates.description ILIKE $3) AND "sales_associates"."deleted_at" IS NULL ORDER BY created_at DESC`)).
 			WithArgs("%SalesA%", "%SalesA%", "%SalesA%").
 			WillReturnRows(salesAssociateRows)
 
@@ -285,7 +285,7 @@ func TestUpdateSalesAssociate(t *testing.T) {
 			WillReturnRows(salesAssociateRows)
 
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "sales_associates" SET .+ WHERE "id" = .+`).
+		mock.ExpectExec(`UPDATE "sales_associates" SET .+ WHERE "sales_associates"\."deleted_at" IS NULL AND "id" = .+`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -363,9 +363,21 @@ func TestDeleteSalesAssociate(t *testing.T) {
 	t.Run("Successfully delete sales associate", func(t *testing.T) {
 		salesAssociateID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sales_associates" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE id = $1 AND "sales_associates"."deleted_at" IS NULL`)).
 			WithArgs(salesAssociateID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(salesAssociateID))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE "sales_associates"."deleted_at" IS NULL AND "sales_associates"."id" = $1 ORDER BY "sales_associates"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(salesAssociateID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_associates" SET "deleted_at"=$1 WHERE "sales_associates"."id" = $2 AND "sales_associates"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), salesAssociateID.String()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -384,11 +396,9 @@ func TestDeleteSalesAssociate(t *testing.T) {
 	t.Run("Sales associate not found", func(t *testing.T) {
 		salesAssociateID := uuid.New()
 
-		mock.ExpectBegin()
-		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "sales_associates" WHERE id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE id = $1 AND "sales_associates"."deleted_at" IS NULL`)).
 			WithArgs(salesAssociateID.String()).
-			WillReturnResult(sqlmock.NewResult(0, 0))
-		mock.ExpectCommit()
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 		req := httptest.NewRequest("DELETE", "/sales-associates/"+salesAssociateID.String(), nil)
 		resp, _ := app.Test(req)
@@ -402,3 +412,90 @@ func TestDeleteSalesAssociate(t *testing.T) {
 		assert.Equal(t, "SalesAssociate not found", response["error"])
 	})
 }
+
+func TestForceDeleteSalesAssociate(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := setupAdminApp()
+	app.Delete("/sales-associates/:id", handlers.DeleteSalesAssociate)
+
+	expectSalesAssociateInUse := func(salesAssociateID uuid.UUID, openSales, users int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE id = $1 AND "sales_associates"."deleted_at" IS NULL`)).
+			WithArgs(salesAssociateID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(salesAssociateID))
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE "sales_associates"."deleted_at" IS NULL AND "sales_associates"."id" = $1 ORDER BY "sales_associates"."id" LIMIT 1 FOR UPDATE`)).
+			WithArgs(salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(salesAssociateID))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(openSales))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_order_drafts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" WHERE sales_associate_id = $1`)).
+			WithArgs(salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(users))
+	}
+
+	t.Run("Linked user accounts are unlinked without a replacement", func(t *testing.T) {
+		salesAssociateID := uuid.New()
+
+		expectSalesAssociateInUse(salesAssociateID, 0, 2)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "sales_associate_id"=$1,"updated_at"=$2 WHERE sales_associate_id = $3`)).
+			WithArgs(nil, sqlmock.AnyArg(), salesAssociateID).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_associates" SET "deleted_at"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("DELETE", "/sales-associates/"+salesAssociateID.String()+"?force=true", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Nil(t, response["replacement_id"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"table": "users", "label": "user accounts", "count": float64(2)}}, response["unlinked"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Documents move while user accounts are unlinked", func(t *testing.T) {
+		salesAssociateID := uuid.New()
+		replacementID := uuid.New()
+
+		expectSalesAssociateInUse(salesAssociateID, 1, 1)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_associates" WHERE id = $1 AND deleted_at IS NULL`)).
+			WithArgs(replacementID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transactions" SET "sales_associate_id"=$1`)).
+			WithArgs(replacementID, sqlmock.AnyArg(), salesAssociateID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_order_drafts" SET "sales_associate_id"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "sales_associate_id"=$1`)).
+			WithArgs(nil, sqlmock.AnyArg(), salesAssociateID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_associates" SET "deleted_at"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest("DELETE", "/sales-associates/"+salesAssociateID.String()+"?force=true&replacement_id="+replacementID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, replacementID.String(), response["replacement_id"])
+		assert.Len(t, response["reassigned"], 1)
+		assert.Len(t, response["unlinked"], 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
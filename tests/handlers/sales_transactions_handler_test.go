@@ -7,9 +7,11 @@ import (
 	"io"
 	"net/http/httptest"
 	"pustaka-backend/handlers"
+	"pustaka-backend/models"
 	"pustaka-backend/tests/testutil"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gofiber/fiber/v2"
@@ -80,6 +82,46 @@ func TestGetAllSalesTransactions(t *testing.T) {
 		assert.NotNil(t, response["sales_transactions"])
 		assert.NotNil(t, response["pagination"])
 	})
+
+	t.Run("Filter by created_by_id", func(t *testing.T) {
+		db2, mock2, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db2)
+		mock2.MatchExpectationsInOrder(false)
+
+		userID := uuid.New()
+		transactionRows := sqlmock.NewRows([]string{"id", "sales_associate_id", "no_invoice", "created_by_id"}).
+			AddRow(uuid.New(), uuid.New(), "INV-001", userID)
+
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE sales_transactions.created_by_id = $1 ORDER BY sales_transactions.created_at desc LIMIT 20`)).
+			WithArgs(userID.String()).
+			WillReturnRows(transactionRows)
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."id" = $1`)).
+			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "email"}).AddRow(userID, "Sales Desk", "desk@example.com"))
+		for _, table := range []string{"sales_associates", "sales_transaction_items", "payments", "shippings"} {
+			mock2.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "` + table + `"`)).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		}
+		mock2.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_transactions" WHERE sales_transactions.created_by_id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest("GET", "/sales-transactions?created_by_id="+userID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		transactions := response["sales_transactions"].([]interface{})
+		assert.Len(t, transactions, 1)
+		createdBy := transactions[0].(map[string]interface{})["created_by"].(map[string]interface{})
+		assert.Equal(t, "Sales Desk", createdBy["full_name"])
+		assert.NoError(t, mock2.ExpectationsWereMet())
+	})
 }
 
 func TestGetSalesTransaction(t *testing.T) {
@@ -108,6 +150,45 @@ func TestGetSalesTransaction(t *testing.T) {
 
 		assert.Equal(t, "Transaction not found", response["error"])
 	})
+
+	t.Run("Soft deleted sales associate is still shown", func(t *testing.T) {
+		transactionID := uuid.New()
+		salesAssociateID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows(salesTransactionColumns).
+				AddRow(transactionID, nil, salesAssociateID, "INV-001", 1,
+					time.Now(), 100000.0, 0, 1, "2024", nil, nil, nil,
+					time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sales_transaction_id"}))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "payments"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sales_transaction_id"}))
+		// The preload must not filter on deleted_at
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_associates" WHERE "sales_associates"."id" = $1`) + `$`).
+			WithArgs(salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name", "deleted_at"}).
+				AddRow(salesAssociateID, "SA-001", "Former Associate", time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shippings"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "sales_transaction_id"}))
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/sales-transactions/%s", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		transaction, _ := response["transaction"].(map[string]interface{})
+		salesAssociate, ok := transaction["sales_associate"].(map[string]interface{})
+		if assert.True(t, ok) {
+			assert.Equal(t, "Former Associate", salesAssociate["name"])
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
 }
 
 func TestCreateSalesTransaction(t *testing.T) {
@@ -274,3 +355,156 @@ func TestDeleteSalesTransaction(t *testing.T) {
 		assert.Equal(t, "Transaction not found", response["error"])
 	})
 }
+
+func TestCancelSalesTransaction(t *testing.T) {
+	app := fiber.New()
+	app.Post("/sales-transactions/:id/cancel", handlers.CancelSalesTransaction)
+
+	t.Run("Transaction not found", func(t *testing.T) {
+		_, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+
+		transactionID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/cancel", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Transaction not found", response["error"])
+	})
+
+	t.Run("Already cancelled", func(t *testing.T) {
+		_, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+
+		transactionID := uuid.New()
+		transactionRows := sqlmock.NewRows(salesTransactionColumns).AddRow(
+			transactionID, nil, uuid.New(), "INV2024010100000001", "T",
+			time.Now(), 100000.00, 3, 1, "2024", nil, nil, nil, time.Now(), time.Now(),
+		)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(transactionRows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items" WHERE "sales_transaction_items"."transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity"}))
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/cancel", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Transaction is already cancelled", response["error"])
+	})
+
+	t.Run("Paid-off transaction cannot be cancelled", func(t *testing.T) {
+		_, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+
+		transactionID := uuid.New()
+		transactionRows := sqlmock.NewRows(salesTransactionColumns).AddRow(
+			transactionID, nil, uuid.New(), "INV2024010100000001", "T",
+			time.Now(), 100000.00, 1, 1, "2024", nil, nil, nil, time.Now(), time.Now(),
+		)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(transactionRows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items" WHERE "sales_transaction_items"."transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity"}))
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/cancel", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Only booked transactions can be cancelled", response["error"])
+	})
+
+	t.Run("Transaction with payments cannot be cancelled", func(t *testing.T) {
+		_, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+
+		transactionID := uuid.New()
+		transactionRows := sqlmock.NewRows(salesTransactionColumns).AddRow(
+			transactionID, nil, uuid.New(), "INV2024010100000001", "K",
+			time.Now(), 100000.00, 0, 1, "2024", nil, nil, nil, time.Now(), time.Now(),
+		)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(transactionRows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items" WHERE "sales_transaction_items"."transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transactions" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs(models.SalesStatusCancelled, sqlmock.AnyArg(), transactionID, models.SalesStatusBooking).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "payments" WHERE sales_transaction_id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "shippings" WHERE sales_transaction_id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/cancel", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Cannot cancel a transaction with payments or shippings", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Concurrent cancel restores no stock", func(t *testing.T) {
+		_, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+
+		transactionID := uuid.New()
+		transactionRows := sqlmock.NewRows(salesTransactionColumns).AddRow(
+			transactionID, nil, uuid.New(), "INV2024010100000001", "T",
+			time.Now(), 100000.00, 0, 1, "2024", nil, nil, nil, time.Now(), time.Now(),
+		)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID.String()).
+			WillReturnRows(transactionRows)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transaction_items" WHERE "sales_transaction_items"."transaction_id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "transaction_id", "book_id", "quantity"}).
+				AddRow(uuid.New(), transactionID, uuid.New(), 5))
+		mock.ExpectBegin()
+		// Another request cancelled the transaction after it was loaded
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transactions" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs(models.SalesStatusCancelled, sqlmock.AnyArg(), transactionID, models.SalesStatusBooking).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", fmt.Sprintf("/sales-transactions/%s/cancel", transactionID.String()), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -161,16 +161,9 @@ func TestUpdateSalesTransaction_Scenario1_CreateTransaction(t *testing.T) {
 		// Store for cleanup
 		defer cleanupTestData(createdTransaction.ID)
 
-		// Step 2: Update status
-		t.Run("Step 2: Update status", func(t *testing.T) {
-			status := 1
-
-			updateReq := handlers.UpdateTransactionRequest{
-				Status: &status,
-			}
-
-			bodyBytes, _ := json.Marshal(updateReq)
-			req := httptest.NewRequest("PUT", fmt.Sprintf("/sales-transactions/%s", createdTransaction.ID), bytes.NewReader(bodyBytes))
+		// Step 2: Status in the body is ignored, only payments and cancelling change it
+		t.Run("Step 2: Status is not editable", func(t *testing.T) {
+			req := httptest.NewRequest("PUT", fmt.Sprintf("/sales-transactions/%s", createdTransaction.ID), bytes.NewReader([]byte(`{"status": 1}`)))
 			req.Header.Set("Content-Type", "application/json")
 			resp, _ := app.Test(req)
 
@@ -180,7 +173,7 @@ func TestUpdateSalesTransaction_Scenario1_CreateTransaction(t *testing.T) {
 			respBody, _ := io.ReadAll(resp.Body)
 			json.Unmarshal(respBody, &updatedTransaction)
 
-			assert.Equal(t, 1, updatedTransaction.Status)
+			assert.Equal(t, models.SalesStatusBooking, updatedTransaction.Status)
 		})
 	})
 }
@@ -242,11 +235,9 @@ func TestUpdateSalesTransaction_Scenario2_UpdateItems(t *testing.T) {
 		// Store for cleanup
 		defer cleanupTestData(createdTransaction.ID)
 
-		// Step 2: Update status only, items should remain the same
-		t.Run("Step 2: Update status only", func(t *testing.T) {
-			status := 2
+		// Step 2: Resend the same items, they should remain the same
+		t.Run("Step 2: Resend the same items", func(t *testing.T) {
 			updateReq := handlers.UpdateTransactionRequest{
-				Status: &status,
 				Items: []handlers.CreateTransactionItemRequest{
 					{
 						BookID:   bookX.ID.String(),
@@ -266,16 +257,14 @@ func TestUpdateSalesTransaction_Scenario2_UpdateItems(t *testing.T) {
 			respBody, _ := io.ReadAll(resp.Body)
 			json.Unmarshal(respBody, &updatedTransaction)
 
-			assert.Equal(t, 2, updatedTransaction.Status)
+			assert.Equal(t, models.SalesStatusBooking, updatedTransaction.Status)
 			assert.Len(t, updatedTransaction.Items, 1)
 			assert.Equal(t, bookX.ID, updatedTransaction.Items[0].BookID)
 			assert.Equal(t, 1, updatedTransaction.Items[0].Quantity)
 
 			// Step 3: Update quantity of existing item
 			t.Run("Step 3: Update quantity of existing item", func(t *testing.T) {
-				status := 1
 				updateReq := handlers.UpdateTransactionRequest{
-					Status: &status,
 					Items: []handlers.CreateTransactionItemRequest{
 						{
 							BookID:   bookX.ID.String(),
@@ -295,7 +284,7 @@ func TestUpdateSalesTransaction_Scenario2_UpdateItems(t *testing.T) {
 				respBody, _ := io.ReadAll(resp.Body)
 				json.Unmarshal(respBody, &updatedTransaction)
 
-				assert.Equal(t, 1, updatedTransaction.Status)
+				assert.Equal(t, models.SalesStatusBooking, updatedTransaction.Status)
 				assert.Len(t, updatedTransaction.Items, 1)
 				assert.Equal(t, bookX.ID, updatedTransaction.Items[0].BookID)
 				assert.Equal(t, 2, updatedTransaction.Items[0].Quantity)
@@ -306,9 +295,7 @@ func TestUpdateSalesTransaction_Scenario2_UpdateItems(t *testing.T) {
 
 				// Step 4: Add additional item
 				t.Run("Step 4: Add additional item", func(t *testing.T) {
-					status := 1
 					updateReq := handlers.UpdateTransactionRequest{
-						Status: &status,
 						Items: []handlers.CreateTransactionItemRequest{
 							{
 								BookID:   bookX.ID.String(),
@@ -332,7 +319,7 @@ func TestUpdateSalesTransaction_Scenario2_UpdateItems(t *testing.T) {
 					respBody, _ := io.ReadAll(resp.Body)
 					json.Unmarshal(respBody, &finalTransaction)
 
-					assert.Equal(t, 1, finalTransaction.Status)
+					assert.Equal(t, models.SalesStatusBooking, finalTransaction.Status)
 					assert.Len(t, finalTransaction.Items, 2)
 
 					// Verify both items exist with correct quantities
@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var refreshTokenColumns = []string{"id", "user_id", "family_id", "token_hash", "token_version", "expires_at", "revoked_at", "replaced_by_id"}
+
+func TestRefreshSession(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	app := fiber.New()
+	app.Post("/auth/refresh", handlers.RefreshSession)
+
+	refresh := func(token string) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(handlers.RefreshTokenRequest{RefreshToken: token})
+		req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	userID := uuid.New()
+	familyID := uuid.New()
+	userRows := func(tokenVersion int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "email", "full_name", "role", "token_version"}).
+			AddRow(userID, "user@example.com", "Test User", "sales", tokenVersion)
+	}
+
+	t.Run("Token is rotated", func(t *testing.T) {
+		tokenID := uuid.New()
+		newTokenID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE token_hash = $1`)).
+			WithArgs(helpers.HashToken("current-token")).
+			WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+				AddRow(tokenID, userID, familyID, helpers.HashToken("current-token"), 0, time.Now().Add(time.Hour), nil, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(userRows(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+			WithArgs(userID, familyID, sqlmock.AnyArg(), 0, sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(newTokenID))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "replaced_by_id"=$1,"revoked_at"=$2,"updated_at"=$3 WHERE id = $4 AND revoked_at IS NULL`)).
+			WithArgs(newTokenID, sqlmock.AnyArg(), sqlmock.AnyArg(), tokenID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, response := refresh("current-token")
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NotEmpty(t, response["token"])
+		assert.NotEmpty(t, response["refresh_token"])
+		assert.NotEqual(t, "current-token", response["refresh_token"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reusing a rotated token revokes the session", func(t *testing.T) {
+		revokedAt := time.Now().Add(-time.Minute)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+				AddRow(uuid.New(), userID, familyID, helpers.HashToken("old-token"), 0, time.Now().Add(time.Hour), revokedAt, uuid.New()))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1,"updated_at"=$2 WHERE family_id = $3 AND revoked_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), familyID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, response := refresh("old-token")
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+		assert.Equal(t, "Refresh token has been revoked", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("User logged out everywhere", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+				AddRow(uuid.New(), userID, familyID, helpers.HashToken("stale-token"), 0, time.Now().Add(time.Hour), nil, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(userRows(1))
+
+		status, response := refresh("stale-token")
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+		assert.Equal(t, "Refresh token has been revoked", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expired token", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "refresh_tokens" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+				AddRow(uuid.New(), userID, familyID, helpers.HashToken("expired-token"), 0, time.Now().Add(-time.Hour), nil, nil))
+
+		status, response := refresh("expired-token")
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+		assert.Equal(t, "Refresh token has expired", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestLogoutAll(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	userID := uuid.New()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", userID.String())
+		return c.Next()
+	})
+	app.Post("/auth/logout-all", handlers.LogoutAll)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "token_version"=token_version + 1 WHERE id = $1`)).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_tokens" SET "revoked_at"=$1,"updated_at"=$2 WHERE user_id = $3 AND revoked_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/auth/logout-all", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
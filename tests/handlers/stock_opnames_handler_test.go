@@ -0,0 +1,263 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var stockOpnameColumns = []string{"id", "no_opname", "warehouse_id", "merk_buku_id", "jenjang_studi_id", "opname_date", "status", "note", "reason", "posted_at", "created_at", "updated_at"}
+var stockOpnameItemColumns = []string{"id", "stock_opname_id", "book_id", "system_quantity", "counted_quantity", "approved", "reason"}
+
+func TestGetStockOpnameVariance(t *testing.T) {
+	app := fiber.New()
+	app.Get("/stock-opnames/:id/variance", handlers.GetStockOpnameVariance)
+
+	t.Run("Variance against system stock", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+		warehouseID := uuid.New()
+		bookID1 := uuid.New()
+		bookID2 := uuid.New()
+		bookID3 := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnRows(sqlmock.NewRows(stockOpnameColumns).
+				AddRow(opnameID, "OPN2024010100000001", warehouseID, nil, nil, time.Now(), models.OpnameStatusOpen, nil, nil, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opname_items" WHERE "stock_opname_items"."stock_opname_id" = $1`)).
+			WithArgs(opnameID).
+			WillReturnRows(sqlmock.NewRows(stockOpnameItemColumns).
+				AddRow(uuid.New(), opnameID, bookID1, 10, 8, true, nil).
+				AddRow(uuid.New(), opnameID, bookID2, 5, 7, false, nil).
+				AddRow(uuid.New(), opnameID, bookID3, 4, nil, false, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE "books"."id" IN`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+				AddRow(bookID1, "Matematika 1").
+				AddRow(bookID2, "IPA 1").
+				AddRow(bookID3, "IPS 1"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE "warehouses"."id" = $1`)).
+			WithArgs(warehouseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name"}).
+				AddRow(warehouseID, "GDG-JKT", "Gudang Jakarta"))
+
+		req := httptest.NewRequest("GET", "/stock-opnames/"+opnameID.String()+"/variance?only_variance=true", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		summary := response["summary"].(map[string]interface{})
+		assert.Equal(t, float64(3), summary["total_items"])
+		assert.Equal(t, float64(2), summary["counted_items"])
+		assert.Equal(t, float64(1), summary["uncounted_items"])
+		assert.Equal(t, float64(2), summary["variance_items"])
+		assert.Equal(t, float64(2), summary["total_surplus"])
+		assert.Equal(t, float64(2), summary["total_shortage"])
+		assert.Len(t, response["data"], 2)
+	})
+
+	t.Run("Stock opname not found", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest("GET", "/stock-opnames/"+opnameID.String()+"/variance", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestUpdateStockOpnameCounts(t *testing.T) {
+	app := fiber.New()
+	app.Put("/stock-opnames/:id/counts", handlers.UpdateStockOpnameCounts)
+
+	t.Run("Cannot approve an uncounted book", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+		bookID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnRows(sqlmock.NewRows(stockOpnameColumns).
+				AddRow(opnameID, "OPN2024010100000001", uuid.New(), nil, nil, time.Now(), models.OpnameStatusOpen, nil, nil, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opname_items" WHERE "stock_opname_items"."stock_opname_id" = $1`)).
+			WithArgs(opnameID).
+			WillReturnRows(sqlmock.NewRows(stockOpnameItemColumns).
+				AddRow(uuid.New(), opnameID, bookID, 10, nil, false, nil))
+
+		approved := true
+		body, _ := json.Marshal(handlers.UpdateStockOpnameCountsRequest{
+			Items: []handlers.StockOpnameCountReq{{BookID: bookID.String(), Approved: &approved}},
+		})
+		req := httptest.NewRequest("PUT", "/stock-opnames/"+opnameID.String()+"/counts", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Book with ID "+bookID.String()+" must be counted before it can be approved", response["error"])
+	})
+
+	t.Run("Posted stock opname cannot be counted", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnRows(sqlmock.NewRows(stockOpnameColumns).
+				AddRow(opnameID, "OPN2024010100000001", uuid.New(), nil, nil, time.Now(), models.OpnameStatusPosted, nil, nil, time.Now(), time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opname_items" WHERE "stock_opname_items"."stock_opname_id" = $1`)).
+			WithArgs(opnameID).
+			WillReturnRows(sqlmock.NewRows(stockOpnameItemColumns))
+
+		req := httptest.NewRequest("PUT", "/stock-opnames/"+opnameID.String()+"/counts", bytes.NewReader([]byte(`{"items": []}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Only open stock opnames can be counted", response["error"])
+	})
+}
+
+func TestPostStockOpname(t *testing.T) {
+	app := fiber.New()
+	app.Post("/stock-opnames/:id/post", handlers.PostStockOpname)
+
+	t.Run("Reason is required", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/stock-opnames/"+uuid.New().String()+"/post", bytes.NewReader([]byte(`{"reason": "  "}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "reason is required", response["error"])
+	})
+
+	t.Run("Approved variance is posted as adjustment", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+		warehouseID := uuid.New()
+		bookID := uuid.New()
+		stockID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnRows(sqlmock.NewRows(stockOpnameColumns).
+				AddRow(opnameID, "OPN2024010100000001", warehouseID, nil, nil, time.Now(), models.OpnameStatusOpen, nil, nil, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opname_items" WHERE "stock_opname_items"."stock_opname_id" = $1`)).
+			WithArgs(opnameID).
+			WillReturnRows(sqlmock.NewRows(stockOpnameItemColumns).
+				AddRow(uuid.New(), opnameID, bookID, 10, 8, true, nil).
+				AddRow(uuid.New(), opnameID, uuid.New(), 5, 7, false, nil))
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WithArgs(bookID, warehouseID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).
+				AddRow(stockID, bookID, warehouseID, 10))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "book_stocks" SET "quantity"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "stock_adjustments"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+		mock.ExpectExec(`UPDATE "stock_opnames" SET .+ WHERE id = \$\d+ AND status = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		body, _ := json.Marshal(handlers.PostStockOpnameRequest{Reason: "Stock opname semester 1"})
+		req := httptest.NewRequest("POST", "/stock-opnames/"+opnameID.String()+"/post", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, float64(1), response["adjusted_items"])
+	})
+
+	t.Run("Concurrent post is rolled back", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		opnameID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opnames" WHERE id = $1`)).
+			WithArgs(opnameID.String()).
+			WillReturnRows(sqlmock.NewRows(stockOpnameColumns).
+				AddRow(opnameID, "OPN2024010100000002", uuid.New(), nil, nil, time.Now(), models.OpnameStatusOpen, nil, nil, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_opname_items" WHERE "stock_opname_items"."stock_opname_id" = $1`)).
+			WithArgs(opnameID).
+			WillReturnRows(sqlmock.NewRows(stockOpnameItemColumns))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "stock_opnames" SET .+ WHERE id = \$\d+ AND status = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		body, _ := json.Marshal(handlers.PostStockOpnameRequest{Reason: "Stock opname semester 1"})
+		req := httptest.NewRequest("POST", "/stock-opnames/"+opnameID.String()+"/post", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Stock opname has already been posted or cancelled", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,211 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var stockTransferColumns = []string{"id", "no_transfer", "from_warehouse_id", "to_warehouse_id", "transfer_date", "status", "note", "created_at", "updated_at"}
+
+func TestCreateStockTransfer(t *testing.T) {
+	app := fiber.New()
+	app.Post("/stock-transfers", handlers.CreateStockTransfer)
+
+	t.Run("Same source and destination", func(t *testing.T) {
+		warehouseID := uuid.New().String()
+		body, _ := json.Marshal(handlers.CreateStockTransferRequest{
+			FromWarehouseID: warehouseID,
+			ToWarehouseID:   warehouseID,
+			Items:           []handlers.CreateStockTransferItemReq{{BookID: uuid.New().String(), Quantity: 1}},
+		})
+		req := httptest.NewRequest("POST", "/stock-transfers", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Source and destination warehouses must be different", response["error"])
+	})
+
+	t.Run("No items", func(t *testing.T) {
+		body, _ := json.Marshal(handlers.CreateStockTransferRequest{
+			FromWarehouseID: uuid.New().String(),
+			ToWarehouseID:   uuid.New().String(),
+		})
+		req := httptest.NewRequest("POST", "/stock-transfers", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "At least one item is required", response["error"])
+	})
+
+	t.Run("Source warehouse not found", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		fromID := uuid.New().String()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WithArgs(fromID).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		body, _ := json.Marshal(handlers.CreateStockTransferRequest{
+			FromWarehouseID: fromID,
+			ToWarehouseID:   uuid.New().String(),
+			Items:           []handlers.CreateStockTransferItemReq{{BookID: uuid.New().String(), Quantity: 1}},
+		})
+		req := httptest.NewRequest("POST", "/stock-transfers", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Source warehouse not found", response["error"])
+	})
+}
+
+func TestCompleteStockTransfer(t *testing.T) {
+	app := fiber.New()
+	app.Post("/stock-transfers/:id/complete", handlers.CompleteStockTransfer)
+
+	t.Run("Stock transfer not found", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transferID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfers" WHERE id = $1`)).
+			WithArgs(transferID.String()).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest("POST", "/stock-transfers/"+transferID.String()+"/complete", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Insufficient stock in source warehouse", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transferID := uuid.New()
+		fromID := uuid.New()
+		toID := uuid.New()
+		bookID := uuid.New()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfers" WHERE id = $1`)).
+			WithArgs(transferID.String()).
+			WillReturnRows(sqlmock.NewRows(stockTransferColumns).
+				AddRow(transferID, "TRF2024010100000001", fromID, toID, time.Now(), models.TransferStatusPending, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfer_items" WHERE "stock_transfer_items"."stock_transfer_id" = $1`)).
+			WithArgs(transferID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "stock_transfer_id", "book_id", "quantity"}).
+				AddRow(uuid.New(), transferID, bookID, 10))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stock_transfers" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WithArgs(models.TransferStatusCompleted, sqlmock.AnyArg(), transferID, models.TransferStatusPending).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "book_stocks" WHERE book_id = $1 AND warehouse_id = $2`)).
+			WithArgs(bookID, fromID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "warehouse_id", "quantity"}).
+				AddRow(uuid.New(), bookID, fromID, 3))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", "/stock-transfers/"+transferID.String()+"/complete", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Insufficient stock in source warehouse for book ID "+bookID.String(), response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Concurrent complete moves no stock", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transferID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfers" WHERE id = $1`)).
+			WithArgs(transferID.String()).
+			WillReturnRows(sqlmock.NewRows(stockTransferColumns).
+				AddRow(transferID, "TRF2024010100000001", uuid.New(), uuid.New(), time.Now(), models.TransferStatusPending, nil, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfer_items"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "stock_transfer_id", "book_id", "quantity"}).
+				AddRow(uuid.New(), transferID, uuid.New(), 10))
+
+		// Another request completed the transfer after it was loaded
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "stock_transfers" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest("POST", "/stock-transfers/"+transferID.String()+"/complete", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteStockTransfer(t *testing.T) {
+	app := fiber.New()
+	app.Delete("/stock-transfers/:id", handlers.DeleteStockTransfer)
+
+	t.Run("Completed transfers cannot be deleted", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		transferID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "stock_transfers" WHERE id = $1`)).
+			WithArgs(transferID.String()).
+			WillReturnRows(sqlmock.NewRows(stockTransferColumns).
+				AddRow(transferID, "TRF2024010100000001", uuid.New(), uuid.New(), time.Now(), models.TransferStatusCompleted, nil, time.Now(), time.Now()))
+
+		req := httptest.NewRequest("DELETE", "/stock-transfers/"+transferID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Completed transfers cannot be deleted", response["error"])
+	})
+}
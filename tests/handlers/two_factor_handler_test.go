@@ -0,0 +1,248 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"pustaka-backend/handlers"
+	"pustaka-backend/helpers"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+var twoFactorUserColumns = []string{"id", "email", "password_hash", "full_name", "role", "two_factor_secret", "two_factor_enabled", "two_factor_last_step"}
+
+func TestLoginWithTwoFactor(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
+	app := fiber.New()
+	app.Post("/login", handlers.Login)
+
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
+		WithArgs("admin@example.com").
+		WillReturnRows(sqlmock.NewRows(twoFactorUserColumns).
+			AddRow(uuid.New(), "admin@example.com", string(hashedPassword), "Admin", "admin", testTOTPSecret, true, 0))
+	// No session yet, only a challenge for the second step
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "login_challenges"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+	mock.ExpectCommit()
+
+	body, _ := json.Marshal(LoginRequest{Email: "admin@example.com", Password: "password123"})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var response map[string]interface{}
+	respBody, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(respBody, &response)
+	assert.Equal(t, true, response["two_factor_required"])
+	assert.NotEmpty(t, response["challenge_token"])
+	assert.Nil(t, response["token"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyTwoFactor(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	defer os.Unsetenv("JWT_SECRET")
+
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	handlers.SetLoginAttemptStore(handlers.NewMemoryLoginAttemptStore())
+	defer handlers.SetLoginAttemptStore(handlers.PostgresLoginAttemptStore{})
+
+	app := fiber.New()
+	app.Post("/auth/2fa/verify", handlers.VerifyTwoFactor)
+
+	verify := func(body handlers.TwoFactorVerifyRequest) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/auth/2fa/verify", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	userID := uuid.New()
+	challengeID := uuid.New()
+	now := time.Now()
+	step := helpers.TOTPStep(now)
+	code, _ := helpers.TOTPCode(testTOTPSecret, step)
+
+	expectChallenge := func(lastStep int64) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "login_challenges" WHERE token_hash = $1`)).
+			WithArgs(helpers.HashToken("challenge")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at", "used_at", "failed_attempts"}).
+				AddRow(challengeID, userID, helpers.HashToken("challenge"), now.Add(time.Minute), nil, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows(twoFactorUserColumns).
+				AddRow(userID, "admin@example.com", "hash", "Admin", "admin", testTOTPSecret, true, lastStep))
+	}
+
+	t.Run("Valid code completes the login", func(t *testing.T) {
+		expectChallenge(0)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "two_factor_last_step"=$1 WHERE id = $2 AND two_factor_last_step < $3`)).
+			WithArgs(step, userID, step).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "login_challenges" SET "used_at"=$1 WHERE id = $2 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), challengeID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, response := verify(handlers.TwoFactorVerifyRequest{ChallengeToken: "challenge", Code: code})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NotEmpty(t, response["token"])
+		assert.NotEmpty(t, response["refresh_token"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Used code is refused", func(t *testing.T) {
+		expectChallenge(step)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "login_challenges" SET "failed_attempts"=failed_attempts + 1 WHERE id = $1`)).
+			WithArgs(challengeID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		status, response := verify(handlers.TwoFactorVerifyRequest{ChallengeToken: "challenge", Code: code})
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+		assert.Equal(t, "Invalid two-factor code", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Recovery code", func(t *testing.T) {
+		expectChallenge(step)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "user_recovery_codes" SET "used_at"=$1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), userID, helpers.HashToken("abcde-fghij")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "login_challenges" SET "used_at"=$1 WHERE id = $2 AND used_at IS NULL`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "refresh_tokens"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		status, _ := verify(handlers.TwoFactorVerifyRequest{ChallengeToken: "challenge", RecoveryCode: "ABCDE FGHIJ"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expired challenge", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "login_challenges" WHERE token_hash = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash", "expires_at", "used_at", "failed_attempts"}).
+				AddRow(challengeID, userID, helpers.HashToken("old"), now.Add(-time.Minute), nil, 0))
+
+		status, response := verify(handlers.TwoFactorVerifyRequest{ChallengeToken: "old", Code: code})
+
+		assert.Equal(t, fiber.StatusUnauthorized, status)
+		assert.Equal(t, "Invalid or expired login challenge", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestConfirmTwoFactor(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	userID := uuid.New()
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", userID.String())
+		return c.Next()
+	})
+	app.Post("/auth/2fa/confirm", handlers.ConfirmTwoFactor)
+
+	confirm := func(code string) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(handlers.TwoFactorCodeRequest{Code: code})
+		req := httptest.NewRequest("POST", "/auth/2fa/confirm", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(twoFactorUserColumns).
+			AddRow(userID, "admin@example.com", "hash", "Admin", "admin", testTOTPSecret, false, 0)
+	}
+
+	t.Run("Valid code enables 2FA and returns recovery codes", func(t *testing.T) {
+		step := helpers.TOTPStep(time.Now())
+		code, _ := helpers.TOTPCode(testTOTPSecret, step)
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(userRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "two_factor_enabled"=$1,"two_factor_last_step"=$2,"updated_at"=$3 WHERE id = $4`)).
+			WithArgs(true, step, sqlmock.AnyArg(), userID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_recovery_codes" WHERE user_id = $1`)).
+			WithArgs(userID).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "user_recovery_codes"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
+
+		status, response := confirm(code)
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Len(t, response["recovery_codes"], 10)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(userRows())
+
+		status, response := confirm("000000")
+
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		assert.Equal(t, "Invalid two-factor code", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
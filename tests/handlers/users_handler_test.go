@@ -50,11 +50,19 @@ func TestCreateUser(t *testing.T) {
 			"role":      "user",
 		}
 
+		// Mock: Check that the role exists
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
 		// Mock: Check if user exists (should return error/not found)
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
 			WithArgs("newuser@example.com").
 			WillReturnError(gorm.ErrRecordNotFound)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Create user
 		userID := uuid.New()
 		mock.ExpectBegin()
@@ -86,11 +94,19 @@ func TestCreateUser(t *testing.T) {
 			"role":      "admin",
 		}
 
+		// Mock: Check that the role exists
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
 		// Mock: Check if user exists
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
 			WithArgs("admin2@example.com").
 			WillReturnError(gorm.ErrRecordNotFound)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`})
+
 		// Mock: Create user
 		userID := uuid.New()
 		mock.ExpectBegin()
@@ -164,6 +180,9 @@ func TestCreateUser(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}).
 			AddRow(existingUser.ID, existingUser.Email, "hashedpass", "Existing User", "user", time.Now(), time.Now())
 
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("user").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1`)).
 			WithArgs("existing@example.com").
 			WillReturnRows(rows)
@@ -190,6 +209,68 @@ func TestCreateUser(t *testing.T) {
 			"role":      "superadmin",
 		}
 
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("superadmin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Role not found: superadmin", response["error"])
+	})
+
+	t.Run("Sales associate role without a linked associate", func(t *testing.T) {
+		reqBody := map[string]interface{}{
+			"email":     "field@example.com",
+			"password":  "Password123!",
+			"full_name": "Field Sales",
+			"role":      "sales_associate",
+		}
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("sales_associate").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "sales_associate_id is required for the sales_associate role", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown sales associate", func(t *testing.T) {
+		salesAssociateID := uuid.New()
+		reqBody := map[string]interface{}{
+			"email":              "field@example.com",
+			"password":           "Password123!",
+			"full_name":          "Field Sales",
+			"role":               "sales_associate",
+			"sales_associate_id": salesAssociateID.String(),
+		}
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("sales_associate").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "sales_associates" WHERE id = $1 AND "sales_associates"."deleted_at" IS NULL`)).
+			WithArgs(salesAssociateID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
 		body, _ := json.Marshal(reqBody)
 		req := httptest.NewRequest("POST", "/users", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
@@ -201,7 +282,8 @@ func TestCreateUser(t *testing.T) {
 		respBody, _ := io.ReadAll(resp.Body)
 		json.Unmarshal(respBody, &response)
 
-		assert.Equal(t, "Role must be either 'user' or 'admin' or 'operator'", response["error"])
+		assert.Equal(t, "Sales associate not found", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
@@ -421,6 +503,9 @@ func TestUpdateUser(t *testing.T) {
 			WithArgs(userID.String()).
 			WillReturnRows(rows)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Update user
 		mock.ExpectBegin()
 		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
@@ -458,6 +543,9 @@ func TestUpdateUser(t *testing.T) {
 			WithArgs(userID.String()).
 			WillReturnRows(rows)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Update user with new password
 		mock.ExpectBegin()
 		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
@@ -493,6 +581,14 @@ func TestUpdateUser(t *testing.T) {
 			WithArgs(userID.String()).
 			WillReturnRows(rows)
 
+		// Mock: Check that the role exists
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Update user
 		mock.ExpectBegin()
 		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
@@ -527,6 +623,9 @@ func TestUpdateUser(t *testing.T) {
 			WithArgs("new@example.com").
 			WillReturnError(gorm.ErrRecordNotFound)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Update user
 		mock.ExpectBegin()
 		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
@@ -642,6 +741,10 @@ func TestUpdateUser(t *testing.T) {
 			"role": "superadmin",
 		}
 
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("superadmin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
 		body, _ := json.Marshal(updateData)
 		req := httptest.NewRequest("PUT", "/users/"+userID.String(), bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
@@ -653,7 +756,7 @@ func TestUpdateUser(t *testing.T) {
 		respBody, _ := io.ReadAll(resp.Body)
 		json.Unmarshal(respBody, &response)
 
-		assert.Equal(t, "Role must be either 'user' or 'admin' or 'operator'", response["error"])
+		assert.Equal(t, "Role not found: superadmin", response["error"])
 	})
 
 	t.Run("Invalid request body", func(t *testing.T) {
@@ -700,6 +803,9 @@ func TestDeleteUser(t *testing.T) {
 			WithArgs(userID.String()).
 			WillReturnRows(rows)
 
+		// Mock: Permissions of the caller and the managed roles
+		expectRolePermissions(mock, map[string]string{"admin": `{"*"}`, "user": `{books:read}`})
+
 		// Mock: Delete user
 		mock.ExpectBegin()
 		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "users" WHERE "users"."id" = $1`)).
@@ -769,3 +875,98 @@ func TestPasswordHashing(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestUserManagementIsLimitedToCallerPermissions(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	// A user manager holds users:write but not "*"
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", uuid.New().String())
+		c.Locals("userRole", "user_manager")
+		return c.Next()
+	})
+	app.Put("/users/:id", handlers.UpdateUser)
+	app.Post("/users/:id/reset-2fa", handlers.ResetUserTwoFactor)
+
+	managerPermissions := `{users:read,users:write}`
+	userColumns := []string{"id", "email", "password_hash", "full_name", "role", "created_at", "updated_at"}
+
+	put := func(userID uuid.UUID, body map[string]interface{}) (int, map[string]interface{}) {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("PUT", "/users/"+userID.String(), bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+		return resp.StatusCode, response
+	}
+
+	t.Run("Cannot promote a user to admin", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(userID, "user@example.com", "hashedpass", "Test User", "user", time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "roles" WHERE name = $1`)).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		expectRolePermissions(mock, map[string]string{"user_manager": managerPermissions, "user": `{users:read}`, "admin": `{"*"}`})
+
+		status, response := put(userID, map[string]interface{}{"role": "admin"})
+
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.Equal(t, "Managing users of role admin requires *", response["error"])
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cannot reset the password of an admin", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(userID, "admin@example.com", "hashedpass", "Admin", "admin", time.Now(), time.Now()))
+		expectRolePermissions(mock, map[string]string{"user_manager": managerPermissions, "admin": `{"*"}`})
+
+		status, _ := put(userID, map[string]interface{}{"password": "NewPassword123!"})
+
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cannot reset the 2FA of an admin", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(userID, "admin@example.com", "hashedpass", "Admin", "admin", time.Now(), time.Now()))
+		expectRolePermissions(mock, map[string]string{"user_manager": managerPermissions, "admin": `{"*"}`})
+
+		resp, _ := app.Test(httptest.NewRequest("POST", "/users/"+userID.String()+"/reset-2fa", nil))
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Can update users of roles it covers", func(t *testing.T) {
+		userID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WithArgs(userID.String()).
+			WillReturnRows(sqlmock.NewRows(userColumns).
+				AddRow(userID, "clerk@example.com", "hashedpass", "Clerk", "clerk", time.Now(), time.Now()))
+		expectRolePermissions(mock, map[string]string{"user_manager": managerPermissions, "clerk": `{users:read}`})
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		status, _ := put(userID, map[string]interface{}{"full_name": "Clerk Two"})
+
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,191 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"pustaka-backend/handlers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var warehouseColumns = []string{"id", "code", "name", "description", "address", "city_id", "is_default", "created_at", "updated_at"}
+
+func TestGetWarehouse(t *testing.T) {
+	app := fiber.New()
+	app.Get("/warehouses/:id", handlers.GetWarehouse)
+
+	t.Run("Successfully get warehouse by ID", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		warehouseID := uuid.New()
+		rows := sqlmock.NewRows(warehouseColumns).
+			AddRow(warehouseID, "GDG-JKT", "Gudang Jakarta", nil, nil, nil, true, time.Now(), time.Now())
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WithArgs(warehouseID.String()).
+			WillReturnRows(rows)
+
+		req := httptest.NewRequest("GET", "/warehouses/"+warehouseID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.NotNil(t, response["warehouse"])
+	})
+
+	t.Run("Warehouse not found", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		warehouseID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WithArgs(warehouseID.String()).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest("GET", "/warehouses/"+warehouseID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Warehouse not found", response["error"])
+	})
+}
+
+func TestCreateWarehouse(t *testing.T) {
+	app := fiber.New()
+	app.Post("/warehouses", handlers.CreateWarehouse)
+
+	t.Run("Successfully create warehouse", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "warehouses"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+				AddRow(uuid.New(), time.Now(), time.Now()))
+		mock.ExpectCommit()
+
+		body, _ := json.Marshal(models.Warehouse{Code: "GDG-SBY", Name: "Gudang Surabaya"})
+		req := httptest.NewRequest("POST", "/warehouses", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Default warehouse moves the default flag", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "warehouses" SET "is_default"=$1,"updated_at"=$2 WHERE is_default = $3`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "warehouses"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+				AddRow(uuid.New(), time.Now(), time.Now()))
+		mock.ExpectCommit()
+
+		body, _ := json.Marshal(models.Warehouse{Code: "GDG-SBY", Name: "Gudang Surabaya", IsDefault: true})
+		req := httptest.NewRequest("POST", "/warehouses", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing code and name", func(t *testing.T) {
+		body, _ := json.Marshal(models.Warehouse{})
+		req := httptest.NewRequest("POST", "/warehouses", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "code and name are required", response["error"])
+	})
+}
+
+func TestDeleteWarehouse(t *testing.T) {
+	app := fiber.New()
+	app.Delete("/warehouses/:id", handlers.DeleteWarehouse)
+
+	t.Run("Cannot delete the default warehouse", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		warehouseID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WithArgs(warehouseID.String()).
+			WillReturnRows(sqlmock.NewRows(warehouseColumns).
+				AddRow(warehouseID, "GDG-JKT", "Gudang Jakarta", nil, nil, nil, true, time.Now(), time.Now()))
+
+		req := httptest.NewRequest("DELETE", "/warehouses/"+warehouseID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Cannot delete the default warehouse", response["error"])
+	})
+
+	t.Run("Cannot delete a warehouse holding stock", func(t *testing.T) {
+		db, mock, err := testutil.SetupMockDB()
+		assert.NoError(t, err)
+		defer testutil.CloseMockDB(db)
+
+		warehouseID := uuid.New()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "warehouses" WHERE id = $1`)).
+			WithArgs(warehouseID.String()).
+			WillReturnRows(sqlmock.NewRows(warehouseColumns).
+				AddRow(warehouseID, "GDG-SBY", "Gudang Surabaya", nil, nil, nil, false, time.Now(), time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(quantity), 0) FROM "book_stocks" WHERE warehouse_id = $1`)).
+			WithArgs(warehouseID).
+			WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(25))
+
+		req := httptest.NewRequest("DELETE", "/warehouses/"+warehouseID.String(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var response map[string]interface{}
+		respBody, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(respBody, &response)
+
+		assert.Equal(t, "Cannot delete a warehouse that still holds stock", response["error"])
+		assert.Equal(t, float64(25), response["total_stock"])
+	})
+}
@@ -0,0 +1,172 @@
+package helpers_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"pustaka-backend/config"
+	"pustaka-backend/helpers"
+	"pustaka-backend/models"
+	"pustaka-backend/tests/testutil"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditJSON matches an audit_logs before or after column holding the given column values
+type auditJSON map[string]interface{}
+
+func (expected auditJSON) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &actual); err != nil {
+		return false
+	}
+	for column, value := range expected {
+		if actual[column] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAuditCallbacks(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+	assert.NoError(t, helpers.RegisterAuditCallbacks(config.DB))
+
+	userID := uuid.New()
+	ctx := helpers.WithAuditActor(context.Background(), helpers.AuditActor{UserID: &userID, IPAddress: "10.0.0.1"})
+
+	t.Run("Create logs the new row", func(t *testing.T) {
+		cityID := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "cities"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(cityID))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WithArgs(userID, models.AuditActionCreate, "cities", cityID.String(),
+				nil, auditJSON{"code": "BDG", "name": "Bandung"}, "10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		err := config.DB.WithContext(ctx).Create(&models.City{Code: "BDG", Name: "Bandung"}).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Create from a column map logs the new row", func(t *testing.T) {
+		cityID := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "cities"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(cityID))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WithArgs(userID, models.AuditActionCreate, "cities", cityID.String(),
+				nil, auditJSON{"code": "SBY", "name": "Surabaya"}, "10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		values := map[string]interface{}{"id": cityID, "code": "SBY", "Name": "Surabaya"}
+		err := config.DB.WithContext(ctx).Model(&models.City{}).Create(values).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Delete logs the removed row", func(t *testing.T) {
+		cityID := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "cities" WHERE id = $1`)).
+			WithArgs(cityID.String()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "code", "name"}).AddRow(cityID.String(), "BDG", "Bandung"))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cities" SET "deleted_at"=$1 WHERE id = $2 AND "cities"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), cityID.String()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WithArgs(userID, models.AuditActionDelete, "cities", cityID.String(),
+				auditJSON{"code": "BDG", "name": "Bandung"}, nil, "10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		err := config.DB.WithContext(ctx).Delete(&models.City{}, "id = ?", cityID.String()).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Password hashes are redacted", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "users"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WithArgs(userID, models.AuditActionCreate, "users", sqlmock.AnyArg(),
+				nil, auditJSON{"email": "new@example.com", "password_hash": "[redacted]"}, "10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		err := config.DB.WithContext(ctx).Create(&models.User{ID: uuid.New(), Email: "new@example.com", PasswordHash: "secret-hash"}).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Create sets created_by_id and updated_by_id", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "payments" ("sales_transaction_id","no_payment","payment_date","amount","discount_percentage","discount_amount","note","created_by_id","updated_by_id","created_at","updated_at")`)).
+			WithArgs(sqlmock.AnyArg(), "PAY-1", sqlmock.AnyArg(), 50000.0, 0.0, 0.0, nil, userID, userID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		payment := models.Payment{SalesTransactionID: uuid.New(), NoPayment: "PAY-1", Amount: 50000}
+		err := config.DB.WithContext(ctx).Create(&payment).Error
+
+		assert.NoError(t, err)
+		assert.Equal(t, &userID, payment.CreatedByID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Update sets updated_by_id", func(t *testing.T) {
+		transactionID := uuid.New()
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE id = $1`)).
+			WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status", "updated_by_id"}).AddRow(transactionID.String(), 0, nil))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "sales_transactions" SET "status"=$1,"updated_by_id"=$2,"updated_at"=$3 WHERE id = $4`)).
+			WithArgs(models.SalesStatusCancelled, userID, sqlmock.AnyArg(), transactionID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "sales_transactions" WHERE "id" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "status", "updated_by_id"}).AddRow(transactionID.String(), 3, userID.String()))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).
+			WithArgs(userID, models.AuditActionUpdate, "sales_transactions", transactionID.String(),
+				auditJSON{"status": float64(0)}, auditJSON{"status": float64(3), "updated_by_id": userID.String()}, "10.0.0.1", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		err := config.DB.WithContext(ctx).Model(&models.SalesTransaction{}).Where("id = ?", transactionID).
+			Update("status", models.SalesStatusCancelled).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Login security tables are not audited", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "login_attempts"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		mock.ExpectCommit()
+
+		err := config.DB.WithContext(ctx).Create(&models.LoginAttempt{Email: "a@example.com"}).Error
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,103 @@
+package helpers_test
+
+import (
+	"bytes"
+	"image/png"
+	"pustaka-backend/helpers"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidISBN(t *testing.T) {
+	tests := []struct {
+		name     string
+		isbn     string
+		expected bool
+	}{
+		{name: "Valid ISBN-13 with hyphens", isbn: "978-0-306-40615-7", expected: true},
+		{name: "Valid ISBN-13 without hyphens", isbn: "9786020324784", expected: true},
+		{name: "Valid ISBN-10", isbn: "0-306-40615-2", expected: true},
+		{name: "Valid ISBN-10 with X check character", isbn: "0-8044-2957-x", expected: true},
+		{name: "ISBN-13 with wrong check digit", isbn: "9780306406158", expected: false},
+		{name: "ISBN-10 with wrong check digit", isbn: "0306406153", expected: false},
+		{name: "EAN-13 without book prefix", isbn: "4006381333931", expected: false},
+		{name: "Too short", isbn: "978030640615", expected: false},
+		{name: "Letters", isbn: "97803064061AB", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.IsValidISBN(tt.isbn))
+		})
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	assert.Equal(t, "9780306406157", helpers.ISBN10To13("0306406152"))
+	assert.Equal(t, "0306406152", helpers.ISBN13To10("9780306406157"))
+	assert.Equal(t, "080442957X", helpers.ISBN13To10(helpers.ISBN10To13("080442957X")))
+	assert.Equal(t, "", helpers.ISBN13To10("9791234567896"))
+}
+
+func TestEncodeBarcode(t *testing.T) {
+	t.Run("ISBN is encoded as EAN-13", func(t *testing.T) {
+		barcode, err := helpers.EncodeBarcode("0-306-40615-2")
+		assert.NoError(t, err)
+		assert.Equal(t, helpers.SymbologyEAN13, barcode.Symbology)
+		assert.Equal(t, "9780306406157", barcode.Text)
+		assert.Len(t, barcode.Modules, 95)
+
+		pattern := func(from, to int) string {
+			var b strings.Builder
+			for _, bar := range barcode.Modules[from:to] {
+				if bar {
+					b.WriteByte('1')
+				} else {
+					b.WriteByte('0')
+				}
+			}
+			return b.String()
+		}
+		assert.Equal(t, "101", pattern(0, 3))
+		assert.Equal(t, "01010", pattern(45, 50))
+		assert.Equal(t, "101", pattern(92, 95))
+		// Second digit 7 uses the L code because the first digit 9 has parity LGGLGL
+		assert.Equal(t, "0111011", pattern(3, 10))
+		// Last digit 7 uses the R code
+		assert.Equal(t, "1000100", pattern(85, 92))
+	})
+
+	t.Run("SKU is encoded as Code 128", func(t *testing.T) {
+		barcode, err := helpers.EncodeBarcode("MTK-01")
+		assert.NoError(t, err)
+		assert.Equal(t, helpers.SymbologyCode128, barcode.Symbology)
+		// Start, 6 characters and checksum take 11 modules each, the stop pattern 13
+		assert.Len(t, barcode.Modules, 11*8+13)
+		assert.True(t, barcode.Modules[0])
+		assert.True(t, barcode.Modules[len(barcode.Modules)-1])
+	})
+
+	t.Run("Non printable characters are rejected", func(t *testing.T) {
+		_, err := helpers.EncodeBarcode("MTK\n01")
+		assert.Error(t, err)
+	})
+}
+
+func TestRenderBarcode(t *testing.T) {
+	barcode, err := helpers.EncodeBarcode("9780306406157")
+	assert.NoError(t, err)
+	label := helpers.BarcodeLabel{Barcode: barcode, Title: "Matematika <1>", Caption: "Rp 50.000"}
+
+	svg := string(helpers.RenderBarcodeSVG(label, 2, 60))
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, "Matematika &lt;1&gt;")
+	assert.Contains(t, svg, "9780306406157")
+
+	pngBytes, err := helpers.RenderBarcodePNG(label, 2, 60)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(pngBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, (95+20)*2, img.Bounds().Dx())
+}
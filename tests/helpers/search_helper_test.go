@@ -0,0 +1,32 @@
+package helpers_test
+
+import (
+	"pustaka-backend/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		prefix   bool
+		expected string
+	}{
+		{name: "Words are lowercased and joined", input: "Matematika Kelas 1", expected: "matematika & kelas & 1"},
+		{name: "Stopwords and punctuation are dropped", input: "Buku untuk SD, dan SMP!", expected: "buku & sd & smp"},
+		{name: "Prefix matching for typeahead", input: "mat kel", prefix: true, expected: "mat:* & kel:*"},
+		{name: "ISBN is kept as one term", input: "978-0-8044-2957-X", expected: "978080442957x"},
+		{name: "Book code is split like the index", input: "MTK-01", expected: "mtk & 01"},
+		{name: "Query operators cannot be injected", input: "a' | !b:*", expected: "a & b"},
+		{name: "Only stopwords", input: "yang dan", expected: ""},
+		{name: "Empty", input: "   ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.BuildTSQuery(tt.input, tt.prefix))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package helpers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"pustaka-backend/helpers"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSpreadsheetCSV(t *testing.T) {
+	t.Run("Comma separated with BOM", func(t *testing.T) {
+		rows, err := helpers.ReadSpreadsheet("books.csv", []byte("\xef\xbb\xbfcode,name\nMTK-01,\"Matematika, Jilid 1\"\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"code", "name"}, {"MTK-01", "Matematika, Jilid 1"}}, rows)
+	})
+
+	t.Run("Semicolon separated", func(t *testing.T) {
+		rows, err := helpers.ReadSpreadsheet("BOOKS.CSV", []byte("code;price\nMTK-01;50000\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"code", "price"}, {"MTK-01", "50000"}}, rows)
+	})
+
+	t.Run("Unsupported extension", func(t *testing.T) {
+		_, err := helpers.ReadSpreadsheet("books.xls", []byte("code"))
+		assert.Error(t, err)
+	})
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := helpers.NewXLSXWriter(&buf, "Books & Co", []string{"code", "name", "price", "active"})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.WriteRow([]interface{}{"MTK-01", "Matematika <1>", 50000.5, true}))
+	assert.NoError(t, writer.WriteRow([]interface{}{"IPA-01", nil, 42, false}))
+	assert.NoError(t, writer.Close())
+
+	rows, err := helpers.ReadSpreadsheet("export.xlsx", buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"code", "name", "price", "active"},
+		{"MTK-01", "Matematika <1>", "50000.5", "1"},
+		{"IPA-01", "", "42", "0"},
+	}, rows)
+}
+
+func TestReadSpreadsheetInvalidXLSX(t *testing.T) {
+	_, err := helpers.ReadSpreadsheet("books.xlsx", []byte("not a zip"))
+	assert.Error(t, err)
+}
+
+func TestReadSpreadsheetSharedStrings(t *testing.T) {
+	// Excel stores text in a shared string table and long numbers in exponent form
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Data" sheetId="1" r:id="rId7"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId7" Target="worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>isbn</t></si><si><t>name</t></si><si><r><t>Bahasa </t></r><r><t>Indonesia</t></r></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+			`<row r="2"><c r="A2"><v>9.780306406157E+12</v></c><c r="C2" t="s"><v>2</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, archive.Close())
+
+	rows, err := helpers.ReadSpreadsheet("books.xlsx", buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"isbn", "", "name"},
+		{"9780306406157", "", "Bahasa Indonesia"},
+	}, rows)
+}
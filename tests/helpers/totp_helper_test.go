@@ -0,0 +1,88 @@
+package helpers_test
+
+import (
+	"net/url"
+	"pustaka-backend/helpers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Base32 of the RFC 6238 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B test vectors (SHA1), cut to 6 digits
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1111111111, expected: "050471"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := helpers.TOTPCode(rfcSecret, helpers.TOTPStep(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, code, "time %d", tt.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := helpers.TOTPStep(now)
+
+	t.Run("Current code", func(t *testing.T) {
+		matched, ok := helpers.ValidateTOTP(rfcSecret, "081804", now)
+		assert.True(t, ok)
+		assert.Equal(t, step, matched)
+	})
+
+	t.Run("Previous code within clock drift", func(t *testing.T) {
+		previous, _ := helpers.TOTPCode(rfcSecret, step-1)
+		matched, ok := helpers.ValidateTOTP(rfcSecret, previous, now)
+		assert.True(t, ok)
+		assert.Equal(t, step-1, matched)
+	})
+
+	t.Run("Old code", func(t *testing.T) {
+		old, _ := helpers.TOTPCode(rfcSecret, step-2)
+		_, ok := helpers.ValidateTOTP(rfcSecret, old, now)
+		assert.False(t, ok)
+	})
+
+	t.Run("Wrong length", func(t *testing.T) {
+		_, ok := helpers.ValidateTOTP(rfcSecret, "81804", now)
+		assert.False(t, ok)
+	})
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := helpers.TOTPProvisioningURI("Pustaka Digital", "admin@example.com", rfcSecret)
+
+	parsed, err := url.Parse(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/Pustaka Digital:admin@example.com", parsed.Path)
+	assert.Equal(t, rfcSecret, parsed.Query().Get("secret"))
+	assert.Equal(t, "Pustaka Digital", parsed.Query().Get("issuer"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := helpers.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.Equal(t, code, helpers.NormalizeRecoveryCode(" "+code[:5]+" "+code[6:]+" "))
+		seen[code] = true
+	}
+	assert.Len(t, seen, 10)
+}
@@ -2,15 +2,21 @@ package middleware_test
 
 import (
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"pustaka-backend/middleware"
+	"pustaka-backend/tests/testutil"
+	"regexp"
 	"testing"
 	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 // Claims struct for testing JWT tokens
@@ -18,6 +24,8 @@ type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// TokenVersion must match users.token_version
+	TokenVersion int `json:"ver"`
 	jwt.RegisteredClaims
 }
 
@@ -26,6 +34,10 @@ func TestAuthRequired(t *testing.T) {
 	os.Setenv("JWT_SECRET", "test-secret-key")
 	defer os.Unsetenv("JWT_SECRET")
 
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
 	// Create a test Fiber app
 	app := fiber.New()
 
@@ -104,7 +116,7 @@ func TestAuthRequired(t *testing.T) {
 	t.Run("Valid token", func(t *testing.T) {
 		// Create a valid token
 		claims := Claims{
-			UserID: "test-user-id-123",
+			UserID: "550e8400-e29b-41d4-a716-446655440123",
 			Email:  "valid@example.com",
 			Role:   "admin",
 			RegisteredClaims: jwt.RegisteredClaims{
@@ -116,18 +128,55 @@ func TestAuthRequired(t *testing.T) {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 		tokenString, _ := token.SignedString([]byte("test-secret-key"))
 
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","role","token_version","two_factor_enabled","sales_associate_id" FROM "users" WHERE id = $1`)).
+			WithArgs("550e8400-e29b-41d4-a716-446655440123").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "role", "token_version"}).AddRow(uuid.New(), "admin", 0))
+
 		req := httptest.NewRequest("GET", "/protected", nil)
 		req.Header.Set("Authorization", "Bearer "+tokenString)
 		resp, _ := app.Test(req)
 
 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
 
 		body, _ := io.ReadAll(resp.Body)
-		assert.Contains(t, string(body), "test-user-id-123")
+		assert.Contains(t, string(body), "550e8400-e29b-41d4-a716-446655440123")
 		assert.Contains(t, string(body), "valid@example.com")
 		assert.Contains(t, string(body), "admin")
 	})
 
+	t.Run("Revoked sessions", func(t *testing.T) {
+		claims := Claims{
+			UserID:       "550e8400-e29b-41d4-a716-446655440123",
+			Email:        "valid@example.com",
+			Role:         "admin",
+			TokenVersion: 1,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		}
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret-key"))
+
+		// The user logged out everywhere after the token was issued
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","role","token_version","two_factor_enabled","sales_associate_id" FROM "users" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "role", "token_version"}).AddRow(uuid.New(), "admin", 2))
+		// The user was deleted
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","role","token_version","two_factor_enabled","sales_associate_id" FROM "users" WHERE id = $1`)).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest("GET", "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenString)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Contains(t, string(body), "Session has been revoked")
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
 	t.Run("Token with wrong secret", func(t *testing.T) {
 		// Create a token with different secret
 		claims := Claims{
@@ -223,3 +272,147 @@ func TestAdminOnly(t *testing.T) {
 		assert.Contains(t, string(body), "Admin access required")
 	})
 }
+
+func TestTwoFactorEnrolled(t *testing.T) {
+	request := func(role string, twoFactorEnabled bool) *http.Response {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("userRole", role)
+			c.Locals("twoFactorEnabled", twoFactorEnabled)
+			return c.Next()
+		})
+		app.Use(middleware.TwoFactorEnrolled())
+		app.Get("/books", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		resp, _ := app.Test(httptest.NewRequest("GET", "/books", nil))
+		return resp
+	}
+
+	t.Run("Admin without 2FA denied", func(t *testing.T) {
+		resp := request("admin", false)
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "two_factor_enrollment_required")
+	})
+
+	t.Run("Admin with 2FA allowed", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, request("admin", true).StatusCode)
+	})
+
+	t.Run("Other roles do not need 2FA", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, request("sales", false).StatusCode)
+	})
+}
+
+func TestRequirePermission(t *testing.T) {
+	db, mock, err := testutil.SetupMockDB()
+	assert.NoError(t, err)
+	defer testutil.CloseMockDB(db)
+
+	newApp := func(role string) *fiber.App {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if role != "" {
+				c.Locals("userRole", role)
+			}
+			return c.Next()
+		})
+		app.Delete("/payments", middleware.RequirePermission("payments:delete"), func(c *fiber.Ctx) error {
+			return c.JSON(fiber.Map{"message": "Payment deleted"})
+		})
+		return app
+	}
+
+	expectRole := func(role, permissions string) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "permissions" FROM "roles" WHERE name = $1`)).
+			WithArgs(role).
+			WillReturnRows(sqlmock.NewRows([]string{"permissions"}).AddRow(permissions))
+	}
+
+	t.Run("Role with the permission", func(t *testing.T) {
+		expectRole("finance", `{payments:read,payments:delete}`)
+
+		resp, _ := newApp("finance").Test(httptest.NewRequest("DELETE", "/payments", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Role without the permission", func(t *testing.T) {
+		expectRole("viewer", `{payments:read}`)
+
+		resp, _ := newApp("viewer").Test(httptest.NewRequest("DELETE", "/payments", nil))
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "Permission required: payments:delete")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin wildcard", func(t *testing.T) {
+		expectRole("admin", `{"*"}`)
+
+		resp, _ := newApp("admin").Test(httptest.NewRequest("DELETE", "/payments", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown role", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "permissions" FROM "roles" WHERE name = $1`)).
+			WithArgs("operator").
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		resp, _ := newApp("operator").Test(httptest.NewRequest("DELETE", "/payments", nil))
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No role", func(t *testing.T) {
+		resp, _ := newApp("").Test(httptest.NewRequest("DELETE", "/payments", nil))
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown permission panics at setup", func(t *testing.T) {
+		assert.Panics(t, func() {
+			middleware.RequirePermission("payment:delete")
+		})
+	})
+}
+
+func TestSalesAssociateRequired(t *testing.T) {
+	request := func(salesAssociateID string) *http.Response {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if salesAssociateID != "" {
+				c.Locals("salesAssociateID", salesAssociateID)
+			}
+			return c.Next()
+		})
+		app.Use(middleware.SalesAssociateRequired())
+		app.Get("/portal/statement", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+
+		resp, _ := app.Test(httptest.NewRequest("GET", "/portal/statement", nil))
+		return resp
+	}
+
+	t.Run("Linked user allowed", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, request(uuid.New().String()).StatusCode)
+	})
+
+	t.Run("Unlinked user denied", func(t *testing.T) {
+		resp := request("")
+
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "User is not linked to a sales associate")
+	})
+}
@@ -0,0 +1,132 @@
+package migrations_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const migrationsDir = "../../migrations"
+
+// Code and name uniqueness of master data among rows that are not deleted
+var masterDataUniqueIndexes = []string{
+	"idx_cities_code", "idx_cities_name",
+	"idx_expeditions_code", "idx_expeditions_name",
+	"idx_merk_buku_code", "idx_merk_buku_name",
+	"idx_jenis_buku_code", "idx_jenis_buku_name",
+	"idx_jenjang_studi_code", "idx_jenjang_studi_name",
+	"idx_bidang_studi_code", "idx_bidang_studi_name",
+	"idx_kelas_code", "idx_kelas_name",
+	"idx_publishers_code", "idx_publishers_name",
+	"idx_books_code",
+	"idx_sales_associates_code",
+	"idx_billers_code", "idx_billers_name", "idx_billers_npwp",
+	"idx_curriculum_code", "idx_curriculum_name",
+	"idx_warehouses_code", "idx_warehouses_name",
+}
+
+var (
+	createIndexPattern = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+(IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+	dropIndexPattern   = regexp.MustCompile(`(?i)^DROP\s+INDEX\s+(IF\s+EXISTS\s+)?(\w+)`)
+)
+
+// migrationFiles returns the migration files in the order the runner applies them
+func migrationFiles(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	require.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+// statements returns the statements of a migration that are not commented out
+func statements(t *testing.T, file string) []string {
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			lines = append(lines, trimmed)
+		}
+	}
+	var result []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
+
+// A CREATE UNIQUE INDEX IF NOT EXISTS does nothing when an index of that name is left over
+// from an earlier migration, and the table silently stays without the unique index
+func TestUniqueIndexesAreNotShadowed(t *testing.T) {
+	existing := map[string]string{}
+	for _, file := range migrationFiles(t) {
+		for _, statement := range statements(t, file) {
+			if m := dropIndexPattern.FindStringSubmatch(statement); m != nil {
+				delete(existing, strings.ToLower(m[2]))
+				continue
+			}
+			m := createIndexPattern.FindStringSubmatch(statement)
+			if m == nil {
+				continue
+			}
+			name := strings.ToLower(m[3])
+			if from, ok := existing[name]; ok && m[1] != "" && m[2] != "" {
+				t.Errorf("%s: unique index %s is skipped, %s already created an index of that name", filepath.Base(file), name, from)
+			}
+			if _, ok := existing[name]; !ok {
+				existing[name] = filepath.Base(file)
+			}
+		}
+	}
+}
+
+// TestMasterDataIndexesAreUnique applies every migration to an empty schema and checks the
+// resulting indexes. It needs a PostgreSQL database in TEST_DATABASE_URL.
+func TestMasterDataIndexesAreUnique(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	// The search path is per connection
+	sqlDB.SetMaxOpenConns(1)
+
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	require.NoError(t, db.Exec("CREATE SCHEMA "+schema).Error)
+	defer db.Exec("DROP SCHEMA " + schema + " CASCADE")
+	require.NoError(t, db.Exec("SET search_path TO "+schema+", public").Error)
+
+	for _, file := range migrationFiles(t) {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NoError(t, db.Exec(string(content)).Error, filepath.Base(file))
+	}
+
+	for _, name := range masterDataUniqueIndexes {
+		var unique []bool
+		require.NoError(t, db.Raw(`SELECT i.indisunique FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relname = ? AND n.nspname = ?`, name, schema).Scan(&unique).Error)
+		if assert.Len(t, unique, 1, name) {
+			assert.True(t, unique[0], "%s is not unique", name)
+		}
+	}
+}